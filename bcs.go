@@ -0,0 +1,136 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// EncodingBCS is the Binary Canonical Serialization of Aptos and Sui
+// (https://github.com/diem/bcs): like borsh, but with ULEB128 lengths
+// and enum tags, and maps sorted by the encoding of their keys.
+// Strict decoders (see UnmarshalBCS) reject the non-canonical inputs.
+var EncodingBCS = RegisterEncoding("BCS", bcsCodec{})
+
+func NewBCSDecoder(data []byte) *Decoder {
+	return NewDecoderWithEncoding(data, EncodingBCS)
+}
+
+func NewBCSEncoder(writer io.Writer) *Encoder {
+	return NewEncoderWithEncoding(writer, EncodingBCS)
+}
+
+func NewBCSBufferedEncoder() *Encoder {
+	return NewBufferedEncoderWithEncoding(EncodingBCS)
+}
+
+func MarshalBCS(v interface{}) ([]byte, error) {
+	return AppendBCS(nil, v)
+}
+
+// AppendBCS appends the BCS encoding of v to dst and returns the extended buffer.
+// When dst has enough capacity, encoding does not allocate.
+func AppendBCS(dst []byte, v interface{}) ([]byte, error) {
+	return appendWithEncoding(dst, v, EncodingBCS)
+}
+
+// UnmarshalBCS decodes v from b, which must be canonical BCS, as bcs::from_bytes
+// does: option and bool bytes other than 0/1, unsorted or duplicate map keys,
+// and bytes left after the decoded value are rejected (see Decoder.SetStrict).
+// ULEB128 values not in their shortest form are rejected by all the BCS decoders.
+func UnmarshalBCS(v interface{}, b []byte) error {
+	decoder := NewBCSDecoder(b).SetStrict(true)
+	return decoder.Decode(v)
+}
+
+// bcsCodec is the wire format of EncodingBCS.
+type bcsCodec struct {
+	fieldsCodec
+	byteTagsCodec
+}
+
+func (bcsCodec) ReadLength(dec *Decoder) (int, error) {
+	length, err := readULEB128(dec)
+	if err != nil {
+		return 0, err
+	}
+	if length > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %d", ErrLengthTooLarge, length)
+	}
+	return int(length), nil
+}
+
+func (bcsCodec) WriteLength(enc *Encoder, length int) error {
+	if length < 0 || length > math.MaxInt32 {
+		return fmt.Errorf("%w: %d", ErrLengthTooLarge, length)
+	}
+	return writeULEB128(enc, uint32(length))
+}
+
+func (bcsCodec) ReadEnumTag(dec *Decoder) (int, error) {
+	variant, err := readULEB128(dec)
+	if err != nil {
+		return 0, err
+	}
+	if variant > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidEnumVariant, variant)
+	}
+	return int(variant), nil
+}
+
+func (bcsCodec) WriteEnumTag(enc *Encoder, variant int) error {
+	return writeULEB128(enc, uint32(variant))
+}
+
+func (bcsCodec) MapOrder() MapOrder { return MapOrderEncodedKeys }
+
+// readULEB128 reads a u32 encoded in ULEB128 (7 bits per byte, least
+// significant first, the high bit set on all bytes but the last),
+// which must be in its shortest form.
+func readULEB128(dec *Decoder) (uint32, error) {
+	var v uint64
+	for shift := uint(0); shift < 32; shift += 7 {
+		b, err := dec.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << shift
+		if v > math.MaxUint32 {
+			return 0, fmt.Errorf("%w: ULEB128 larger than a u32", ErrOverflow)
+		}
+		if b&0x80 == 0 {
+			if b == 0 && shift > 0 {
+				return 0, fmt.Errorf("%w: ULEB128 not in its shortest form", ErrInvalidVarint)
+			}
+			return uint32(v), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: ULEB128 larger than a u32", ErrOverflow)
+}
+
+// writeULEB128 writes v in ULEB128, in its shortest form.
+func writeULEB128(enc *Encoder, v uint32) error {
+	var buf [5]byte
+	n := 0
+	for v >= 0x80 {
+		buf[n] = byte(v) | 0x80
+		v >>= 7
+		n++
+	}
+	buf[n] = byte(v)
+	return enc.WriteBytes(buf[:n+1], false)
+}
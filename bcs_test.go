@@ -0,0 +1,189 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The types of the examples of the BCS specification (https://github.com/diem/bcs).
+type bcsService struct {
+	IP            [4]byte
+	Port          []uint16
+	ConnectionMax *uint32 `bin:"optional"`
+	Enabled       bool
+}
+
+type bcsEnum struct {
+	Enum     BorshEnum `borsh_enum:"true"`
+	Variant0 uint16
+	Variant1 uint8
+	Variant2 string
+}
+
+type bcsMyStruct struct {
+	Boolean bool
+	Bytes   []byte
+	Label   string
+}
+
+type bcsWrapper struct {
+	Inner bcsMyStruct
+	Name  string
+}
+
+type bcsTuple struct {
+	A int8
+	B string
+}
+
+func TestBCS_vectors(t *testing.T) {
+	connectionMax := uint32(5000)
+	some := uint8(8)
+	for _, test := range []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{"u16", uint16(4660), []byte{0x34, 0x12}},
+		{"u32", uint32(305419896), []byte{0x78, 0x56, 0x34, 0x12}},
+		{"i64", int64(-1311768467750121216), []byte{0x00, 0x11, 0x32, 0x54, 0x87, 0xA9, 0xCB, 0xED}},
+		{"option some", &struct {
+			V *uint8 `bin:"optional"`
+		}{&some}, []byte{1, 8}},
+		{"option none", &struct {
+			V *uint8 `bin:"optional"`
+		}{}, []byte{0}},
+		{"vec", []uint8{1, 2, 3}, []byte{3, 1, 2, 3}},
+		{"string", "çå∞≠¢õß∂ƒ∫", []byte{
+			24, 0xc3, 0xa7, 0xc3, 0xa5, 0xe2, 0x88, 0x9e, 0xe2, 0x89, 0xa0, 0xc2,
+			0xa2, 0xc3, 0xb5, 0xc3, 0x9f, 0xe2, 0x88, 0x82, 0xc6, 0x92, 0xe2, 0x88, 0xab,
+		}},
+		{"tuple", bcsTuple{A: -1, B: "diem"}, []byte{0xFF, 4, 'd', 'i', 'e', 'm'}},
+		{"struct", bcsMyStruct{Boolean: true, Bytes: []byte{0xC0, 0xDE}, Label: "a"}, []byte{1, 2, 0xC0, 0xDE, 1, 'a'}},
+		{"nested struct", bcsWrapper{
+			Inner: bcsMyStruct{Boolean: true, Bytes: []byte{0xC0, 0xDE}, Label: "a"},
+			Name:  "b",
+		}, []byte{1, 2, 0xC0, 0xDE, 1, 'a', 1, 'b'}},
+		{"enum 0", bcsEnum{Enum: 0, Variant0: 8000}, []byte{0, 0x40, 0x1F}},
+		{"enum 1", bcsEnum{Enum: 1, Variant1: 255}, []byte{1, 0xFF}},
+		{"enum 2", bcsEnum{Enum: 2, Variant2: "e"}, []byte{2, 1, 'e'}},
+		{"map", map[uint8]uint8{'e': 'f', 'c': 'd', 'a': 'b'}, []byte{3, 'a', 'b', 'c', 'd', 'e', 'f'}},
+		{"service", bcsService{
+			IP:            [4]byte{192, 168, 1, 1},
+			Port:          []uint16{8001, 8002, 8003},
+			ConnectionMax: &connectionMax,
+			Enabled:       false,
+		}, []byte{0xc0, 0xa8, 0x01, 0x01, 0x03, 0x41, 0x1f, 0x42, 0x1f, 0x43, 0x1f, 0x01, 0x88, 0x13, 0x00, 0x00, 0x00}},
+	} {
+		data, err := MarshalBCS(test.v)
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.want, data, test.name)
+	}
+
+	var service bcsService
+	require.NoError(t, UnmarshalBCS(&service, []byte{0xc0, 0xa8, 0x01, 0x01, 0x03, 0x41, 0x1f, 0x42, 0x1f, 0x43, 0x1f, 0x01, 0x88, 0x13, 0x00, 0x00, 0x00}))
+	require.Equal(t, []uint16{8001, 8002, 8003}, service.Port)
+	require.Equal(t, uint32(5000), *service.ConnectionMax)
+
+	var e bcsEnum
+	require.NoError(t, UnmarshalBCS(&e, []byte{2, 1, 'e'}))
+	require.Equal(t, bcsEnum{Enum: 2, Variant2: "e"}, e)
+}
+
+func TestBCS_uleb128(t *testing.T) {
+	for _, test := range []struct {
+		v    uint32
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{128, []byte{0x80, 0x01}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+		{268435456, []byte{0x80, 0x80, 0x80, 0x80, 0x01}},
+		{9487, []byte{0x8f, 0x4a}},
+		{0xFFFFFFFF, []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+	} {
+		enc := NewBCSBufferedEncoder()
+		require.NoError(t, writeULEB128(enc, test.v))
+		require.Equal(t, test.want, enc.Bytes(), test.v)
+		got, err := readULEB128(NewBCSDecoder(test.want))
+		require.NoError(t, err)
+		require.Equal(t, test.v, got)
+	}
+
+	for _, data := range [][]byte{
+		{0x80, 0x00},
+		{0x81, 0x80, 0x00},
+	} {
+		_, err := readULEB128(NewBCSDecoder(data))
+		require.True(t, errors.Is(err, ErrInvalidVarint), "%x: %v", data, err)
+	}
+	for _, data := range [][]byte{
+		{0xff, 0xff, 0xff, 0xff, 0x1f},
+		{0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+	} {
+		_, err := readULEB128(NewBCSDecoder(data))
+		require.True(t, errors.Is(err, ErrOverflow), "%x: %v", data, err)
+	}
+	_, err := readULEB128(NewBCSDecoder([]byte{0x80}))
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+}
+
+func TestBCS_canonical(t *testing.T) {
+	var s bcsMyStruct
+	// A length not in its shortest form:
+	err := NewBCSDecoder([]byte{1, 0x82, 0x00, 0xC0, 0xDE, 1, 'a'}).Decode(&s)
+	require.True(t, errors.Is(err, ErrInvalidVarint), err)
+	// Trailing bytes, and bools other than 0/1:
+	err = UnmarshalBCS(&s, []byte{1, 2, 0xC0, 0xDE, 1, 'a', 0})
+	require.True(t, errors.Is(err, ErrTrailingBytes), err)
+	err = UnmarshalBCS(&s, []byte{2, 2, 0xC0, 0xDE, 1, 'a'})
+	require.True(t, errors.Is(err, ErrInvalidBoolByte), err)
+
+	// Maps are sorted by the encoding of their keys, so 256 (0x00 0x01) comes before 1 (0x01 0x00):
+	m := map[uint16]bool{1: true, 256: false}
+	data, err := MarshalBCS(m)
+	require.NoError(t, err)
+	require.Equal(t, []byte{2, 0x00, 0x01, 0, 0x01, 0x00, 1}, data)
+	var got map[uint16]bool
+	require.NoError(t, UnmarshalBCS(&got, data))
+	require.Equal(t, m, got)
+	err = UnmarshalBCS(&got, []byte{2, 0x01, 0x00, 1, 0x00, 0x01, 0})
+	require.True(t, errors.Is(err, ErrUnsortedKeys), err)
+	err = UnmarshalBCS(&got, []byte{2, 0x01, 0x00, 1, 0x01, 0x00, 0})
+	require.True(t, errors.Is(err, ErrDuplicateKey), err)
+	// Not strict:
+	require.NoError(t, NewBCSDecoder([]byte{2, 0x01, 0x00, 1, 0x00, 0x01, 0}).Decode(&got))
+
+	// Strings are sorted by length first:
+	set := struct {
+		S map[string]struct{} `bin:"set"`
+	}{map[string]struct{}{"b": {}, "aa": {}}}
+	data, err = MarshalBCS(&set)
+	require.NoError(t, err)
+	require.Equal(t, []byte{2, 1, 'b', 2, 'a', 'a'}, data)
+
+	// Enum tags are ULEB128 too:
+	var e bcsEnum
+	err = UnmarshalBCS(&e, []byte{0x82, 0x00, 1, 'e'})
+	require.True(t, errors.Is(err, ErrInvalidVarint), err)
+	err = UnmarshalBCS(&e, []byte{3})
+	require.True(t, errors.Is(err, ErrInvalidEnumVariant), err)
+}
@@ -0,0 +1,269 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+var (
+	// EncodingBincode is the encoding of Rust's bincode (1.x) with its default
+	// options, used by the System, Stake and Vote programs of Solana:
+	// little-endian integers, u64 lengths, u32 enum tags and 1-byte option tags.
+	EncodingBincode = RegisterEncoding("Bincode", bincodeCodec{})
+	// EncodingBincodeVarint is bincode with the varint integer encoding
+	// (`with_varint_encoding()`): the integers of 16 bits or more, the lengths
+	// and the enum tags are varints; signed integers are zigzag-encoded.
+	EncodingBincodeVarint = RegisterEncoding("BincodeVarint", bincodeVarintCodec{})
+)
+
+func NewBincodeDecoder(data []byte) *Decoder {
+	return NewDecoderWithEncoding(data, EncodingBincode)
+}
+
+func NewBincodeEncoder(writer io.Writer) *Encoder {
+	return NewEncoderWithEncoding(writer, EncodingBincode)
+}
+
+func NewBincodeBufferedEncoder() *Encoder {
+	return NewBufferedEncoderWithEncoding(EncodingBincode)
+}
+
+func MarshalBincode(v interface{}) ([]byte, error) {
+	return AppendBincode(nil, v)
+}
+
+// AppendBincode appends the bincode encoding of v to dst and returns the extended buffer.
+// When dst has enough capacity, encoding does not allocate.
+func AppendBincode(dst []byte, v interface{}) ([]byte, error) {
+	return appendWithEncoding(dst, v, EncodingBincode)
+}
+
+func UnmarshalBincode(v interface{}, b []byte) error {
+	decoder := NewBincodeDecoder(b)
+	return decoder.Decode(v)
+}
+
+// BincodeByteCount computes the byte count size for the received populated structure. The reported size
+// is the one for the populated structure received in arguments. Depending on how serialization of
+// your fields is performed, size could vary for different structure.
+func BincodeByteCount(v interface{}) (uint64, error) {
+	counter := byteCounter{}
+	err := NewBincodeEncoder(&counter).Encode(v)
+	if err != nil {
+		return 0, fmt.Errorf("encode %T: %w", v, err)
+	}
+	return counter.count, nil
+}
+
+// MustBincodeByteCount acts just like BincodeByteCount but panics if it encounters any encoding errors.
+func MustBincodeByteCount(v interface{}) uint64 {
+	count, err := BincodeByteCount(v)
+	if err != nil {
+		panic(err)
+	}
+	return count
+}
+
+// bincodeCodec is the wire format of EncodingBincode.
+type bincodeCodec struct {
+	fieldsCodec
+	byteTagsCodec
+}
+
+func (bincodeCodec) ReadLength(dec *Decoder) (int, error) {
+	val, err := dec.ReadUint64(LE)
+	if err != nil {
+		return 0, err
+	}
+	if val > 0x7FFF_FFFF {
+		return 0, fmt.Errorf("%w: %d", ErrLengthTooLarge, val)
+	}
+	return int(val), nil
+}
+
+func (bincodeCodec) WriteLength(enc *Encoder, length int) error {
+	return enc.WriteUint64(uint64(length), LE)
+}
+
+func (bincodeCodec) ReadEnumTag(dec *Decoder) (int, error) {
+	variant, err := dec.ReadUint32(LE)
+	if err != nil {
+		return 0, err
+	}
+	if variant > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidEnumVariant, variant)
+	}
+	return int(variant), nil
+}
+
+func (bincodeCodec) WriteEnumTag(enc *Encoder, variant int) error {
+	return enc.WriteUint32(uint32(variant), LE)
+}
+
+// MapOrder returns MapOrderNone: bincode writes the entries of maps in their
+// iteration order (sorted for a BTreeMap, in any order for a HashMap).
+func (bincodeCodec) MapOrder() MapOrder { return MapOrderNone }
+
+// bincodeVarintCodec is the wire format of EncodingBincodeVarint.
+type bincodeVarintCodec struct {
+	bincodeCodec
+}
+
+func (bincodeVarintCodec) ReadLength(dec *Decoder) (int, error) {
+	val, err := readBincodeVarint(dec, 64)
+	if err != nil {
+		return 0, err
+	}
+	if val.Lo > 0x7FFF_FFFF {
+		return 0, fmt.Errorf("%w: %d", ErrLengthTooLarge, val.Lo)
+	}
+	return int(val.Lo), nil
+}
+
+func (bincodeVarintCodec) WriteLength(enc *Encoder, length int) error {
+	return writeBincodeVarint(enc, Uint128{Lo: uint64(length)})
+}
+
+func (bincodeVarintCodec) ReadEnumTag(dec *Decoder) (int, error) {
+	variant, err := readBincodeVarint(dec, 32)
+	if err != nil {
+		return 0, err
+	}
+	if variant.Lo > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidEnumVariant, variant.Lo)
+	}
+	return int(variant.Lo), nil
+}
+
+func (bincodeVarintCodec) WriteEnumTag(enc *Encoder, variant int) error {
+	return writeBincodeVarint(enc, Uint128{Lo: uint64(uint32(variant))})
+}
+
+func (bincodeVarintCodec) ReadUint(dec *Decoder, bits int) (Uint128, error) {
+	return readBincodeVarint(dec, bits)
+}
+
+func (bincodeVarintCodec) WriteUint(enc *Encoder, v Uint128, bits int) error {
+	return writeBincodeVarint(enc, v)
+}
+
+func (bincodeVarintCodec) ReadInt(dec *Decoder, bits int) (Int128, error) {
+	// The zigzag encoding of an integer of n bits fits in n bits.
+	v, err := readBincodeVarint(dec, bits)
+	if err != nil {
+		return Int128{}, err
+	}
+	return zigzagDecode(v), nil
+}
+
+func (bincodeVarintCodec) WriteInt(enc *Encoder, v Int128, bits int) error {
+	return writeBincodeVarint(enc, zigzagEncode(v))
+}
+
+// The bytes that introduce the bincode varints larger than one byte.
+const (
+	bincodeVarintU16  = 251
+	bincodeVarintU32  = 252
+	bincodeVarintU64  = 253
+	bincodeVarintU128 = 254
+)
+
+// readBincodeVarint reads a bincode varint, which must fit in bits bits:
+// a byte up to 250, or one of the bincodeVarint bytes followed
+// by a little-endian integer of its size.
+func readBincodeVarint(dec *Decoder, bits int) (v Uint128, err error) {
+	b, err := dec.ReadByte()
+	if err != nil {
+		return v, err
+	}
+	switch b {
+	case bincodeVarintU16:
+		var n uint16
+		n, err = dec.ReadUint16(LE)
+		v.Lo = uint64(n)
+	case bincodeVarintU32:
+		var n uint32
+		n, err = dec.ReadUint32(LE)
+		v.Lo = uint64(n)
+	case bincodeVarintU64:
+		v.Lo, err = dec.ReadUint64(LE)
+	case bincodeVarintU128:
+		if bits < 128 {
+			return v, fmt.Errorf("%w: u128 varint for an integer of %d bits", ErrInvalidVarint, bits)
+		}
+		v, err = dec.ReadUint128(LE)
+	case 255:
+		return v, fmt.Errorf("%w: reserved byte %d", ErrInvalidVarint, b)
+	default:
+		v.Lo = uint64(b)
+	}
+	if err != nil {
+		return v, err
+	}
+	if bits < 64 && v.Lo>>uint(bits) != 0 {
+		return v, fmt.Errorf("%w: varint %d in %d bits", ErrOverflow, v.Lo, bits)
+	}
+	return v, nil
+}
+
+// writeBincodeVarint writes v as a bincode varint, in its shortest form.
+func writeBincodeVarint(enc *Encoder, v Uint128) error {
+	switch {
+	case v.Hi == 0 && v.Lo < bincodeVarintU16:
+		return enc.WriteUint8(uint8(v.Lo))
+	case v.Hi == 0 && v.Lo <= math.MaxUint16:
+		if err := enc.WriteUint8(bincodeVarintU16); err != nil {
+			return err
+		}
+		return enc.WriteUint16(uint16(v.Lo), LE)
+	case v.Hi == 0 && v.Lo <= math.MaxUint32:
+		if err := enc.WriteUint8(bincodeVarintU32); err != nil {
+			return err
+		}
+		return enc.WriteUint32(uint32(v.Lo), LE)
+	case v.Hi == 0:
+		if err := enc.WriteUint8(bincodeVarintU64); err != nil {
+			return err
+		}
+		return enc.WriteUint64(v.Lo, LE)
+	default:
+		if err := enc.WriteUint8(bincodeVarintU128); err != nil {
+			return err
+		}
+		return enc.WriteUint128(v, LE)
+	}
+}
+
+// zigzagEncode maps the signed integers to unsigned ones
+// (0, -1, 1, -2, ... to 0, 1, 2, 3, ...), so that small ones stay small.
+func zigzagEncode(v Int128) Uint128 {
+	sign := uint64(int64(v.Hi) >> 63)
+	return Uint128{
+		Lo: v.Lo<<1 ^ sign,
+		Hi: (v.Hi<<1 | v.Lo>>63) ^ sign,
+	}
+}
+
+// zigzagDecode is the inverse of zigzagEncode.
+func zigzagDecode(v Uint128) Int128 {
+	sign := -(v.Lo & 1)
+	return Int128{
+		Lo: (v.Lo>>1 | v.Hi<<63) ^ sign,
+		Hi: v.Hi>>1 ^ sign,
+	}
+}
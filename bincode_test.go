@@ -0,0 +1,160 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// systemInstruction has the first variants of the instructions
+// of the Solana System program.
+type systemInstruction struct {
+	Enum          BorshEnum `borsh_enum:"true"`
+	CreateAccount systemCreateAccount
+	Assign        systemAssign
+	Transfer      systemTransfer
+}
+
+type systemCreateAccount struct {
+	Lamports uint64
+	Space    uint64
+	Owner    [32]byte
+}
+
+type systemAssign struct {
+	Owner [32]byte
+}
+
+type systemTransfer struct {
+	Lamports uint64
+}
+
+type bincodeFields struct {
+	A    uint32
+	Name string
+	Opt  *uint8 `bin:"optional"`
+	List []uint16
+	Big  Uint128
+	Neg  int64
+	Map  map[uint8]bool
+}
+
+func TestBincode(t *testing.T) {
+	// The data of a transfer of 1000 lamports:
+	transfer := systemInstruction{Enum: 2, Transfer: systemTransfer{Lamports: 1000}}
+	data, err := MarshalBincode(&transfer)
+	require.NoError(t, err)
+	require.Equal(t, []byte{2, 0, 0, 0, 0xe8, 3, 0, 0, 0, 0, 0, 0}, data)
+	var gotTransfer systemInstruction
+	require.NoError(t, UnmarshalBincode(&gotTransfer, data))
+	require.Equal(t, transfer, gotTransfer)
+
+	five := uint8(5)
+	v := bincodeFields{
+		A:    1,
+		Name: "ab",
+		Opt:  &five,
+		List: []uint16{1, 2},
+		Big:  Uint128{Lo: 3},
+		Neg:  -2,
+		Map:  map[uint8]bool{7: true},
+	}
+	want := concatByteSlices(
+		[]byte{1, 0, 0, 0},
+		[]byte{2, 0, 0, 0, 0, 0, 0, 0, 'a', 'b'},
+		[]byte{1, 5},
+		[]byte{2, 0, 0, 0, 0, 0, 0, 0, 1, 0, 2, 0},
+		[]byte{3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		[]byte{1, 0, 0, 0, 0, 0, 0, 0, 7, 1},
+	)
+	data, err = MarshalBincode(&v)
+	require.NoError(t, err)
+	require.Equal(t, want, data)
+	count, err := BincodeByteCount(&v)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(want)), count)
+
+	var got bincodeFields
+	require.NoError(t, UnmarshalBincode(&got, data))
+	require.Equal(t, v, got)
+
+	err = NewBincodeDecoder([]byte{2, 0, 0, 0}).SetStrict(true).Decode(&got)
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+	err = UnmarshalBincode(&gotTransfer, []byte{3, 0, 0, 0})
+	require.True(t, errors.Is(err, ErrInvalidEnumVariant), err)
+}
+
+func TestBincodeVarint(t *testing.T) {
+	for _, test := range []struct {
+		v    interface{}
+		want []byte
+	}{
+		{uint16(250), []byte{250}},
+		{uint16(251), []byte{251, 251, 0}},
+		{uint32(math.MaxUint16), []byte{251, 0xff, 0xff}},
+		{uint32(math.MaxUint16 + 1), []byte{252, 0, 0, 1, 0}},
+		{uint64(math.MaxUint32 + 1), []byte{253, 0, 0, 0, 0, 1, 0, 0, 0}},
+		{Uint128{Hi: 1}, []byte{254, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}},
+		{int32(0), []byte{0}},
+		{int32(-1), []byte{1}},
+		{int32(1), []byte{2}},
+		{int64(-65), []byte{129}},
+		{int16(math.MinInt16), []byte{251, 0xff, 0xff}},
+		{int64(math.MaxInt64), []byte{253, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{NewInt128FromInt64(-200), []byte{251, 0x8f, 1}},
+		{uint8(255), []byte{255}},
+		{"ab", []byte{2, 'a', 'b'}},
+		{systemInstruction{Enum: 2, Transfer: systemTransfer{Lamports: 1000}}, []byte{2, 251, 0xe8, 3}},
+	} {
+		enc := NewBufferedEncoderWithEncoding(EncodingBincodeVarint)
+		require.NoError(t, enc.Encode(test.v), "%#v", test.v)
+		require.Equal(t, test.want, enc.Bytes(), "%#v", test.v)
+	}
+
+	var got bincodeFields
+	v := bincodeFields{A: 300, Name: "ab", List: []uint16{1, 1000}, Big: Uint128{Lo: 3}, Neg: -2}
+	data, err := marshalWithEncoding(&v, EncodingBincodeVarint)
+	require.NoError(t, err)
+	require.Equal(t, []byte{251, 0x2c, 1, 2, 'a', 'b', 0, 2, 1, 251, 0xe8, 3, 3, 3, 0}, data)
+	require.NoError(t, NewDecoderWithEncoding(data, EncodingBincodeVarint).Decode(&got))
+	require.Equal(t, v, got)
+
+	// Non-minimal varints are accepted, like bincode does:
+	var n uint32
+	require.NoError(t, NewDecoderWithEncoding([]byte{252, 1, 0, 0, 0}, EncodingBincodeVarint).Decode(&n))
+	require.Equal(t, uint32(1), n)
+
+	var small uint16
+	err = NewDecoderWithEncoding([]byte{252, 0, 0, 1, 0}, EncodingBincodeVarint).Decode(&small)
+	require.True(t, errors.Is(err, ErrOverflow), err)
+	err = NewDecoderWithEncoding([]byte{255}, EncodingBincodeVarint).Decode(&small)
+	require.True(t, errors.Is(err, ErrInvalidVarint), err)
+	err = NewDecoderWithEncoding([]byte{254, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, EncodingBincodeVarint).Decode(&n)
+	require.True(t, errors.Is(err, ErrInvalidVarint), err)
+	err = NewDecoderWithEncoding([]byte{251, 1}, EncodingBincodeVarint).Decode(&small)
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+
+	// The varints have no 256-bit form:
+	_, err = marshalWithEncoding(Uint256{}, EncodingBincodeVarint)
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	var u Uint256
+	err = NewDecoderWithEncoding(make([]byte, 32), EncodingBincodeVarint).Decode(&u)
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+}
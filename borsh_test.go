@@ -16,6 +16,7 @@ package bin
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -1496,6 +1497,92 @@ func TestSet(t *testing.T) {
 	require.Equal(t, x, *y)
 }
 
+type taggedSet struct {
+	Strings map[string]struct{}  `bin:"set"`
+	Keys    map[[2]byte]struct{} `bin:"set"`
+}
+
+func TestSet_tag(t *testing.T) {
+	x := taggedSet{
+		Strings: map[string]struct{}{"b": {}, "ab": {}, "a": {}},
+		Keys:    map[[2]byte]struct{}{{1, 0}: {}, {0, 2}: {}},
+	}
+	data, err := MarshalBorsh(x)
+	require.NoError(t, err)
+	require.Equal(t,
+		[]byte{
+			3, 0, 0, 0,
+			1, 0, 0, 0, 'a',
+			2, 0, 0, 0, 'a', 'b',
+			1, 0, 0, 0, 'b',
+			2, 0, 0, 0,
+			0, 2,
+			1, 0,
+		},
+		data,
+	)
+
+	y := new(taggedSet)
+	require.NoError(t, UnmarshalBorsh(y, data))
+	require.Equal(t, x, *y)
+
+	// untagged maps of empty structs are encoded the same way:
+	untagged, err := MarshalBorsh(S{S: map[int64]struct{}{2: {}, 1: {}}})
+	require.NoError(t, err)
+	tagged, err := MarshalBorsh(struct {
+		S map[int64]struct{} `bin:"set"`
+	}{S: map[int64]struct{}{2: {}, 1: {}}})
+	require.NoError(t, err)
+	require.Equal(t, untagged, tagged)
+
+	_, err = MarshalBorsh(struct {
+		S map[int64]bool `bin:"set"`
+	}{S: map[int64]bool{1: true}})
+	require.True(t, errors.Is(err, ErrUnsupportedType))
+}
+
+func TestSet_nonCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"unsorted", []byte{2, 0, 0, 0, 1, 0, 0, 0, 'b', 1, 0, 0, 0, 'a', 0, 0, 0, 0}, ErrUnsortedKeys},
+		{"duplicate", []byte{2, 0, 0, 0, 1, 0, 0, 0, 'a', 1, 0, 0, 0, 'a', 0, 0, 0, 0}, ErrDuplicateKey},
+		{"unsorted arrays", []byte{0, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 2}, ErrUnsortedKeys},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := UnmarshalBorsh(new(taggedSet), tt.data)
+			require.True(t, errors.Is(err, tt.err), "got %v", err)
+		})
+	}
+}
+
+func TestMap_strictKeyOrder(t *testing.T) {
+	// {2: 20, 1: 10}
+	unsorted := []byte{2, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 20, 1, 0, 0, 0, 0, 0, 0, 0, 10}
+	// {1: 10, 1: 20}
+	duplicate := []byte{2, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 10, 1, 0, 0, 0, 0, 0, 0, 0, 20}
+
+	var m map[int64]uint8
+	require.NoError(t, NewBorshDecoder(unsorted).Decode(&m))
+	require.Equal(t, map[int64]uint8{1: 10, 2: 20}, m)
+
+	strict := DecoderOptions{StrictKeyOrder: true}
+	err := NewBorshDecoder(unsorted).SetOptions(strict).Decode(&m)
+	require.True(t, errors.Is(err, ErrUnsortedKeys), "got %v", err)
+
+	err = NewBorshDecoder(duplicate).SetOptions(strict).Decode(&m)
+	require.True(t, errors.Is(err, ErrDuplicateKey), "got %v", err)
+
+	// what we encode is canonical:
+	data, err := MarshalBorsh(map[int64]uint8{-1: 1, 2: 20, 1: 10, 3: 30})
+	require.NoError(t, err)
+	require.NoError(t, NewBorshDecoder(data).SetOptions(strict).Decode(&m))
+	require.Equal(t, map[int64]uint8{-1: 1, 1: 10, 2: 20, 3: 30}, m)
+}
+
 type Skipped struct {
 	A int64
 	B int64 `borsh_skip:"true"`
@@ -1762,3 +1849,37 @@ func TestStringSlice(t *testing.T) {
 		require.Equal(t, x, *y)
 	}
 }
+
+func TestOptionalMarshaler(t *testing.T) {
+	type S struct {
+		A *Uint128 `bin:"optional"`
+		B *Uint128 `bin:"coption"`
+		C uint8
+	}
+	{
+		x := S{A: &Uint128{Lo: 1, Hi: 2}, B: &Uint128{Lo: 3}, C: 4}
+		data, err := MarshalBorsh(x)
+		require.NoError(t, err)
+		require.Equal(t, concatByteSlices(
+			[]byte{0x01}, // optionality
+			[]byte{0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0},
+			[]byte{0x01, 0x0, 0x0, 0x0}, // c-optionality
+			[]byte{0x3, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0},
+			[]byte{0x4},
+		), data)
+
+		var y S
+		require.NoError(t, UnmarshalBorsh(&y, data))
+		require.Equal(t, x, y)
+	}
+	{
+		x := S{C: 4}
+		data, err := MarshalBorsh(x)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x0, 0x0, 0x0, 0x0, 0x0, 0x4}, data)
+
+		y := S{A: &Uint128{Lo: 1}, B: &Uint128{Lo: 1}}
+		require.NoError(t, UnmarshalBorsh(&y, data))
+		require.Equal(t, x, y)
+	}
+}
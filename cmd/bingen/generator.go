@@ -0,0 +1,1020 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const binPath = "github.com/gagliardetto/binary"
+
+// generate returns the source of a file implementing MarshalWithEncoder
+// and UnmarshalWithDecoder for the named struct types of the package in dir;
+// output is the name of that file, which is left out of the package
+// when type checking it (it may be stale).
+func generate(dir string, typeNames []string, output string) ([]byte, error) {
+	pkg, checkErr, err := loadPackage(dir, filepath.Base(output))
+	if err != nil {
+		return nil, err
+	}
+	return generateTypes(pkg, checkErr, typeNames)
+}
+
+// generateTypes returns the source of the methods of the named types of pkg;
+// checkErr is the error returned by loadPackage.
+func generateTypes(pkg *types.Package, checkErr error, typeNames []string) ([]byte, error) {
+	g := &generator{
+		pkg:       pkg,
+		checkErr:  checkErr,
+		generated: make(map[*types.TypeName]bool),
+		imports:   map[string]string{binPath: "bin"},
+	}
+
+	var targets []*types.TypeName
+	for _, name := range typeNames {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("type %q not found in package %s", name, pkg.Name())
+		}
+		if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("type %q is not a struct", name)
+		}
+		g.generated[obj] = true
+		targets = append(targets, obj)
+	}
+	for _, obj := range targets {
+		if err := g.genType(obj); err != nil {
+			return nil, fmt.Errorf("type %s: %w", obj.Name(), err)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "// Code generated by bingen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "package %s\n\n", pkg.Name())
+	fmt.Fprintf(out, "import (\n")
+	var std, others []string
+	for path := range g.imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			others = append(others, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+	for i, paths := range [][]string{std, others} {
+		if i > 0 && len(std) > 0 {
+			fmt.Fprintf(out, "\n")
+		}
+		for _, path := range paths {
+			if name := g.imports[path]; name != filepath.Base(path) {
+				fmt.Fprintf(out, "%s %q\n", name, path)
+			} else {
+				fmt.Fprintf(out, "%q\n", path)
+			}
+		}
+	}
+	fmt.Fprintf(out, ")\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated code: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+// loadPackage parses and type checks the package in dir, leaving out
+// the file named skip. The type checking errors are not fatal (the package
+// may use the methods about to be generated): the first one is returned
+// as checkErr, to explain the types that could not be resolved.
+func loadPackage(dir string, skip string) (pkg *types.Package, checkErr error, err error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		if name == skip {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, file)
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if checkErr == nil {
+				checkErr = err
+			}
+		},
+	}
+	pkg, _ = conf.Check(bp.ImportPath, fset, files, nil)
+	return pkg, checkErr, nil
+}
+
+type generator struct {
+	pkg      *types.Package
+	checkErr error
+	// generated holds the types whose methods are being generated.
+	generated map[*types.TypeName]bool
+	// imports maps the paths of the packages used by the generated code to their names.
+	imports map[string]string
+	buf     bytes.Buffer
+
+	// The state of the method being generated:
+	tmp       int    // counter of the temporary variables
+	errReturn string // statement returning err, wrapped with the current field
+	order     string // byte order of the Uint128 and Int128 values of the current field
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) use(path string) string {
+	name := filepath.Base(path)
+	if path == binPath {
+		name = "bin"
+	}
+	g.imports[path] = name
+	return name
+}
+
+func (g *generator) qualifier(pkg *types.Package) string {
+	if pkg == g.pkg {
+		return ""
+	}
+	g.imports[pkg.Path()] = pkg.Name()
+	return pkg.Name()
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+// relative returns the name of t in the error messages.
+func (g *generator) relative(t types.Type) string {
+	return types.TypeString(t, types.RelativeTo(g.pkg))
+}
+
+func (g *generator) newVar(prefix string) string {
+	g.tmp++
+	return prefix + strconv.Itoa(g.tmp)
+}
+
+// check emits a call to stmt, returning the error of the current field.
+func (g *generator) check(stmt string) {
+	g.printf("if err = %s; err != nil {\n%s\n}\n", stmt, g.errReturn)
+}
+
+func (g *generator) setField(verb string, f *field) {
+	g.use("fmt")
+	g.errReturn = fmt.Sprintf("return fmt.Errorf(%s, err)", strconv.Quote(fmt.Sprintf("error while %s %q field: %%w", verb, f.name)))
+	g.order = "bin.LE"
+	if f.tag.bigEndian {
+		g.order = "bin.BE"
+	}
+}
+
+// elems sets the byte order of the elements of an array or slice,
+// which ignore the tag of their field, and returns a function restoring it.
+func (g *generator) elems() func() {
+	order := g.order
+	g.order = "bin.LE"
+	return func() { g.order = order }
+}
+
+// fieldTag holds the flags of a `bin:"..."` tag (or of the borsh_* tags)
+// used by the borsh encoding; see parseFieldTag in package bin.
+type fieldTag struct {
+	raw             string
+	sizeOf          string
+	byteSizeOf      string
+	skip            bool
+	option          bool
+	coption         bool
+	binaryExtension bool
+	rest            bool
+	isBorshEnum     bool
+	bigEndian       bool
+	// reflective is set by the tags whose fields are
+	// left to the reflective encoder (e.g. `fixed=N`).
+	reflective bool
+}
+
+func parseFieldTag(raw string) fieldTag {
+	tag := reflect.StructTag(raw)
+	t := fieldTag{raw: raw}
+	for _, s := range strings.Split(tag.Get("bin"), " ") {
+		switch {
+		case strings.HasPrefix(s, "sizeof="):
+			t.sizeOf = strings.SplitN(s, "=", 2)[1]
+		case strings.HasPrefix(s, "byte_sizeof="):
+			t.byteSizeOf = strings.TrimPrefix(s, "byte_sizeof=")
+		case s == "optional", s == "option":
+			t.option = true
+		case s == "coption":
+			t.coption = true
+		case s == "binary_extension":
+			t.binaryExtension = true
+		case s == "-", s == "skip":
+			t.skip = true
+		case s == "enum":
+			t.isBorshEnum = true
+		case s == "big":
+			t.bigEndian = true
+		case s == "little":
+			t.bigEndian = false
+		case s == "rest":
+			t.rest = true
+			t.reflective = true
+		case strings.HasPrefix(s, "fixed="), s == "cstring", strings.HasPrefix(s, "len="), s == "compact":
+			t.reflective = true
+		}
+	}
+	if strings.TrimSpace(tag.Get("borsh_skip")) == "true" {
+		t.skip = true
+	}
+	if strings.TrimSpace(tag.Get("borsh_enum")) == "true" {
+		t.isBorshEnum = true
+	}
+	return t
+}
+
+type field struct {
+	index int
+	name  string
+	typ   types.Type
+	tag   fieldTag
+	// sizeOfVar is the variable holding the length of this slice,
+	// set by the `sizeof=` tag of a previous field.
+	sizeOfVar string
+	// setsSizeOf is the variable set from the value of this field.
+	setsSizeOf string
+	// byteSizeVar is the variable holding the size in bytes of this field,
+	// set by the `byte_sizeof=` tag of a previous field.
+	byteSizeVar string
+	// setsByteSize is the byteSizeVar set from the value of this field.
+	setsByteSize string
+}
+
+// encoded tells whether the field is encoded: like the reflective
+// encoder, the skipped and unexported fields are left out.
+func (f *field) encoded() bool {
+	return !f.tag.skip && ast.IsExported(f.name)
+}
+
+func (g *generator) fields(st *types.Struct) ([]*field, error) {
+	fields := make([]*field, st.NumFields())
+	byName := make(map[string]int)
+	for i := range fields {
+		v := st.Field(i)
+		if strings.Contains(types.TypeString(v.Type(), nil), "invalid type") {
+			return nil, fmt.Errorf("field %s: unresolved type (%v)", v.Name(), g.checkErr)
+		}
+		fields[i] = &field{
+			index: i,
+			name:  v.Name(),
+			typ:   v.Type(),
+			tag:   parseFieldTag(st.Tag(i)),
+		}
+		if fields[i].tag.option && isBoolPtr(v.Type()) {
+			// Some encodings (e.g. SCALE) write an optional bool in a single byte.
+			fields[i].tag.reflective = true
+		}
+		byName[v.Name()] = i
+	}
+
+	seenBinaryExtension := false
+	seenRest := false
+	for _, f := range fields {
+		if f.tag.skip {
+			continue
+		}
+		if seenRest {
+			return nil, fmt.Errorf("the `bin:\"rest\"` tag must be on the last field of the struct, problematic field %q", f.name)
+		}
+		seenRest = f.tag.rest
+		if f.tag.binaryExtension {
+			seenBinaryExtension = true
+		} else if seenBinaryExtension {
+			return nil, fmt.Errorf("the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field %q", f.name)
+		}
+
+		if err := g.byteSizeOf(f, fields, byName); err != nil {
+			return nil, err
+		}
+
+		if f.tag.sizeOf == "" {
+			continue
+		}
+		target, ok := byName[f.tag.sizeOf]
+		if !ok || target < f.index {
+			// Like the reflective path, ignore the sizeof
+			// of the missing fields and of the previous ones.
+			continue
+		}
+		if !g.usesSizeOf(fields[target]) {
+			continue
+		}
+		if !isInteger(f.typ) {
+			return nil, fmt.Errorf("field %s: sizeof field must be an integer, got %s", f.name, g.relative(f.typ))
+		}
+		if !f.encoded() || f.tag.binaryExtension {
+			return nil, fmt.Errorf("field %s: unsupported sizeof field: it must be exported and not a binary extension", f.name)
+		}
+		if fields[target].sizeOfVar != "" {
+			return nil, fmt.Errorf("field %s: multiple sizeof fields", fields[target].name)
+		}
+		f.setsSizeOf = "sizeOf" + fields[target].name
+		fields[target].sizeOfVar = f.setsSizeOf
+	}
+	return fields, nil
+}
+
+// byteSizeOf links f, if it has a `byte_sizeof=` tag, to the field
+// whose size in bytes it holds.
+func (g *generator) byteSizeOf(f *field, fields []*field, byName map[string]int) error {
+	if f.tag.byteSizeOf == "" {
+		return nil
+	}
+	target, ok := byName[f.tag.byteSizeOf]
+	if !ok || target < f.index || !fields[target].encoded() {
+		// Like the reflective path, ignore the byte_sizeof
+		// of the missing fields and of the previous ones.
+		return nil
+	}
+	if !isInteger(f.typ) {
+		return fmt.Errorf("field %s: byte_sizeof field must be an integer, got %s", f.name, g.relative(f.typ))
+	}
+	if !f.encoded() || f.tag.binaryExtension {
+		return fmt.Errorf("field %s: unsupported byte_sizeof field: it must be exported and not a binary extension", f.name)
+	}
+	if fields[target].byteSizeVar != "" {
+		return fmt.Errorf("field %s: multiple byte_sizeof fields", fields[target].name)
+	}
+	f.setsByteSize = "byteSizeOf" + fields[target].name
+	fields[target].byteSizeVar = f.setsByteSize
+	return nil
+}
+
+// usesSizeOf tells whether the encoding of f depends on a sizeof field.
+func (g *generator) usesSizeOf(f *field) bool {
+	if !f.encoded() || g.hasMarshaler(f.typ) || g.hasUnmarshaler(f.typ) {
+		return false
+	}
+	_, isSlice := f.typ.Underlying().(*types.Slice)
+	return isSlice
+}
+
+func isInteger(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
+
+func (g *generator) isGenerated(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && g.generated[named.Obj()]
+}
+
+// isGeneratedPtr tells whether t is a pointer to a generated type.
+func (g *generator) isGeneratedPtr(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	return ok && g.isGenerated(ptr.Elem())
+}
+
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// hasMarshaler tells whether t (or, as t is addressable, *t)
+// implements BinaryMarshaler.
+func (g *generator) hasMarshaler(t types.Type) bool {
+	return g.isGenerated(t) || g.isGeneratedPtr(t) || hasMethod(t, "MarshalWithEncoder")
+}
+
+func (g *generator) hasUnmarshaler(t types.Type) bool {
+	return g.isGenerated(t) || g.isGeneratedPtr(t) || hasMethod(t, "UnmarshalWithDecoder")
+}
+
+// isBoolPtr tells whether t is a pointer to a bool.
+func isBoolPtr(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	if !ok {
+		return false
+	}
+	basic, ok := ptr.Elem().Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Bool
+}
+
+// isBinType tells whether t is the named type of package bin.
+func isBinType(t types.Type, name string) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Name() == name && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == binPath
+}
+
+func (g *generator) genType(obj *types.TypeName) error {
+	for _, method := range []string{"MarshalWithEncoder", "UnmarshalWithDecoder"} {
+		if hasMethod(obj.Type(), method) {
+			return fmt.Errorf("it already has a %s method (possibly promoted from an embedded field)", method)
+		}
+	}
+	st := obj.Type().Underlying().(*types.Struct)
+	fields, err := g.fields(st)
+	if err != nil {
+		return err
+	}
+	isComplexEnum := len(fields) > 0 && isBinType(fields[0].typ, "BorshEnum") && fields[0].tag.isBorshEnum
+
+	name := obj.Name()
+	bin := g.use(binPath)
+
+	g.tmp = 0
+	g.printf("\n// MarshalWithEncoder encodes %s with borsh without using reflection;\n", name)
+	g.printf("// the other encodings use the reflective encoder.\n")
+	g.printf("func (obj %s) MarshalWithEncoder(encoder *%s.Encoder) (err error) {\n", name, bin)
+	g.printf("if !encoder.IsBorsh() {\ntype plain %s\nreturn encoder.Encode((*plain)(&obj))\n}\n", name)
+	if isComplexEnum {
+		err = g.encodeComplexEnum(fields)
+	} else {
+		err = g.encodeStruct(fields)
+	}
+	if err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n")
+
+	g.tmp = 0
+	g.printf("\n// UnmarshalWithDecoder decodes %s from borsh without using reflection;\n", name)
+	g.printf("// the other encodings use the reflective decoder.\n")
+	g.printf("func (obj *%s) UnmarshalWithDecoder(decoder *%s.Decoder) (err error) {\n", name, bin)
+	g.printf("if !decoder.IsBorsh() {\ntype plain %s\nreturn decoder.Decode((*plain)(obj))\n}\n", name)
+	g.printf("if err = decoder.EnterNested(); err != nil {\nreturn err\n}\ndefer decoder.LeaveNested()\n")
+	if isComplexEnum {
+		err = g.decodeComplexEnum(fields)
+	} else {
+		err = g.decodeStruct(fields)
+	}
+	if err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+func (g *generator) encodeStruct(fields []*field) error {
+	for _, f := range fields {
+		if !f.encoded() {
+			continue
+		}
+		g.setField("encoding", f)
+		expr := "obj." + f.name
+		if f.setsSizeOf != "" {
+			// Like the reflective path, fill a zero sizeof field with the length
+			// of its slice, and check the other values (obj is a copy).
+			slice := "obj." + f.tag.sizeOf
+			g.printf("if %s == 0 {\n%s = %s(len(%s))\n}\n", expr, expr, g.typeString(f.typ), slice)
+			g.printf("%s := int(%s)\n", f.setsSizeOf, expr)
+			if basic := f.typ.Underlying().(*types.Basic); basic.Info()&types.IsUnsigned != 0 {
+				// Like the reflective path, guard against the truncation of huge lengths.
+				g.printf("if %s < 0 {\n%s = 0\n}\n", f.setsSizeOf, f.setsSizeOf)
+			}
+			g.check(fmt.Sprintf("encoder.CheckSizeOf(%s, %s, len(%s))", f.setsSizeOf, strconv.Quote(f.tag.sizeOf), slice))
+		}
+		if err := g.encodeField(expr, f); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func (g *generator) encodeField(expr string, f *field) error {
+	if !g.isNative(f.typ) || f.tag.reflective {
+		g.check(fmt.Sprintf("encoder.EncodeField(&%s, %s)", expr, tagLiteral(f.tag.raw)))
+		return nil
+	}
+	if !f.tag.option && !f.tag.coption {
+		return g.encode(expr, f.typ, f.sizeOfVar)
+	}
+	writeOption := "WriteOption"
+	if !f.tag.option {
+		writeOption = "WriteCOption"
+	}
+	g.printf("if %s {\n", g.isZero(expr, f.typ))
+	g.check(fmt.Sprintf("encoder.%s(false)", writeOption))
+	g.printf("} else {\n")
+	g.check(fmt.Sprintf("encoder.%s(true)", writeOption))
+	var err error
+	switch ptr, isPtr := f.typ.(*types.Pointer); {
+	case g.isGeneratedPtr(f.typ):
+		g.check(expr + ".MarshalWithEncoder(encoder)")
+	case isPtr:
+		// The pointer is not nil.
+		err = g.encode("(*"+expr+")", ptr.Elem(), "")
+	default:
+		err = g.encode(expr, f.typ, f.sizeOfVar)
+	}
+	if err != nil {
+		return err
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// isNative tells whether the values of type t are encoded
+// by the generated code, rather than by the reflective path.
+func (g *generator) isNative(t types.Type) bool {
+	if g.isGenerated(t) || g.isGeneratedPtr(t) {
+		return true
+	}
+	if _, ok := int128Name(t); ok {
+		return true
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		if _, ok := int128Name(ptr.Elem()); ok {
+			return true
+		}
+	}
+	if hasMethod(t, "MarshalWithEncoder") || hasMethod(t, "UnmarshalWithDecoder") {
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		_, ok := basicCodecs[u.Kind()]
+		return ok
+	case *types.Array, *types.Slice, *types.Pointer:
+		return true
+	}
+	return false
+}
+
+// int128Name returns the name of t, if it is bin.Uint128 or bin.Int128:
+// their values are encoded with the Write and Read methods of that name.
+func int128Name(t types.Type) (string, bool) {
+	for _, name := range []string{"Uint128", "Int128"} {
+		if isBinType(t, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// basicCodec holds the methods encoding a basic kind,
+// and the type they take and return.
+type basicCodec struct {
+	write, read string
+	typ         string
+	withOrder   bool
+}
+
+var basicCodecs = map[types.BasicKind]basicCodec{
+	types.Bool:    {"WriteBool", "ReadBool", "bool", false},
+	types.Int8:    {"WriteInt8", "ReadInt8", "int8", false},
+	types.Int16:   {"WriteInt16", "ReadInt16", "int16", true},
+	types.Int32:   {"WriteInt32", "ReadInt32", "int32", true},
+	types.Int64:   {"WriteInt64", "ReadInt64", "int64", true},
+	types.Uint8:   {"WriteUint8", "ReadUint8", "uint8", false},
+	types.Uint16:  {"WriteUint16", "ReadUint16", "uint16", true},
+	types.Uint32:  {"WriteUint32", "ReadUint32", "uint32", true},
+	types.Uint64:  {"WriteUint64", "ReadUint64", "uint64", true},
+	types.Float32: {"WriteFloat32", "ReadFloat32", "float32", true},
+	types.Float64: {"WriteFloat64", "ReadFloat64", "float64", true},
+	types.String:  {"WriteString", "ReadString", "string", false},
+}
+
+func (c basicCodec) args() string {
+	if c.withOrder {
+		return "bin.LE"
+	}
+	return ""
+}
+
+// isUintElem tells whether the arrays and slices of t are encoded in bulk
+// by the reflective path, which then ignores the methods of t.
+func isUintElem(t types.Type) (basicCodec, bool) {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return basicCodec{}, false
+	}
+	switch basic.Kind() {
+	case types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		return basicCodecs[basic.Kind()], true
+	}
+	return basicCodec{}, false
+}
+
+func isByte(t types.Type) bool {
+	return types.Identical(t, types.Typ[types.Uint8])
+}
+
+// isZero returns the condition telling whether expr is the zero value,
+// as reflect.Value.IsZero does.
+func (g *generator) isZero(expr string, t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface, *types.Chan, *types.Signature:
+		return expr + " == nil"
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "!" + expr
+		case u.Info()&types.IsString != 0:
+			return expr + ` == ""`
+		case u.Info()&types.IsNumeric != 0:
+			return expr + " == 0"
+		}
+	}
+	if name, ok := int128Name(t); ok {
+		return fmt.Sprintf("%s == (bin.%s{})", expr, name)
+	}
+	return fmt.Sprintf("%s.ValueOf(&%s).Elem().IsZero()", g.use("reflect"), expr)
+}
+
+// encode emits the encoding of expr (an addressable expression) of type t;
+// sizeOfVar, if set, holds the length of a slice encoded without prefix.
+func (g *generator) encode(expr string, t types.Type, sizeOfVar string) error {
+	switch {
+	case g.isGenerated(t):
+		g.check(expr + ".MarshalWithEncoder(encoder)")
+		return nil
+	case g.isGeneratedPtr(t):
+		// Like the reflective path, a nil marshaler is not encoded.
+		g.printf("if %s != nil {\n", expr)
+		g.check(expr + ".MarshalWithEncoder(encoder)")
+		g.printf("}\n")
+		return nil
+	case !g.isNative(t):
+		g.check(fmt.Sprintf("encoder.EncodeField(&%s, \"\")", expr))
+		return nil
+	}
+
+	if name, ok := int128Name(t); ok {
+		g.check(fmt.Sprintf("encoder.Write%s(%s, %s)", name, bare(expr), g.order))
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		g.encodeBasic(expr, t, basicCodecs[u.Kind()])
+	case *types.Pointer:
+		if _, ok := int128Name(u.Elem()); ok {
+			// Like the reflective path, a nil marshaler is not encoded.
+			g.printf("if %s != nil {\n", expr)
+			if err := g.encode("(*"+expr+")", u.Elem(), ""); err != nil {
+				return err
+			}
+			g.printf("}\n")
+			return nil
+		}
+		// A nil pointer is encoded as the zero value.
+		p := g.newVar("p")
+		g.printf("%s := %s\nif %s == nil {\n%s = new(%s)\n}\n", p, expr, p, p, g.typeString(u.Elem()))
+		return g.encode("(*"+p+")", u.Elem(), "")
+	case *types.Array:
+		return g.encodeElems(expr, u.Elem(), "")
+	case *types.Slice:
+		if sizeOfVar == "" {
+			g.check(fmt.Sprintf("encoder.WriteUint32(uint32(len(%s)), bin.LE)", expr))
+		}
+		return g.encodeElems(expr, u.Elem(), sizeOfVar)
+	}
+	return nil
+}
+
+func (g *generator) encodeBasic(expr string, t types.Type, codec basicCodec) {
+	arg := bare(expr)
+	if !isBasic(t) {
+		arg = fmt.Sprintf("%s(%s)", codec.typ, arg)
+	}
+	if args := codec.args(); args != "" {
+		arg += ", " + args
+	}
+	g.check(fmt.Sprintf("encoder.%s(%s)", codec.write, arg))
+}
+
+// encodeElems emits the encoding of the first l elements of expr,
+// or of all of them if l is empty.
+func (g *generator) encodeElems(expr string, elem types.Type, l string) error {
+	defer g.elems()()
+	if isByte(elem) {
+		g.check(fmt.Sprintf("encoder.WriteBytes(%s[:%s], false)", expr, l))
+		return nil
+	}
+	i := g.newVar("i")
+	if l == "" {
+		g.printf("for %s := range %s {\n", i, expr)
+	} else {
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, l, i)
+	}
+	if codec, ok := isUintElem(elem); ok {
+		g.encodeBasic(expr+"["+i+"]", elem, codec)
+	} else if err := g.encode(expr+"["+i+"]", elem, ""); err != nil {
+		return err
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func (g *generator) encodeComplexEnum(fields []*field) error {
+	enum := fields[0]
+	g.printf("if err = encoder.WriteUint8(uint8(obj.%s)); err != nil {\nreturn err\n}\n", enum.name)
+	g.printf("switch obj.%s {\n", enum.name)
+	for _, f := range fields[1:] {
+		g.printf("case %d:\n", f.index-1)
+		g.setField("encoding", f)
+		if err := g.encodeVariant("obj."+f.name, f); err != nil {
+			return err
+		}
+	}
+	g.printf("default:\nreturn %s.New(\"complex enum too large\")\n}\n", g.use("errors"))
+	return nil
+}
+
+// encodeVariant emits the encoding of a variant of a complex enum,
+// following the reflective path: the struct variants are encoded
+// field by field (ignoring their own marshaler), the pointers are
+// dereferenced and the basic types are encoded as such.
+func (g *generator) encodeVariant(expr string, f *field) error {
+	if !ast.IsExported(f.name) {
+		return fmt.Errorf("variant %s: unexported variants are not supported", f.name)
+	}
+	t := f.typ
+	ptrExpr := expr
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		g.printf("if %s != nil {\n", expr)
+		defer g.printf("}\n")
+		t = ptr.Elem()
+		expr = "(*" + expr + ")"
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		if u.NumFields() == 0 {
+			return nil
+		}
+		if !g.isGenerated(t) {
+			return fmt.Errorf("variant %s: the methods of %s must be generated too", f.name, g.relative(t))
+		}
+		g.check(ptrExpr + ".MarshalWithEncoder(encoder)")
+		return nil
+	case *types.Basic:
+		if codec, ok := basicCodecs[u.Kind()]; ok {
+			g.encodeBasic(expr, t, codec)
+			return nil
+		}
+	}
+	return fmt.Errorf("variant %s: unsupported type %s", f.name, g.relative(f.typ))
+}
+
+func (g *generator) decodeStruct(fields []*field) error {
+	for _, f := range fields {
+		if !f.encoded() {
+			continue
+		}
+		g.setField("decoding", f)
+		expr := "obj." + f.name
+		if f.tag.binaryExtension {
+			g.printf("if decoder.HasRemaining() {\n")
+		}
+		var parent string
+		if f.byteSizeVar != "" {
+			// The field is decoded from a sub-decoder of its bytes.
+			parent = g.newVar("parent")
+			g.printf("{\n%s := decoder\ndecoder, err := %s.SubDecoder(%s)\nif err != nil {\n%s\n}\n", parent, parent, f.byteSizeVar, g.errReturn)
+		}
+		if err := g.decodeField(expr, f); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+		if parent != "" {
+			g.check(fmt.Sprintf("%s.CloseSubDecoder(decoder)", parent))
+			g.printf("}\n")
+		}
+		if f.tag.binaryExtension {
+			g.printf("}\n")
+		}
+		for _, v := range []string{f.setsSizeOf, f.setsByteSize} {
+			if v == "" {
+				continue
+			}
+			g.printf("%s := int(%s)\n", v, expr)
+			if basic := f.typ.Underlying().(*types.Basic); basic.Info()&types.IsUnsigned != 0 {
+				g.printf("if %s < 0 {\n%s = 0\n}\n", v, v)
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) decodeField(expr string, f *field) error {
+	if !g.isNative(f.typ) || f.tag.reflective {
+		g.check(fmt.Sprintf("decoder.DecodeField(&%s, %s)", expr, tagLiteral(f.tag.raw)))
+		return nil
+	}
+	if !f.tag.option && !f.tag.coption {
+		return g.decodeFresh(expr, f.typ, f.sizeOfVar)
+	}
+	readOption := "ReadOption"
+	if !f.tag.option {
+		readOption = "ReadCOption"
+	}
+	g.printf("{\nisPresent, err := decoder.%s()\nif err != nil {\n%s\n}\n", readOption, g.errReturn)
+	g.printf("if !isPresent {\n")
+	switch f.typ.Underlying().(type) {
+	case *types.Pointer, *types.Slice:
+		g.printf("%s = nil\n", expr)
+	default:
+		g.printf("var zero %s\n%s = zero\n", g.typeString(f.typ), expr)
+	}
+	g.printf("} else {\n")
+	if err := g.decodeFresh(expr, f.typ, f.sizeOfVar); err != nil {
+		return err
+	}
+	g.printf("}\n}\n")
+	return nil
+}
+
+// decodeFresh emits the decoding of a struct field: like the reflective
+// path, the unmarshalers decode a new value instead of the current one.
+func (g *generator) decodeFresh(expr string, t types.Type, sizeOfVar string) error {
+	switch {
+	case g.isGenerated(t):
+		v := g.newVar("v")
+		g.printf("var %s %s\n", v, g.typeString(t))
+		g.check(v + ".UnmarshalWithDecoder(decoder)")
+		g.printf("%s = %s\n", expr, v)
+		return nil
+	case g.isGeneratedPtr(t):
+		g.printf("%s = new(%s)\n", expr, g.typeString(t.(*types.Pointer).Elem()))
+		g.check(expr + ".UnmarshalWithDecoder(decoder)")
+		return nil
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		if _, ok := int128Name(ptr.Elem()); ok {
+			g.printf("%s = new(%s)\n", expr, g.typeString(ptr.Elem()))
+			return g.decode("(*"+expr+")", ptr.Elem(), "")
+		}
+	}
+	return g.decode(expr, t, sizeOfVar)
+}
+
+// decode emits the decoding into expr (an addressable expression) of type t.
+func (g *generator) decode(expr string, t types.Type, sizeOfVar string) error {
+	switch {
+	case g.isGenerated(t):
+		g.check(expr + ".UnmarshalWithDecoder(decoder)")
+		return nil
+	case g.isGeneratedPtr(t):
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, g.typeString(t.(*types.Pointer).Elem()))
+		g.check(expr + ".UnmarshalWithDecoder(decoder)")
+		return nil
+	case !g.isNative(t):
+		g.check(fmt.Sprintf("decoder.DecodeField(&%s, \"\")", expr))
+		return nil
+	}
+
+	if name, ok := int128Name(t); ok {
+		g.printf("if %s, err = decoder.Read%s(%s); err != nil {\n%s\n}\n", bare(expr), name, g.order, g.errReturn)
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		g.decodeBasic(expr, t, basicCodecs[u.Kind()])
+	case *types.Pointer:
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, g.typeString(u.Elem()))
+		return g.decode("(*"+expr+")", u.Elem(), "")
+	case *types.Array:
+		if isByte(u.Elem()) {
+			b := g.newVar("b")
+			g.printf("{\n%s, err := decoder.ReadNBytes(%d)\nif err != nil {\n%s\n}\ncopy(%s[:], %s)\n}\n", b, u.Len(), g.errReturn, expr, b)
+			return nil
+		}
+		return g.decodeElems(expr, u.Elem())
+	case *types.Slice:
+		l := g.newVar("l")
+		g.printf("{\n")
+		if sizeOfVar != "" {
+			g.printf("%s := %s\n", l, sizeOfVar)
+		} else {
+			g.printf("n, err := decoder.ReadUint32(bin.LE)\nif err != nil {\n%s\n}\n%s := int(n)\n", g.errReturn, l)
+		}
+		g.check(fmt.Sprintf("decoder.CheckSliceLength(%s, int(%s.Sizeof(%s[0])))", l, g.use("unsafe"), expr))
+		// Like the reflective path, empty slices are left untouched.
+		g.printf("if %s > 0 {\n", l)
+		if isByte(u.Elem()) {
+			g.printf("if %s, err = decoder.ReadNBytes(%s); err != nil {\n%s\n}\n", expr, l, g.errReturn)
+		} else {
+			g.printf("%s = make(%s, %s)\n", expr, g.typeString(t), l)
+			if err := g.decodeElems(expr, u.Elem()); err != nil {
+				return err
+			}
+		}
+		g.printf("}\n}\n")
+	}
+	return nil
+}
+
+func (g *generator) decodeBasic(expr string, t types.Type, codec basicCodec) {
+	if isBasic(t) {
+		g.printf("if %s, err = decoder.%s(%s); err != nil {\n%s\n}\n", bare(expr), codec.read, codec.args(), g.errReturn)
+		return
+	}
+	v := g.newVar("v")
+	g.printf("{\n%s, err := decoder.%s(%s)\nif err != nil {\n%s\n}\n%s = %s(%s)\n}\n", v, codec.read, codec.args(), g.errReturn, bare(expr), g.typeString(t), v)
+}
+
+// isBasic tells whether t is a predeclared basic type (rather than a named one).
+func isBasic(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && types.Identical(t, types.Typ[basic.Kind()])
+}
+
+// bare strips the parentheses around a dereference, where they are not needed.
+func bare(expr string) string {
+	if strings.HasPrefix(expr, "(*") && strings.HasSuffix(expr, ")") && strings.Count(expr, "(") == 1 {
+		return expr[1 : len(expr)-1]
+	}
+	return expr
+}
+
+// decodeElems emits the decoding of all the elements of expr.
+func (g *generator) decodeElems(expr string, elem types.Type) error {
+	defer g.elems()()
+	i := g.newVar("i")
+	g.printf("for %s := range %s {\n", i, expr)
+	if codec, ok := isUintElem(elem); ok {
+		g.decodeBasic(expr+"["+i+"]", elem, codec)
+	} else if err := g.decode(expr+"["+i+"]", elem, ""); err != nil {
+		return err
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func (g *generator) decodeComplexEnum(fields []*field) error {
+	enum := fields[0]
+	g.printf("{\nv, err := decoder.ReadUint8()\nif err != nil {\nreturn err\n}\nobj.%s = bin.BorshEnum(v)\n}\n", enum.name)
+	g.printf("switch obj.%s {\n", enum.name)
+	for _, f := range fields[1:] {
+		g.printf("case %d:\n", f.index-1)
+		g.setField("decoding", f)
+		if err := g.decodeVariant("obj."+f.name, f); err != nil {
+			return err
+		}
+	}
+	g.use("fmt")
+	g.printf("default:\nreturn fmt.Errorf(\"%%w: complex enum variant %%d out of %%d\", bin.ErrInvalidEnumVariant, obj.%s, %d)\n}\n", enum.name, len(fields)-1)
+	return nil
+}
+
+// decodeVariant emits the decoding of a variant of a complex enum,
+// which (unlike struct fields) is decoded in place.
+func (g *generator) decodeVariant(expr string, f *field) error {
+	t := f.typ
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if st, ok := t.Underlying().(*types.Struct); ok && st.NumFields() == 0 && (!g.hasUnmarshaler(t) || isBinType(t, "EmptyVariant")) {
+		return nil
+	}
+	return g.decode(expr, f.typ, "")
+}
+
+// tagLiteral returns the Go literal of a struct tag.
+func tagLiteral(tag string) string {
+	if tag == "" {
+		return `""`
+	}
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the generated golden file")
+
+// TestGenerate_golden checks that the generated golden file is up to date;
+// its contents are checked against the reflective encoder by the tests
+// of the golden package.
+func TestGenerate_golden(t *testing.T) {
+	dir := filepath.Join("internal", "golden")
+	src, err := ioutil.ReadFile(filepath.Join(dir, "types.go"))
+	require.NoError(t, err)
+	m := regexp.MustCompile(`(?m)^//go:generate go run \.\./\.\. -type=(\S+) -output=(\S+)$`).FindSubmatch(src)
+	require.NotNil(t, m, "go:generate directive not found")
+
+	output := filepath.Join(dir, string(m[2]))
+	got, err := generate(dir, regexp.MustCompile(",").Split(string(m[1]), -1), output)
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, ioutil.WriteFile(output, got, 0644))
+	}
+	want, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "run go generate ./cmd/bingen/... (or go test ./cmd/bingen -update)")
+}
+
+func TestGenerate_errors(t *testing.T) {
+	pkg, checkErr, err := loadPackage(filepath.Join("testdata", "invalid"), "")
+	require.NoError(t, err)
+	tests := []struct {
+		typeName string
+		err      string
+	}{
+		{
+			typeName: "Missing",
+			err:      `type "Missing" not found in package invalid`,
+		},
+		{
+			typeName: "Alias",
+			err:      `type "Alias" is not a struct`,
+		},
+		{
+			typeName: "Unpacked",
+			err:      "type Unpacked: the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field \"Value\"",
+		},
+		{
+			typeName: "RestNotLast",
+			err:      "type RestNotLast: the `bin:\"rest\"` tag must be on the last field of the struct, problematic field \"Value\"",
+		},
+		{
+			typeName: "Enum",
+			err:      "type Enum: variant Inner: the methods of Inner must be generated too",
+		},
+		{
+			typeName: "SizeOf",
+			err:      "type SizeOf: field Len: sizeof field must be an integer, got string",
+		},
+	}
+	for _, test := range tests {
+		_, err := generateTypes(pkg, checkErr, []string{test.typeName})
+		assert.EqualError(t, err, test.err, test.typeName)
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golden
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The plain types have the fields (and tags) of the generated types,
+// but not their methods: they are encoded by the reflective path.
+type (
+	plainAccount   Account
+	plainHeader    Header
+	plainEntry     Entry
+	plainTransfer  Transfer
+	plainAction    Action
+	plainNode      Node
+	plainVersioned Versioned
+)
+
+func testAccounts() []Account {
+	memo := "memo"
+	delegate := [32]byte{7, 7}
+	amount := bin.Uint128{Lo: 1, Hi: 2}
+	pinned := false
+	full := Account{
+		Header: Header{
+			Version: 2,
+			Kind:    0x1234,
+			Flags:   [3]bool{true, false, true},
+			Magic:   [4]byte{0xde, 0xad, 0xbe, 0xef},
+			Timeout: -time.Second,
+			Symbol:  "SOL",
+			Label:   []byte("native"),
+			Aliases: []string{"wSOL"},
+			Seq:     100000,
+			Pinned:  &pinned,
+		},
+		Owner:    [32]byte{1, 2, 3},
+		Balance:  bin.Uint128{Lo: math.MaxUint64, Hi: 3},
+		Delta:    -42,
+		Big:      0x0102030405060708,
+		Enabled:  true,
+		Name:     "account",
+		Memo:     &memo,
+		Delegate: &delegate,
+		Limit:    1000,
+		Score:    math.Copysign(0, -1),
+		Entries: []Entry{
+			{Key: "a", Value: -1, Weights: []uint16{1, 2, 3}, Ratio: 0.5},
+			{Key: "b", Value: math.MaxInt64},
+		},
+		Pointers: []*Entry{{Key: "c"}},
+		Data:     []byte{0xca, 0xfe},
+		Matrix:   [2][3]int16{{1, -2, 3}, {-4, 5, math.MinInt16}},
+		Count:    2,
+		Items:    []uint32{10, 20},
+		Labels:   map[string]uint8{"z": 1, "a": 2, "m": 3},
+		Tags:     map[uint16]struct{}{300: {}, 2: {}, 70: {}},
+		Action:   Action{Enum: 1, Transfer: Transfer{To: [32]byte{9}, Amount: 99}},
+		Chain:    &Node{Value: 1, Next: &Node{Value: 2, Next: &Node{Value: 3}}},
+		Amount:   &amount,
+		Price:    bin.Uint128{Lo: 1, Hi: 2},
+		Fees:     [2]bin.Uint128{{Lo: 3}, {Hi: 4}},
+		Shares:   []bin.Int128{{Lo: math.MaxUint64, Hi: math.MaxUint64}, {Lo: 5}},
+		Last:     &Header{Version: 9},
+		internal: 5,
+		Cache:    []byte{1},
+		Ext:      77,
+		ExtName:  "extension",
+	}
+
+	noExtensions := full
+	noExtensions.Ext = 0
+	noExtensions.ExtName = ""
+
+	return []Account{
+		{Chain: &Node{}},
+		full,
+		noExtensions,
+		{Action: Action{Enum: 2, Close: &Transfer{Amount: 1}}, Chain: &Node{}, Score: 1.5},
+		{Action: Action{Enum: 3, Note: "note"}, Chain: &Node{Value: 4}},
+	}
+}
+
+func TestGolden_encoding(t *testing.T) {
+	for i, account := range testAccounts() {
+		account := account
+		want, err := bin.MarshalBorsh((*plainAccount)(&account))
+		require.NoError(t, err)
+
+		got, err := bin.MarshalBorsh(&account)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "account %d", i)
+
+		// By value, with the append API:
+		got, err = bin.AppendBorsh([]byte{0xff}, account)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte{0xff}, want...), got, "account %d", i)
+
+		// The other encodings use the reflective path
+		// (without ordering the maps):
+		account.Labels = nil
+		account.Tags = nil
+		wantBin, err := bin.MarshalBin((*plainAccount)(&account))
+		require.NoError(t, err)
+		gotBin, err := bin.MarshalBin(&account)
+		require.NoError(t, err)
+		assert.Equal(t, wantBin, gotBin, "account %d", i)
+	}
+}
+
+func TestGolden_decoding(t *testing.T) {
+	for i, account := range testAccounts() {
+		account := account
+		data, err := bin.MarshalBorsh(&account)
+		require.NoError(t, err)
+
+		var want plainAccount
+		require.NoError(t, bin.UnmarshalBorsh(&want, data))
+
+		var got Account
+		require.NoError(t, bin.UnmarshalBorsh(&got, data))
+		assert.Equal(t, Account(want), got, "account %d", i)
+
+		account.internal = 0
+		account.Cache = nil
+		if account.Score == 0 {
+			account.Score = 0 // -0.0 is zero, so it is not encoded.
+		}
+		assert.Equal(t, account, got, "account %d", i)
+
+		// Truncated data, up to the binary extensions
+		// (a u16, and a string with its u32 length):
+		for n := 0; n < len(data)-6-len(account.ExtName); n++ {
+			var got Account
+			err := bin.UnmarshalBorsh(&got, data[:n])
+			require.Error(t, err, "account %d truncated at %d", i, n)
+			require.True(t, errors.Is(err, bin.ErrShortBuffer), "account %d truncated at %d: %s", i, n, err)
+		}
+	}
+}
+
+func TestGolden_types(t *testing.T) {
+	check := func(t *testing.T, plain interface{}, generated interface{}) {
+		t.Helper()
+		want, err := bin.MarshalBorsh(plain)
+		require.NoError(t, err)
+		got, err := bin.MarshalBorsh(generated)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	header := Header{Version: 1, Kind: 2, Magic: [4]byte{3}}
+	check(t, (*plainHeader)(&header), &header)
+	entry := Entry{Key: "k", Weights: []uint16{0xffff}, Ratio: float32(math.Inf(1))}
+	check(t, (*plainEntry)(&entry), &entry)
+	transfer := Transfer{To: [32]byte{1}, Amount: 2}
+	check(t, (*plainTransfer)(&transfer), &transfer)
+	for _, action := range []Action{
+		{Enum: 0},
+		{Enum: 1, Transfer: transfer},
+		{Enum: 2, Close: &transfer},
+		{Enum: 2},
+		{Enum: 3, Note: "x"},
+	} {
+		action := action
+		check(t, (*plainAction)(&action), &action)
+	}
+	node := Node{Value: 1, Next: &Node{Value: 2}}
+	check(t, (*plainNode)(&node), &node)
+
+	{
+		action := Action{Enum: 4}
+		_, err := bin.MarshalBorsh((*plainAction)(&action))
+		require.EqualError(t, err, "complex enum too large")
+		_, err = bin.MarshalBorsh(&action)
+		require.EqualError(t, err, "complex enum too large")
+
+		err = bin.UnmarshalBorsh(&action, []byte{4})
+		require.True(t, errors.Is(err, bin.ErrInvalidEnumVariant))
+	}
+}
+
+func TestGolden_sizeOf(t *testing.T) {
+	account := testAccounts()[1]
+	account.Count = 0
+	want, err := bin.MarshalBorsh((*plainAccount)(&account))
+	require.NoError(t, err)
+	got, err := bin.MarshalBorsh(&account)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	account.Count = 3
+	_, err = bin.MarshalBorsh((*plainAccount)(&account))
+	require.True(t, errors.Is(err, bin.ErrSizeOfMismatch), err)
+	_, err = bin.MarshalBorsh(&account)
+	require.True(t, errors.Is(err, bin.ErrSizeOfMismatch), err)
+}
+
+func TestGolden_byteSizeOf(t *testing.T) {
+	versioned := Versioned{Size: 6, Inner: Extended{Value: 1, Ext: 2}, After: 3}
+	want, err := bin.MarshalBorsh((*plainVersioned)(&versioned))
+	require.NoError(t, err)
+	got, err := bin.MarshalBorsh(&versioned)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	for _, test := range []struct {
+		data []byte
+		want Versioned
+	}{
+		{
+			data: []byte{6, 0, 1, 0, 0, 0, 2, 0, 3},
+			want: versioned,
+		},
+		{
+			// Without the extension:
+			data: []byte{4, 0, 1, 0, 0, 0, 3},
+			want: Versioned{Size: 4, Inner: Extended{Value: 1}, After: 3},
+		},
+		{
+			// With an unknown extension:
+			data: []byte{8, 0, 1, 0, 0, 0, 2, 0, 0xff, 0xff, 3},
+			want: Versioned{Size: 8, Inner: Extended{Value: 1, Ext: 2}, After: 3},
+		},
+	} {
+		var plain plainVersioned
+		require.NoError(t, bin.UnmarshalBorsh(&plain, test.data))
+		assert.Equal(t, test.want, Versioned(plain))
+
+		var got Versioned
+		require.NoError(t, bin.UnmarshalBorsh(&got, test.data))
+		assert.Equal(t, test.want, got)
+	}
+
+	var decoded Versioned
+	err = bin.UnmarshalBorsh(&decoded, []byte{9, 0, 1, 0, 0, 0, 2, 0, 3})
+	require.True(t, errors.Is(err, bin.ErrShortBuffer), err)
+	err = bin.UnmarshalBorshStrict(&decoded, []byte{8, 0, 1, 0, 0, 0, 2, 0, 0xff, 0xff, 3})
+	require.True(t, errors.Is(err, bin.ErrTrailingBytes), err)
+}
+
+func TestGolden_limits(t *testing.T) {
+	entry := Entry{Weights: make([]uint16, 100)}
+	data, err := bin.MarshalBorsh(&entry)
+	require.NoError(t, err)
+
+	dec := bin.NewBorshDecoder(data)
+	dec.SetOptions(bin.DecoderOptions{MaxCollectionLength: 10})
+	var got Entry
+	err = dec.Decode(&got)
+	require.True(t, errors.Is(err, bin.ErrLimitExceeded), err)
+
+	// A hostile length:
+	data[12] = 0xff
+	err = bin.UnmarshalBorsh(&got, data)
+	require.True(t, errors.Is(err, bin.ErrShortBuffer), err)
+
+	// Nesting:
+	chain := Node{}
+	for i := 0; i < 10; i++ {
+		next := chain
+		chain = Node{Value: uint32(i), Next: &next}
+	}
+	data, err = bin.MarshalBorsh(&chain)
+	require.NoError(t, err)
+	dec = bin.NewBorshDecoder(data)
+	dec.SetOptions(bin.DecoderOptions{MaxNestingDepth: 5})
+	err = dec.Decode(&chain)
+	require.True(t, errors.Is(err, bin.ErrLimitExceeded), err)
+}
+
+func BenchmarkGolden(b *testing.B) {
+	account := testAccounts()[1]
+	data, err := bin.MarshalBorsh(&account)
+	require.NoError(b, err)
+
+	b.Run("encode/generated", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, 1024)
+		for i := 0; i < b.N; i++ {
+			if _, err := bin.AppendBorsh(buf[:0], &account); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("encode/reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, 1024)
+		for i := 0; i < b.N; i++ {
+			if _, err := bin.AppendBorsh(buf[:0], (*plainAccount)(&account)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("decode/generated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var got Account
+			if err := bin.UnmarshalBorsh(&got, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("decode/reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var got plainAccount
+			if err := bin.UnmarshalBorsh(&got, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package golden holds the types used to check the code generated by bingen
+// against the reflective encoder.
+package golden
+
+import (
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+)
+
+//go:generate go run ../.. -type=Account,Header,Entry,Transfer,Action,Node,Extended,Versioned -output=types_bingen.go
+
+type Kind uint16
+
+type Header struct {
+	Version uint8
+	Kind    Kind
+	Flags   [3]bool
+	Magic   [4]byte
+	Timeout time.Duration
+	Symbol  string   `bin:"fixed=8"`
+	Label   []byte   `bin:"cstring"`
+	Aliases []string `bin:"len=u8"`
+	Seq     uint32   `bin:"compact"`
+	Pinned  *bool    `bin:"optional"`
+}
+
+type Entry struct {
+	Key     string
+	Value   int64
+	Weights []uint16
+	Ratio   float32
+}
+
+type Transfer struct {
+	To     [32]byte
+	Amount uint64
+}
+
+type Action struct {
+	Enum     bin.BorshEnum `borsh_enum:"true"`
+	Noop     bin.EmptyVariant
+	Transfer Transfer
+	Close    *Transfer
+	Note     string
+}
+
+type Node struct {
+	Value uint32
+	Next  *Node `bin:"optional"`
+}
+
+type Extended struct {
+	Value uint32
+	Ext   uint16 `bin:"binary_extension"`
+}
+
+// Versioned holds an Extended, with its size in bytes.
+type Versioned struct {
+	Size  uint16 `bin:"byte_sizeof=Inner"`
+	Inner Extended
+	After uint8
+}
+
+type Account struct {
+	Header   Header
+	Owner    [32]byte
+	Balance  bin.Uint128
+	Delta    int32
+	Big      uint64 `bin:"big"`
+	Enabled  bool
+	Name     string
+	Memo     *string   `bin:"optional"`
+	Delegate *[32]byte `bin:"coption"`
+	Limit    uint64    `bin:"optional"`
+	Score    float64   `bin:"optional"`
+	Entries  []Entry
+	Pointers []*Entry
+	Data     []byte
+	Matrix   [2][3]int16
+	Count    uint8 `bin:"sizeof=Items"`
+	Items    []uint32
+	Labels   map[string]uint8
+	Tags     map[uint16]struct{} `bin:"set"`
+	Action   Action
+	Chain    *Node
+	Amount   *bin.Uint128   `bin:"optional"`
+	Price    bin.Uint128    `bin:"big"`
+	Fees     [2]bin.Uint128 `bin:"big"`
+	Shares   []bin.Int128
+	Last     *Header `bin:"optional"`
+	Any      interface{}
+	internal int
+	Cache    []byte `borsh_skip:"true"`
+	Ext      uint16 `bin:"binary_extension"`
+	ExtName  string `bin:"binary_extension"`
+}
@@ -0,0 +1,905 @@
+// Code generated by bingen. DO NOT EDIT.
+
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	bin "github.com/gagliardetto/binary"
+)
+
+// MarshalWithEncoder encodes Account with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Account) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Account
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = obj.Header.MarshalWithEncoder(encoder); err != nil {
+		return fmt.Errorf("error while encoding \"Header\" field: %w", err)
+	}
+	if err = encoder.WriteBytes(obj.Owner[:], false); err != nil {
+		return fmt.Errorf("error while encoding \"Owner\" field: %w", err)
+	}
+	if err = encoder.WriteUint128(obj.Balance, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Balance\" field: %w", err)
+	}
+	if err = encoder.WriteInt32(obj.Delta, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Delta\" field: %w", err)
+	}
+	if err = encoder.WriteUint64(obj.Big, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Big\" field: %w", err)
+	}
+	if err = encoder.WriteBool(obj.Enabled); err != nil {
+		return fmt.Errorf("error while encoding \"Enabled\" field: %w", err)
+	}
+	if err = encoder.WriteString(obj.Name); err != nil {
+		return fmt.Errorf("error while encoding \"Name\" field: %w", err)
+	}
+	if obj.Memo == nil {
+		if err = encoder.WriteOption(false); err != nil {
+			return fmt.Errorf("error while encoding \"Memo\" field: %w", err)
+		}
+	} else {
+		if err = encoder.WriteOption(true); err != nil {
+			return fmt.Errorf("error while encoding \"Memo\" field: %w", err)
+		}
+		if err = encoder.WriteString(*obj.Memo); err != nil {
+			return fmt.Errorf("error while encoding \"Memo\" field: %w", err)
+		}
+	}
+	if obj.Delegate == nil {
+		if err = encoder.WriteCOption(false); err != nil {
+			return fmt.Errorf("error while encoding \"Delegate\" field: %w", err)
+		}
+	} else {
+		if err = encoder.WriteCOption(true); err != nil {
+			return fmt.Errorf("error while encoding \"Delegate\" field: %w", err)
+		}
+		if err = encoder.WriteBytes((*obj.Delegate)[:], false); err != nil {
+			return fmt.Errorf("error while encoding \"Delegate\" field: %w", err)
+		}
+	}
+	if obj.Limit == 0 {
+		if err = encoder.WriteOption(false); err != nil {
+			return fmt.Errorf("error while encoding \"Limit\" field: %w", err)
+		}
+	} else {
+		if err = encoder.WriteOption(true); err != nil {
+			return fmt.Errorf("error while encoding \"Limit\" field: %w", err)
+		}
+		if err = encoder.WriteUint64(obj.Limit, bin.LE); err != nil {
+			return fmt.Errorf("error while encoding \"Limit\" field: %w", err)
+		}
+	}
+	if obj.Score == 0 {
+		if err = encoder.WriteOption(false); err != nil {
+			return fmt.Errorf("error while encoding \"Score\" field: %w", err)
+		}
+	} else {
+		if err = encoder.WriteOption(true); err != nil {
+			return fmt.Errorf("error while encoding \"Score\" field: %w", err)
+		}
+		if err = encoder.WriteFloat64(obj.Score, bin.LE); err != nil {
+			return fmt.Errorf("error while encoding \"Score\" field: %w", err)
+		}
+	}
+	if err = encoder.WriteUint32(uint32(len(obj.Entries)), bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Entries\" field: %w", err)
+	}
+	for i1 := range obj.Entries {
+		if err = obj.Entries[i1].MarshalWithEncoder(encoder); err != nil {
+			return fmt.Errorf("error while encoding \"Entries\" field: %w", err)
+		}
+	}
+	if err = encoder.WriteUint32(uint32(len(obj.Pointers)), bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Pointers\" field: %w", err)
+	}
+	for i2 := range obj.Pointers {
+		if obj.Pointers[i2] != nil {
+			if err = obj.Pointers[i2].MarshalWithEncoder(encoder); err != nil {
+				return fmt.Errorf("error while encoding \"Pointers\" field: %w", err)
+			}
+		}
+	}
+	if err = encoder.WriteUint32(uint32(len(obj.Data)), bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Data\" field: %w", err)
+	}
+	if err = encoder.WriteBytes(obj.Data[:], false); err != nil {
+		return fmt.Errorf("error while encoding \"Data\" field: %w", err)
+	}
+	for i3 := range obj.Matrix {
+		for i4 := range obj.Matrix[i3] {
+			if err = encoder.WriteInt16(obj.Matrix[i3][i4], bin.LE); err != nil {
+				return fmt.Errorf("error while encoding \"Matrix\" field: %w", err)
+			}
+		}
+	}
+	if obj.Count == 0 {
+		obj.Count = uint8(len(obj.Items))
+	}
+	sizeOfItems := int(obj.Count)
+	if sizeOfItems < 0 {
+		sizeOfItems = 0
+	}
+	if err = encoder.CheckSizeOf(sizeOfItems, "Items", len(obj.Items)); err != nil {
+		return fmt.Errorf("error while encoding \"Count\" field: %w", err)
+	}
+	if err = encoder.WriteUint8(obj.Count); err != nil {
+		return fmt.Errorf("error while encoding \"Count\" field: %w", err)
+	}
+	for i5 := 0; i5 < sizeOfItems; i5++ {
+		if err = encoder.WriteUint32(obj.Items[i5], bin.LE); err != nil {
+			return fmt.Errorf("error while encoding \"Items\" field: %w", err)
+		}
+	}
+	if err = encoder.EncodeField(&obj.Labels, ""); err != nil {
+		return fmt.Errorf("error while encoding \"Labels\" field: %w", err)
+	}
+	if err = encoder.EncodeField(&obj.Tags, `bin:"set"`); err != nil {
+		return fmt.Errorf("error while encoding \"Tags\" field: %w", err)
+	}
+	if err = obj.Action.MarshalWithEncoder(encoder); err != nil {
+		return fmt.Errorf("error while encoding \"Action\" field: %w", err)
+	}
+	if obj.Chain != nil {
+		if err = obj.Chain.MarshalWithEncoder(encoder); err != nil {
+			return fmt.Errorf("error while encoding \"Chain\" field: %w", err)
+		}
+	}
+	if obj.Amount == nil {
+		if err = encoder.WriteOption(false); err != nil {
+			return fmt.Errorf("error while encoding \"Amount\" field: %w", err)
+		}
+	} else {
+		if err = encoder.WriteOption(true); err != nil {
+			return fmt.Errorf("error while encoding \"Amount\" field: %w", err)
+		}
+		if err = encoder.WriteUint128(*obj.Amount, bin.LE); err != nil {
+			return fmt.Errorf("error while encoding \"Amount\" field: %w", err)
+		}
+	}
+	if err = encoder.WriteUint128(obj.Price, bin.BE); err != nil {
+		return fmt.Errorf("error while encoding \"Price\" field: %w", err)
+	}
+	for i6 := range obj.Fees {
+		if err = encoder.WriteUint128(obj.Fees[i6], bin.LE); err != nil {
+			return fmt.Errorf("error while encoding \"Fees\" field: %w", err)
+		}
+	}
+	if err = encoder.WriteUint32(uint32(len(obj.Shares)), bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Shares\" field: %w", err)
+	}
+	for i7 := range obj.Shares {
+		if err = encoder.WriteInt128(obj.Shares[i7], bin.LE); err != nil {
+			return fmt.Errorf("error while encoding \"Shares\" field: %w", err)
+		}
+	}
+	if obj.Last == nil {
+		if err = encoder.WriteOption(false); err != nil {
+			return fmt.Errorf("error while encoding \"Last\" field: %w", err)
+		}
+	} else {
+		if err = encoder.WriteOption(true); err != nil {
+			return fmt.Errorf("error while encoding \"Last\" field: %w", err)
+		}
+		if err = obj.Last.MarshalWithEncoder(encoder); err != nil {
+			return fmt.Errorf("error while encoding \"Last\" field: %w", err)
+		}
+	}
+	if err = encoder.EncodeField(&obj.Any, ""); err != nil {
+		return fmt.Errorf("error while encoding \"Any\" field: %w", err)
+	}
+	if err = encoder.WriteUint16(obj.Ext, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Ext\" field: %w", err)
+	}
+	if err = encoder.WriteString(obj.ExtName); err != nil {
+		return fmt.Errorf("error while encoding \"ExtName\" field: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Account from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Account) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Account
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	var v1 Header
+	if err = v1.UnmarshalWithDecoder(decoder); err != nil {
+		return fmt.Errorf("error while decoding \"Header\" field: %w", err)
+	}
+	obj.Header = v1
+	{
+		b2, err := decoder.ReadNBytes(32)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Owner\" field: %w", err)
+		}
+		copy(obj.Owner[:], b2)
+	}
+	if obj.Balance, err = decoder.ReadUint128(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Balance\" field: %w", err)
+	}
+	if obj.Delta, err = decoder.ReadInt32(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Delta\" field: %w", err)
+	}
+	if obj.Big, err = decoder.ReadUint64(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Big\" field: %w", err)
+	}
+	if obj.Enabled, err = decoder.ReadBool(); err != nil {
+		return fmt.Errorf("error while decoding \"Enabled\" field: %w", err)
+	}
+	if obj.Name, err = decoder.ReadString(); err != nil {
+		return fmt.Errorf("error while decoding \"Name\" field: %w", err)
+	}
+	{
+		isPresent, err := decoder.ReadOption()
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Memo\" field: %w", err)
+		}
+		if !isPresent {
+			obj.Memo = nil
+		} else {
+			if obj.Memo == nil {
+				obj.Memo = new(string)
+			}
+			if *obj.Memo, err = decoder.ReadString(); err != nil {
+				return fmt.Errorf("error while decoding \"Memo\" field: %w", err)
+			}
+		}
+	}
+	{
+		isPresent, err := decoder.ReadCOption()
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Delegate\" field: %w", err)
+		}
+		if !isPresent {
+			obj.Delegate = nil
+		} else {
+			if obj.Delegate == nil {
+				obj.Delegate = new([32]byte)
+			}
+			{
+				b3, err := decoder.ReadNBytes(32)
+				if err != nil {
+					return fmt.Errorf("error while decoding \"Delegate\" field: %w", err)
+				}
+				copy((*obj.Delegate)[:], b3)
+			}
+		}
+	}
+	{
+		isPresent, err := decoder.ReadOption()
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Limit\" field: %w", err)
+		}
+		if !isPresent {
+			var zero uint64
+			obj.Limit = zero
+		} else {
+			if obj.Limit, err = decoder.ReadUint64(bin.LE); err != nil {
+				return fmt.Errorf("error while decoding \"Limit\" field: %w", err)
+			}
+		}
+	}
+	{
+		isPresent, err := decoder.ReadOption()
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Score\" field: %w", err)
+		}
+		if !isPresent {
+			var zero float64
+			obj.Score = zero
+		} else {
+			if obj.Score, err = decoder.ReadFloat64(bin.LE); err != nil {
+				return fmt.Errorf("error while decoding \"Score\" field: %w", err)
+			}
+		}
+	}
+	{
+		n, err := decoder.ReadUint32(bin.LE)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Entries\" field: %w", err)
+		}
+		l4 := int(n)
+		if err = decoder.CheckSliceLength(l4, int(unsafe.Sizeof(obj.Entries[0]))); err != nil {
+			return fmt.Errorf("error while decoding \"Entries\" field: %w", err)
+		}
+		if l4 > 0 {
+			obj.Entries = make([]Entry, l4)
+			for i5 := range obj.Entries {
+				if err = obj.Entries[i5].UnmarshalWithDecoder(decoder); err != nil {
+					return fmt.Errorf("error while decoding \"Entries\" field: %w", err)
+				}
+			}
+		}
+	}
+	{
+		n, err := decoder.ReadUint32(bin.LE)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Pointers\" field: %w", err)
+		}
+		l6 := int(n)
+		if err = decoder.CheckSliceLength(l6, int(unsafe.Sizeof(obj.Pointers[0]))); err != nil {
+			return fmt.Errorf("error while decoding \"Pointers\" field: %w", err)
+		}
+		if l6 > 0 {
+			obj.Pointers = make([]*Entry, l6)
+			for i7 := range obj.Pointers {
+				if obj.Pointers[i7] == nil {
+					obj.Pointers[i7] = new(Entry)
+				}
+				if err = obj.Pointers[i7].UnmarshalWithDecoder(decoder); err != nil {
+					return fmt.Errorf("error while decoding \"Pointers\" field: %w", err)
+				}
+			}
+		}
+	}
+	{
+		n, err := decoder.ReadUint32(bin.LE)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Data\" field: %w", err)
+		}
+		l8 := int(n)
+		if err = decoder.CheckSliceLength(l8, int(unsafe.Sizeof(obj.Data[0]))); err != nil {
+			return fmt.Errorf("error while decoding \"Data\" field: %w", err)
+		}
+		if l8 > 0 {
+			if obj.Data, err = decoder.ReadNBytes(l8); err != nil {
+				return fmt.Errorf("error while decoding \"Data\" field: %w", err)
+			}
+		}
+	}
+	for i9 := range obj.Matrix {
+		for i10 := range obj.Matrix[i9] {
+			if obj.Matrix[i9][i10], err = decoder.ReadInt16(bin.LE); err != nil {
+				return fmt.Errorf("error while decoding \"Matrix\" field: %w", err)
+			}
+		}
+	}
+	if obj.Count, err = decoder.ReadUint8(); err != nil {
+		return fmt.Errorf("error while decoding \"Count\" field: %w", err)
+	}
+	sizeOfItems := int(obj.Count)
+	if sizeOfItems < 0 {
+		sizeOfItems = 0
+	}
+	{
+		l11 := sizeOfItems
+		if err = decoder.CheckSliceLength(l11, int(unsafe.Sizeof(obj.Items[0]))); err != nil {
+			return fmt.Errorf("error while decoding \"Items\" field: %w", err)
+		}
+		if l11 > 0 {
+			obj.Items = make([]uint32, l11)
+			for i12 := range obj.Items {
+				if obj.Items[i12], err = decoder.ReadUint32(bin.LE); err != nil {
+					return fmt.Errorf("error while decoding \"Items\" field: %w", err)
+				}
+			}
+		}
+	}
+	if err = decoder.DecodeField(&obj.Labels, ""); err != nil {
+		return fmt.Errorf("error while decoding \"Labels\" field: %w", err)
+	}
+	if err = decoder.DecodeField(&obj.Tags, `bin:"set"`); err != nil {
+		return fmt.Errorf("error while decoding \"Tags\" field: %w", err)
+	}
+	var v13 Action
+	if err = v13.UnmarshalWithDecoder(decoder); err != nil {
+		return fmt.Errorf("error while decoding \"Action\" field: %w", err)
+	}
+	obj.Action = v13
+	obj.Chain = new(Node)
+	if err = obj.Chain.UnmarshalWithDecoder(decoder); err != nil {
+		return fmt.Errorf("error while decoding \"Chain\" field: %w", err)
+	}
+	{
+		isPresent, err := decoder.ReadOption()
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Amount\" field: %w", err)
+		}
+		if !isPresent {
+			obj.Amount = nil
+		} else {
+			obj.Amount = new(bin.Uint128)
+			if *obj.Amount, err = decoder.ReadUint128(bin.LE); err != nil {
+				return fmt.Errorf("error while decoding \"Amount\" field: %w", err)
+			}
+		}
+	}
+	if obj.Price, err = decoder.ReadUint128(bin.BE); err != nil {
+		return fmt.Errorf("error while decoding \"Price\" field: %w", err)
+	}
+	for i14 := range obj.Fees {
+		if obj.Fees[i14], err = decoder.ReadUint128(bin.LE); err != nil {
+			return fmt.Errorf("error while decoding \"Fees\" field: %w", err)
+		}
+	}
+	{
+		n, err := decoder.ReadUint32(bin.LE)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Shares\" field: %w", err)
+		}
+		l15 := int(n)
+		if err = decoder.CheckSliceLength(l15, int(unsafe.Sizeof(obj.Shares[0]))); err != nil {
+			return fmt.Errorf("error while decoding \"Shares\" field: %w", err)
+		}
+		if l15 > 0 {
+			obj.Shares = make([]bin.Int128, l15)
+			for i16 := range obj.Shares {
+				if obj.Shares[i16], err = decoder.ReadInt128(bin.LE); err != nil {
+					return fmt.Errorf("error while decoding \"Shares\" field: %w", err)
+				}
+			}
+		}
+	}
+	{
+		isPresent, err := decoder.ReadOption()
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Last\" field: %w", err)
+		}
+		if !isPresent {
+			obj.Last = nil
+		} else {
+			obj.Last = new(Header)
+			if err = obj.Last.UnmarshalWithDecoder(decoder); err != nil {
+				return fmt.Errorf("error while decoding \"Last\" field: %w", err)
+			}
+		}
+	}
+	if err = decoder.DecodeField(&obj.Any, ""); err != nil {
+		return fmt.Errorf("error while decoding \"Any\" field: %w", err)
+	}
+	if decoder.HasRemaining() {
+		if obj.Ext, err = decoder.ReadUint16(bin.LE); err != nil {
+			return fmt.Errorf("error while decoding \"Ext\" field: %w", err)
+		}
+	}
+	if decoder.HasRemaining() {
+		if obj.ExtName, err = decoder.ReadString(); err != nil {
+			return fmt.Errorf("error while decoding \"ExtName\" field: %w", err)
+		}
+	}
+	return nil
+}
+
+// MarshalWithEncoder encodes Header with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Header) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Header
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = encoder.WriteUint8(obj.Version); err != nil {
+		return fmt.Errorf("error while encoding \"Version\" field: %w", err)
+	}
+	if err = encoder.WriteUint16(uint16(obj.Kind), bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Kind\" field: %w", err)
+	}
+	for i1 := range obj.Flags {
+		if err = encoder.WriteBool(obj.Flags[i1]); err != nil {
+			return fmt.Errorf("error while encoding \"Flags\" field: %w", err)
+		}
+	}
+	if err = encoder.WriteBytes(obj.Magic[:], false); err != nil {
+		return fmt.Errorf("error while encoding \"Magic\" field: %w", err)
+	}
+	if err = encoder.WriteInt64(int64(obj.Timeout), bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Timeout\" field: %w", err)
+	}
+	if err = encoder.EncodeField(&obj.Symbol, `bin:"fixed=8"`); err != nil {
+		return fmt.Errorf("error while encoding \"Symbol\" field: %w", err)
+	}
+	if err = encoder.EncodeField(&obj.Label, `bin:"cstring"`); err != nil {
+		return fmt.Errorf("error while encoding \"Label\" field: %w", err)
+	}
+	if err = encoder.EncodeField(&obj.Aliases, `bin:"len=u8"`); err != nil {
+		return fmt.Errorf("error while encoding \"Aliases\" field: %w", err)
+	}
+	if err = encoder.EncodeField(&obj.Seq, `bin:"compact"`); err != nil {
+		return fmt.Errorf("error while encoding \"Seq\" field: %w", err)
+	}
+	if err = encoder.EncodeField(&obj.Pinned, `bin:"optional"`); err != nil {
+		return fmt.Errorf("error while encoding \"Pinned\" field: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Header from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Header) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Header
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	if obj.Version, err = decoder.ReadUint8(); err != nil {
+		return fmt.Errorf("error while decoding \"Version\" field: %w", err)
+	}
+	{
+		v1, err := decoder.ReadUint16(bin.LE)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Kind\" field: %w", err)
+		}
+		obj.Kind = Kind(v1)
+	}
+	for i2 := range obj.Flags {
+		if obj.Flags[i2], err = decoder.ReadBool(); err != nil {
+			return fmt.Errorf("error while decoding \"Flags\" field: %w", err)
+		}
+	}
+	{
+		b3, err := decoder.ReadNBytes(4)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Magic\" field: %w", err)
+		}
+		copy(obj.Magic[:], b3)
+	}
+	{
+		v4, err := decoder.ReadInt64(bin.LE)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Timeout\" field: %w", err)
+		}
+		obj.Timeout = time.Duration(v4)
+	}
+	if err = decoder.DecodeField(&obj.Symbol, `bin:"fixed=8"`); err != nil {
+		return fmt.Errorf("error while decoding \"Symbol\" field: %w", err)
+	}
+	if err = decoder.DecodeField(&obj.Label, `bin:"cstring"`); err != nil {
+		return fmt.Errorf("error while decoding \"Label\" field: %w", err)
+	}
+	if err = decoder.DecodeField(&obj.Aliases, `bin:"len=u8"`); err != nil {
+		return fmt.Errorf("error while decoding \"Aliases\" field: %w", err)
+	}
+	if err = decoder.DecodeField(&obj.Seq, `bin:"compact"`); err != nil {
+		return fmt.Errorf("error while decoding \"Seq\" field: %w", err)
+	}
+	if err = decoder.DecodeField(&obj.Pinned, `bin:"optional"`); err != nil {
+		return fmt.Errorf("error while decoding \"Pinned\" field: %w", err)
+	}
+	return nil
+}
+
+// MarshalWithEncoder encodes Entry with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Entry) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Entry
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = encoder.WriteString(obj.Key); err != nil {
+		return fmt.Errorf("error while encoding \"Key\" field: %w", err)
+	}
+	if err = encoder.WriteInt64(obj.Value, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Value\" field: %w", err)
+	}
+	if err = encoder.WriteUint32(uint32(len(obj.Weights)), bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Weights\" field: %w", err)
+	}
+	for i1 := range obj.Weights {
+		if err = encoder.WriteUint16(obj.Weights[i1], bin.LE); err != nil {
+			return fmt.Errorf("error while encoding \"Weights\" field: %w", err)
+		}
+	}
+	if err = encoder.WriteFloat32(obj.Ratio, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Ratio\" field: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Entry from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Entry) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Entry
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	if obj.Key, err = decoder.ReadString(); err != nil {
+		return fmt.Errorf("error while decoding \"Key\" field: %w", err)
+	}
+	if obj.Value, err = decoder.ReadInt64(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Value\" field: %w", err)
+	}
+	{
+		n, err := decoder.ReadUint32(bin.LE)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Weights\" field: %w", err)
+		}
+		l1 := int(n)
+		if err = decoder.CheckSliceLength(l1, int(unsafe.Sizeof(obj.Weights[0]))); err != nil {
+			return fmt.Errorf("error while decoding \"Weights\" field: %w", err)
+		}
+		if l1 > 0 {
+			obj.Weights = make([]uint16, l1)
+			for i2 := range obj.Weights {
+				if obj.Weights[i2], err = decoder.ReadUint16(bin.LE); err != nil {
+					return fmt.Errorf("error while decoding \"Weights\" field: %w", err)
+				}
+			}
+		}
+	}
+	if obj.Ratio, err = decoder.ReadFloat32(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Ratio\" field: %w", err)
+	}
+	return nil
+}
+
+// MarshalWithEncoder encodes Transfer with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Transfer) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Transfer
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = encoder.WriteBytes(obj.To[:], false); err != nil {
+		return fmt.Errorf("error while encoding \"To\" field: %w", err)
+	}
+	if err = encoder.WriteUint64(obj.Amount, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Amount\" field: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Transfer from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Transfer) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Transfer
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	{
+		b1, err := decoder.ReadNBytes(32)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"To\" field: %w", err)
+		}
+		copy(obj.To[:], b1)
+	}
+	if obj.Amount, err = decoder.ReadUint64(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Amount\" field: %w", err)
+	}
+	return nil
+}
+
+// MarshalWithEncoder encodes Action with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Action) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Action
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = encoder.WriteUint8(uint8(obj.Enum)); err != nil {
+		return err
+	}
+	switch obj.Enum {
+	case 0:
+	case 1:
+		if err = obj.Transfer.MarshalWithEncoder(encoder); err != nil {
+			return fmt.Errorf("error while encoding \"Transfer\" field: %w", err)
+		}
+	case 2:
+		if obj.Close != nil {
+			if err = obj.Close.MarshalWithEncoder(encoder); err != nil {
+				return fmt.Errorf("error while encoding \"Close\" field: %w", err)
+			}
+		}
+	case 3:
+		if err = encoder.WriteString(obj.Note); err != nil {
+			return fmt.Errorf("error while encoding \"Note\" field: %w", err)
+		}
+	default:
+		return errors.New("complex enum too large")
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Action from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Action) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Action
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	{
+		v, err := decoder.ReadUint8()
+		if err != nil {
+			return err
+		}
+		obj.Enum = bin.BorshEnum(v)
+	}
+	switch obj.Enum {
+	case 0:
+	case 1:
+		if err = obj.Transfer.UnmarshalWithDecoder(decoder); err != nil {
+			return fmt.Errorf("error while decoding \"Transfer\" field: %w", err)
+		}
+	case 2:
+		if obj.Close == nil {
+			obj.Close = new(Transfer)
+		}
+		if err = obj.Close.UnmarshalWithDecoder(decoder); err != nil {
+			return fmt.Errorf("error while decoding \"Close\" field: %w", err)
+		}
+	case 3:
+		if obj.Note, err = decoder.ReadString(); err != nil {
+			return fmt.Errorf("error while decoding \"Note\" field: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: complex enum variant %d out of %d", bin.ErrInvalidEnumVariant, obj.Enum, 4)
+	}
+	return nil
+}
+
+// MarshalWithEncoder encodes Node with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Node) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Node
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = encoder.WriteUint32(obj.Value, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Value\" field: %w", err)
+	}
+	if obj.Next == nil {
+		if err = encoder.WriteOption(false); err != nil {
+			return fmt.Errorf("error while encoding \"Next\" field: %w", err)
+		}
+	} else {
+		if err = encoder.WriteOption(true); err != nil {
+			return fmt.Errorf("error while encoding \"Next\" field: %w", err)
+		}
+		if err = obj.Next.MarshalWithEncoder(encoder); err != nil {
+			return fmt.Errorf("error while encoding \"Next\" field: %w", err)
+		}
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Node from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Node) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Node
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	if obj.Value, err = decoder.ReadUint32(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Value\" field: %w", err)
+	}
+	{
+		isPresent, err := decoder.ReadOption()
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Next\" field: %w", err)
+		}
+		if !isPresent {
+			obj.Next = nil
+		} else {
+			obj.Next = new(Node)
+			if err = obj.Next.UnmarshalWithDecoder(decoder); err != nil {
+				return fmt.Errorf("error while decoding \"Next\" field: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalWithEncoder encodes Extended with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Extended) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Extended
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = encoder.WriteUint32(obj.Value, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Value\" field: %w", err)
+	}
+	if err = encoder.WriteUint16(obj.Ext, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Ext\" field: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Extended from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Extended) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Extended
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	if obj.Value, err = decoder.ReadUint32(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Value\" field: %w", err)
+	}
+	if decoder.HasRemaining() {
+		if obj.Ext, err = decoder.ReadUint16(bin.LE); err != nil {
+			return fmt.Errorf("error while decoding \"Ext\" field: %w", err)
+		}
+	}
+	return nil
+}
+
+// MarshalWithEncoder encodes Versioned with borsh without using reflection;
+// the other encodings use the reflective encoder.
+func (obj Versioned) MarshalWithEncoder(encoder *bin.Encoder) (err error) {
+	if !encoder.IsBorsh() {
+		type plain Versioned
+		return encoder.Encode((*plain)(&obj))
+	}
+	if err = encoder.WriteUint16(obj.Size, bin.LE); err != nil {
+		return fmt.Errorf("error while encoding \"Size\" field: %w", err)
+	}
+	if err = obj.Inner.MarshalWithEncoder(encoder); err != nil {
+		return fmt.Errorf("error while encoding \"Inner\" field: %w", err)
+	}
+	if err = encoder.WriteUint8(obj.After); err != nil {
+		return fmt.Errorf("error while encoding \"After\" field: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalWithDecoder decodes Versioned from borsh without using reflection;
+// the other encodings use the reflective decoder.
+func (obj *Versioned) UnmarshalWithDecoder(decoder *bin.Decoder) (err error) {
+	if !decoder.IsBorsh() {
+		type plain Versioned
+		return decoder.Decode((*plain)(obj))
+	}
+	if err = decoder.EnterNested(); err != nil {
+		return err
+	}
+	defer decoder.LeaveNested()
+	if obj.Size, err = decoder.ReadUint16(bin.LE); err != nil {
+		return fmt.Errorf("error while decoding \"Size\" field: %w", err)
+	}
+	byteSizeOfInner := int(obj.Size)
+	if byteSizeOfInner < 0 {
+		byteSizeOfInner = 0
+	}
+	{
+		parent1 := decoder
+		decoder, err := parent1.SubDecoder(byteSizeOfInner)
+		if err != nil {
+			return fmt.Errorf("error while decoding \"Inner\" field: %w", err)
+		}
+		var v2 Extended
+		if err = v2.UnmarshalWithDecoder(decoder); err != nil {
+			return fmt.Errorf("error while decoding \"Inner\" field: %w", err)
+		}
+		obj.Inner = v2
+		if err = parent1.CloseSubDecoder(decoder); err != nil {
+			return fmt.Errorf("error while decoding \"Inner\" field: %w", err)
+		}
+	}
+	if obj.After, err = decoder.ReadUint8(); err != nil {
+		return fmt.Errorf("error while decoding \"After\" field: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Bingen generates the MarshalWithEncoder and UnmarshalWithDecoder methods
+// of struct types, which encode them with borsh without using reflection.
+// The generated code honors the same tags as the reflective encoder
+// (`bin:"..."`, `borsh_skip`, `borsh_enum`), and produces the same bytes;
+// the fields it can't encode by itself (e.g. maps, or the types
+// implementing BinaryMarshaler) are encoded with the reflective encoder.
+// The other encodings (bin, compact-u16) use the reflective encoder.
+//
+// Usage:
+//
+//	//go:generate go run github.com/gagliardetto/binary/cmd/bingen -type=Foo,Bar
+//
+// The methods are written to foo_bingen.go (see -output),
+// in the directory of the package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of the struct types to generate the methods of (required)")
+	output := flag.String("output", "", "output file name (default <first type>_bingen.go, in the package directory)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: bingen -type=T[,T...] [-output=file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(names[0])+"_bingen.go")
+	}
+
+	src, err := generate(dir, names, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bingen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "bingen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,30 @@
+// Package invalid holds the types bingen can't generate the methods of.
+package invalid
+
+import bin "github.com/gagliardetto/binary"
+
+type Unpacked struct {
+	Ext   uint8 `bin:"binary_extension"`
+	Value uint8
+}
+
+type RestNotLast struct {
+	Data  []byte `bin:"rest"`
+	Value uint8
+}
+
+type Inner struct {
+	Value uint8
+}
+
+type Enum struct {
+	Enum  bin.BorshEnum `borsh_enum:"true"`
+	Inner Inner
+}
+
+type SizeOf struct {
+	Len  string `bin:"sizeof=Data"`
+	Data []byte
+}
+
+type Alias uint8
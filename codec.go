@@ -0,0 +1,432 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Codec is the wire format of an Encoding: the parts of the layout of values
+// that differ between formats. Values are walked by the reflective decoder
+// and encoder of the package, which read and write the primitives
+// (integers, bytes, ...) with the Decoder and Encoder methods,
+// honor the `bin` tags, and call the Codec for the rest.
+//
+// Codec only describes the formats that fit this walker, possibly with the
+// help of IntCodec and OptionBoolCodec. The others (e.g. EncodingBin,
+// EncodingCompactU16 and EncodingRLP, which don't have the same layout of
+// arrays or integers) walk the values themselves: see WalkerCodec.
+//
+// New formats are added with RegisterEncoding.
+type Codec interface {
+	// ReadLength reads the length prefix of a string, slice or map
+	// (unless set by the `len` tag of the field).
+	ReadLength(dec *Decoder) (int, error)
+	WriteLength(enc *Encoder, length int) error
+
+	// ReadOptionTag reads the tag of a field with the `optional` (or `option`) tag,
+	// which tells whether the value is present.
+	// The 4-byte tag of `coption` fields is the same in all the encodings.
+	ReadOptionTag(dec *Decoder) (isPresent bool, err error)
+	WriteOptionTag(enc *Encoder, isPresent bool) error
+
+	// ReadEnumTag reads the variant index of a complex enum (see BorshEnum).
+	ReadEnumTag(dec *Decoder) (variant int, err error)
+	WriteEnumTag(enc *Encoder, variant int) error
+
+	// MapOrder is the order of the entries of maps; strict decoders reject
+	// the maps in another order. The entries of sets (see the `set` tag)
+	// are always sorted, by their keys unless MapOrder is MapOrderEncodedKeys.
+	MapOrder() MapOrder
+
+	// DecodeStruct decodes the struct rv: decodeFields decodes its fields, in order,
+	// with the provided decoder (e.g. dec, or a sub-decoder of the bytes of the struct).
+	DecodeStruct(dec *Decoder, rv reflect.Value, decodeFields func(*Decoder, reflect.Value) error) error
+	// EncodeStruct encodes the struct rv: encodeFields encodes its fields, in order,
+	// with the provided encoder (e.g. enc, or a buffered encoder of the same encoding).
+	EncodeStruct(enc *Encoder, rv reflect.Value, encodeFields func(*Encoder, reflect.Value) error) error
+}
+
+// MapOrder is the order of the entries of the maps of an encoding.
+type MapOrder int
+
+const (
+	// MapOrderNone keeps the entries in the iteration order of the map.
+	MapOrderNone MapOrder = iota
+	// MapOrderKeys sorts the entries in increasing order of their keys
+	// (like the BTreeMap of Rust).
+	MapOrderKeys
+	// MapOrderEncodedKeys sorts the entries in increasing order
+	// of the encoding of their keys, compared as byte strings (e.g. BCS).
+	MapOrderEncodedKeys
+)
+
+// IntCodec is implemented by the codecs whose integers of 16 bits or more
+// aren't fixed-size little-endian values (e.g. varints): the integers of these
+// sizes, including Uint128 and Int128 values, are read and written by the codec.
+// bits is the size of the integer: 16, 32, 64 or 128.
+type IntCodec interface {
+	// ReadUint reads an unsigned integer, which must fit in bits bits.
+	ReadUint(dec *Decoder, bits int) (Uint128, error)
+	WriteUint(enc *Encoder, v Uint128, bits int) error
+	// ReadInt reads a signed integer, which must fit in bits bits.
+	ReadInt(dec *Decoder, bits int) (Int128, error)
+	WriteInt(enc *Encoder, v Int128, bits int) error
+}
+
+// OptionBoolCodec is implemented by the codecs that encode an optional bool
+// (a *bool with the `optional` tag) in a single byte, instead of an option tag
+// followed by the bool (e.g. SCALE).
+type OptionBoolCodec interface {
+	ReadOptionBool(dec *Decoder) (isPresent bool, value bool, err error)
+	WriteOptionBool(enc *Encoder, isPresent bool, value bool) error
+}
+
+// WalkerCodec is implemented by the codecs that walk the values with their
+// own reflective decoder and encoder, instead of the ones of the package
+// (e.g. EncodingBin, EncodingCompactU16 and EncodingRLP). Decoder.Decode,
+// Encoder.Encode and the Field methods then only call DecodeValue and
+// EncodeValue: the other methods of the Codec are only called by the
+// walker, if at all.
+type WalkerCodec interface {
+	// DecodeValue decodes rv, a pointer (as passed to Decoder.Decode) or
+	// a settable value, the way a struct field with the provided tag is decoded;
+	// the tag is empty for the top-level values.
+	DecodeValue(dec *Decoder, rv reflect.Value, tag reflect.StructTag) error
+	// EncodeValue encodes rv the way a struct field with the provided tag is encoded.
+	EncodeValue(enc *Encoder, rv reflect.Value, tag reflect.StructTag) error
+}
+
+type registeredCodec struct {
+	name  string
+	codec Codec
+}
+
+var (
+	// codecs holds the []registeredCodec of the encodings, indexed by Encoding;
+	// it's replaced, not modified, by RegisterEncoding.
+	codecs     = builtinCodecs()
+	codecsLock sync.Mutex
+)
+
+func builtinCodecs() *atomic.Value {
+	v := new(atomic.Value)
+	v.Store([]registeredCodec{
+		EncodingBin:        {name: "Bin", codec: binCodec{}},
+		EncodingCompactU16: {name: "CompactU16", codec: compactU16Codec{}},
+		EncodingBorsh:      {name: "Borsh", codec: borshCodec{}},
+	})
+	return v
+}
+
+// RegisterEncoding adds an encoding with the provided name and wire format,
+// and returns it: it can be used with NewDecoderWithEncoding,
+// NewEncoderWithEncoding and the other functions taking an Encoding.
+// RegisterEncoding is meant to be called when initializing a package;
+// it panics if the name is empty or already registered.
+func RegisterEncoding(name string, codec Codec) Encoding {
+	if name == "" || codec == nil {
+		panic("bin: RegisterEncoding with an empty name or a nil codec")
+	}
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	registered := codecs.Load().([]registeredCodec)
+	for _, c := range registered {
+		if c.name == name {
+			panic(fmt.Sprintf("bin: encoding %q registered twice", name))
+		}
+	}
+	updated := make([]registeredCodec, len(registered), len(registered)+1)
+	copy(updated, registered)
+	codecs.Store(append(updated, registeredCodec{name: name, codec: codec}))
+	return Encoding(len(registered))
+}
+
+// lookupCodec returns the registered codec of enc, if any.
+func lookupCodec(enc Encoding) (registeredCodec, bool) {
+	registered := codecs.Load().([]registeredCodec)
+	if enc < 0 || int(enc) >= len(registered) {
+		return registeredCodec{}, false
+	}
+	return registered[enc], true
+}
+
+// codec returns the wire format of enc; it panics if enc isn't registered.
+func (enc Encoding) codec() Codec {
+	c, ok := lookupCodec(enc)
+	if !ok {
+		panic(fmt.Errorf("encoding not implemented: %d", int(enc)))
+	}
+	return c.codec
+}
+
+// intCodec returns the IntCodec of enc, if it has one.
+func (enc Encoding) intCodec() (IntCodec, bool) {
+	switch enc {
+	case EncodingBin, EncodingCompactU16, EncodingBorsh:
+		// Fast path: fixed-size integers.
+		return nil, false
+	}
+	c, ok := enc.codec().(IntCodec)
+	return c, ok
+}
+
+// isFixedSizeUint reports whether the unsigned integers of kind k
+// are fixed-size values in enc, so that arrays of them are read and written at once.
+func (enc Encoding) isFixedSizeUint(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint8:
+		return true
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, ok := enc.intCodec()
+		return !ok
+	}
+	return false
+}
+
+// intBits returns the size in bits of the integers of kind k
+// that are read and written by an IntCodec, or 0.
+func intBits(k reflect.Kind) int {
+	switch k {
+	case reflect.Int16, reflect.Uint16:
+		return 16
+	case reflect.Int32, reflect.Uint32:
+		return 32
+	case reflect.Int64, reflect.Uint64:
+		return 64
+	}
+	return 0
+}
+
+// decodeInt decodes rv, an integer of bits bits, with c.
+func (dec *Decoder) decodeInt(c IntCodec, rv reflect.Value, bits int) error {
+	switch rv.Kind() {
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := c.ReadUint(dec, bits)
+		if err != nil {
+			return err
+		}
+		rv.SetUint(v.Lo)
+	default:
+		v, err := c.ReadInt(dec, bits)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(v.Lo))
+	}
+	return nil
+}
+
+// encodeInt encodes rv, an integer of bits bits, with c.
+func (e *Encoder) encodeInt(c IntCodec, rv reflect.Value, bits int) error {
+	switch rv.Kind() {
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return c.WriteUint(e, Uint128{Lo: rv.Uint()}, bits)
+	default:
+		return c.WriteInt(e, NewInt128FromInt64(rv.Int()), bits)
+	}
+}
+
+// decodeValue decodes rv, a value without tag, with the walker of the encoding
+// of dec: the one of its WalkerCodec, or decodeBorsh.
+func (dec *Decoder) decodeValue(rv reflect.Value) error {
+	if w, ok := dec.encoding.codec().(WalkerCodec); ok {
+		return w.DecodeValue(dec, rv, "")
+	}
+	return dec.decodeBorsh(rv, nil)
+}
+
+// encodeValue encodes rv, a value without tag, with the walker of the encoding
+// of e: the one of its WalkerCodec, or encodeBorsh.
+func (e *Encoder) encodeValue(rv reflect.Value) error {
+	if w, ok := e.encoding.codec().(WalkerCodec); ok {
+		return w.EncodeValue(e, rv, "")
+	}
+	return e.encodeBorsh(rv, nil)
+}
+
+// decodeStructFields and the other field walkers are the decodeFields and
+// encodeFields functions passed to Codec.DecodeStruct and Codec.EncodeStruct.
+func decodeStructFields(dec *Decoder, rv reflect.Value) error {
+	return dec.decodeStructBorsh(rv.Type(), rv)
+}
+
+func encodeStructFields(e *Encoder, rv reflect.Value) error {
+	return e.encodeStructBorsh(rv.Type(), rv)
+}
+
+func decodeStructFieldsBin(dec *Decoder, rv reflect.Value) error {
+	return dec.decodeStructBin(rv.Type(), rv)
+}
+
+func encodeStructFieldsBin(e *Encoder, rv reflect.Value) error {
+	return e.encodeStructBin(rv.Type(), rv)
+}
+
+func decodeStructFieldsRLP(dec *Decoder, rv reflect.Value) error {
+	return dec.decodeStructRLP(rv.Type(), rv)
+}
+
+func encodeStructFieldsRLP(e *Encoder, rv reflect.Value) error {
+	return e.encodeStructRLP(rv.Type(), rv)
+}
+
+func decodeStructFieldsCompactU16(dec *Decoder, rv reflect.Value) error {
+	return dec.decodeStructCompactU16(rv.Type(), rv)
+}
+
+func encodeStructFieldsCompactU16(e *Encoder, rv reflect.Value) error {
+	return e.encodeStructCompactU16(rv.Type(), rv)
+}
+
+// fieldsCodec has the struct layout of the built-in encodings:
+// the fields one after the other.
+type fieldsCodec struct{}
+
+func (fieldsCodec) DecodeStruct(dec *Decoder, rv reflect.Value, decodeFields func(*Decoder, reflect.Value) error) error {
+	return decodeFields(dec, rv)
+}
+
+func (fieldsCodec) EncodeStruct(enc *Encoder, rv reflect.Value, encodeFields func(*Encoder, reflect.Value) error) error {
+	return encodeFields(enc, rv)
+}
+
+// byteTagsCodec has the 1-byte option and enum tags of the built-in encodings.
+type byteTagsCodec struct{}
+
+func (byteTagsCodec) ReadOptionTag(dec *Decoder) (bool, error) {
+	return dec.ReadOption()
+}
+
+func (byteTagsCodec) WriteOptionTag(enc *Encoder, isPresent bool) error {
+	return enc.WriteOption(isPresent)
+}
+
+func (byteTagsCodec) ReadEnumTag(dec *Decoder) (int, error) {
+	variant, err := dec.ReadUint8()
+	return int(variant), err
+}
+
+func (byteTagsCodec) WriteEnumTag(enc *Encoder, variant int) error {
+	if variant < 0 || variant > 0xFF {
+		return fmt.Errorf("%w: enum variant %d in a 1-byte tag", ErrInvalidEnumVariant, variant)
+	}
+	return enc.WriteUint8(uint8(variant))
+}
+
+// borshCodec is the wire format of EncodingBorsh:
+// u32 lengths and sorted maps.
+type borshCodec struct {
+	fieldsCodec
+	byteTagsCodec
+}
+
+func (borshCodec) ReadLength(dec *Decoder) (int, error) {
+	val, err := dec.ReadUint32(LE)
+	if err != nil {
+		return 0, err
+	}
+	if val > 0x7FFF_FFFF {
+		return 0, fmt.Errorf("%w: %d", ErrLengthTooLarge, val)
+	}
+	return int(val), nil
+}
+
+func (borshCodec) WriteLength(enc *Encoder, length int) error {
+	return enc.WriteUint32(uint32(length), LE)
+}
+
+func (borshCodec) MapOrder() MapOrder { return MapOrderKeys }
+
+// binCodec is the wire format of EncodingBin: uvarint lengths
+// and u32 option tags.
+type binCodec struct {
+	fieldsCodec
+	byteTagsCodec
+}
+
+func (binCodec) ReadLength(dec *Decoder) (int, error) {
+	val, err := dec.ReadUvarint64()
+	if err != nil {
+		return 0, err
+	}
+	if val > 0x7FFF_FFFF {
+		return 0, fmt.Errorf("%w: %d", ErrLengthTooLarge, val)
+	}
+	return int(val), nil
+}
+
+func (binCodec) WriteLength(enc *Encoder, length int) error {
+	return enc.WriteUVarInt(length)
+}
+
+func (binCodec) MapOrder() MapOrder { return MapOrderNone }
+
+// ReadOptionTag reads the option tag of EncodingBin, a u32 (little endian).
+func (binCodec) ReadOptionTag(dec *Decoder) (bool, error) {
+	tag, err := dec.ReadUint32(LE)
+	if err != nil {
+		return false, fmt.Errorf("decode: read option, %w", err)
+	}
+	if dec.strict && tag > 1 {
+		return false, fmt.Errorf("decode: read option, %w: %d", ErrInvalidOptionByte, tag)
+	}
+	return tag != 0, nil
+}
+
+// WriteOptionTag writes the option tag of EncodingBin, a u32 (little endian).
+func (binCodec) WriteOptionTag(enc *Encoder, isPresent bool) error {
+	var tag uint32
+	if isPresent {
+		tag = 1
+	}
+	return enc.WriteUint32(tag, LE)
+}
+
+func (binCodec) DecodeValue(dec *Decoder, rv reflect.Value, tag reflect.StructTag) error {
+	return dec.decodeBin(rv, fieldOption(tag))
+}
+
+func (binCodec) EncodeValue(enc *Encoder, rv reflect.Value, tag reflect.StructTag) error {
+	return enc.encodeBin(rv, fieldOption(tag))
+}
+
+// compactU16Codec is the wire format of EncodingCompactU16: compact-u16 lengths.
+type compactU16Codec struct {
+	fieldsCodec
+	byteTagsCodec
+}
+
+func (compactU16Codec) ReadLength(dec *Decoder) (int, error) {
+	return dec.ReadCompactU16()
+}
+
+func (compactU16Codec) WriteLength(enc *Encoder, length int) error {
+	return enc.WriteCompactU16(length)
+}
+
+func (compactU16Codec) MapOrder() MapOrder { return MapOrderNone }
+
+func (compactU16Codec) DecodeValue(dec *Decoder, rv reflect.Value, tag reflect.StructTag) error {
+	return dec.decodeCompactU16(rv, fieldOption(tag))
+}
+
+func (compactU16Codec) EncodeValue(enc *Encoder, rv reflect.Value, tag reflect.StructTag) error {
+	return enc.encodeCompactU16(rv, fieldOption(tag))
+}
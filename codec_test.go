@@ -0,0 +1,283 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordCodec is a wire format with u16 lengths, 'Y'/'N' option tags,
+// big-endian u16 enum tags, and structs prefixed with their size in bytes.
+type recordCodec struct{}
+
+func (recordCodec) ReadLength(dec *Decoder) (int, error) {
+	l, err := dec.ReadUint16(LE)
+	return int(l), err
+}
+
+func (recordCodec) WriteLength(enc *Encoder, length int) error {
+	return enc.WriteUint16(uint16(length), LE)
+}
+
+func (recordCodec) ReadOptionTag(dec *Decoder) (bool, error) {
+	b, err := dec.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	switch b {
+	case 'Y':
+		return true, nil
+	case 'N':
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: %q", ErrInvalidOptionByte, b)
+	}
+}
+
+func (recordCodec) WriteOptionTag(enc *Encoder, isPresent bool) error {
+	if isPresent {
+		return enc.WriteByte('Y')
+	}
+	return enc.WriteByte('N')
+}
+
+func (recordCodec) ReadEnumTag(dec *Decoder) (int, error) {
+	variant, err := dec.ReadUint16(BE)
+	return int(variant), err
+}
+
+func (recordCodec) WriteEnumTag(enc *Encoder, variant int) error {
+	return enc.WriteUint16(uint16(variant), BE)
+}
+
+func (recordCodec) MapOrder() MapOrder { return MapOrderNone }
+
+func (recordCodec) DecodeStruct(dec *Decoder, rv reflect.Value, decodeFields func(*Decoder, reflect.Value) error) error {
+	size, err := dec.ReadUint8()
+	if err != nil {
+		return err
+	}
+	sub, err := dec.SubDecoder(int(size))
+	if err != nil {
+		return err
+	}
+	if err = decodeFields(sub, rv); err != nil {
+		return err
+	}
+	return dec.CloseSubDecoder(sub)
+}
+
+func (recordCodec) EncodeStruct(enc *Encoder, rv reflect.Value, encodeFields func(*Encoder, reflect.Value) error) error {
+	fields := NewBufferedEncoderWithEncoding(enc.Encoding())
+	if err := encodeFields(fields, rv); err != nil {
+		return err
+	}
+	if err := enc.WriteUint8(uint8(len(fields.Bytes()))); err != nil {
+		return err
+	}
+	return enc.WriteBytes(fields.Bytes(), false)
+}
+
+var encodingRecord = RegisterEncoding("Record", recordCodec{})
+
+type recordInner struct {
+	X uint8
+}
+
+type recordVariant struct {
+	Enum BorshEnum `borsh_enum:"true"`
+	A    EmptyVariant
+	B    uint16
+}
+
+type recordFields struct {
+	Name    string
+	Values  []uint16
+	Inner   recordInner
+	Opt     *uint8 `bin:"optional"`
+	COpt    *uint8 `bin:"coption"`
+	Variant recordVariant
+	Map     map[uint8]uint8
+	Set     map[uint8]struct{} `bin:"set"`
+	Prefix  []byte             `bin:"len=u8"`
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	require.Equal(t, "Record", encodingRecord.String())
+	require.Equal(t, "Borsh", EncodingBorsh.String())
+	require.Equal(t, "", Encoding(-1).String())
+	require.Panics(t, func() { RegisterEncoding("Record", recordCodec{}) })
+	require.Panics(t, func() { RegisterEncoding("", recordCodec{}) })
+
+	five := uint8(5)
+	v := recordFields{
+		Name:    "ab",
+		Values:  []uint16{1},
+		Inner:   recordInner{X: 7},
+		Opt:     &five,
+		Variant: recordVariant{Enum: 1, B: 0x0102},
+		Map:     map[uint8]uint8{1: 2},
+		Set:     map[uint8]struct{}{4: {}, 3: {}},
+		Prefix:  []byte{9},
+	}
+	want := concatByteSlices(
+		[]byte{31},                  // size of recordFields
+		[]byte{2, 0, 'a', 'b'},      // Name
+		[]byte{1, 0, 1, 0},          // Values
+		[]byte{1, 7},                // Inner
+		[]byte{'Y', 5},              // Opt
+		[]byte{0, 0, 0, 0},          // COpt
+		[]byte{4, 0, 1, 0x02, 0x01}, // Variant
+		[]byte{1, 0, 1, 2},          // Map
+		[]byte{2, 0, 3, 4},          // Set
+		[]byte{1, 9},                // Prefix
+	)
+
+	data, err := marshalWithEncoding(&v, encodingRecord)
+	require.NoError(t, err)
+	require.Equal(t, want, data)
+
+	var got recordFields
+	require.NoError(t, NewDecoderWithEncoding(data, encodingRecord).Decode(&got))
+	require.Equal(t, v, got)
+
+	// The decoders and encoders of the generated code take the same path:
+	enc := NewBufferedEncoderWithEncoding(encodingRecord)
+	require.NoError(t, enc.EncodeField(&v.Opt, `bin:"optional"`))
+	require.Equal(t, []byte{'Y', 5}, enc.Bytes())
+	var opt *uint8
+	require.NoError(t, NewDecoderWithEncoding([]byte{'N'}, encodingRecord).DecodeField(&opt, `bin:"optional"`))
+	require.Nil(t, opt)
+
+	// The errors of the codec are returned:
+	err = NewDecoderWithEncoding([]byte{2, 'X'}, encodingRecord).Decode(&recordInner{})
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+	err = NewDecoderWithEncoding([]byte{1}, encodingRecord).DecodeField(&opt, `bin:"optional"`)
+	require.True(t, errors.Is(err, ErrInvalidOptionByte), err)
+	err = NewDecoderWithEncoding([]byte{2, 0, 2}, encodingRecord).Decode(&recordVariant{})
+	require.True(t, errors.Is(err, ErrInvalidEnumVariant), err)
+}
+
+// jsonCodec is a WalkerCodec: its values are JSON documents prefixed with
+// their u16 length; the tags are ignored.
+type jsonCodec struct {
+	recordCodec
+}
+
+func (jsonCodec) DecodeValue(dec *Decoder, rv reflect.Value, tag reflect.StructTag) error {
+	data, err := dec.ReadByteSlice()
+	if err != nil {
+		return err
+	}
+	if rv.Kind() != reflect.Ptr {
+		rv = rv.Addr()
+	}
+	return json.Unmarshal(data, rv.Interface())
+}
+
+func (jsonCodec) EncodeValue(enc *Encoder, rv reflect.Value, tag reflect.StructTag) error {
+	data, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return err
+	}
+	return enc.WriteBytes(data, true)
+}
+
+var encodingJSON = RegisterEncoding("JSON", jsonCodec{})
+
+func TestRegisterEncoding_walker(t *testing.T) {
+	v := recordInner{X: 7}
+	want := append([]byte{7, 0}, `{"X":7}`...)
+
+	data, err := marshalWithEncoding(&v, encodingJSON)
+	require.NoError(t, err)
+	require.Equal(t, want, data)
+
+	var got recordInner
+	require.NoError(t, NewDecoderWithEncoding(data, encodingJSON).Decode(&got))
+	require.Equal(t, v, got)
+
+	// The Field methods call the walker too:
+	enc := NewBufferedEncoderWithEncoding(encodingJSON)
+	require.NoError(t, enc.EncodeField(&v, `bin:"optional"`))
+	require.Equal(t, want, enc.Bytes())
+	got = recordInner{}
+	require.NoError(t, NewDecoderWithEncoding(data, encodingJSON).DecodeField(&got, `bin:"optional"`))
+	require.Equal(t, v, got)
+}
+
+// recordBoolCodec is recordCodec, with optional bools in a single
+// 'N', 'F' or 'T' byte.
+type recordBoolCodec struct {
+	recordCodec
+}
+
+func (recordBoolCodec) ReadOptionBool(dec *Decoder) (bool, bool, error) {
+	b, err := dec.ReadByte()
+	if err != nil {
+		return false, false, err
+	}
+	switch b {
+	case 'N':
+		return false, false, nil
+	case 'F':
+		return true, false, nil
+	case 'T':
+		return true, true, nil
+	default:
+		return false, false, fmt.Errorf("%w: %q", ErrInvalidOptionByte, b)
+	}
+}
+
+func (recordBoolCodec) WriteOptionBool(enc *Encoder, isPresent bool, value bool) error {
+	switch {
+	case !isPresent:
+		return enc.WriteByte('N')
+	case value:
+		return enc.WriteByte('T')
+	default:
+		return enc.WriteByte('F')
+	}
+}
+
+var encodingRecordBool = RegisterEncoding("RecordBool", recordBoolCodec{})
+
+type recordOptionBool struct {
+	A *bool  `bin:"optional"`
+	B *bool  `bin:"optional"`
+	C *uint8 `bin:"optional"`
+}
+
+func TestRegisterEncoding_optionBool(t *testing.T) {
+	yes := true
+	v := recordOptionBool{A: &yes}
+
+	data, err := marshalWithEncoding(&v, encodingRecordBool)
+	require.NoError(t, err)
+	require.Equal(t, []byte{3, 'T', 'N', 'N'}, data)
+
+	var got recordOptionBool
+	require.NoError(t, NewDecoderWithEncoding(data, encodingRecordBool).Decode(&got))
+	require.Equal(t, v, got)
+
+	err = NewDecoderWithEncoding([]byte{3, 'Y', 'N', 'N'}, encodingRecordBool).Decode(&got)
+	require.True(t, errors.Is(err, ErrInvalidOptionByte), err)
+}
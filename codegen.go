@@ -0,0 +1,122 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// The methods in this file are used by the MarshalWithEncoder and
+// UnmarshalWithDecoder methods generated by cmd/bingen,
+// for the fields that they don't encode by themselves.
+
+// fieldTagCache holds the parsed struct tags, by tag.
+var fieldTagCache sync.Map
+
+func cachedFieldTag(tag reflect.StructTag) *fieldTag {
+	if t, ok := fieldTagCache.Load(tag); ok {
+		return t.(*fieldTag)
+	}
+	t, _ := fieldTagCache.LoadOrStore(tag, parseFieldTag(tag))
+	return t.(*fieldTag)
+}
+
+// fieldOption returns the options of a struct field with the provided tag;
+// nil (the defaults of the walkers) if the tag is empty.
+func fieldOption(tag reflect.StructTag) *option {
+	if tag == "" {
+		return nil
+	}
+	fieldTag := cachedFieldTag(tag)
+	return &option{
+		is_OptionalField:  fieldTag.Option,
+		is_COptionalField: fieldTag.COption,
+		Order:             fieldTag.Order,
+		FixedLayout:       fieldTag.FixedLayout,
+		FixedSize:         fieldTag.FixedSize,
+		CString:           fieldTag.CString,
+		LenPrefix:         fieldTag.LenPrefix,
+		Rest:              fieldTag.Rest,
+		Compact:           fieldTag.Compact,
+		is_SetField:       fieldTag.Set,
+	}
+}
+
+// EncodeField encodes the value pointed to by v the way the reflective
+// encoder encodes a struct field with the provided tag (e.g. `bin:"optional"`).
+func (e *Encoder) EncodeField(v interface{}, tag reflect.StructTag) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("encode field: expected a non-nil pointer, got %T", v)
+	}
+	rv = rv.Elem()
+
+	if w, ok := e.encoding.codec().(WalkerCodec); ok {
+		return w.EncodeValue(e, rv, tag)
+	}
+	return e.encodeBorsh(rv, fieldOption(tag))
+}
+
+// DecodeField decodes into the value pointed to by v the way the reflective
+// decoder decodes a struct field with the provided tag (e.g. `bin:"optional"`).
+func (dec *Decoder) DecodeField(v interface{}, tag reflect.StructTag) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidDecoderError{reflect.TypeOf(v)}
+	}
+	rv = rv.Elem()
+
+	if w, ok := dec.encoding.codec().(WalkerCodec); ok {
+		return w.DecodeValue(dec, rv, tag)
+	}
+	opt := fieldOption(tag)
+	if opt == nil {
+		opt = newDefaultOption()
+	}
+	if !opt.Compact {
+		// Like decodeStructBorsh:
+		rt := rv.Type()
+		ptrImplements := reflect.PtrTo(rt).Implements(unmarshalableType)
+		if ptrImplements || (rt.Kind() == reflect.Ptr && rt.Implements(unmarshalableType)) {
+			return dec.decodeUnmarshalerField(rv, rt, ptrImplements, opt)
+		}
+	}
+	return dec.decodeBorsh(rv, opt)
+}
+
+// CheckSliceLength checks the length l of a slice whose elements take
+// elemSize bytes in memory, before the slice is allocated:
+// l must be within the limits of the decoder, and
+// there must be at least l bytes left to decode.
+func (dec *Decoder) CheckSliceLength(l int, elemSize int) error {
+	if err := dec.checkCollectionLength(l); err != nil {
+		return err
+	}
+	if err := dec.trackAllocation(l, elemSize); err != nil {
+		return err
+	}
+	if l > 0 && !dec.hasAtLeast(l) {
+		return fmt.Errorf("%w: slice of %d elements", ErrShortBuffer, l)
+	}
+	return nil
+}
+
+// CheckSizeOf checks that size, the value of a `sizeof=` field,
+// is the length of the slice target (see ErrSizeOfMismatch).
+func (e *Encoder) CheckSizeOf(size int, target string, length int) error {
+	return checkSizeOf(size, target, length)
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeField(t *testing.T) {
+	type S struct {
+		A *Uint128        `bin:"optional"`
+		B map[uint16]bool `bin:"optional"`
+		C []string
+	}
+	x := S{
+		A: &Uint128{Lo: 1},
+		B: map[uint16]bool{2: true, 1: false},
+		C: []string{"c"},
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		want := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(want, encoding).Encode(x), encoding)
+
+		got := new(bytes.Buffer)
+		enc := NewEncoderWithEncoding(got, encoding)
+		require.NoError(t, enc.EncodeField(&x.A, `bin:"optional"`))
+		if encoding.IsBorsh() {
+			// The other encodings don't order the maps.
+			require.NoError(t, enc.EncodeField(&x.B, `bin:"optional"`))
+			require.NoError(t, enc.EncodeField(&x.C, ``))
+			assert.Equal(t, want.Bytes(), got.Bytes(), encoding)
+		}
+
+		var y S
+		dec := NewDecoderWithEncoding(want.Bytes(), encoding)
+		require.NoError(t, dec.DecodeField(&y.A, `bin:"optional"`), encoding)
+		require.NoError(t, dec.DecodeField(&y.B, `bin:"optional"`), encoding)
+		require.NoError(t, dec.DecodeField(&y.C, ``), encoding)
+		assert.Equal(t, x, y, encoding)
+		assert.Equal(t, 0, dec.Remaining(), encoding)
+	}
+
+	enc := NewBorshEncoder(new(bytes.Buffer))
+	require.Error(t, enc.EncodeField(x, ``))
+	var nilPtr *S
+	require.Error(t, enc.EncodeField(nilPtr, ``))
+	dec := NewBorshDecoder(nil)
+	require.Error(t, dec.DecodeField(x, ``))
+}
+
+func TestDecoder_CheckSliceLength(t *testing.T) {
+	dec := NewBorshDecoder([]byte{1, 2, 3})
+	require.NoError(t, dec.CheckSliceLength(0, 8))
+	require.NoError(t, dec.CheckSliceLength(3, 8))
+
+	err := dec.CheckSliceLength(4, 1)
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+
+	dec.SetOptions(DecoderOptions{MaxCollectionLength: 2})
+	err = dec.CheckSliceLength(3, 1)
+	require.True(t, errors.Is(err, ErrLimitExceeded), err)
+}
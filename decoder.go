@@ -18,6 +18,7 @@
 package bin
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
@@ -43,6 +44,7 @@ var TypeSize = struct {
 	Uint32  int
 	Uint64  int
 	Uint128 int
+	Uint256 int
 
 	Float32 int
 	Float64 int
@@ -61,6 +63,7 @@ var TypeSize = struct {
 	Uint32:  4,
 	Uint64:  8,
 	Uint128: 16,
+	Uint256: 32,
 
 	Float32: 4,
 	Float64: 8,
@@ -73,15 +76,36 @@ type Decoder struct {
 	data []byte
 	pos  int
 
+	// stream is set when decoding from an io.Reader;
+	// data then only holds the currently buffered window,
+	// and offset is the position of data[0] in the stream.
+	// On sub-decoders, offset is the position of data[0] in the parent.
+	stream *streamSource
+	offset int
+
 	currentFieldOpt *option
 
 	encoding Encoding
+
+	options DecoderOptions
+	// strict rejects non-canonical inputs (see SetStrict).
+	strict bool
+	// depth and allocated track the usage of the options' limits.
+	depth     int
+	allocated int
+	// calls counts the Decode calls in progress: Decode is called again
+	// by the UnmarshalWithDecoder methods, within the top-level call.
+	calls int
 }
 
 // Reset resets the decoder to decode a new message.
 func (dec *Decoder) Reset(data []byte) {
 	dec.data = data
 	dec.pos = 0
+	dec.stream = nil
+	dec.offset = 0
+	dec.depth = 0
+	dec.allocated = 0
 	dec.currentFieldOpt = nil
 }
 
@@ -107,6 +131,11 @@ func NewDecoderWithEncoding(data []byte, enc Encoding) *Decoder {
 	}
 }
 
+// Encoding returns the encoding scheme of the decoder.
+func (dec *Decoder) Encoding() Encoding {
+	return dec.encoding
+}
+
 // SetEncoding sets the encoding scheme to use for decoding.
 func (dec *Decoder) SetEncoding(enc Encoding) {
 	dec.encoding = enc
@@ -125,16 +154,39 @@ func NewCompactU16Decoder(data []byte) *Decoder {
 }
 
 func (dec *Decoder) Decode(v interface{}) (err error) {
-	switch dec.encoding {
-	case EncodingBin:
-		return dec.decodeWithOptionBin(v, nil)
-	case EncodingBorsh:
-		return dec.decodeWithOptionBorsh(v, nil)
-	case EncodingCompactU16:
-		return dec.decodeWithOptionCompactU16(v, nil)
-	default:
-		panic(fmt.Errorf("encoding not implemented: %s", dec.encoding))
+	if dec.calls == 0 {
+		// Not called from within an UnmarshalWithDecoder method.
+		dec.allocated = 0
+	}
+	dec.calls++
+	defer func() { dec.calls-- }()
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return &InvalidDecoderError{reflect.TypeOf(v)}
+	}
+	// We decode rv not rv.Elem because the Unmarshaler interface
+	// test must be applied at the top level of the value.
+	err = dec.decodeValue(rv)
+	if err == nil && dec.calls == 1 && dec.strict && dec.stream == nil && dec.Remaining() > 0 {
+		err = &DecodeError{
+			Offset: int(dec.Position()),
+			Type:   reflect.TypeOf(v).Elem(),
+			Err:    fmt.Errorf("%w: %d bytes", ErrTrailingBytes, dec.Remaining()),
+		}
 	}
+	if err != nil && dec.calls == 1 {
+		// Not called from within an UnmarshalWithDecoder method:
+		// the path starts from the root type.
+		if decodeErr, ok := err.(*DecodeError); ok {
+			if typ := reflect.TypeOf(v); typ != nil && typ.Kind() == reflect.Ptr && typ.Elem().Name() != "" {
+				decodeErr.withField(typ.Elem().Name())
+			}
+		}
+	}
+	if err != nil && dec.stream != nil {
+		return dec.stream.wrapErr(err)
+	}
+	return err
 }
 
 func sizeof(t reflect.Type, v reflect.Value) int {
@@ -157,6 +209,7 @@ func sizeof(t reflect.Type, v reflect.Value) int {
 var ErrVarIntBufferSize = errors.New("varint: invalid buffer size")
 
 func (dec *Decoder) ReadUvarint64() (uint64, error) {
+	dec.fill(binary.MaxVarintLen64)
 	l, read := binary.Uvarint(dec.data[dec.pos:])
 	if read <= 0 {
 		return l, ErrVarIntBufferSize
@@ -169,6 +222,7 @@ func (dec *Decoder) ReadUvarint64() (uint64, error) {
 }
 
 func (d *Decoder) ReadVarint64() (out int64, err error) {
+	d.fill(binary.MaxVarintLen64)
 	l, read := binary.Varint(d.data[d.pos:])
 	if read <= 0 {
 		return l, ErrVarIntBufferSize
@@ -229,13 +283,28 @@ func (dec *Decoder) ReadUvarint16() (out uint16, err error) {
 }
 
 func (dec *Decoder) ReadByteSlice() (out []byte, err error) {
+	return dec.readByteSlice(false)
+}
+
+func (dec *Decoder) readByteSlice(isString bool) (out []byte, err error) {
 	length, err := dec.ReadLength()
 	if err != nil {
 		return nil, err
 	}
+	if isString {
+		err = dec.checkStringLength(length)
+	} else {
+		err = dec.checkCollectionLength(length)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = dec.trackAllocation(length, 1); err != nil {
+		return nil, err
+	}
 
-	if len(dec.data) < dec.pos+length {
-		return nil, fmt.Errorf("byte array: varlen=%d, missing %d bytes", length, dec.pos+length-len(dec.data))
+	if !dec.fill(length) {
+		return nil, fmt.Errorf("%w: byte array: varlen=%d, missing %d bytes", ErrShortBuffer, length, dec.pos+length-len(dec.data))
 	}
 
 	out = dec.data[dec.pos : dec.pos+length]
@@ -246,36 +315,10 @@ func (dec *Decoder) ReadByteSlice() (out []byte, err error) {
 	return
 }
 
+// ReadLength reads the length prefix of a string, slice or map,
+// in the format of the encoding of the decoder.
 func (dec *Decoder) ReadLength() (length int, err error) {
-	switch dec.encoding {
-	case EncodingBin:
-		val, err := dec.ReadUvarint64()
-		if err != nil {
-			return 0, err
-		}
-		if val > 0x7FFF_FFFF {
-			return 0, io.ErrUnexpectedEOF
-		}
-		length = int(val)
-	case EncodingBorsh:
-		val, err := dec.ReadUint32(LE)
-		if err != nil {
-			return 0, err
-		}
-		if val > 0x7FFF_FFFF {
-			return 0, io.ErrUnexpectedEOF
-		}
-		length = int(val)
-	case EncodingCompactU16:
-		val, err := dec.ReadCompactU16()
-		if err != nil {
-			return 0, err
-		}
-		length = val
-	default:
-		panic(fmt.Errorf("encoding not implemented: %s", dec.encoding))
-	}
-	return
+	return dec.encoding.codec().ReadLength(dec)
 }
 
 func readNBytes(n int, reader *Decoder) ([]byte, error) {
@@ -283,10 +326,10 @@ func readNBytes(n int, reader *Decoder) ([]byte, error) {
 		return make([]byte, 0), nil
 	}
 	if n < 0 || n > 0x7FFF_FFFF {
-		return nil, fmt.Errorf("invalid length n: %v", n)
+		return nil, fmt.Errorf("%w: invalid length n: %v", ErrLengthTooLarge, n)
 	}
-	if reader.pos+n > len(reader.data) {
-		return nil, fmt.Errorf("not enough data: %d bytes missing", reader.pos+n-len(reader.data))
+	if !reader.fill(n) {
+		return nil, fmt.Errorf("%w: not enough data: %d bytes missing", ErrShortBuffer, reader.pos+n-len(reader.data))
 	}
 	out := reader.data[reader.pos : reader.pos+n]
 	reader.pos += n
@@ -298,13 +341,13 @@ func discardNBytes(n int, reader *Decoder) error {
 		return nil
 	}
 	if n < 0 || n > 0x7FFF_FFFF {
-		return fmt.Errorf("invalid length n: %v", n)
+		return fmt.Errorf("%w: invalid length n: %v", ErrLengthTooLarge, n)
 	}
 	return reader.SkipBytes(uint(n))
 }
 
 func (d *Decoder) Read(buf []byte) (int, error) {
-	if d.pos+len(buf) > len(d.data) {
+	if !d.fill(len(buf)) {
 		return 0, io.ErrShortBuffer
 	}
 	numCopied := copy(buf, d.data[d.pos:])
@@ -356,8 +399,12 @@ func (dec *Decoder) Peek(n int) (out []byte, err error) {
 	}
 
 	requiredSize := TypeSize.Byte * n
-	if dec.Remaining() < requiredSize {
-		err = fmt.Errorf("required [%d] bytes, remaining [%d]", requiredSize, dec.Remaining())
+	if dec.stream != nil && requiredSize > dec.stream.window {
+		err = fmt.Errorf("peek of [%d] bytes exceeds the stream window of [%d] bytes: %w", requiredSize, dec.stream.window, ErrUnsupportedOnStream)
+		return
+	}
+	if !dec.fill(requiredSize) {
+		err = fmt.Errorf("%w: required [%d] bytes, remaining [%d]", ErrShortBuffer, requiredSize, dec.Remaining())
 		return
 	}
 
@@ -370,7 +417,13 @@ func (dec *Decoder) Peek(n int) (out []byte, err error) {
 
 // ReadCompactU16 reads a compact u16 from the decoder.
 func (dec *Decoder) ReadCompactU16() (out int, err error) {
+	dec.fill(_MAX_COMPACTU16_ENCODING_LENGTH)
 	out, size, err := DecodeCompactU16(dec.data[dec.pos:])
+	if err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: compact-u16: %s", ErrShortBuffer, err)
+	} else if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidCompactU16, err)
+	}
 	if traceEnabled {
 		zlog.Debug("decode: read compact u16", zap.Int("val", out))
 	}
@@ -383,6 +436,9 @@ func (dec *Decoder) ReadOption() (out bool, err error) {
 	if err != nil {
 		return false, fmt.Errorf("decode: read option, %w", err)
 	}
+	if dec.strict && b > 1 {
+		return false, fmt.Errorf("decode: read option, %w: %d", ErrInvalidOptionByte, b)
+	}
 	out = b != 0
 	if traceEnabled {
 		zlog.Debug("decode: read option", zap.Bool("val", out))
@@ -396,7 +452,7 @@ func (dec *Decoder) ReadCOption() (out bool, err error) {
 		return false, fmt.Errorf("decode: read c-option, %w", err)
 	}
 	if b > 1 {
-		return false, fmt.Errorf("decode: read c-option, invalid value: %d", b)
+		return false, fmt.Errorf("decode: read c-option, %w: %d", ErrInvalidOptionByte, b)
 	}
 	out = b != 0
 	if traceEnabled {
@@ -405,9 +461,18 @@ func (dec *Decoder) ReadCOption() (out bool, err error) {
 	return
 }
 
+// readOptionTag reads the tag of a value with the `option` (in the format
+// of the encoding) or `coption` (4 bytes) tag, as set in opt.
+func (dec *Decoder) readOptionTag(opt *option) (isPresent bool, err error) {
+	if opt.is_COptional() {
+		return dec.ReadCOption()
+	}
+	return dec.encoding.codec().ReadOptionTag(dec)
+}
+
 func (dec *Decoder) ReadByte() (out byte, err error) {
-	if dec.Remaining() < TypeSize.Byte {
-		err = fmt.Errorf("required [1] byte, remaining [%d]", dec.Remaining())
+	if !dec.fill(TypeSize.Byte) {
+		err = fmt.Errorf("%w: required [1] byte, remaining [%d]", ErrShortBuffer, dec.Remaining())
 		return
 	}
 
@@ -420,14 +485,17 @@ func (dec *Decoder) ReadByte() (out byte, err error) {
 }
 
 func (dec *Decoder) ReadBool() (out bool, err error) {
-	if dec.Remaining() < TypeSize.Bool {
-		err = fmt.Errorf("bool required [%d] byte, remaining [%d]", TypeSize.Bool, dec.Remaining())
+	if !dec.fill(TypeSize.Bool) {
+		err = fmt.Errorf("%w: bool required [%d] byte, remaining [%d]", ErrShortBuffer, TypeSize.Bool, dec.Remaining())
 		return
 	}
 
 	b, err := dec.ReadByte()
 	if err != nil {
-		err = fmt.Errorf("readBool, %s", err)
+		err = fmt.Errorf("readBool, %w", err)
+	}
+	if dec.strict && b > 1 {
+		return false, fmt.Errorf("readBool, %w: %d", ErrInvalidBoolByte, b)
 	}
 	out = b != 0
 	if traceEnabled {
@@ -451,8 +519,8 @@ func (dec *Decoder) ReadInt8() (out int8, err error) {
 }
 
 func (dec *Decoder) ReadUint16(order binary.ByteOrder) (out uint16, err error) {
-	if dec.Remaining() < TypeSize.Uint16 {
-		err = fmt.Errorf("uint16 required [%d] bytes, remaining [%d]", TypeSize.Uint16, dec.Remaining())
+	if !dec.fill(TypeSize.Uint16) {
+		err = fmt.Errorf("%w: uint16 required [%d] bytes, remaining [%d]", ErrShortBuffer, TypeSize.Uint16, dec.Remaining())
 		return
 	}
 
@@ -474,8 +542,8 @@ func (dec *Decoder) ReadInt16(order binary.ByteOrder) (out int16, err error) {
 }
 
 func (dec *Decoder) ReadUint32(order binary.ByteOrder) (out uint32, err error) {
-	if dec.Remaining() < TypeSize.Uint32 {
-		err = fmt.Errorf("uint32 required [%d] bytes, remaining [%d]", TypeSize.Uint32, dec.Remaining())
+	if !dec.fill(TypeSize.Uint32) {
+		err = fmt.Errorf("%w: uint32 required [%d] bytes, remaining [%d]", ErrShortBuffer, TypeSize.Uint32, dec.Remaining())
 		return
 	}
 
@@ -497,8 +565,8 @@ func (dec *Decoder) ReadInt32(order binary.ByteOrder) (out int32, err error) {
 }
 
 func (dec *Decoder) ReadUint64(order binary.ByteOrder) (out uint64, err error) {
-	if dec.Remaining() < TypeSize.Uint64 {
-		err = fmt.Errorf("decode: uint64 required [%d] bytes, remaining [%d]", TypeSize.Uint64, dec.Remaining())
+	if !dec.fill(TypeSize.Uint64) {
+		err = fmt.Errorf("%w: uint64 required [%d] bytes, remaining [%d]", ErrShortBuffer, TypeSize.Uint64, dec.Remaining())
 		return
 	}
 
@@ -523,8 +591,8 @@ func (dec *Decoder) ReadInt64(order binary.ByteOrder) (out int64, err error) {
 }
 
 func (dec *Decoder) ReadUint128(order binary.ByteOrder) (out Uint128, err error) {
-	if dec.Remaining() < TypeSize.Uint128 {
-		err = fmt.Errorf("uint128 required [%d] bytes, remaining [%d]", TypeSize.Uint128, dec.Remaining())
+	if !dec.fill(TypeSize.Uint128) {
+		err = fmt.Errorf("%w: uint128 required [%d] bytes, remaining [%d]", ErrShortBuffer, TypeSize.Uint128, dec.Remaining())
 		return
 	}
 
@@ -554,9 +622,45 @@ func (dec *Decoder) ReadInt128(order binary.ByteOrder) (out Int128, err error) {
 	return Int128(v), nil
 }
 
+func (dec *Decoder) ReadUint256(order binary.ByteOrder) (out Uint256, err error) {
+	if !dec.fill(TypeSize.Uint256) {
+		err = fmt.Errorf("%w: uint256 required [%d] bytes, remaining [%d]", ErrShortBuffer, TypeSize.Uint256, dec.Remaining())
+		return
+	}
+
+	data := dec.data[dec.pos : dec.pos+TypeSize.Uint256]
+	switch order {
+	case binary.LittleEndian:
+		for k := range out.Words {
+			out.Words[k] = order.Uint64(data[8*k:])
+		}
+	case binary.BigEndian:
+		for k := range out.Words {
+			out.Words[k] = order.Uint64(data[8*(3-k):])
+		}
+	default:
+		err = fmt.Errorf("invalid byte order: %v", order)
+		return
+	}
+
+	dec.pos += TypeSize.Uint256
+	if traceEnabled {
+		zlog.Debug("decode: read uint256", zap.Stringer("val", out))
+	}
+	return
+}
+
+func (dec *Decoder) ReadInt256(order binary.ByteOrder) (out Int256, err error) {
+	v, err := dec.ReadUint256(order)
+	if err != nil {
+		return
+	}
+	return Int256(v), nil
+}
+
 func (dec *Decoder) ReadFloat32(order binary.ByteOrder) (out float32, err error) {
-	if dec.Remaining() < TypeSize.Float32 {
-		err = fmt.Errorf("float32 required [%d] bytes, remaining [%d]", TypeSize.Float32, dec.Remaining())
+	if !dec.fill(TypeSize.Float32) {
+		err = fmt.Errorf("%w: float32 required [%d] bytes, remaining [%d]", ErrShortBuffer, TypeSize.Float32, dec.Remaining())
 		return
 	}
 
@@ -569,15 +673,15 @@ func (dec *Decoder) ReadFloat32(order binary.ByteOrder) (out float32, err error)
 
 	if dec.IsBorsh() {
 		if math.IsNaN(float64(out)) {
-			return 0, errors.New("NaN for float not allowed")
+			return 0, ErrNaN
 		}
 	}
 	return
 }
 
 func (dec *Decoder) ReadFloat64(order binary.ByteOrder) (out float64, err error) {
-	if dec.Remaining() < TypeSize.Float64 {
-		err = fmt.Errorf("float64 required [%d] bytes, remaining [%d]", TypeSize.Float64, dec.Remaining())
+	if !dec.fill(TypeSize.Float64) {
+		err = fmt.Errorf("%w: float64 required [%d] bytes, remaining [%d]", ErrShortBuffer, TypeSize.Float64, dec.Remaining())
 		return
 	}
 
@@ -589,7 +693,7 @@ func (dec *Decoder) ReadFloat64(order binary.ByteOrder) (out float64, err error)
 	}
 	if dec.IsBorsh() {
 		if math.IsNaN(out) {
-			return 0, errors.New("NaN for float not allowed")
+			return 0, ErrNaN
 		}
 	}
 	return
@@ -598,13 +702,19 @@ func (dec *Decoder) ReadFloat64(order binary.ByteOrder) (out float64, err error)
 func (dec *Decoder) ReadFloat128(order binary.ByteOrder) (out Float128, err error) {
 	value, err := dec.ReadUint128(order)
 	if err != nil {
-		return out, fmt.Errorf("float128: %s", err)
+		return out, fmt.Errorf("float128: %w", err)
+	}
+	out = Float128(value)
+	if dec.IsBorsh() {
+		if out.IsNaN() {
+			return Float128{}, ErrNaN
+		}
 	}
-	return Float128(value), nil
+	return
 }
 
 func (dec *Decoder) SafeReadUTF8String() (out string, err error) {
-	data, err := dec.ReadByteSlice()
+	data, err := dec.readByteSlice(true)
 	out = strings.Map(fixUtf, string(data))
 	if traceEnabled {
 		zlog.Debug("read safe UTF8 string", zap.String("val", out))
@@ -620,7 +730,7 @@ func fixUtf(r rune) rune {
 }
 
 func (dec *Decoder) ReadString() (out string, err error) {
-	data, err := dec.ReadByteSlice()
+	data, err := dec.readByteSlice(true)
 	out = string(data)
 	if traceEnabled {
 		zlog.Debug("read string", zap.String("val", out))
@@ -634,7 +744,13 @@ func (dec *Decoder) ReadRustString() (out string, err error) {
 		return "", err
 	}
 	if length > 0x7FFF_FFFF {
-		return "", io.ErrUnexpectedEOF
+		return "", fmt.Errorf("%w: %d", ErrLengthTooLarge, length)
+	}
+	if err = dec.checkStringLength(int(length)); err != nil {
+		return "", err
+	}
+	if err = dec.trackAllocation(int(length), 1); err != nil {
+		return "", err
 	}
 	bytes, err := dec.ReadNBytes(int(length))
 	if err != nil {
@@ -647,40 +763,123 @@ func (dec *Decoder) ReadRustString() (out string, err error) {
 	return
 }
 
+// ReadFixedString reads a string stored in n bytes,
+// stripping the trailing NUL bytes padding it.
+func (dec *Decoder) ReadFixedString(n int) (out string, err error) {
+	data, err := dec.ReadNBytes(n)
+	if err != nil {
+		return "", err
+	}
+	out = string(bytes.TrimRight(data, "\x00"))
+	if traceEnabled {
+		zlog.Debug("read fixed string", zap.String("val", out))
+	}
+	return
+}
+
+// ReadCString reads a NUL-terminated string, consuming its terminator.
+func (dec *Decoder) ReadCString() (out string, err error) {
+	n := 0
+	for {
+		if i := bytes.IndexByte(dec.data[dec.pos+n:], 0); i >= 0 {
+			n += i
+			break
+		}
+		n = len(dec.data) - dec.pos
+		if err = dec.checkStringLength(n); err != nil {
+			return "", err
+		}
+		if !dec.fill(n + 1) {
+			return "", fmt.Errorf("%w: cstring without NUL terminator", ErrShortBuffer)
+		}
+	}
+	if err = dec.checkStringLength(n); err != nil {
+		return "", err
+	}
+	out = string(dec.data[dec.pos : dec.pos+n])
+	dec.pos += n + 1
+	if traceEnabled {
+		zlog.Debug("read cstring", zap.String("val", out))
+	}
+	return
+}
+
 func (dec *Decoder) ReadCompactU16Length() (int, error) {
 	return dec.ReadCompactU16()
 }
 
 func (dec *Decoder) SkipBytes(count uint) error {
+	if dec.stream != nil {
+		return dec.stream.skip(dec, count)
+	}
 	if uint(dec.Remaining()) < count {
-		return fmt.Errorf("request to skip %d but only %d bytes remain", count, dec.Remaining())
+		return fmt.Errorf("%w: request to skip %d but only %d bytes remain", ErrShortBuffer, count, dec.Remaining())
 	}
 	dec.pos += int(count)
 	return nil
 }
 
+// SetPosition moves the decoder to the provided position.
+// On stream decoders, the position can only be moved within
+// the currently buffered window.
 func (dec *Decoder) SetPosition(idx uint) error {
-	if int(idx) < len(dec.data) {
-		dec.pos = int(idx)
+	if dec.stream != nil {
+		return dec.stream.setPosition(dec, idx)
+	}
+	if int(idx) >= dec.offset && int(idx)-dec.offset < len(dec.data) {
+		// dec.offset is only set on sub-decoders (see SubDecoder).
+		dec.pos = int(idx) - dec.offset
 		return nil
 	}
 	return fmt.Errorf("request to set position to %d outsize of buffer (buffer size %d)", idx, len(dec.data))
 }
 
 func (dec *Decoder) Position() uint {
-	return uint(dec.pos)
+	return uint(dec.offset + dec.pos)
 }
 
+// Remaining returns the number of bytes left to be decoded.
+// On stream decoders, the total is not known in advance: Remaining
+// returns the number of bytes available in the buffered window
+// (which is filled first), and is exact only once the end of
+// the stream is within the window.
 func (dec *Decoder) Remaining() int {
+	if dec.stream != nil {
+		dec.fill(dec.stream.window)
+	}
 	return len(dec.data) - dec.pos
 }
 
+// Len returns the length of the data being decoded.
+// On stream decoders, it's the number of bytes read from the stream so far.
 func (dec *Decoder) Len() int {
-	return len(dec.data)
+	return dec.offset + len(dec.data)
 }
 
 func (dec *Decoder) HasRemaining() bool {
-	return dec.Remaining() > 0
+	return dec.fill(1)
+}
+
+// hasAtLeast reports whether at least n bytes are left to be decoded.
+// On stream decoders, only the part of n that fits the window is verified.
+func (dec *Decoder) hasAtLeast(n int) bool {
+	if dec.stream != nil && n > dec.stream.window {
+		n = dec.stream.window
+	}
+	return dec.fill(n)
+}
+
+// fill makes sure that at least n bytes are buffered after the current
+// position, reading from the stream if needed. It returns false if
+// there are less than n bytes left.
+func (dec *Decoder) fill(n int) bool {
+	if len(dec.data)-dec.pos >= n {
+		return true
+	}
+	if dec.stream == nil {
+		return false
+	}
+	return dec.stream.fill(dec, n)
 }
 
 // indirect walks down v allocating pointers as needed,
@@ -935,23 +1134,23 @@ func reflect_readArrayOfUint_(d *Decoder, l int, k reflect.Kind, rv reflect.Valu
 	// 	}
 	// 	return reflect_readArrayOfUint64(  d, l, rv, order)
 	case reflect.Uint8:
-		if l > d.Remaining() {
-			return io.ErrUnexpectedEOF
+		if !d.hasAtLeast(l) {
+			return fmt.Errorf("%w: array of %d %s", ErrShortBuffer, l, k)
 		}
 		return reflect_readArrayOfBytes(d, l, rv)
 	case reflect.Uint16:
-		if l*2 > d.Remaining() {
-			return io.ErrUnexpectedEOF
+		if !d.hasAtLeast(l * 2) {
+			return fmt.Errorf("%w: array of %d %s", ErrShortBuffer, l, k)
 		}
 		return reflect_readArrayOfUint16(d, l, rv, order)
 	case reflect.Uint32:
-		if l*4 > d.Remaining() {
-			return io.ErrUnexpectedEOF
+		if !d.hasAtLeast(l * 4) {
+			return fmt.Errorf("%w: array of %d %s", ErrShortBuffer, l, k)
 		}
 		return reflect_readArrayOfUint32(d, l, rv, order)
 	case reflect.Uint64:
-		if l*8 > d.Remaining() {
-			return io.ErrUnexpectedEOF
+		if !d.hasAtLeast(l * 8) {
+			return fmt.Errorf("%w: array of %d %s", ErrShortBuffer, l, k)
 		}
 		return reflect_readArrayOfUint64(d, l, rv, order)
 	default:
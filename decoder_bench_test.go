@@ -1,6 +1,7 @@
 package bin
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 )
@@ -417,3 +418,92 @@ func Benchmark_uintSlice32_Decode_field_withCustomDecoder(b *testing.B) {
 		}
 	}
 }
+
+type benchPlanStruct struct {
+	F1  uint8
+	F2  int16 `bin:"big"`
+	F3  uint16
+	F4  int32
+	F5  uint32
+	F6  int64
+	F7  uint64
+	F8  bool
+	F9  string
+	F10 [32]byte
+	F11 uint32 `bin:"sizeof=F12"`
+	F12 []uint64
+	F13 *uint64 `bin:"optional"`
+	F14 Uint128
+	F15 string `borsh_skip:"true"`
+}
+
+func newBenchPlanStruct() *benchPlanStruct {
+	f13 := uint64(13)
+	return &benchPlanStruct{
+		F1:  1,
+		F2:  2,
+		F3:  3,
+		F4:  4,
+		F5:  5,
+		F6:  6,
+		F7:  7,
+		F8:  true,
+		F9:  "nine",
+		F11: 4,
+		F12: []uint64{1, 2, 3, 4},
+		F13: &f13,
+		F14: Uint128{Lo: 14},
+	}
+}
+
+func benchmarkStructPlanDecode(b *testing.B, enc Encoding, cached bool) {
+	buf := new(bytes.Buffer)
+	if err := NewEncoderWithEncoding(buf, enc).Encode(newBenchPlanStruct()); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	rt := reflect.TypeOf(benchPlanStruct{})
+
+	setupBench(b)
+	for i := 0; i < b.N; i++ {
+		if !cached {
+			// Simulate re-walking the type on every call.
+			structPlanCache.Delete(rt)
+		}
+		var got benchPlanStruct
+		if err := NewDecoderWithEncoding(data, enc).Decode(&got); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkStructPlanEncode(b *testing.B, enc Encoding, cached bool) {
+	v := newBenchPlanStruct()
+	rt := reflect.TypeOf(benchPlanStruct{})
+	counter := &byteCounter{}
+
+	setupBench(b)
+	for i := 0; i < b.N; i++ {
+		if !cached {
+			// Simulate re-walking the type on every call.
+			structPlanCache.Delete(rt)
+		}
+		if err := NewEncoderWithEncoding(counter, enc).Encode(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_structPlan_Decode(b *testing.B) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		b.Run(enc.String()+"/cached", func(b *testing.B) { benchmarkStructPlanDecode(b, enc, true) })
+		b.Run(enc.String()+"/uncached", func(b *testing.B) { benchmarkStructPlanDecode(b, enc, false) })
+	}
+}
+
+func Benchmark_structPlan_Encode(b *testing.B) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		b.Run(enc.String()+"/cached", func(b *testing.B) { benchmarkStructPlanEncode(b, enc, true) })
+		b.Run(enc.String()+"/uncached", func(b *testing.B) { benchmarkStructPlanEncode(b, enc, false) })
+	}
+}
@@ -18,36 +18,29 @@
 package bin
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
-	"io"
 	"reflect"
 
 	"go.uber.org/zap"
 )
 
-func (dec *Decoder) decodeWithOptionBorsh(v interface{}, option *option) (err error) {
-	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr {
-		return &InvalidDecoderError{reflect.TypeOf(v)}
-	}
-
-	// We decode rv not rv.Elem because the Unmarshaler interface
-	// test must be applied at the top level of the value.
-	err = dec.decodeBorsh(rv, option)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
+// decodeBorsh is the reflective decoder of borsh, and of the encodings
+// added with RegisterEncoding, with the wire format of their Codec.
 func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 	if opt == nil {
 		opt = newDefaultOption()
 	}
 	dec.currentFieldOpt = opt
 
+	offset := int(dec.Position())
+	field := rv
 	unmarshaler, rv := indirect(rv, opt.is_Optional() || opt.is_COptional())
+	defer func() {
+		if err != nil {
+			err = newDecodeError(err, offset, decodedType(rv, unmarshaler))
+		}
+	}()
 
 	if traceEnabled {
 		zlog.Debug("decode: type",
@@ -57,29 +50,13 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		)
 	}
 
-	if opt.is_Optional() {
-		isPresent, e := dec.ReadOption()
-		if e != nil {
-			err = fmt.Errorf("decode: %t isPresent, %s", rv.Type(), e)
-			return
-		}
-
-		if !isPresent {
-			if traceEnabled {
-				zlog.Debug("decode: skipping optional value", zap.Stringer("type", rv.Kind()))
-			}
-
-			rv.Set(reflect.Zero(rv.Type()))
-			return
+	if opt.is_Optional() || opt.is_COptional() {
+		if c, ok := dec.encoding.codec().(OptionBoolCodec); ok && isOptionBool(rv, opt) {
+			return dec.decodeOptionBool(c, rv)
 		}
-
-		// we have ptr here we should not go get the element
-		unmarshaler, rv = indirect(rv, false)
-	}
-	if opt.is_COptional() {
-		isPresent, e := dec.ReadCOption()
+		isPresent, e := dec.readOptionTag(opt)
 		if e != nil {
-			err = fmt.Errorf("decode: %t isPresent, %s", rv.Type(), e)
+			err = fmt.Errorf("decode: %s isPresent, %w", rv.Type(), e)
 			return
 		}
 
@@ -88,22 +65,42 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 				zlog.Debug("decode: skipping optional value", zap.Stringer("type", rv.Kind()))
 			}
 
+			if !rv.IsValid() {
+				// A value (not a pointer) with an UnmarshalWithDecoder method:
+				rv = field
+			}
 			rv.Set(reflect.Zero(rv.Type()))
 			return
 		}
 
-		// we have ptr here we should not go get the element
-		unmarshaler, rv = indirect(rv, false)
+		if unmarshaler == nil {
+			// we have ptr here we should not go get the element
+			unmarshaler, rv = indirect(rv, false)
+		}
 	}
 	// Reset optionality so it won't propagate to child types:
 	opt = opt.clone().set_Optional(false).set_COptional(false)
 
+	if opt.Compact {
+		return dec.decodeCompact(field)
+	}
 	if unmarshaler != nil {
 		if traceEnabled {
 			zlog.Debug("decode: using UnmarshalWithDecoder method to decode type")
 		}
 		return unmarshaler.UnmarshalWithDecoder(dec)
 	}
+	if opt.isText() {
+		return dec.decodeText(rv, opt)
+	}
+	if opt.Rest {
+		return dec.decodeRest(rv, opt)
+	}
+	if c, ok := dec.encoding.intCodec(); ok {
+		if bits := intBits(rv.Kind()); bits > 0 {
+			return dec.decodeInt(c, rv, bits)
+		}
+	}
 
 	rt := rv.Type()
 	switch rv.Kind() {
@@ -120,7 +117,13 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 	// 	rv.SetUint(n)
 	// 	return
 	case reflect.String:
-		s, e := dec.ReadString()
+		var s string
+		var e error
+		if opt.LenPrefix != LengthPrefixDefault {
+			s, e = dec.readPrefixedString(opt.LenPrefix)
+		} else {
+			s, e = dec.ReadString()
+		}
 		if e != nil {
 			err = e
 			return
@@ -188,6 +191,12 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		return nil
 		// TODO: handle reflect.Ptr ???
 	}
+
+	if err = dec.enterNested(); err != nil {
+		return err
+	}
+	defer dec.leaveNested()
+
 	switch rt.Kind() {
 	case reflect.Array:
 		l := rt.Len()
@@ -195,15 +204,15 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 			zlog.Debug("decoding: reading array", zap.Int("length", l))
 		}
 
-		switch k := rv.Type().Elem().Kind(); k {
-		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch k := rv.Type().Elem().Kind(); {
+		case dec.encoding.isFixedSizeUint(k):
 			if err := reflect_readArrayOfUint_(dec, l, k, rv, LE); err != nil {
 				return err
 			}
 		default:
 			for i := 0; i < l; i++ {
 				if err = dec.decodeBorsh(rv.Index(i), nil); err != nil {
-					return
+					return withIndexPath(err, i)
 				}
 			}
 		}
@@ -213,27 +222,34 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		if opt.hasSizeOfSlice() {
 			l = opt.getSizeOfSlice()
 		} else {
-			length, err := dec.ReadUint32(LE)
+			length, err := dec.ReadLengthPrefix(opt.LenPrefix)
 			if err != nil {
 				return err
 			}
-			l = int(length)
+			l = length
 		}
 
 		if traceEnabled {
 			zlog.Debug("reading slice", zap.Int("len", l), typeField("type", rv))
 		}
 
+		if err := dec.checkCollectionLength(l); err != nil {
+			return err
+		}
+		if err := dec.trackAllocation(l, int(rt.Elem().Size())); err != nil {
+			return err
+		}
+
 		if l == 0 {
 			// Empty slices are left nil
 			return
 		}
-		if l > dec.Remaining() {
-			return io.ErrUnexpectedEOF
+		if !dec.hasAtLeast(l) {
+			return fmt.Errorf("%w: slice of %d elements", ErrShortBuffer, l)
 		}
 
-		switch k := rv.Type().Elem().Kind(); k {
-		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch k := rv.Type().Elem().Kind(); {
+		case dec.encoding.isFixedSizeUint(k):
 			if err := reflect_readArrayOfUint_(dec, l, k, rv, LE); err != nil {
 				return err
 			}
@@ -244,7 +260,7 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 				element := reflect.New(rt.Elem())
 				// decode into element:
 				if err = dec.decodeBorsh(element, nil); err != nil {
-					return
+					return withIndexPath(err, i)
 				}
 				// append to slice:
 				rv.Set(reflect.Append(rv, element.Elem()))
@@ -252,19 +268,32 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 
 	case reflect.Struct:
-		if err = dec.decodeStructBorsh(rt, rv); err != nil {
+		if err = dec.encoding.codec().DecodeStruct(dec, rv, decodeStructFields); err != nil {
 			return
 		}
 
 	case reflect.Map:
-		l, err := dec.ReadUint32(LE)
+		l, err := dec.ReadLengthPrefix(opt.LenPrefix)
 		if err != nil {
 			return err
 		}
+		if err := dec.checkCollectionLength(int(l)); err != nil {
+			return err
+		}
+		if err := dec.trackAllocation(int(l), int(rt.Key().Size()+rt.Elem().Size())); err != nil {
+			return err
+		}
+		isSet := opt.is_Set()
+		if isSet && !isSetType(rt) {
+			return fmt.Errorf("decode: %w: set must be a map[K]struct{}, got %q", ErrUnsupportedType, rt)
+		}
 		if l == 0 {
 			// If the map has no content, keep it nil.
 			return nil
 		}
+		order := dec.encoding.codec().MapOrder()
+		checkKeyOrder := isSet || (order != MapOrderNone && (dec.strict || dec.options.StrictKeyOrder))
+		keyOrder := keyOrderChecker{encoding: dec.encoding, byEncoding: order == MapOrderEncodedKeys}
 		rv.Set(reflect.MakeMap(rt))
 		for i := 0; i < int(l); i++ {
 			key := reflect.New(rt.Key())
@@ -272,17 +301,27 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 			if err != nil {
 				return err
 			}
+			if checkKeyOrder {
+				if err := keyOrder.next(key.Elem()); err != nil {
+					return withKeyPath(err, key.Elem())
+				}
+			}
+			if isSet {
+				// A set only has keys.
+				rv.SetMapIndex(key.Elem(), reflect.Zero(rt.Elem()))
+				continue
+			}
 			val := reflect.New(rt.Elem())
 			err = dec.decodeBorsh(val.Elem(), nil)
 			if err != nil {
-				return err
+				return withKeyPath(err, key.Elem())
 			}
 			rv.SetMapIndex(key.Elem(), val.Elem())
 		}
 		return nil
 
 	default:
-		return fmt.Errorf("decode: unsupported type %q", rt)
+		return fmt.Errorf("decode: %w %q", ErrUnsupportedType, rt)
 	}
 
 	return
@@ -291,19 +330,72 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 func (dec *Decoder) deserializeComplexEnum(rv reflect.Value) error {
 	rt := rv.Type()
 	// read enum identifier
-	tmp, err := dec.ReadUint8()
+	variant, err := dec.encoding.codec().ReadEnumTag(dec)
 	if err != nil {
 		return err
 	}
-	enum := BorshEnum(tmp)
-	rv.Field(0).Set(reflect.ValueOf(enum).Convert(rv.Field(0).Type()))
+	if variant < 0 || variant+1 >= rt.NumField() {
+		return fmt.Errorf("%w: complex enum variant %d out of %d", ErrInvalidEnumVariant, variant, rt.NumField()-1)
+	}
+	rv.Field(0).SetUint(uint64(variant))
 
 	// read enum field, if necessary
-	if int(enum)+1 >= rt.NumField() {
-		return errors.New("complex enum too large")
+	field := rv.Field(variant + 1)
+	if err = dec.decodeBorsh(field, nil); err != nil {
+		return withFieldPath(err, rt.Field(variant+1).Name)
 	}
-	field := rv.Field(int(enum) + 1)
-	return dec.decodeBorsh(field, nil)
+	return nil
+}
+
+// keyOrderChecker checks that the keys of a map come in strictly increasing
+// order: of their values, or of their encodings if byEncoding.
+type keyOrderChecker struct {
+	encoding    Encoding
+	byEncoding  bool
+	prev        reflect.Value
+	prevEncoded []byte
+}
+
+// next checks that key comes after the previous keys.
+func (c *keyOrderChecker) next(key reflect.Value) error {
+	if !c.byEncoding {
+		if c.prev.IsValid() {
+			if err := checkKeysOrdered(c.prev, key); err != nil {
+				return err
+			}
+		}
+		c.prev = key
+		return nil
+	}
+	encoded, err := encodeKey(c.encoding, key)
+	if err != nil {
+		return err
+	}
+	if c.prevEncoded != nil {
+		switch cmp := bytes.Compare(c.prevEncoded, encoded); {
+		case cmp == 0:
+			return ErrDuplicateKey
+		case cmp > 0:
+			return ErrUnsortedKeys
+		}
+	}
+	c.prevEncoded = encoded
+	return nil
+}
+
+// checkKeysOrdered checks that key comes strictly after prev,
+// as required for the keys of canonical borsh maps and sets.
+func checkKeysOrdered(prev, key reflect.Value) error {
+	cmp, ok := compareKeys(prev, key)
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: cannot compare keys of type %q", ErrUnsupportedType, key.Type())
+	case cmp == 0:
+		return ErrDuplicateKey
+	case cmp > 0:
+		return ErrUnsortedKeys
+	}
+	return nil
 }
 
 var borshEnumType = reflect.TypeOf(BorshEnum(0))
@@ -319,46 +411,46 @@ func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err er
 		zlog.Debug("decode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
 	}
 
+	plan := getStructPlan(rt)
+
 	// Handle complex enum:
-	if rt.NumField() > 0 {
-		// If the first field has type BorshEnum and is flagged with "borsh_enum"
-		// we have a complex enum:
-		firstField := rt.Field(0)
-		if isTypeBorshEnum(firstField.Type) &&
-			parseFieldTag(firstField.Tag).IsBorshEnum {
-			return dec.deserializeComplexEnum(rv)
-		}
+	if plan.isComplexEnum {
+		return dec.deserializeComplexEnum(rv)
 	}
 
-	sizeOfMap := map[string]int{}
+	sizeOfMap := plan.newSizeOfTracker()
+	byteSizeOfMap := plan.newByteSizeOfTracker()
 	seenBinaryExtensionField := false
+	seenRestField := false
 	for i := 0; i < l; i++ {
-		structField := rt.Field(i)
-		fieldTag := parseFieldTag(structField.Tag)
+		structField := &plan.fields[i]
+		fieldTag := structField.tag
 
 		if fieldTag.Skip {
 			if traceEnabled {
 				zlog.Debug("decode: skipping struct field with skip flag",
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
 			continue
 		}
 
 		if !fieldTag.BinaryExtension && seenBinaryExtensionField {
-			panic(fmt.Sprintf("the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field %q", structField.Name))
+			panic(fmt.Sprintf("the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field %q", structField.name))
+		}
+
+		if seenRestField {
+			panic(fmt.Sprintf("the `bin:\"rest\"` tag must be on the last field of the struct, problematic field %q", structField.name))
 		}
+		seenRestField = fieldTag.Rest
 
 		if fieldTag.BinaryExtension {
 			seenBinaryExtensionField = true
-			// FIXME: This works only if what is in `d.data` is the actual full data buffer that
-			//        needs to be decoded. If there is for example two structs in the buffer, this
-			//        will not work as we would continue into the next struct.
-			//
-			//        But at the same time, does it make sense otherwise? What would be the inference
-			//        rule in the case of extra bytes available? Continue decoding and revert if it's
-			//        not working? But how to detect valid errors?
-			if len(dec.data[dec.pos:]) <= 0 {
+			// This works only if what is in `d.data` ends with the struct: if there is for example
+			// two structs in the buffer, we would continue into the next struct. The struct must then
+			// be the last value of the data, or its size in bytes must be known: a `byte_sizeof=`
+			// field (or SubDecoder) bounds the decoder to the bytes of the struct.
+			if !dec.HasRemaining() {
 				continue
 			}
 		}
@@ -370,11 +462,11 @@ func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err er
 				// we cannot create a point to field skipping
 				if traceEnabled {
 					zlog.Debug("skipping struct field that cannot be addressed",
-						zap.String("struct_field_name", structField.Name),
+						zap.String("struct_field_name", structField.name),
 						zap.Stringer("struct_value_type", v.Kind()),
 					)
 				}
-				return fmt.Errorf("unable to decode a none setup struc field %q with type %q", structField.Name, v.Kind())
+				return fmt.Errorf("unable to decode a none setup struc field %q with type %q", structField.name, v.Kind())
 			}
 			v = v.Addr()
 		}
@@ -382,7 +474,7 @@ func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err er
 		if !v.CanSet() {
 			if traceEnabled {
 				zlog.Debug("skipping struct field that cannot be addressed",
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 					zap.Stringer("struct_value_type", v.Kind()),
 				)
 			}
@@ -392,66 +484,101 @@ func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err er
 		option := &option{
 			is_OptionalField:  fieldTag.Option,
 			is_COptionalField: fieldTag.COption,
+			is_SetField:       fieldTag.Set,
 			Order:             fieldTag.Order,
+			FixedLayout:       fieldTag.FixedLayout,
+			FixedSize:         fieldTag.FixedSize,
+			CString:           fieldTag.CString,
+			LenPrefix:         fieldTag.LenPrefix,
+			Rest:              fieldTag.Rest,
+			Compact:           fieldTag.Compact,
 		}
 
-		if s, ok := sizeOfMap[structField.Name]; ok {
+		if s, ok := sizeOfMap.get(structField); ok {
 			option.setSizeOfSlice(s)
 		}
 
 		if traceEnabled {
 			zlog.Debug("decode: struct field",
 				zap.Stringer("struct_field_value_type", v.Kind()),
-				zap.String("struct_field_name", structField.Name),
+				zap.String("struct_field_name", structField.name),
 				zap.Reflect("struct_field_tags", fieldTag),
 				zap.Reflect("struct_field_option", option),
 			)
 		}
 
-		rt := v.Type()
-		ptrImplements := reflect.PtrTo(rt).Implements(unmarshalableType)
-		vImplements := rt.Implements(unmarshalableType)
-		if ptrImplements || vImplements {
-			switch {
-			case ptrImplements:
-				m := reflect.New(rt)
-				val := m.Interface()
-				err := val.(BinaryUnmarshaler).UnmarshalWithDecoder(dec)
-				if err != nil {
-					return err
-				}
-				v.Set(reflect.ValueOf(val).Elem())
-				continue
-			case vImplements:
-				m := reflect.New(rt.Elem())
-				val := m.Interface()
-				err := val.(BinaryUnmarshaler).UnmarshalWithDecoder(dec)
-				if err != nil {
-					return err
-				}
-				v.Set(reflect.ValueOf(val))
-				continue
+		fieldOffset := int(dec.Position())
+		fieldDec := dec
+		if n, ok := byteSizeOfMap.get(structField); ok {
+			if fieldDec, err = dec.SubDecoder(n); err != nil {
+				return newDecodeError(err, fieldOffset, structField.typ).withField(structField.name)
 			}
 		}
-
-		if err = dec.decodeBorsh(v, option); err != nil {
-			return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+		rt := structField.typ
+		ptrImplements := structField.ptrImplementsUnmarshaler
+		vImplements := structField.implementsUnmarshaler
+		if (ptrImplements || vImplements) && !option.Compact {
+			err = fieldDec.decodeUnmarshalerField(v, rt, ptrImplements, option)
+		} else {
+			err = fieldDec.decodeBorsh(v, option)
+		}
+		if err == nil && fieldDec != dec {
+			err = dec.CloseSubDecoder(fieldDec)
+		}
+		if err != nil {
+			return newDecodeError(err, fieldOffset, rt).withField(structField.name)
 		}
 
 		if fieldTag.SizeOf != "" {
-			size := sizeof(structField.Type, v)
+			size := sizeof(structField.typ, v)
 			if traceEnabled {
 				zlog.Debug("setting size of field",
 					zap.String("field_name", fieldTag.SizeOf),
 					zap.Int("size", size),
 				)
 			}
-			sizeOfMap[fieldTag.SizeOf] = size
+			sizeOfMap.set(structField, size)
+		}
+		if fieldTag.ByteSizeOf != "" {
+			byteSizeOfMap.setByteSize(structField, sizeof(structField.typ, v))
 		}
 	}
 	return
 }
 
+// decodeUnmarshalerField decodes a new value of type rt with its
+// UnmarshalWithDecoder method (the one of *rt if ptrImplements,
+// otherwise rt is a pointer type), and stores it in v;
+// optional fields are zeroed when absent.
+func (dec *Decoder) decodeUnmarshalerField(v reflect.Value, rt reflect.Type, ptrImplements bool, opt *option) error {
+	// The unmarshaler reads the options of its field (e.g. the byte order):
+	dec.currentFieldOpt = opt
+	if opt.is_Optional() || opt.is_COptional() {
+		isPresent, err := dec.readOptionTag(opt)
+		if err != nil {
+			return err
+		}
+		if !isPresent {
+			v.Set(reflect.Zero(rt))
+			return nil
+		}
+	}
+	if ptrImplements {
+		m := reflect.New(rt)
+		if err := m.Interface().(BinaryUnmarshaler).UnmarshalWithDecoder(dec); err != nil {
+			return err
+		}
+		v.Set(m.Elem())
+		return nil
+	}
+	m := reflect.New(rt.Elem())
+	if err := m.Interface().(BinaryUnmarshaler).UnmarshalWithDecoder(dec); err != nil {
+		return err
+	}
+	v.Set(m)
+	return nil
+}
+
 var (
 	marshalableType   = reflect.TypeOf((*BinaryMarshaler)(nil)).Elem()
 	unmarshalableType = reflect.TypeOf((*BinaryUnmarshaler)(nil)).Elem()
@@ -19,34 +19,25 @@ package bin
 
 import (
 	"fmt"
-	"io"
 	"reflect"
 
 	"go.uber.org/zap"
 )
 
-func (dec *Decoder) decodeWithOptionCompactU16(v interface{}, option *option) (err error) {
-	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr {
-		return &InvalidDecoderError{reflect.TypeOf(v)}
-	}
-
-	// We decode rv not rv.Elem because the Unmarshaler interface
-	// test must be applied at the top level of the value.
-	err = dec.decodeCompactU16(rv, option)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error) {
 	if opt == nil {
 		opt = newDefaultOption()
 	}
 	dec.currentFieldOpt = opt
 
-	unmarshaler, rv := indirect(rv, opt.is_Optional())
+	offset := int(dec.Position())
+	field := rv
+	unmarshaler, rv := indirect(rv, opt.is_Optional() || opt.is_COptional())
+	defer func() {
+		if err != nil {
+			err = newDecodeError(err, offset, decodedType(rv, unmarshaler))
+		}
+	}()
 
 	if traceEnabled {
 		zlog.Debug("decode: type",
@@ -56,37 +47,60 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 		)
 	}
 
-	if opt.is_Optional() {
-		isPresent, e := dec.ReadByte()
+	if opt.is_Optional() || opt.is_COptional() {
+		isPresent, e := dec.readOptionTag(opt)
 		if e != nil {
-			err = fmt.Errorf("decode: %t isPresent, %s", rv.Type(), e)
+			err = fmt.Errorf("decode: %s isPresent, %w", rv.Type(), e)
 			return
 		}
 
-		if isPresent == 0 {
+		if !isPresent {
 			if traceEnabled {
 				zlog.Debug("decode: skipping optional value", zap.Stringer("type", rv.Kind()))
 			}
 
+			if !rv.IsValid() {
+				// A value (not a pointer) with an UnmarshalWithDecoder method:
+				rv = field
+			}
 			rv.Set(reflect.Zero(rv.Type()))
 			return
 		}
 
-		// we have ptr here we should not go get the element
-		unmarshaler, rv = indirect(rv, false)
+		if unmarshaler == nil {
+			// we have ptr here we should not go get the element
+			unmarshaler, rv = indirect(rv, false)
+		}
+		// Reset optionality so it won't propagate to child types:
+		opt = opt.clone().set_Optional(false).set_COptional(false)
 	}
 
+	if opt.Compact {
+		return dec.decodeCompact(field)
+	}
 	if unmarshaler != nil {
 		if traceEnabled {
 			zlog.Debug("decode: using UnmarshalWithDecoder method to decode type")
 		}
 		return unmarshaler.UnmarshalWithDecoder(dec)
 	}
+	if opt.isText() {
+		return dec.decodeText(rv, opt)
+	}
+	if opt.Rest {
+		return dec.decodeRest(rv, opt)
+	}
 	rt := rv.Type()
 
 	switch rv.Kind() {
 	case reflect.String:
-		s, e := dec.ReadString()
+		var s string
+		var e error
+		if opt.LenPrefix != LengthPrefixDefault {
+			s, e = dec.readPrefixedString(opt.LenPrefix)
+		} else {
+			s, e = dec.ReadString()
+		}
 		if e != nil {
 			err = e
 			return
@@ -152,6 +166,12 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 		// skip
 		return nil
 	}
+
+	if err = dec.enterNested(); err != nil {
+		return err
+	}
+	defer dec.leaveNested()
+
 	switch rt.Kind() {
 	case reflect.Array:
 		l := rt.Len()
@@ -167,7 +187,7 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 		default:
 			for i := 0; i < l; i++ {
 				if err = dec.decodeCompactU16(rv.Index(i), nil); err != nil {
-					return
+					return withIndexPath(err, i)
 				}
 			}
 		}
@@ -177,7 +197,7 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 		if opt.hasSizeOfSlice() {
 			l = opt.getSizeOfSlice()
 		} else {
-			length, err := dec.ReadCompactU16Length()
+			length, err := dec.ReadLengthPrefix(opt.LenPrefix)
 			if err != nil {
 				return err
 			}
@@ -188,8 +208,15 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 			zlog.Debug("reading slice", zap.Int("len", l), typeField("type", rv))
 		}
 
-		if l > dec.Remaining() {
-			return io.ErrUnexpectedEOF
+		if err := dec.checkCollectionLength(l); err != nil {
+			return err
+		}
+		if err := dec.trackAllocation(l, int(rt.Elem().Size())); err != nil {
+			return err
+		}
+
+		if !dec.hasAtLeast(l) {
+			return fmt.Errorf("%w: slice of %d elements", ErrShortBuffer, l)
 		}
 
 		switch k := rv.Type().Elem().Kind(); k {
@@ -204,7 +231,7 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 				element := reflect.New(rt.Elem())
 				// decode into element:
 				if err = dec.decodeCompactU16(element, nil); err != nil {
-					return
+					return withIndexPath(err, i)
 				}
 				// append to slice:
 				rv.Set(reflect.Append(rv, element.Elem()))
@@ -212,15 +239,21 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 		}
 
 	case reflect.Struct:
-		if err = dec.decodeStructCompactU16(rt, rv); err != nil {
+		if err = dec.encoding.codec().DecodeStruct(dec, rv, decodeStructFieldsCompactU16); err != nil {
 			return
 		}
 
 	case reflect.Map:
-		l, err := dec.ReadCompactU16Length()
+		l, err := dec.ReadLengthPrefix(opt.LenPrefix)
 		if err != nil {
 			return err
 		}
+		if err := dec.checkCollectionLength(int(l)); err != nil {
+			return err
+		}
+		if err := dec.trackAllocation(int(l), int(rt.Key().Size()+rt.Elem().Size())); err != nil {
+			return err
+		}
 		if l == 0 {
 			// If the map has no content, keep it nil.
 			return nil
@@ -235,14 +268,14 @@ func (dec *Decoder) decodeCompactU16(rv reflect.Value, opt *option) (err error)
 			val := reflect.New(rt.Elem())
 			err = dec.decodeCompactU16(val.Elem(), nil)
 			if err != nil {
-				return err
+				return withKeyPath(err, key.Elem())
 			}
 			rv.SetMapIndex(key.Elem(), val.Elem())
 		}
 		return nil
 
 	default:
-		return fmt.Errorf("decode: unsupported type %q", rt)
+		return fmt.Errorf("decode: %w %q", ErrUnsupportedType, rt)
 	}
 
 	return
@@ -255,35 +288,40 @@ func (dec *Decoder) decodeStructCompactU16(rt reflect.Type, rv reflect.Value) (e
 		zlog.Debug("decode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
 	}
 
-	sizeOfMap := map[string]int{}
+	plan := getStructPlan(rt)
+	sizeOfMap := plan.newSizeOfTracker()
+	byteSizeOfMap := plan.newByteSizeOfTracker()
 	seenBinaryExtensionField := false
+	seenRestField := false
 	for i := 0; i < l; i++ {
-		structField := rt.Field(i)
-		fieldTag := parseFieldTag(structField.Tag)
+		structField := &plan.fields[i]
+		fieldTag := structField.tag
 
 		if fieldTag.Skip {
 			if traceEnabled {
 				zlog.Debug("decode: skipping struct field with skip flag",
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
 			continue
 		}
 
 		if !fieldTag.BinaryExtension && seenBinaryExtensionField {
-			panic(fmt.Sprintf("the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field %q", structField.Name))
+			panic(fmt.Sprintf("the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field %q", structField.name))
 		}
 
+		if seenRestField {
+			panic(fmt.Sprintf("the `bin:\"rest\"` tag must be on the last field of the struct, problematic field %q", structField.name))
+		}
+		seenRestField = fieldTag.Rest
+
 		if fieldTag.BinaryExtension {
 			seenBinaryExtensionField = true
-			// FIXME: This works only if what is in `d.data` is the actual full data buffer that
-			//        needs to be decoded. If there is for example two structs in the buffer, this
-			//        will not work as we would continue into the next struct.
-			//
-			//        But at the same time, does it make sense otherwise? What would be the inference
-			//        rule in the case of extra bytes available? Continue decoding and revert if it's
-			//        not working? But how to detect valid errors?
-			if len(dec.data[dec.pos:]) <= 0 {
+			// This works only if what is in `d.data` ends with the struct: if there is for example
+			// two structs in the buffer, we would continue into the next struct. The struct must then
+			// be the last value of the data, or its size in bytes must be known: a `byte_sizeof=`
+			// field (or SubDecoder) bounds the decoder to the bytes of the struct.
+			if !dec.HasRemaining() {
 				continue
 			}
 		}
@@ -295,11 +333,11 @@ func (dec *Decoder) decodeStructCompactU16(rt reflect.Type, rv reflect.Value) (e
 				// we cannot create a point to field skipping
 				if traceEnabled {
 					zlog.Debug("skipping struct field that cannot be addressed",
-						zap.String("struct_field_name", structField.Name),
+						zap.String("struct_field_name", structField.name),
 						zap.Stringer("struct_value_type", v.Kind()),
 					)
 				}
-				return fmt.Errorf("unable to decode a none setup struc field %q with type %q", structField.Name, v.Kind())
+				return fmt.Errorf("unable to decode a none setup struc field %q with type %q", structField.name, v.Kind())
 			}
 			v = v.Addr()
 		}
@@ -307,7 +345,7 @@ func (dec *Decoder) decodeStructCompactU16(rt reflect.Type, rv reflect.Value) (e
 		if !v.CanSet() {
 			if traceEnabled {
 				zlog.Debug("skipping struct field that cannot be addressed",
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 					zap.Stringer("struct_value_type", v.Kind()),
 				)
 			}
@@ -315,36 +353,56 @@ func (dec *Decoder) decodeStructCompactU16(rt reflect.Type, rv reflect.Value) (e
 		}
 
 		option := &option{
-			is_OptionalField: fieldTag.Option,
-			Order:            fieldTag.Order,
+			is_OptionalField:  fieldTag.Option,
+			is_COptionalField: fieldTag.COption,
+			Order:             fieldTag.Order,
+			FixedLayout:       fieldTag.FixedLayout,
+			FixedSize:         fieldTag.FixedSize,
+			CString:           fieldTag.CString,
+			LenPrefix:         fieldTag.LenPrefix,
+			Rest:              fieldTag.Rest,
+			Compact:           fieldTag.Compact,
 		}
 
-		if s, ok := sizeOfMap[structField.Name]; ok {
+		if s, ok := sizeOfMap.get(structField); ok {
 			option.setSizeOfSlice(s)
 		}
 
 		if traceEnabled {
 			zlog.Debug("decode: struct field",
 				zap.Stringer("struct_field_value_type", v.Kind()),
-				zap.String("struct_field_name", structField.Name),
+				zap.String("struct_field_name", structField.name),
 				zap.Reflect("struct_field_tags", fieldTag),
 				zap.Reflect("struct_field_option", option),
 			)
 		}
 
-		if err = dec.decodeCompactU16(v, option); err != nil {
-			return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+		fieldOffset := int(dec.Position())
+		fieldDec := dec
+		if n, ok := byteSizeOfMap.get(structField); ok {
+			if fieldDec, err = dec.SubDecoder(n); err != nil {
+				return newDecodeError(err, fieldOffset, structField.typ).withField(structField.name)
+			}
+		}
+		if err = fieldDec.decodeCompactU16(v, option); err == nil && fieldDec != dec {
+			err = dec.CloseSubDecoder(fieldDec)
+		}
+		if err != nil {
+			return newDecodeError(err, fieldOffset, structField.typ).withField(structField.name)
 		}
 
 		if fieldTag.SizeOf != "" {
-			size := sizeof(structField.Type, v)
+			size := sizeof(structField.typ, v)
 			if traceEnabled {
 				zlog.Debug("setting size of field",
 					zap.String("field_name", fieldTag.SizeOf),
 					zap.Int("size", size),
 				)
 			}
-			sizeOfMap[fieldTag.SizeOf] = size
+			sizeOfMap.set(structField, size)
+		}
+		if fieldTag.ByteSizeOf != "" {
+			byteSizeOfMap.setByteSize(structField, sizeof(structField.typ, v))
 		}
 	}
 	return
@@ -0,0 +1,121 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+// DecoderOptions defines the limits enforced by a Decoder,
+// to defend against hostile inputs (e.g. huge length prefixes).
+// A zero value means that the corresponding limit is disabled.
+type DecoderOptions struct {
+	// MaxCollectionLength is the maximum number of elements
+	// of a slice, map or byte slice.
+	MaxCollectionLength int
+	// MaxTotalAllocation is the maximum number of bytes that the
+	// collections and strings decoded by a single Decode call can take up.
+	MaxTotalAllocation int
+	// MaxNestingDepth is the maximum nesting depth
+	// of arrays, slices, structs and maps.
+	MaxNestingDepth int
+	// MaxStringLength is the maximum length (in bytes) of a string.
+	MaxStringLength int
+	// StrictKeyOrder rejects borsh maps whose keys are not
+	// in strictly increasing order (as borsh-rs does).
+	// Sets (`bin:"set"`) are always checked, and so are maps in strict mode.
+	StrictKeyOrder bool
+}
+
+// SetStrict enables (or disables) the strict mode of the decoder,
+// which rejects the inputs that are not canonical, as borsh-rs does:
+//
+//   - option bytes other than 0 or 1 (ErrInvalidOptionByte);
+//   - bool bytes other than 0 or 1 (ErrInvalidBoolByte);
+//   - map keys not in strictly increasing order (ErrUnsortedKeys, ErrDuplicateKey);
+//   - bytes left after a top-level Decode (ErrTrailingBytes);
+//     stream decoders are not checked for trailing bytes.
+func (dec *Decoder) SetStrict(strict bool) *Decoder {
+	dec.strict = strict
+	return dec
+}
+
+// IsStrict tells whether the strict mode of the decoder is enabled.
+func (dec *Decoder) IsStrict() bool {
+	return dec.strict
+}
+
+// SetOptions sets the limits enforced by the decoder.
+func (dec *Decoder) SetOptions(opts DecoderOptions) *Decoder {
+	dec.options = opts
+	return dec
+}
+
+// Options returns the limits enforced by the decoder.
+func (dec *Decoder) Options() DecoderOptions {
+	return dec.options
+}
+
+func (dec *Decoder) checkCollectionLength(l int) error {
+	if dec.options.MaxCollectionLength > 0 && l > dec.options.MaxCollectionLength {
+		return &LimitExceededError{Limit: "MaxCollectionLength", Max: dec.options.MaxCollectionLength, Value: l}
+	}
+	return nil
+}
+
+func (dec *Decoder) checkStringLength(l int) error {
+	if dec.options.MaxStringLength > 0 && l > dec.options.MaxStringLength {
+		return &LimitExceededError{Limit: "MaxStringLength", Max: dec.options.MaxStringLength, Value: l}
+	}
+	return nil
+}
+
+// trackAllocation accounts for count elements of the provided size
+// being allocated by the decoder.
+func (dec *Decoder) trackAllocation(count int, size int) error {
+	if dec.options.MaxTotalAllocation <= 0 || count <= 0 || size <= 0 {
+		return nil
+	}
+	max := dec.options.MaxTotalAllocation
+	if count > (max-dec.allocated)/size {
+		return &LimitExceededError{Limit: "MaxTotalAllocation", Max: max, Value: dec.allocated + count*size}
+	}
+	dec.allocated += count * size
+	return nil
+}
+
+// enterNested must be called before decoding a nested value;
+// if it succeeds, leaveNested must be called once done.
+func (dec *Decoder) enterNested() error {
+	if dec.options.MaxNestingDepth > 0 && dec.depth >= dec.options.MaxNestingDepth {
+		return &LimitExceededError{Limit: "MaxNestingDepth", Max: dec.options.MaxNestingDepth, Value: dec.depth + 1}
+	}
+	dec.depth++
+	return nil
+}
+
+func (dec *Decoder) leaveNested() {
+	dec.depth--
+}
+
+// EnterNested lets the UnmarshalWithDecoder methods (e.g. the ones
+// generated by cmd/bingen) enforce DecoderOptions.MaxNestingDepth:
+// it must be called before decoding a nested value, and
+// if it succeeds, LeaveNested must be called once done.
+func (dec *Decoder) EnterNested() error {
+	return dec.enterNested()
+}
+
+// LeaveNested must be called once done with a nested value
+// for which EnterNested succeeded.
+func (dec *Decoder) LeaveNested() {
+	dec.leaveNested()
+}
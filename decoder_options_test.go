@@ -0,0 +1,207 @@
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type limitsTestNested struct {
+	Inner *limitsTestNested `bin:"optional"`
+}
+
+// limitsTestTwoCalls calls Decode once for each of its fields.
+type limitsTestTwoCalls struct {
+	A []uint8
+	B []uint8
+}
+
+func (v *limitsTestTwoCalls) UnmarshalWithDecoder(dec *Decoder) error {
+	if err := dec.Decode(&v.A); err != nil {
+		return err
+	}
+	return dec.Decode(&v.B)
+}
+
+func TestDecoderOptions(t *testing.T) {
+	// A 4-byte hostile length prefix for a slice:
+	hostile := uint32ToBytes(0x7FFF_0000, LE)
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			{
+				data, err := marshalWithEncoding([]uint64{1, 2, 3}, enc)
+				require.NoError(t, err)
+
+				var got []uint64
+				dec := NewDecoderWithEncoding(data, enc).SetOptions(DecoderOptions{MaxCollectionLength: 2})
+				err = dec.Decode(&got)
+				require.True(t, errors.Is(err, ErrLimitExceeded))
+				var limitErr *LimitExceededError
+				require.True(t, errors.As(err, &limitErr))
+				require.Equal(t, "MaxCollectionLength", limitErr.Limit)
+				require.Equal(t, 2, limitErr.Max)
+				require.Equal(t, 3, limitErr.Value)
+
+				got = nil
+				dec = NewDecoderWithEncoding(data, enc).SetOptions(DecoderOptions{MaxCollectionLength: 3})
+				require.NoError(t, dec.Decode(&got))
+				require.Equal(t, []uint64{1, 2, 3}, got)
+			}
+			{
+				data, err := marshalWithEncoding(map[string]uint8{"a": 1, "b": 2}, enc)
+				require.NoError(t, err)
+
+				var got map[string]uint8
+				dec := NewDecoderWithEncoding(data, enc).SetOptions(DecoderOptions{MaxCollectionLength: 1})
+				require.True(t, errors.Is(dec.Decode(&got), ErrLimitExceeded))
+			}
+			{
+				data, err := marshalWithEncoding("hello world", enc)
+				require.NoError(t, err)
+
+				var got string
+				dec := NewDecoderWithEncoding(data, enc).SetOptions(DecoderOptions{MaxStringLength: 5})
+				err = dec.Decode(&got)
+				var limitErr *LimitExceededError
+				require.True(t, errors.As(err, &limitErr))
+				require.Equal(t, "MaxStringLength", limitErr.Limit)
+			}
+			{
+				data, err := marshalWithEncoding([]uint64{1, 2, 3}, enc)
+				require.NoError(t, err)
+
+				var got []uint64
+				dec := NewDecoderWithEncoding(data, enc).SetOptions(DecoderOptions{MaxTotalAllocation: 16})
+				err = dec.Decode(&got)
+				var limitErr *LimitExceededError
+				require.True(t, errors.As(err, &limitErr))
+				require.Equal(t, "MaxTotalAllocation", limitErr.Limit)
+
+				// The allocation budget is per Decode call.
+				dec = NewDecoderWithEncoding(append(data, data...), enc).SetOptions(DecoderOptions{MaxTotalAllocation: 24})
+				require.NoError(t, dec.Decode(&got))
+				require.NoError(t, dec.Decode(&got))
+			}
+			{
+				val := limitsTestNested{Inner: &limitsTestNested{Inner: &limitsTestNested{}}}
+				data, err := marshalWithEncoding(val, enc)
+				require.NoError(t, err)
+
+				var got limitsTestNested
+				dec := NewDecoderWithEncoding(data, enc).SetOptions(DecoderOptions{MaxNestingDepth: 2})
+				err = dec.Decode(&got)
+				var limitErr *LimitExceededError
+				require.True(t, errors.As(err, &limitErr))
+				require.Equal(t, "MaxNestingDepth", limitErr.Limit)
+
+				got = limitsTestNested{}
+				dec = NewDecoderWithEncoding(data, enc).SetOptions(DecoderOptions{MaxNestingDepth: 3})
+				require.NoError(t, dec.Decode(&got))
+				require.Equal(t, val, got)
+			}
+		})
+	}
+	{
+		var got []uint8
+		dec := NewBorshDecoder(hostile).SetOptions(DecoderOptions{MaxCollectionLength: 1024})
+		require.True(t, errors.Is(dec.Decode(&got), ErrLimitExceeded))
+	}
+	{
+		// The Decode calls of an UnmarshalWithDecoder method share the budget of the top-level call:
+		data := []byte{2, 0, 0, 0, 1, 2, 2, 0, 0, 0, 3, 4}
+		var got limitsTestTwoCalls
+		require.NoError(t, NewBorshDecoder(data).SetOptions(DecoderOptions{MaxTotalAllocation: 4}).Decode(&got))
+		require.Equal(t, limitsTestTwoCalls{A: []uint8{1, 2}, B: []uint8{3, 4}}, got)
+
+		err := NewBorshDecoder(data).SetOptions(DecoderOptions{MaxTotalAllocation: 3}).Decode(&got)
+		require.True(t, errors.Is(err, ErrLimitExceeded), err)
+	}
+}
+
+func marshalWithEncoding(v interface{}, enc Encoding) ([]byte, error) {
+	return appendWithEncoding(nil, v, enc)
+}
+
+type strictOptionalField struct {
+	A uint8 `bin:"optional"`
+}
+
+type strictBoolField struct {
+	B bool
+}
+
+type strictMapField struct {
+	M map[uint8]uint8
+}
+
+func TestDecoder_strict(t *testing.T) {
+	tests := []struct {
+		name string
+		// target returns a new value to decode into.
+		target func() interface{}
+		data   []byte
+		// err is the error returned in strict mode;
+		// nil means that the input is canonical.
+		err error
+	}{
+		{"option none", func() interface{} { return new(strictOptionalField) }, []byte{0}, nil},
+		{"option some", func() interface{} { return new(strictOptionalField) }, []byte{1, 7}, nil},
+		{"option byte 2", func() interface{} { return new(strictOptionalField) }, []byte{2, 7}, ErrInvalidOptionByte},
+		{"option byte 0xff", func() interface{} { return new(strictOptionalField) }, []byte{0xff, 7}, ErrInvalidOptionByte},
+		{"bool false", func() interface{} { return new(strictBoolField) }, []byte{0}, nil},
+		{"bool true", func() interface{} { return new(strictBoolField) }, []byte{1}, nil},
+		{"bool byte 2", func() interface{} { return new(strictBoolField) }, []byte{2}, ErrInvalidBoolByte},
+		{"bool slice", func() interface{} { return new([]bool) }, []byte{2, 0, 0, 0, 1, 3}, ErrInvalidBoolByte},
+		{"map sorted", func() interface{} { return new(strictMapField) }, []byte{2, 0, 0, 0, 1, 10, 2, 20}, nil},
+		{"map unsorted", func() interface{} { return new(strictMapField) }, []byte{2, 0, 0, 0, 2, 20, 1, 10}, ErrUnsortedKeys},
+		{"map duplicate", func() interface{} { return new(strictMapField) }, []byte{2, 0, 0, 0, 1, 10, 1, 20}, ErrDuplicateKey},
+		{"trailing byte", func() interface{} { return new(uint16) }, []byte{1, 0, 0}, ErrTrailingBytes},
+		{"trailing bytes after struct", func() interface{} { return new(strictBoolField) }, []byte{1, 0, 0}, ErrTrailingBytes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The lenient decoder accepts all of these:
+			require.NoError(t, UnmarshalBorsh(tt.target(), tt.data))
+
+			err := UnmarshalBorshStrict(tt.target(), tt.data)
+			if tt.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, tt.err), "got %v", err)
+			var decodeErr *DecodeError
+			require.True(t, errors.As(err, &decodeErr))
+		})
+	}
+}
+
+func TestDecoder_strictTrailingBytes(t *testing.T) {
+	dec := NewBorshDecoder([]byte{1, 0, 2, 0}).SetStrict(true)
+	require.True(t, dec.IsStrict())
+
+	var v uint16
+	err := dec.Decode(&v)
+	require.True(t, errors.Is(err, ErrTrailingBytes))
+	require.EqualError(t, err, "decode: uint16 (uint16) at offset 2: trailing bytes: 2 bytes")
+
+	// An UnmarshalWithDecoder method can call Decode several times:
+	var two limitsTestTwoCalls
+	require.NoError(t, UnmarshalBorshStrict(&two, []byte{1, 0, 0, 0, 1, 1, 0, 0, 0, 2}))
+	require.Equal(t, limitsTestTwoCalls{A: []uint8{1}, B: []uint8{2}}, two)
+	err = UnmarshalBorshStrict(&two, []byte{1, 0, 0, 0, 1, 1, 0, 0, 0, 2, 0})
+	require.True(t, errors.Is(err, ErrTrailingBytes), err)
+	require.NoError(t, UnmarshalBCS(&two, []byte{1, 1, 1, 2}))
+	var list []limitsTestTwoCalls
+	require.NoError(t, NewRLPDecoder([]byte{0xc2, 1, 2}).SetStrict(true).Decode(&list))
+	require.Equal(t, []limitsTestTwoCalls{{A: []uint8{1}, B: []uint8{2}}}, list)
+
+	// Stream decoders hold consecutive values, so trailing bytes are fine:
+	dec = NewBorshStreamDecoder(bytes.NewReader([]byte{1, 0, 2, 0})).SetStrict(true)
+	require.NoError(t, dec.Decode(&v))
+	require.Equal(t, uint16(1), v)
+	require.NoError(t, dec.Decode(&v))
+	require.Equal(t, uint16(2), v)
+}
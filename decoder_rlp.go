@@ -0,0 +1,242 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// decodeRLP is the reflective decoder of EncodingRLP.
+func (dec *Decoder) decodeRLP(rv reflect.Value, opt *option) (err error) {
+	if opt == nil {
+		opt = newDefaultOption()
+	}
+	dec.currentFieldOpt = opt
+
+	offset := int(dec.Position())
+	// Stop at the last pointer, which may be encoded as an empty item:
+	unmarshaler, rv := indirect(rv, true)
+	defer func() {
+		if err != nil {
+			err = newDecodeError(err, offset, decodedType(rv, unmarshaler))
+		}
+	}()
+
+	if traceEnabled {
+		zlog.Debug("decode: type",
+			zap.Stringer("value_kind", rv.Kind()),
+			zap.Bool("has_unmarshaler", (unmarshaler != nil)),
+			zap.Reflect("options", opt),
+		)
+	}
+
+	if unmarshaler == nil && rv.Kind() == reflect.Ptr {
+		// A nil pointer is encoded as the empty value of its type,
+		// which is decoded as nil if the field is optional, and as
+		// a pointer to the zero value otherwise:
+		empty := byte(rlpString)
+		if isRLPList(rv.Type().Elem()) {
+			empty = rlpList
+		}
+		next, e := dec.Peek(1)
+		if e != nil {
+			return e
+		}
+		if next[0] == empty {
+			if err = dec.Discard(1); err != nil {
+				return err
+			}
+			if opt.is_Optional() {
+				rv.Set(reflect.Zero(rv.Type()))
+			} else {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			return nil
+		}
+		unmarshaler, rv = indirect(rv, false)
+	}
+	opt = opt.clone().set_Optional(false).set_COptional(false)
+
+	if unmarshaler != nil {
+		// The fixed-size types of the package have no raw bytes in RLP:
+		switch u := unmarshaler.(type) {
+		case *Uint256:
+			value, err := dec.readRLPUint256()
+			if err != nil {
+				return err
+			}
+			value.Endianness = u.Endianness
+			*u = value
+			return nil
+		case *Float128:
+			return fmt.Errorf("%w: Float128 in RLP", ErrUnsupportedType)
+		}
+		if traceEnabled {
+			zlog.Debug("decode: using UnmarshalWithDecoder method to decode type")
+		}
+		return unmarshaler.UnmarshalWithDecoder(dec)
+	}
+
+	rt := rv.Type()
+	if rt == bigIntType {
+		data, err := dec.readRLPBigEndian()
+		if err != nil {
+			return err
+		}
+		rv.Addr().Interface().(*big.Int).SetBytes(data)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		data, err := dec.readRLPString()
+		if err != nil {
+			return err
+		}
+		if err = dec.trackAllocation(len(data), 1); err != nil {
+			return err
+		}
+		rv.SetString(string(data))
+		return nil
+	case reflect.Bool:
+		v, err := dec.readRLPUint(8)
+		if err != nil {
+			return err
+		}
+		if v.Lo > 1 {
+			return fmt.Errorf("%w: %d", ErrInvalidBoolByte, v.Lo)
+		}
+		rv.SetBool(v.Lo == 1)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := dec.readRLPUint(rt.Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(v.Lo)
+		return nil
+	case reflect.Interface:
+		// Skip: cannot know the concrete type of the interface.
+		// The parent container should implement a custom decoder.
+		return nil
+	}
+
+	if err = dec.enterNested(); err != nil {
+		return err
+	}
+	defer dec.leaveNested()
+
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			data, err := dec.readRLPString()
+			if err != nil {
+				return err
+			}
+			if err = dec.trackAllocation(len(data), 1); err != nil {
+				return err
+			}
+			if len(data) == 0 {
+				// Empty slices are left nil
+				return nil
+			}
+			rv.SetBytes(append([]byte(nil), data...))
+			return nil
+		}
+		sub, err := dec.readRLPList()
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.Zero(rt))
+		for i := 0; sub.HasRemaining(); i++ {
+			if err := sub.checkCollectionLength(i + 1); err != nil {
+				return err
+			}
+			if err := sub.trackAllocation(1, int(rt.Elem().Size())); err != nil {
+				return err
+			}
+			element := reflect.New(rt.Elem())
+			if err = sub.decodeRLP(element, nil); err != nil {
+				return withIndexPath(err, i)
+			}
+			rv.Set(reflect.Append(rv, element.Elem()))
+		}
+		return dec.CloseSubDecoder(sub)
+
+	case reflect.Array:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			data, err := dec.readRLPString()
+			if err != nil {
+				return err
+			}
+			if len(data) != rt.Len() {
+				return fmt.Errorf("%w: RLP string of %d bytes for a %s", ErrUnexpectedKind, len(data), rt)
+			}
+			reflect.Copy(rv, reflect.ValueOf(data))
+			return nil
+		}
+		sub, err := dec.readRLPList()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < rt.Len(); i++ {
+			if err = sub.decodeRLP(rv.Index(i), nil); err != nil {
+				return withIndexPath(err, i)
+			}
+		}
+		return dec.CloseSubDecoder(sub)
+
+	case reflect.Struct:
+		return dec.encoding.codec().DecodeStruct(dec, rv, decodeStructFieldsRLP)
+
+	default:
+		return fmt.Errorf("decode: %w %q", ErrUnsupportedType, rt)
+	}
+}
+
+// decodeStructRLP decodes the fields of the struct rv from the items of its list.
+func (dec *Decoder) decodeStructRLP(rt reflect.Type, rv reflect.Value) (err error) {
+	plan := getStructPlan(rt)
+	for i := range plan.fields {
+		structField := &plan.fields[i]
+		fieldTag := structField.tag
+		if fieldTag.Skip {
+			continue
+		}
+		if fieldTag.BinaryExtension && !dec.HasRemaining() {
+			// The trailing extensions may be missing.
+			continue
+		}
+		v := rv.Field(i)
+		if !v.CanSet() {
+			// Like the unexported fields, which aren't encoded.
+			continue
+		}
+
+		option := &option{
+			is_OptionalField: fieldTag.Option,
+			Order:            fieldTag.Order,
+		}
+		fieldOffset := int(dec.Position())
+		if err = dec.decodeRLP(v, option); err != nil {
+			return newDecodeError(err, fieldOffset, structField.typ).withField(structField.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultStreamWindowSize is the size of the window buffered by stream decoders.
+const DefaultStreamWindowSize = 4096
+
+// ErrUnsupportedOnStream is returned by the operations that cannot be
+// performed by a stream decoder (e.g. peeking or seeking outside of
+// the buffered window).
+var ErrUnsupportedOnStream = errors.New("operation unsupported on stream decoder")
+
+// maxConsecutiveEmptyReads is the number of consecutive (0, nil)
+// reads after which a reader is considered broken.
+const maxConsecutiveEmptyReads = 100
+
+type streamSource struct {
+	reader io.Reader
+	window int
+	// err is the sticky error returned by the reader
+	// (io.EOF once the end of the stream is reached).
+	err error
+}
+
+// NewStreamDecoderWithEncoding creates a decoder that reads the data
+// to be decoded from the provided reader, buffering it as needed.
+//
+// The Read* methods and Decode work just like on a decoder created from a
+// byte slice; Peek and SetPosition only work within the buffered window,
+// and Remaining/Len only know about the data that has been read so far.
+func NewStreamDecoderWithEncoding(reader io.Reader, enc Encoding) *Decoder {
+	if !isValidEncoding(enc) {
+		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
+	}
+	return &Decoder{
+		encoding: enc,
+		stream: &streamSource{
+			reader: reader,
+			window: DefaultStreamWindowSize,
+		},
+	}
+}
+
+func NewBinStreamDecoder(reader io.Reader) *Decoder {
+	return NewStreamDecoderWithEncoding(reader, EncodingBin)
+}
+
+func NewBorshStreamDecoder(reader io.Reader) *Decoder {
+	return NewStreamDecoderWithEncoding(reader, EncodingBorsh)
+}
+
+func NewCompactU16StreamDecoder(reader io.Reader) *Decoder {
+	return NewStreamDecoderWithEncoding(reader, EncodingCompactU16)
+}
+
+// IsStream returns true if the decoder reads from an io.Reader.
+func (dec *Decoder) IsStream() bool {
+	return dec.stream != nil
+}
+
+// fill reads from the stream until at least n bytes are buffered
+// after the decoder's position, or the stream ends.
+func (s *streamSource) fill(dec *Decoder, n int) bool {
+	empty := 0
+	for s.err == nil && len(dec.data)-dec.pos < n {
+		if len(dec.data) == cap(dec.data) {
+			s.grow(dec, n)
+		}
+		read, err := s.reader.Read(dec.data[len(dec.data):cap(dec.data)])
+		dec.data = dec.data[:len(dec.data)+read]
+		if err != nil {
+			s.err = err
+			break
+		}
+		if read == 0 {
+			empty++
+			if empty >= maxConsecutiveEmptyReads {
+				s.err = io.ErrNoProgress
+				break
+			}
+			continue
+		}
+		empty = 0
+	}
+	return len(dec.data)-dec.pos >= n
+}
+
+// grow moves the unread bytes to a new buffer with room for more bytes,
+// up to n unread bytes. The buffer grows with the bytes actually read
+// (by a window, or by as many bytes as are buffered), so that a length
+// read from the stream can't allocate more than the stream holds.
+func (s *streamSource) grow(dec *Decoder, n int) {
+	unread := len(dec.data) - dec.pos
+	step := unread
+	if step < s.window {
+		step = s.window
+	}
+	size := unread + step
+	if size > n {
+		size = n
+	}
+	if size < s.window {
+		size = s.window
+	}
+	// Allocate a fresh buffer instead of compacting in place:
+	// slices previously returned by the decoder might alias the current one.
+	buf := make([]byte, unread, size)
+	copy(buf, dec.data[dec.pos:])
+	dec.offset += dec.pos
+	dec.data = buf
+	dec.pos = 0
+}
+
+func (s *streamSource) skip(dec *Decoder, count uint) error {
+	for count > 0 {
+		chunk := s.window
+		if uint(chunk) > count {
+			chunk = int(count)
+		}
+		dec.fill(chunk)
+		available := len(dec.data) - dec.pos
+		if available == 0 {
+			return fmt.Errorf("request to skip %d more bytes but the stream has ended", count)
+		}
+		if available > chunk {
+			available = chunk
+		}
+		dec.pos += available
+		count -= uint(available)
+	}
+	return nil
+}
+
+func (s *streamSource) setPosition(dec *Decoder, idx uint) error {
+	if int(idx) >= dec.offset && int(idx) < dec.offset+len(dec.data) {
+		dec.pos = int(idx) - dec.offset
+		return nil
+	}
+	return fmt.Errorf("request to set position to %d outside of the buffered window [%d, %d): %w", idx, dec.offset, dec.offset+len(dec.data), ErrUnsupportedOnStream)
+}
+
+// wrapErr adds the read error of the stream (if any, other than EOF) to
+// the provided decoding error.
+func (s *streamSource) wrapErr(err error) error {
+	if s.err == nil || s.err == io.EOF {
+		return err
+	}
+	if decodeErr, ok := err.(*DecodeError); ok {
+		decodeErr.Err = &streamReadError{err: decodeErr.Err, readErr: s.err}
+		return decodeErr
+	}
+	return &streamReadError{err: err, readErr: s.err}
+}
+
+// streamReadError is a decoding error (e.g. ErrShortBuffer) caused by
+// the read error of a stream: errors.Is and errors.As match both.
+type streamReadError struct {
+	err     error
+	readErr error
+}
+
+func (e *streamReadError) Error() string {
+	return fmt.Sprintf("%s: %s", e.err, e.readErr)
+}
+
+func (e *streamReadError) Unwrap() error {
+	return e.err
+}
+
+func (e *streamReadError) Is(target error) bool {
+	return errors.Is(e.readErr, target)
+}
+
+func (e *streamReadError) As(target interface{}) bool {
+	return errors.As(e.readErr, target)
+}
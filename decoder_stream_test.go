@@ -0,0 +1,185 @@
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+type streamTestRecord struct {
+	ID      uint64
+	Name    string
+	Flag    bool
+	Payload []byte
+	Values  []uint32
+	Big     Uint128
+	Opt     *uint16 `bin:"optional"`
+	Nested  []streamTestNested
+}
+
+type streamTestNested struct {
+	A int16
+	B [3]byte
+}
+
+func newStreamTestRecord(i int) streamTestRecord {
+	opt := uint16(i)
+	return streamTestRecord{
+		ID:      uint64(i),
+		Name:    "record",
+		Flag:    i%2 == 0,
+		Payload: bytes.Repeat([]byte{byte(i)}, i*100),
+		Values:  []uint32{1, 2, uint32(i)},
+		Big:     Uint128{Lo: uint64(i), Hi: 1},
+		Opt:     &opt,
+		Nested:  []streamTestNested{{A: -1, B: [3]byte{1, 2, 3}}},
+	}
+}
+
+func TestStreamDecoder_Decode(t *testing.T) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			encoder := NewEncoderWithEncoding(buf, enc)
+			var expected []streamTestRecord
+			for i := 1; i < 80; i++ {
+				record := newStreamTestRecord(i)
+				expected = append(expected, record)
+				require.NoError(t, encoder.Encode(record))
+			}
+			data := buf.Bytes()
+
+			for _, reader := range []io.Reader{
+				bytes.NewReader(data),
+				iotest.OneByteReader(bytes.NewReader(data)),
+				iotest.HalfReader(bytes.NewReader(data)),
+			} {
+				decoder := NewStreamDecoderWithEncoding(reader, enc)
+				require.True(t, decoder.IsStream())
+				for _, record := range expected {
+					var got streamTestRecord
+					require.NoError(t, decoder.Decode(&got))
+					require.Equal(t, record, got)
+				}
+				require.False(t, decoder.HasRemaining())
+				require.Equal(t, 0, decoder.Remaining())
+				require.Equal(t, uint(len(data)), decoder.Position())
+				require.Equal(t, len(data), decoder.Len())
+
+				var got streamTestRecord
+				require.Error(t, decoder.Decode(&got))
+			}
+		})
+	}
+}
+
+func TestStreamDecoder_Primitives(t *testing.T) {
+	data := concatByteSlices(
+		[]byte{0xaa},
+		uint32ToBytes(7, LE),
+		uint64ToBytes(9, BE),
+		[]byte{3, 'a', 'b', 'c'},
+		[]byte{0x81, 0x01},
+	)
+	decoder := NewBinStreamDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+
+	b, err := decoder.ReadByte()
+	require.NoError(t, err)
+	require.Equal(t, byte(0xaa), b)
+
+	u32, err := decoder.ReadUint32(LE)
+	require.NoError(t, err)
+	require.Equal(t, uint32(7), u32)
+
+	peeked, err := decoder.Peek(8)
+	require.NoError(t, err)
+	require.Equal(t, uint64ToBytes(9, BE), peeked)
+
+	u64, err := decoder.ReadUint64(BE)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), u64)
+
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	require.Equal(t, "abc", s)
+
+	l, err := decoder.ReadCompactU16()
+	require.NoError(t, err)
+	require.Equal(t, 129, l)
+
+	_, err = decoder.ReadByte()
+	require.Error(t, err)
+}
+
+func TestStreamDecoder_Window(t *testing.T) {
+	data := make([]byte, DefaultStreamWindowSize*3)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	decoder := NewBorshStreamDecoder(bytes.NewReader(data))
+
+	_, err := decoder.Peek(DefaultStreamWindowSize + 1)
+	require.True(t, errors.Is(err, ErrUnsupportedOnStream))
+
+	require.Equal(t, DefaultStreamWindowSize, decoder.Remaining())
+
+	require.NoError(t, decoder.SkipBytes(10))
+	require.NoError(t, decoder.SetPosition(2))
+	b, err := decoder.ReadByte()
+	require.NoError(t, err)
+	require.Equal(t, byte(2), b)
+
+	require.NoError(t, decoder.SkipBytes(uint(DefaultStreamWindowSize*2)))
+	require.Equal(t, uint(DefaultStreamWindowSize*2+3), decoder.Position())
+
+	err = decoder.SetPosition(0)
+	require.True(t, errors.Is(err, ErrUnsupportedOnStream))
+
+	b, err = decoder.ReadByte()
+	require.NoError(t, err)
+	require.Equal(t, data[DefaultStreamWindowSize*2+3], b)
+
+	require.Error(t, decoder.SkipBytes(uint(DefaultStreamWindowSize)))
+}
+
+func TestStreamDecoder_ReadError(t *testing.T) {
+	readErr := errors.New("boom")
+	reader := io.MultiReader(
+		bytes.NewReader(uint64ToBytes(1, LE)),
+		iotest.ErrReader(readErr),
+	)
+	decoder := NewBorshStreamDecoder(reader)
+	var got struct {
+		A uint64
+		B uint64
+	}
+	err := decoder.Decode(&got)
+	require.Error(t, err)
+	// Both the decoding error and the read error are in the chain:
+	require.True(t, errors.Is(err, readErr), err)
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	require.Contains(t, err.Error(), "boom")
+}
+
+func TestStreamDecoder_LargeLength(t *testing.T) {
+	// The length of the string is 256 MiB, but the stream ends after 1 byte:
+	decoder := NewBorshStreamDecoder(bytes.NewReader([]byte{0, 0, 0, 0x10, 1}))
+	var s string
+	err := decoder.Decode(&s)
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+	require.LessOrEqual(t, cap(decoder.data), DefaultStreamWindowSize)
+
+	// Long strings are buffered as they are read:
+	want := string(bytes.Repeat([]byte("abc"), DefaultStreamWindowSize*3))
+	data, err := MarshalBorsh(want)
+	require.NoError(t, err)
+	decoder = NewBorshStreamDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+	require.NoError(t, decoder.Decode(&s))
+	require.Equal(t, want, s)
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+)
+
+// SubDecoder returns a decoder of the next n bytes, which can't read past
+// them (e.g. its HasRemaining returns false at their end, so a nested struct
+// with `binary_extension` fields can be decoded from the middle of the data).
+// dec is moved past the n bytes; if less than n bytes are left,
+// SubDecoder returns ErrShortBuffer and dec is left unchanged.
+//
+// The sub-decoder has the encoding, options and strictness of dec;
+// its positions (and the offsets of its errors) are those of dec.
+func (dec *Decoder) SubDecoder(n int) (*Decoder, error) {
+	if n < 0 || n > 0x7FFF_FFFF {
+		return nil, fmt.Errorf("%w: invalid length n: %v", ErrLengthTooLarge, n)
+	}
+	if !dec.fill(n) {
+		return nil, fmt.Errorf("%w: sub-decoder of %d bytes, %d bytes left", ErrShortBuffer, n, len(dec.data)-dec.pos)
+	}
+	sub := dec.sub(n)
+	dec.pos += n
+	return sub, nil
+}
+
+// Limit returns a decoder of at most the next n bytes: like io.LimitReader,
+// it doesn't fail if less than n bytes are left, and the sub-decoder ends
+// with the data. dec is moved past the bytes of the sub-decoder.
+// See SubDecoder.
+func (dec *Decoder) Limit(n int) *Decoder {
+	if n < 0 {
+		n = 0
+	}
+	if !dec.fill(n) {
+		n = len(dec.data) - dec.pos
+	}
+	sub := dec.sub(n)
+	dec.pos += n
+	return sub
+}
+
+// sub returns a decoder of the next n bytes, which must be buffered.
+func (dec *Decoder) sub(n int) *Decoder {
+	return &Decoder{
+		// The capacity is limited too, so that appending
+		// to the returned slices doesn't overwrite dec's data.
+		data:      dec.data[dec.pos : dec.pos+n : dec.pos+n],
+		offset:    int(dec.Position()),
+		encoding:  dec.encoding,
+		options:   dec.options,
+		strict:    dec.strict,
+		depth:     dec.depth,
+		allocated: dec.allocated,
+		calls:     dec.calls,
+	}
+}
+
+// CloseSubDecoder must be called once a value has been decoded from sub,
+// a sub-decoder of dec: the bytes of sub that the value didn't use (e.g. the
+// extensions unknown to its Go type) are skipped, unless dec is strict,
+// and the allocations of sub count towards the limits of dec.
+func (dec *Decoder) CloseSubDecoder(sub *Decoder) error {
+	dec.allocated = sub.allocated
+	if dec.strict && sub.Remaining() > 0 {
+		return fmt.Errorf("%w: %d bytes after the value", ErrTrailingBytes, sub.Remaining())
+	}
+	return nil
+}
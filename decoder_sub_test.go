@@ -0,0 +1,157 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_SubDecoder(t *testing.T) {
+	dec := NewBorshDecoder([]byte{1, 2, 3, 4, 5, 6})
+	_, err := dec.ReadUint8()
+	require.NoError(t, err)
+
+	sub, err := dec.SubDecoder(3)
+	require.NoError(t, err)
+	require.Equal(t, uint(4), dec.Position())
+	require.Equal(t, uint(1), sub.Position())
+	require.Equal(t, 3, sub.Remaining())
+	require.True(t, sub.IsBorsh())
+
+	v, err := sub.ReadUint16(LE)
+	require.NoError(t, err)
+	require.Equal(t, uint16(0x0302), v)
+	require.Equal(t, uint(3), sub.Position())
+
+	// The sub-decoder can't read past its end:
+	_, err = sub.ReadUint16(LE)
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+	require.NoError(t, sub.SetPosition(1))
+	require.Error(t, sub.SetPosition(4))
+	b, err := sub.ReadNBytes(3)
+	require.NoError(t, err)
+	require.Equal(t, []byte{2, 3, 4}, b)
+	require.False(t, sub.HasRemaining())
+
+	// Not enough bytes: dec is left unchanged.
+	_, err = dec.SubDecoder(3)
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+	require.Equal(t, uint(4), dec.Position())
+	_, err = dec.SubDecoder(-1)
+	require.True(t, errors.Is(err, ErrLengthTooLarge), err)
+
+	// Limit takes what's left.
+	sub = dec.Limit(3)
+	require.Equal(t, 2, sub.Remaining())
+	require.False(t, dec.HasRemaining())
+	b, err = sub.ReadNBytes(2)
+	require.NoError(t, err)
+	require.Equal(t, []byte{5, 6}, b)
+	require.Equal(t, 0, dec.Limit(10).Remaining())
+
+	// The errors of the sub-decoder have the offsets of the parent:
+	dec = NewBorshDecoder([]byte{0, 0, 0xff, 0xff, 0xff, 0xff})
+	require.NoError(t, dec.SkipBytes(2))
+	sub, err = dec.SubDecoder(4)
+	require.NoError(t, err)
+	var s string
+	err = sub.Decode(&s)
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr), err)
+	require.Equal(t, 2, decodeErr.Offset)
+}
+
+func TestDecoder_SubDecoder_stream(t *testing.T) {
+	data := bytes.Repeat([]byte{1, 2, 3}, 2*DefaultStreamWindowSize)
+	dec := NewBorshStreamDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+	require.NoError(t, dec.SkipBytes(3))
+
+	sub, err := dec.SubDecoder(3 * DefaultStreamWindowSize)
+	require.NoError(t, err)
+	require.False(t, sub.IsStream())
+	require.Equal(t, uint(3), sub.Position())
+	b, err := sub.ReadNBytes(3 * DefaultStreamWindowSize)
+	require.NoError(t, err)
+	require.Equal(t, data[3:3+3*DefaultStreamWindowSize], b)
+
+	require.Equal(t, uint(3+3*DefaultStreamWindowSize), dec.Position())
+	b, err = dec.ReadNBytes(3)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, b)
+}
+
+type sizedExtended struct {
+	Value uint32
+	Ext   uint16 `bin:"binary_extension"`
+}
+
+type sizedOuter struct {
+	Size  uint8 `bin:"byte_sizeof=Inner"`
+	Inner sizedExtended
+	After uint8
+}
+
+func TestByteSizeOfTag(t *testing.T) {
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		for _, test := range []struct {
+			data []byte
+			want sizedOuter
+		}{
+			{
+				data: []byte{6, 1, 0, 0, 0, 2, 0, 3},
+				want: sizedOuter{Size: 6, Inner: sizedExtended{Value: 1, Ext: 2}, After: 3},
+			},
+			{
+				// Without the extension:
+				data: []byte{4, 1, 0, 0, 0, 3},
+				want: sizedOuter{Size: 4, Inner: sizedExtended{Value: 1}, After: 3},
+			},
+			{
+				// With an unknown extension:
+				data: []byte{7, 1, 0, 0, 0, 2, 0, 0xff, 3},
+				want: sizedOuter{Size: 7, Inner: sizedExtended{Value: 1, Ext: 2}, After: 3},
+			},
+		} {
+			var got sizedOuter
+			require.NoError(t, NewDecoderWithEncoding(test.data, encoding).Decode(&got), encoding)
+			require.Equal(t, test.want, got, encoding)
+		}
+
+		// Like sizeof, the size is encoded as set:
+		x := sizedOuter{Size: 6, Inner: sizedExtended{Value: 1, Ext: 2}, After: 3}
+		data, err := marshalWithEncoding(&x, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, []byte{6, 1, 0, 0, 0, 2, 0, 3}, data, encoding)
+
+		var got sizedOuter
+		err = NewDecoderWithEncoding([]byte{5, 1, 0, 0, 0, 3}, encoding).Decode(&got)
+		require.True(t, errors.Is(err, ErrShortBuffer), err)
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr), err)
+		require.Equal(t, "sizedOuter.Inner.Ext", decodeErr.Path)
+
+		// A struct too large for its size:
+		err = NewDecoderWithEncoding([]byte{3, 1, 0, 0, 0, 3}, encoding).Decode(&got)
+		require.True(t, errors.Is(err, ErrShortBuffer), err)
+
+		err = NewDecoderWithEncoding([]byte{7, 1, 0, 0, 0, 2, 0, 0xff, 3}, encoding).SetStrict(true).Decode(&got)
+		require.True(t, errors.Is(err, ErrTrailingBytes), err)
+	}
+}
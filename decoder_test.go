@@ -20,6 +20,7 @@ package bin
 import (
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"math"
 	"reflect"
 	"testing"
@@ -510,7 +511,8 @@ func TestDecoder_Decode_String_Err(t *testing.T) {
 
 	var s string
 	err := decoder.Decode(&s)
-	assert.EqualError(t, err, "decode: uint64 required [8] bytes, remaining [5]")
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.EqualError(t, err, "decode: string (string) at offset 0: short buffer: uint64 required [8] bytes, remaining [5]")
 }
 
 func TestDecoder_Byte(t *testing.T) {
@@ -575,7 +577,8 @@ func TestDecoder_ByteArray_MissingData(t *testing.T) {
 	d := NewBinDecoder(buf)
 
 	_, err := d.ReadByteSlice()
-	assert.EqualError(t, err, "byte array: varlen=10, missing 10 bytes")
+	assert.True(t, errors.Is(err, ErrShortBuffer))
+	assert.EqualError(t, err, "short buffer: byte array: varlen=10, missing 10 bytes")
 }
 
 func TestDecoder_Array(t *testing.T) {
@@ -594,13 +597,13 @@ func TestDecoder_Slice_Err(t *testing.T) {
 	decoder := NewBinDecoder(buf)
 	var s []string
 	err := decoder.Decode(&s)
-	assert.Equal(t, ErrVarIntBufferSize, err)
+	assert.True(t, errors.Is(err, ErrVarIntBufferSize))
 
 	buf = []byte{0x01}
 
 	decoder = NewBinDecoder(buf)
 	err = decoder.Decode(&s)
-	assert.EqualError(t, err, "unexpected EOF")
+	assert.True(t, errors.Is(err, ErrShortBuffer))
 }
 
 func TestDecoder_Slice_InvalidLen(t *testing.T) {
@@ -609,7 +612,7 @@ func TestDecoder_Slice_InvalidLen(t *testing.T) {
 	decoder := NewBinDecoder(buf)
 	var s []string
 	err := decoder.Decode(&s)
-	assert.EqualError(t, err, "unexpected EOF")
+	assert.True(t, errors.Is(err, ErrLengthTooLarge))
 }
 
 func TestDecoder_Int64(t *testing.T) {
@@ -24,6 +24,7 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -32,9 +33,16 @@ type Encoder struct {
 	count int
 
 	currentFieldOpt *option
+	fieldOpt        option
 	encoding        Encoding
 
 	output io.Writer
+
+	// buffered encoders append to buf instead of writing to output,
+	// and reuse scratch to serialize primitives without allocating.
+	buffered bool
+	buf      []byte
+	scratch  [32]byte
 }
 
 func (enc *Encoder) IsBorsh() bool {
@@ -49,6 +57,11 @@ func (enc *Encoder) IsCompactU16() bool {
 	return enc.encoding.IsCompactU16()
 }
 
+// Encoding returns the encoding scheme of the encoder.
+func (enc *Encoder) Encoding() Encoding {
+	return enc.encoding
+}
+
 func NewEncoderWithEncoding(writer io.Writer, enc Encoding) *Encoder {
 	if !isValidEncoding(enc) {
 		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
@@ -60,6 +73,52 @@ func NewEncoderWithEncoding(writer io.Writer, enc Encoding) *Encoder {
 	}
 }
 
+// NewBufferedEncoderWithEncoding returns an encoder that appends
+// the encoded bytes to an internal buffer instead of writing them to an io.Writer.
+// The buffer is retrieved with Bytes, and is reused across calls to Reset,
+// so that encoding into a warmed-up buffered encoder does not allocate.
+func NewBufferedEncoderWithEncoding(enc Encoding) *Encoder {
+	if !isValidEncoding(enc) {
+		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
+	}
+	return &Encoder{
+		buffered: true,
+		encoding: enc,
+	}
+}
+
+func NewBinBufferedEncoder() *Encoder {
+	return NewBufferedEncoderWithEncoding(EncodingBin)
+}
+
+func NewBorshBufferedEncoder() *Encoder {
+	return NewBufferedEncoderWithEncoding(EncodingBorsh)
+}
+
+func NewCompactU16BufferedEncoder() *Encoder {
+	return NewBufferedEncoderWithEncoding(EncodingCompactU16)
+}
+
+// Bytes returns the bytes appended so far to a buffered encoder.
+// The returned slice is only valid until the next call to Reset.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Reset empties the buffer of a buffered encoder (keeping its capacity)
+// and resets the count of written bytes.
+func (e *Encoder) Reset() {
+	e.buf = e.buf[:0]
+	e.count = 0
+}
+
+// setBuffer makes the encoder append to dst.
+func (e *Encoder) setBuffer(dst []byte) {
+	e.buffered = true
+	e.buf = dst
+	e.count = 0
+}
+
 func NewBinEncoder(writer io.Writer) *Encoder {
 	return NewEncoderWithEncoding(writer, EncodingBin)
 }
@@ -73,16 +132,7 @@ func NewCompactU16Encoder(writer io.Writer) *Encoder {
 }
 
 func (e *Encoder) Encode(v interface{}) (err error) {
-	switch e.encoding {
-	case EncodingBin:
-		return e.encodeBin(reflect.ValueOf(v), nil)
-	case EncodingBorsh:
-		return e.encodeBorsh(reflect.ValueOf(v), nil)
-	case EncodingCompactU16:
-		return e.encodeCompactU16(reflect.ValueOf(v), nil)
-	default:
-		panic(fmt.Errorf("encoding not implemented: %s", e.encoding))
-	}
+	return e.encodeValue(reflect.ValueOf(v))
 }
 
 func (e *Encoder) toWriter(bytes []byte) (err error) {
@@ -90,10 +140,31 @@ func (e *Encoder) toWriter(bytes []byte) (err error) {
 	if traceEnabled {
 		zlog.Debug("	> encode: appending", zap.Stringer("hex", HexBytes(bytes)), zap.Int("pos", e.count))
 	}
+	if e.buffered {
+		e.buf = append(e.buf, bytes...)
+		return nil
+	}
 	_, err = e.output.Write(bytes)
 	return
 }
 
+// scratchBuf returns a buffer of n bytes to serialize a primitive into.
+// Buffered encoders copy what they write, so they can reuse their scratch space;
+// an io.Writer may retain the written slice, so in that case a new one is allocated.
+func (e *Encoder) scratchBuf(n int) []byte {
+	if e.buffered {
+		return e.scratch[:n]
+	}
+	return make([]byte, n)
+}
+
+// setCurrentFieldOpt records the options of the value being encoded,
+// copying them so that the caller's options don't escape to the heap.
+func (e *Encoder) setCurrentFieldOpt(opt *option) {
+	e.fieldOpt = *opt
+	e.currentFieldOpt = &e.fieldOpt
+}
+
 // Written returns the count of bytes written.
 func (e *Encoder) Written() int {
 	return e.count
@@ -119,32 +190,20 @@ func (e *Encoder) Write(b []byte) (n int, err error) {
 	if traceEnabled {
 		zlog.Debug("	> encode: appending", zap.Stringer("hex", HexBytes(b)), zap.Int("pos", e.count))
 	}
+	if e.buffered {
+		e.buf = append(e.buf, b...)
+		return len(b), nil
+	}
 	return e.output.Write(b)
 }
 
+// WriteLength writes the length prefix of a string, slice or map,
+// in the format of the encoding of the encoder.
 func (e *Encoder) WriteLength(length int) error {
 	if traceEnabled {
 		zlog.Debug("encode: write length", zap.Int("len", length))
 	}
-	switch e.encoding {
-	case EncodingBin:
-		if err := e.WriteUVarInt(length); err != nil {
-			return err
-		}
-	case EncodingBorsh:
-		if err := e.WriteUint32(uint32(length), LE); err != nil {
-			return err
-		}
-	case EncodingCompactU16:
-		var buf []byte
-		EncodeCompactU16Length(&buf, length)
-		if err := e.WriteBytes(buf, false); err != nil {
-			return err
-		}
-	default:
-		panic(fmt.Errorf("encoding not implemented: %s", e.encoding))
-	}
-	return nil
+	return e.encoding.codec().WriteLength(e, length)
 }
 
 func (e *Encoder) WriteUVarInt(v int) (err error) {
@@ -152,7 +211,7 @@ func (e *Encoder) WriteUVarInt(v int) (err error) {
 		zlog.Debug("encode: write uvarint", zap.Int("val", v))
 	}
 
-	buf := make([]byte, 8)
+	buf := e.scratchBuf(binary.MaxVarintLen64)
 	l := binary.PutUvarint(buf, uint64(v))
 	return e.toWriter(buf[:l])
 }
@@ -162,7 +221,7 @@ func (e *Encoder) WriteVarInt(v int) (err error) {
 		zlog.Debug("encode: write varint", zap.Int("val", v))
 	}
 
-	buf := make([]byte, 8)
+	buf := e.scratchBuf(binary.MaxVarintLen64)
 	l := binary.PutVarint(buf, int64(v))
 	return e.toWriter(buf[:l])
 }
@@ -171,7 +230,9 @@ func (e *Encoder) WriteByte(b byte) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write byte", zap.Uint8("val", b))
 	}
-	return e.toWriter([]byte{b})
+	buf := e.scratchBuf(1)
+	buf[0] = b
+	return e.toWriter(buf)
 }
 
 func (e *Encoder) WriteOption(b bool) (err error) {
@@ -192,6 +253,15 @@ func (e *Encoder) WriteCOption(b bool) (err error) {
 	return e.WriteUint32(num, LE)
 }
 
+// writeOptionTag writes the tag of a value with the `option` (in the format
+// of the encoding) or `coption` (4 bytes) tag, as set in opt.
+func (e *Encoder) writeOptionTag(opt *option, isPresent bool) error {
+	if opt.is_COptional() {
+		return e.WriteCOption(isPresent)
+	}
+	return e.encoding.codec().WriteOptionTag(e, isPresent)
+}
+
 func (e *Encoder) WriteBool(b bool) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write bool", zap.Bool("val", b))
@@ -215,7 +285,7 @@ func (e *Encoder) WriteUint16(i uint16, order binary.ByteOrder) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write uint16", zap.Uint16("val", i))
 	}
-	buf := make([]byte, TypeSize.Uint16)
+	buf := e.scratchBuf(TypeSize.Uint16)
 	order.PutUint16(buf, i)
 	return e.toWriter(buf)
 }
@@ -231,7 +301,7 @@ func (e *Encoder) WriteUint32(i uint32, order binary.ByteOrder) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write uint32", zap.Uint32("val", i))
 	}
-	buf := make([]byte, TypeSize.Uint32)
+	buf := e.scratchBuf(TypeSize.Uint32)
 	order.PutUint32(buf, i)
 	return e.toWriter(buf)
 }
@@ -247,7 +317,7 @@ func (e *Encoder) WriteUint64(i uint64, order binary.ByteOrder) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write uint64", zap.Uint64("val", i))
 	}
-	buf := make([]byte, TypeSize.Uint64)
+	buf := e.scratchBuf(TypeSize.Uint64)
 	order.PutUint64(buf, i)
 	return e.toWriter(buf)
 }
@@ -263,7 +333,7 @@ func (e *Encoder) WriteUint128(i Uint128, order binary.ByteOrder) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write uint128", zap.Stringer("hex", i), zap.Uint64("lo", i.Lo), zap.Uint64("hi", i.Hi))
 	}
-	buf := make([]byte, TypeSize.Uint128)
+	buf := e.scratchBuf(TypeSize.Uint128)
 	switch order {
 	case binary.LittleEndian:
 		order.PutUint64(buf[:8], i.Lo)
@@ -281,7 +351,7 @@ func (e *Encoder) WriteInt128(i Int128, order binary.ByteOrder) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write int128", zap.Stringer("hex", i), zap.Uint64("lo", i.Lo), zap.Uint64("hi", i.Hi))
 	}
-	buf := make([]byte, TypeSize.Uint128)
+	buf := e.scratchBuf(TypeSize.Uint128)
 	switch order {
 	case binary.LittleEndian:
 		order.PutUint64(buf[:8], i.Lo)
@@ -295,6 +365,33 @@ func (e *Encoder) WriteInt128(i Int128, order binary.ByteOrder) (err error) {
 	return e.toWriter(buf)
 }
 
+func (e *Encoder) WriteUint256(i Uint256, order binary.ByteOrder) (err error) {
+	if traceEnabled {
+		zlog.Debug("encode: write uint256", zap.Stringer("val", i))
+	}
+	buf := e.scratchBuf(TypeSize.Uint256)
+	switch order {
+	case binary.LittleEndian:
+		for k, w := range i.Words {
+			order.PutUint64(buf[8*k:], w)
+		}
+	case binary.BigEndian:
+		for k, w := range i.Words {
+			order.PutUint64(buf[8*(3-k):], w)
+		}
+	default:
+		return fmt.Errorf("invalid byte order: %v", order)
+	}
+	return e.toWriter(buf)
+}
+
+func (e *Encoder) WriteInt256(i Int256, order binary.ByteOrder) (err error) {
+	if traceEnabled {
+		zlog.Debug("encode: write int256", zap.Stringer("val", i))
+	}
+	return e.WriteUint256(Uint256(i), order)
+}
+
 func (e *Encoder) WriteFloat32(f float32, order binary.ByteOrder) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write float32", zap.Float32("val", f))
@@ -307,7 +404,7 @@ func (e *Encoder) WriteFloat32(f float32, order binary.ByteOrder) (err error) {
 	}
 
 	i := math.Float32bits(f)
-	buf := make([]byte, TypeSize.Uint32)
+	buf := e.scratchBuf(TypeSize.Uint32)
 	order.PutUint32(buf, i)
 
 	return e.toWriter(buf)
@@ -324,12 +421,25 @@ func (e *Encoder) WriteFloat64(f float64, order binary.ByteOrder) (err error) {
 		}
 	}
 	i := math.Float64bits(f)
-	buf := make([]byte, TypeSize.Uint64)
+	buf := e.scratchBuf(TypeSize.Uint64)
 	order.PutUint64(buf, i)
 
 	return e.toWriter(buf)
 }
 
+func (e *Encoder) WriteFloat128(f Float128, order binary.ByteOrder) (err error) {
+	if traceEnabled {
+		zlog.Debug("encode: write float128", zap.Stringer("val", f))
+	}
+
+	if e.IsBorsh() {
+		if f.IsNaN() {
+			return errors.New("NaN float value")
+		}
+	}
+	return e.WriteUint128(Uint128(f), order)
+}
+
 func (e *Encoder) WriteString(s string) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write string", zap.String("val", s))
@@ -348,11 +458,60 @@ func (e *Encoder) WriteRustString(s string) (err error) {
 	return e.WriteBytes([]byte(s), false)
 }
 
+// WriteFixedString writes s in n bytes, padded with NUL bytes;
+// it returns ErrValueTooLong if s is longer than n bytes.
+func (e *Encoder) WriteFixedString(s string, n int) (err error) {
+	if len(s) > n {
+		return fmt.Errorf("%w: string of %d bytes in %d bytes", ErrValueTooLong, len(s), n)
+	}
+	if traceEnabled {
+		zlog.Debug("encode: write fixed string", zap.String("val", s), zap.Int("size", n))
+	}
+	if err = e.WriteBytes([]byte(s), false); err != nil {
+		return err
+	}
+	return e.writeZeros(n - len(s))
+}
+
+// WriteCString writes s followed by a NUL terminator;
+// s must not contain NUL bytes.
+func (e *Encoder) WriteCString(s string) (err error) {
+	if strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("cstring %q contains a NUL byte", s)
+	}
+	if traceEnabled {
+		zlog.Debug("encode: write cstring", zap.String("val", s))
+	}
+	if err = e.WriteBytes([]byte(s), false); err != nil {
+		return err
+	}
+	return e.WriteByte(0)
+}
+
+// writeZeros writes n zero bytes.
+func (e *Encoder) writeZeros(n int) error {
+	for n > 0 {
+		size := len(e.scratch)
+		if n < size {
+			size = n
+		}
+		buf := e.scratchBuf(size)
+		for i := range buf {
+			buf[i] = 0
+		}
+		if err := e.toWriter(buf); err != nil {
+			return err
+		}
+		n -= len(buf)
+	}
+	return nil
+}
+
 func (e *Encoder) WriteCompactU16(ln int) (err error) {
 	if traceEnabled {
 		zlog.Debug("encode: write compact-u16", zap.Int("val", ln))
 	}
-	buf := make([]byte, 0)
+	buf := e.scratchBuf(0)
 	EncodeCompactU16Length(&buf, ln)
 	return e.toWriter(buf)
 }
@@ -361,36 +520,83 @@ func (e *Encoder) WriteCompactU16Length(ln int) (err error) {
 	return e.WriteCompactU16(ln)
 }
 
+// asBinaryMarshaler returns rv as a BinaryMarshaler, checking its type first
+// since boxing a value that is not a marshaler just to find out would allocate.
+func asBinaryMarshaler(rv reflect.Value) (BinaryMarshaler, bool) {
+	switch rv.Kind() {
+	case reflect.Interface:
+	case reflect.Ptr:
+		if !rv.Type().Implements(marshalableType) {
+			return nil, false
+		}
+	default:
+		if !rv.Type().Implements(marshalableType) {
+			return nil, false
+		}
+		if rv.CanAddr() {
+			// The value methods can be called through a pointer,
+			// which (unlike the value itself) is boxed without a copy.
+			return rv.Addr().Interface().(BinaryMarshaler), true
+		}
+	}
+	marshaler, ok := rv.Interface().(BinaryMarshaler)
+	return marshaler, ok
+}
+
+// arrayBuf returns a buffer of n bytes to accumulate an array into;
+// buffered encoders hand out the tail of their own buffer,
+// which is then committed with writeArrayBuf.
+func (e *Encoder) arrayBuf(n int) []byte {
+	if !e.buffered {
+		return make([]byte, n)
+	}
+	start := len(e.buf)
+	e.buf = append(e.buf, make([]byte, n)...)
+	return e.buf[start:]
+}
+
+// writeArrayBuf writes a buffer obtained from arrayBuf.
+func (e *Encoder) writeArrayBuf(arr []byte) error {
+	if !e.buffered {
+		return e.WriteBytes(arr, false)
+	}
+	e.count += len(arr)
+	if traceEnabled {
+		zlog.Debug("	> encode: appending", zap.Stringer("hex", HexBytes(arr)), zap.Int("pos", e.count))
+	}
+	return nil
+}
+
 func reflect_writeArrayOfBytes(e *Encoder, l int, rv reflect.Value) error {
-	arr := make([]byte, l)
+	arr := e.arrayBuf(l)
 	for i := 0; i < l; i++ {
 		arr[i] = byte(rv.Index(i).Uint())
 	}
-	return e.WriteBytes(arr, false)
+	return e.writeArrayBuf(arr)
 }
 
 func reflect_writeArrayOfUint16(e *Encoder, l int, rv reflect.Value, order binary.ByteOrder) error {
-	arr := make([]byte, l*2)
+	arr := e.arrayBuf(l * 2)
 	for i := 0; i < l; i++ {
 		order.PutUint16(arr[i*2:], uint16(rv.Index(i).Uint()))
 	}
-	return e.WriteBytes(arr, false)
+	return e.writeArrayBuf(arr)
 }
 
 func reflect_writeArrayOfUint32(e *Encoder, l int, rv reflect.Value, order binary.ByteOrder) error {
-	arr := make([]byte, l*4)
+	arr := e.arrayBuf(l * 4)
 	for i := 0; i < l; i++ {
 		order.PutUint32(arr[i*4:], uint32(rv.Index(i).Uint()))
 	}
-	return e.WriteBytes(arr, false)
+	return e.writeArrayBuf(arr)
 }
 
 func reflect_writeArrayOfUint64(e *Encoder, l int, rv reflect.Value, order binary.ByteOrder) error {
-	arr := make([]byte, l*8)
+	arr := e.arrayBuf(l * 8)
 	for i := 0; i < l; i++ {
 		order.PutUint64(arr[i*8:], uint64(rv.Index(i).Uint()))
 	}
-	return e.WriteBytes(arr, false)
+	return e.writeArrayBuf(arr)
 }
 
 // reflect_writeArrayOfUint_ is used for writing arrays/slices of uints of any size.
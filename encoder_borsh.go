@@ -18,6 +18,7 @@
 package bin
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"reflect"
@@ -27,6 +28,11 @@ import (
 )
 
 func (e *Encoder) encodePrimitive(rv reflect.Value, opt *option) (isPrimitive bool, err error) {
+	if c, ok := e.encoding.intCodec(); ok {
+		if bits := intBits(rv.Kind()); bits > 0 {
+			return true, e.encodeInt(c, rv, bits)
+		}
+	}
 	isPrimitive = true
 	switch rv.Kind() {
 	// case reflect.Int:
@@ -34,7 +40,11 @@ func (e *Encoder) encodePrimitive(rv reflect.Value, opt *option) (isPrimitive bo
 	// case reflect.Uint:
 	// 	err = e.WriteUint64(rv.Uint(), LE)
 	case reflect.String:
-		err = e.WriteString(rv.String())
+		if opt != nil && opt.LenPrefix != LengthPrefixDefault {
+			err = e.writePrefixedString(rv.String(), opt.LenPrefix)
+		} else {
+			err = e.WriteString(rv.String())
+		}
 	case reflect.Uint8:
 		err = e.WriteByte(byte(rv.Uint()))
 	case reflect.Int8:
@@ -63,55 +73,51 @@ func (e *Encoder) encodePrimitive(rv reflect.Value, opt *option) (isPrimitive bo
 	return
 }
 
+// encodeBorsh is the reflective encoder of borsh, and of the encodings
+// added with RegisterEncoding, with the wire format of their Codec.
 func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 	if opt == nil {
-		opt = newDefaultOption()
+		defaultOpt := option{Order: defaultByteOrder}
+		opt = &defaultOpt
 	}
-	e.currentFieldOpt = opt
+	e.setCurrentFieldOpt(opt)
 
 	if traceEnabled {
 		zlog.Debug("encode: type",
 			zap.Stringer("value_kind", rv.Kind()),
-			zap.Reflect("options", opt),
+			zap.Reflect("options", *opt),
 		)
 	}
 
-	if opt.is_Optional() {
-		if rv.IsZero() {
-			if traceEnabled {
-				zlog.Debug("encode: skipping optional value with", zap.Stringer("type", rv.Kind()))
-			}
-			return e.WriteOption(false)
-		}
-		err := e.WriteOption(true)
-		if err != nil {
-			return err
+	if opt.is_Optional() || opt.is_COptional() {
+		if c, ok := e.encoding.codec().(OptionBoolCodec); ok && isOptionBool(rv, opt) {
+			return c.WriteOptionBool(e, !rv.IsNil(), !rv.IsNil() && rv.Elem().Bool())
 		}
-		// The optionality has been used; stop its propagation:
-		opt.set_Optional(false)
-	}
-	if opt.is_COptional() {
 		if rv.IsZero() {
 			if traceEnabled {
 				zlog.Debug("encode: skipping optional value with", zap.Stringer("type", rv.Kind()))
 			}
-			return e.WriteCOption(false)
+			return e.writeOptionTag(opt, false)
 		}
-		err := e.WriteCOption(true)
+		err := e.writeOptionTag(opt, true)
 		if err != nil {
 			return err
 		}
 		// The optionality has been used; stop its propagation:
-		opt.set_COptional(false)
+		opt.set_Optional(false).set_COptional(false)
 	}
 	// Reset optionality so it won't propagate to child types:
-	opt = opt.clone().set_Optional(false).set_COptional(false)
+	childOpt := *opt
+	opt = childOpt.set_Optional(false).set_COptional(false)
 
 	if isZero(rv) {
 		return nil
 	}
+	if opt.Compact {
+		return e.encodeCompact(rv)
+	}
 
-	if marshaler, ok := rv.Interface().(BinaryMarshaler); ok {
+	if marshaler, ok := asBinaryMarshaler(rv); ok {
 		if rv.Kind() == reflect.Ptr && rv.IsZero() {
 			return nil
 		}
@@ -120,9 +126,15 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 		return marshaler.MarshalWithEncoder(e)
 	}
+	if opt.isText() {
+		return e.encodeText(rv, opt)
+	}
+	if opt.Rest {
+		return e.encodeRest(rv, opt)
+	}
 
 	// Encode the value if it's a primitive type
-	isPrimitive, err := e.encodePrimitive(rv, nil)
+	isPrimitive, err := e.encodePrimitive(rv, opt)
 	if isPrimitive {
 		return err
 	}
@@ -153,8 +165,8 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 			zlog.Debug("encode: array", zap.Int("length", l), zap.Stringer("type", rv.Kind()))
 		}
 
-		switch k := rv.Type().Elem().Kind(); k {
-		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch k := rv.Type().Elem().Kind(); {
+		case e.encoding.isFixedSizeUint(k):
 			// if it's a [n]byte, accumulate and write in one command:
 			if err := reflect_writeArrayOfUint_(e, l, k, rv, LE); err != nil {
 				return err
@@ -175,7 +187,7 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 			}
 		} else {
 			l = rv.Len()
-			if err = e.WriteUint32(uint32(l), LE); err != nil {
+			if err = e.WriteLengthPrefix(opt.LenPrefix, l); err != nil {
 				return
 			}
 		}
@@ -187,8 +199,8 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 
 		// we would want to skip to the correct head_offset
 
-		switch k := rv.Type().Elem().Kind(); k {
-		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch k := rv.Type().Elem().Kind(); {
+		case e.encoding.isFixedSizeUint(k):
 			// if it's a [n]byte, accumulate and write in one command:
 			if err := reflect_writeArrayOfUint_(e, l, k, rv, LE); err != nil {
 				return err
@@ -202,13 +214,19 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 
 	case reflect.Struct:
-		if err = e.encodeStructBorsh(rt, rv); err != nil {
+		if err = e.encoding.codec().EncodeStruct(e, rv, encodeStructFields); err != nil {
 			return
 		}
 
 	case reflect.Map:
+		isSet := opt.is_Set()
+		if isSet && !isSetType(rt) {
+			return fmt.Errorf("encode: %w: set must be a map[K]struct{}, got %q", ErrUnsupportedType, rt)
+		}
 		keys := rv.MapKeys()
-		sort.Slice(keys, vComp(keys))
+		if err = e.sortKeys(keys, isSet); err != nil {
+			return
+		}
 
 		keyCount := rv.Len()
 		if traceEnabled {
@@ -221,7 +239,7 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 			zlog = zlog.Named("struct")
 		}
 
-		if err = e.WriteUint32(uint32(keyCount), LE); err != nil {
+		if err = e.WriteLengthPrefix(opt.LenPrefix, keyCount); err != nil {
 			return
 		}
 
@@ -229,6 +247,10 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 			if err = e.Encode(mapKey.Interface()); err != nil {
 				return
 			}
+			if isSet {
+				// A set only has keys.
+				continue
+			}
 
 			if err = e.Encode(rv.MapIndex(mapKey).Interface()); err != nil {
 				return
@@ -249,21 +271,20 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 func (enc *Encoder) encodeComplexEnumBorsh(rv reflect.Value) error {
 	t := rv.Type()
 	enum := BorshEnum(rv.Field(0).Uint())
+	if int(enum)+1 >= t.NumField() {
+		return errors.New("complex enum too large")
+	}
 	// write enum identifier
-	if err := enc.WriteByte(byte(enum)); err != nil {
+	if err := enc.encoding.codec().WriteEnumTag(enc, int(enum)); err != nil {
 		return err
 	}
 	// write enum field, if necessary
-	if int(enum)+1 >= t.NumField() {
-		return errors.New("complex enum too large")
-	}
-	// Enum is empty
 	field := rv.Field(int(enum) + 1)
 	if field.Kind() == reflect.Ptr {
 		field = field.Elem()
 	}
 	if field.Kind() == reflect.Struct {
-		return enc.encodeStructBorsh(field.Type(), field)
+		return enc.encoding.codec().EncodeStruct(enc, field, encodeStructFields)
 	}
 	// Encode the value if it's a primitive type
 	isPrimitive, err := enc.encodePrimitive(field, nil)
@@ -293,26 +314,23 @@ func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err erro
 		zlog.Debug("encode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
 	}
 
+	plan := getStructPlan(rt)
+
 	// Handle complex enum:
-	if rt.NumField() > 0 {
-		// If the first field has type BorshEnum and is flagged with "borsh_enum"
-		// we have a complex enum:
-		firstField := rt.Field(0)
-		if isTypeBorshEnum(firstField.Type) &&
-			parseFieldTag(firstField.Tag).IsBorshEnum {
-			return e.encodeComplexEnumBorsh(rv)
-		}
+	if plan.isComplexEnum {
+		return e.encodeComplexEnumBorsh(rv)
 	}
 
-	sizeOfMap := map[string]int{}
+	sizeOfMap := plan.newSizeOfTracker()
+	structValue := rv
 	for i := 0; i < l; i++ {
-		structField := rt.Field(i)
-		fieldTag := parseFieldTag(structField.Tag)
+		structField := &plan.fields[i]
+		fieldTag := structField.tag
 
 		if fieldTag.Skip {
 			if traceEnabled {
 				zlog.Debug("encode: skipping struct field with skip flag",
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
 			continue
@@ -324,31 +342,41 @@ func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err erro
 			if traceEnabled {
 				zlog.Debug("encode: struct field has sizeof tag",
 					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
-			sizeOfMap[fieldTag.SizeOf] = sizeof(structField.Type, rv)
+			if rv, err = plan.sizeOfValue(structField, rv, structValue); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.name, err)
+			}
+			sizeOfMap.set(structField, sizeof(structField.typ, rv))
 		}
 
 		if !rv.CanInterface() {
 			if traceEnabled {
 				zlog.Debug("encode:  skipping field: unable to interface field, probably since field is not exported",
 					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
 			continue
 		}
 
-		option := &option{
+		option := option{
 			is_OptionalField:  fieldTag.Option,
 			is_COptionalField: fieldTag.COption,
+			is_SetField:       fieldTag.Set,
 			Order:             fieldTag.Order,
+			FixedLayout:       fieldTag.FixedLayout,
+			FixedSize:         fieldTag.FixedSize,
+			CString:           fieldTag.CString,
+			LenPrefix:         fieldTag.LenPrefix,
+			Rest:              fieldTag.Rest,
+			Compact:           fieldTag.Compact,
 		}
 
-		if s, ok := sizeOfMap[structField.Name]; ok {
+		if s, ok := sizeOfMap.get(structField); ok {
 			if traceEnabled {
-				zlog.Debug("setting sizeof option", zap.String("of", structField.Name), zap.Int("size", s))
+				zlog.Debug("setting sizeof option", zap.String("of", structField.name), zap.Int("size", s))
 			}
 			option.setSizeOfSlice(s)
 		}
@@ -356,40 +384,131 @@ func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err erro
 		if traceEnabled {
 			zlog.Debug("encode: struct field",
 				zap.Stringer("struct_field_value_type", rv.Kind()),
-				zap.String("struct_field_name", structField.Name),
+				zap.String("struct_field_name", structField.name),
 				zap.Reflect("struct_field_tags", fieldTag),
 				zap.Reflect("struct_field_option", option),
 			)
 		}
 
-		if err := e.encodeBorsh(rv, option); err != nil {
-			return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+		if err := e.encodeBorsh(rv, &option); err != nil {
+			return fmt.Errorf("error while encoding %q field: %w", structField.name, err)
 		}
 	}
 	return nil
 }
 
+// sortKeys sorts the keys of a map (or set) in the order of the encoding of e.
+func (e *Encoder) sortKeys(keys []reflect.Value, isSet bool) error {
+	switch e.encoding.codec().MapOrder() {
+	case MapOrderEncodedKeys:
+		encoded := make([][]byte, len(keys))
+		for i, key := range keys {
+			b, err := encodeKey(e.encoding, key)
+			if err != nil {
+				return err
+			}
+			encoded[i] = b
+		}
+		sort.Sort(keysByEncoding{keys: keys, encoded: encoded})
+	case MapOrderKeys:
+		sort.Slice(keys, vComp(keys))
+	default:
+		if isSet {
+			sort.Slice(keys, vComp(keys))
+		}
+	}
+	return nil
+}
+
+// encodeKey returns the encoding of a map key in enc.
+func encodeKey(enc Encoding, key reflect.Value) ([]byte, error) {
+	e := NewBufferedEncoderWithEncoding(enc)
+	if err := e.Encode(key.Interface()); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// keysByEncoding sorts keys by their encoded bytes.
+type keysByEncoding struct {
+	keys    []reflect.Value
+	encoded [][]byte
+}
+
+func (k keysByEncoding) Len() int { return len(k.keys) }
+
+func (k keysByEncoding) Less(i, j int) bool {
+	return bytes.Compare(k.encoded[i], k.encoded[j]) < 0
+}
+
+func (k keysByEncoding) Swap(i, j int) {
+	k.keys[i], k.keys[j] = k.keys[j], k.keys[i]
+	k.encoded[i], k.encoded[j] = k.encoded[j], k.encoded[i]
+}
+
 func vComp(keys []reflect.Value) func(int, int) bool {
 	return func(i int, j int) bool {
-		a, b := keys[i], keys[j]
-		if a.Kind() == reflect.Interface {
-			a = a.Elem()
-			b = b.Elem()
-		}
-		switch a.Kind() {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
-			return a.Int() < b.Int()
-		case reflect.Int64:
-			return a.Interface().(int64) < b.Interface().(int64)
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
-			return a.Uint() < b.Uint()
-		case reflect.Uint64:
-			return a.Interface().(uint64) < b.Interface().(uint64)
-		case reflect.Float32, reflect.Float64:
-			return a.Float() < b.Float()
-		case reflect.String:
-			return a.String() < b.String()
-		}
-		panic("unsupported key compare")
+		cmp, ok := compareKeys(keys[i], keys[j])
+		if !ok {
+			panic("unsupported key compare")
+		}
+		return cmp < 0
+	}
+}
+
+// compareKeys compares two map (or set) keys of the same type
+// following the ordering of borsh-rs (i.e. the derived Rust `Ord`):
+// numbers by value, strings byte-wise, arrays and structs lexicographically.
+// It returns -1, 0 or +1, and false if the keys can't be compared.
+func compareKeys(a, b reflect.Value) (int, bool) {
+	if a.Kind() == reflect.Interface {
+		a = a.Elem()
+		b = b.Elem()
+		if a.Type() != b.Type() {
+			return 0, false
+		}
 	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float() < b.Float(), a.Float() > b.Float()), true
+	case reflect.String:
+		return compareOrdered(a.String() < b.String(), a.String() > b.String()), true
+	case reflect.Bool:
+		return compareOrdered(!a.Bool() && b.Bool(), a.Bool() && !b.Bool()), true
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if cmp, ok := compareKeys(a.Index(i), b.Index(i)); !ok || cmp != 0 {
+				return cmp, ok
+			}
+		}
+		return 0, true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if cmp, ok := compareKeys(a.Field(i), b.Field(i)); !ok || cmp != 0 {
+				return cmp, ok
+			}
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// isSetType tells whether rt can hold a set (`bin:"set"`),
+// i.e. whether it's a map with empty values.
+func isSetType(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Map && rt.Elem().Kind() == reflect.Struct && rt.Elem().NumField() == 0
 }
@@ -26,45 +26,62 @@ import (
 
 func (e *Encoder) encodeCompactU16(rv reflect.Value, opt *option) (err error) {
 	if opt == nil {
-		opt = newDefaultOption()
+		defaultOpt := option{Order: defaultByteOrder}
+		opt = &defaultOpt
 	}
-	e.currentFieldOpt = opt
+	e.setCurrentFieldOpt(opt)
 
 	if traceEnabled {
 		zlog.Debug("encode: type",
 			zap.Stringer("value_kind", rv.Kind()),
-			zap.Reflect("options", opt),
+			zap.Reflect("options", *opt),
 		)
 	}
 
-	if opt.is_Optional() {
+	if opt.is_Optional() || opt.is_COptional() {
 		if rv.IsZero() {
 			if traceEnabled {
 				zlog.Debug("encode: skipping optional value with", zap.Stringer("type", rv.Kind()))
 			}
-			return e.WriteBool(false)
+			return e.writeOptionTag(opt, false)
 		}
-		err := e.WriteBool(true)
+		err := e.writeOptionTag(opt, true)
 		if err != nil {
 			return err
 		}
 		// The optionality has been used; stop its propagation:
-		opt.set_Optional(false)
+		opt.set_Optional(false).set_COptional(false)
 	}
 
 	if isZero(rv) {
 		return nil
 	}
+	if opt.Compact {
+		return e.encodeCompact(rv)
+	}
 
-	if marshaler, ok := rv.Interface().(BinaryMarshaler); ok {
+	if marshaler, ok := asBinaryMarshaler(rv); ok {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			// Like a nil pointer to a type without marshaler:
+			return nil
+		}
 		if traceEnabled {
 			zlog.Debug("encode: using MarshalerBinary method to encode type")
 		}
 		return marshaler.MarshalWithEncoder(e)
 	}
+	if opt.isText() {
+		return e.encodeText(rv, opt)
+	}
+	if opt.Rest {
+		return e.encodeRest(rv, opt)
+	}
 
 	switch rv.Kind() {
 	case reflect.String:
+		if opt.LenPrefix != LengthPrefixDefault {
+			return e.writePrefixedString(rv.String(), opt.LenPrefix)
+		}
 		return e.WriteString(rv.String())
 	case reflect.Uint8:
 		return e.WriteByte(byte(rv.Uint()))
@@ -128,7 +145,7 @@ func (e *Encoder) encodeCompactU16(rv reflect.Value, opt *option) (err error) {
 			}
 		} else {
 			l = rv.Len()
-			if err = e.WriteCompactU16Length(l); err != nil {
+			if err = e.WriteLengthPrefix(opt.LenPrefix, l); err != nil {
 				return
 			}
 		}
@@ -154,7 +171,7 @@ func (e *Encoder) encodeCompactU16(rv reflect.Value, opt *option) (err error) {
 			}
 		}
 	case reflect.Struct:
-		if err = e.encodeStructCompactU16(rt, rv); err != nil {
+		if err = e.encoding.codec().EncodeStruct(e, rv, encodeStructFieldsCompactU16); err != nil {
 			return
 		}
 
@@ -171,7 +188,7 @@ func (e *Encoder) encodeCompactU16(rv reflect.Value, opt *option) (err error) {
 			zlog = zlog.Named("struct")
 		}
 
-		if err = e.WriteCompactU16Length(keyCount); err != nil {
+		if err = e.WriteLengthPrefix(opt.LenPrefix, keyCount); err != nil {
 			return
 		}
 
@@ -198,15 +215,17 @@ func (e *Encoder) encodeStructCompactU16(rt reflect.Type, rv reflect.Value) (err
 		zlog.Debug("encode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
 	}
 
-	sizeOfMap := map[string]int{}
+	plan := getStructPlan(rt)
+	sizeOfMap := plan.newSizeOfTracker()
+	structValue := rv
 	for i := 0; i < l; i++ {
-		structField := rt.Field(i)
-		fieldTag := parseFieldTag(structField.Tag)
+		structField := &plan.fields[i]
+		fieldTag := structField.tag
 
 		if fieldTag.Skip {
 			if traceEnabled {
 				zlog.Debug("encode: skipping struct field with skip flag",
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
 			continue
@@ -218,30 +237,40 @@ func (e *Encoder) encodeStructCompactU16(rt reflect.Type, rv reflect.Value) (err
 			if traceEnabled {
 				zlog.Debug("encode: struct field has sizeof tag",
 					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
-			sizeOfMap[fieldTag.SizeOf] = sizeof(structField.Type, rv)
+			if rv, err = plan.sizeOfValue(structField, rv, structValue); err != nil {
+				return fmt.Errorf("error while encoding %q field: %w", structField.name, err)
+			}
+			sizeOfMap.set(structField, sizeof(structField.typ, rv))
 		}
 
 		if !rv.CanInterface() {
 			if traceEnabled {
 				zlog.Debug("encode:  skipping field: unable to interface field, probably since field is not exported",
 					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+					zap.String("struct_field_name", structField.name),
 				)
 			}
 			continue
 		}
 
-		option := &option{
-			is_OptionalField: fieldTag.Option,
-			Order:            fieldTag.Order,
+		option := option{
+			is_OptionalField:  fieldTag.Option,
+			is_COptionalField: fieldTag.COption,
+			Order:             fieldTag.Order,
+			FixedLayout:       fieldTag.FixedLayout,
+			FixedSize:         fieldTag.FixedSize,
+			CString:           fieldTag.CString,
+			LenPrefix:         fieldTag.LenPrefix,
+			Rest:              fieldTag.Rest,
+			Compact:           fieldTag.Compact,
 		}
 
-		if s, ok := sizeOfMap[structField.Name]; ok {
+		if s, ok := sizeOfMap.get(structField); ok {
 			if traceEnabled {
-				zlog.Debug("setting sizeof option", zap.String("of", structField.Name), zap.Int("size", s))
+				zlog.Debug("setting sizeof option", zap.String("of", structField.name), zap.Int("size", s))
 			}
 			option.setSizeOfSlice(s)
 		}
@@ -249,14 +278,14 @@ func (e *Encoder) encodeStructCompactU16(rt reflect.Type, rv reflect.Value) (err
 		if traceEnabled {
 			zlog.Debug("encode: struct field",
 				zap.Stringer("struct_field_value_type", rv.Kind()),
-				zap.String("struct_field_name", structField.Name),
+				zap.String("struct_field_name", structField.name),
 				zap.Reflect("struct_field_tags", fieldTag),
 				zap.Reflect("struct_field_option", option),
 			)
 		}
 
-		if err := e.encodeCompactU16(rv, option); err != nil {
-			return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+		if err := e.encodeCompactU16(rv, &option); err != nil {
+			return fmt.Errorf("error while encoding %q field: %w", structField.name, err)
 		}
 	}
 	return nil
@@ -0,0 +1,159 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+// encodeRLP is the reflective encoder of EncodingRLP.
+func (e *Encoder) encodeRLP(rv reflect.Value, opt *option) (err error) {
+	if opt == nil {
+		defaultOpt := option{Order: defaultByteOrder}
+		opt = &defaultOpt
+	}
+	e.setCurrentFieldOpt(opt)
+
+	if traceEnabled {
+		zlog.Debug("encode: type",
+			zap.Stringer("value_kind", rv.Kind()),
+			zap.Reflect("options", *opt),
+		)
+	}
+
+	if isZero(rv) {
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		// A nil pointer is the empty value of its type:
+		if isRLPList(rv.Type().Elem()) {
+			return e.WriteByte(rlpList)
+		}
+		return e.WriteByte(rlpString)
+	}
+
+	if marshaler, ok := asBinaryMarshaler(rv); ok {
+		// The fixed-size types of the package have no raw bytes in RLP:
+		switch m := marshaler.(type) {
+		case Uint256:
+			return e.writeRLPUint256(m)
+		case *Uint256:
+			return e.writeRLPUint256(*m)
+		case Float128, *Float128:
+			return fmt.Errorf("%w: Float128 in RLP", ErrUnsupportedType)
+		}
+		if traceEnabled {
+			zlog.Debug("encode: using MarshalerBinary method to encode type")
+		}
+		return marshaler.MarshalWithEncoder(e)
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		return e.encodeRLP(rv.Elem(), nil)
+	case reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return e.encodeRLP(rv.Elem(), nil)
+	}
+
+	rt := rv.Type()
+	if rt == bigIntType {
+		var v *big.Int
+		if rv.CanAddr() {
+			v = rv.Addr().Interface().(*big.Int)
+		} else {
+			b := rv.Interface().(big.Int)
+			v = &b
+		}
+		if v.Sign() < 0 {
+			return fmt.Errorf("%w: negative big.Int in RLP", ErrUnsupportedType)
+		}
+		return e.writeRLPString(v.Bytes())
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		return e.writeRLPString([]byte(rv.String()))
+	case reflect.Bool:
+		if rv.Bool() {
+			return e.WriteByte(1)
+		}
+		return e.WriteByte(rlpString)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rlpCodec{}.WriteUint(e, Uint128{Lo: rv.Uint()}, rt.Bits())
+	case reflect.Slice:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			return e.writeRLPString(rv.Bytes())
+		}
+		return e.writeRLPList(func(items *Encoder) error {
+			for i := 0; i < rv.Len(); i++ {
+				if err := items.encodeRLP(rv.Index(i), nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Array:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(data), rv)
+			return e.writeRLPString(data)
+		}
+		return e.writeRLPList(func(items *Encoder) error {
+			for i := 0; i < rv.Len(); i++ {
+				if err := items.encodeRLP(rv.Index(i), nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Struct:
+		return e.encoding.codec().EncodeStruct(e, rv, encodeStructFieldsRLP)
+	default:
+		return fmt.Errorf("encode: %w %q", ErrUnsupportedType, rt)
+	}
+}
+
+// encodeStructRLP encodes the fields of the struct rv as the items of its list.
+func (e *Encoder) encodeStructRLP(rt reflect.Type, rv reflect.Value) (err error) {
+	plan := getStructPlan(rt)
+	for i := range plan.fields {
+		structField := &plan.fields[i]
+		fieldTag := structField.tag
+		if fieldTag.Skip {
+			continue
+		}
+		rv := rv.Field(i)
+		if !rv.CanInterface() {
+			// The unexported fields aren't encoded.
+			continue
+		}
+
+		option := option{
+			is_OptionalField: fieldTag.Option,
+			Order:            fieldTag.Order,
+		}
+		if err := e.encodeRLP(rv, &option); err != nil {
+			return fmt.Errorf("error while encoding %q field: %w", structField.name, err)
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"math"
 	"reflect"
 	"testing"
@@ -444,6 +445,19 @@ func TestEncoder_Uint128(t *testing.T) {
 	}, buf.Bytes())
 }
 
+func TestEncoder_NilPointerToMarshaler(t *testing.T) {
+	// Uint128 has a value receiver MarshalWithEncoder method.
+	type S struct {
+		A *Uint128
+		B uint8
+	}
+	for _, encoding := range []Encoding{EncodingBin, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, encoding).Encode(S{B: 7}), encoding)
+		assert.Equal(t, []byte{0x07}, buf.Bytes(), encoding)
+	}
+}
+
 func TestEncoder_BinaryStruct(t *testing.T) {
 	s := &binaryTestStruct{
 		F1:  "abc",
@@ -1272,3 +1286,51 @@ func Test_writeArrayOfUint64(t *testing.T) {
 			buf.Bytes())
 	}
 }
+
+type sizeOfStruct struct {
+	Count  uint16 `bin:"sizeof=Items"`
+	Prefix uint8
+	Items  []uint32
+}
+
+type sizeOfSmall struct {
+	Count int8 `bin:"sizeof=Items"`
+	Items []uint8
+}
+
+func TestEncoder_sizeOf(t *testing.T) {
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		want := []byte{2, 0, 9, 1, 0, 0, 0, 2, 0, 0, 0}
+
+		// A zero count is filled with the length of the slice:
+		x := sizeOfStruct{Prefix: 9, Items: []uint32{1, 2}}
+		data, err := marshalWithEncoding(&x, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, want, data, encoding)
+		require.Equal(t, uint16(0), x.Count, encoding)
+
+		var y sizeOfStruct
+		require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+		require.Equal(t, sizeOfStruct{Count: 2, Prefix: 9, Items: []uint32{1, 2}}, y, encoding)
+
+		// The right count is kept:
+		data, err = marshalWithEncoding(&y, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, want, data, encoding)
+
+		data, err = marshalWithEncoding(&sizeOfStruct{Prefix: 9}, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, []byte{0, 0, 9}, data, encoding)
+
+		// A wrong count:
+		for _, count := range []uint16{1, 3} {
+			_, err = marshalWithEncoding(&sizeOfStruct{Count: count, Items: []uint32{1, 2}}, encoding)
+			require.True(t, errors.Is(err, ErrSizeOfMismatch), err)
+			require.Contains(t, err.Error(), `"Count" field`)
+		}
+
+		// A length that doesn't fit the count:
+		_, err = marshalWithEncoding(&sizeOfSmall{Items: make([]uint8, 200)}, encoding)
+		require.True(t, errors.Is(err, ErrSizeOfMismatch), err)
+	}
+}
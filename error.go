@@ -14,7 +14,12 @@
 
 package bin
 
-import "reflect"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
 
 // An InvalidDecoderError describes an invalid argument passed to Decoder.
 // (The argument to Decoder must be a non-nil pointer.)
@@ -32,3 +37,186 @@ func (e *InvalidDecoderError) Error() string {
 	}
 	return "decoder: Decode(nil " + e.Type.String() + ")"
 }
+
+// ErrLimitExceeded is matched (via errors.Is) by all the errors
+// returned when a limit set with DecoderOptions is exceeded.
+var ErrLimitExceeded = errors.New("decoder limit exceeded")
+
+// A LimitExceededError describes a DecoderOptions limit that has been exceeded.
+type LimitExceededError struct {
+	// Limit is the name of the exceeded limit (e.g. "MaxCollectionLength").
+	Limit string
+	Max   int
+	Value int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("decoder: %s exceeded: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+var (
+	// ErrShortBuffer is matched by the errors returned when
+	// there is not enough data left to decode a value.
+	ErrShortBuffer = errors.New("short buffer")
+	// ErrInvalidEnumVariant is matched by the errors returned when
+	// an enum (or variant) discriminator does not match any known variant.
+	ErrInvalidEnumVariant = errors.New("invalid enum variant")
+	// ErrInvalidOptionByte is matched by the errors returned when
+	// an option (or c-option) tag has an invalid value.
+	ErrInvalidOptionByte = errors.New("invalid option byte")
+	// ErrLengthTooLarge is matched by the errors returned when
+	// a length prefix is larger than what can be decoded.
+	ErrLengthTooLarge = errors.New("length too large")
+	// ErrInvalidCompactU16 is matched by the errors returned when
+	// a compact-u16 value is malformed.
+	ErrInvalidCompactU16 = errors.New("invalid compact-u16")
+	// ErrNaN is matched by the errors returned when
+	// a NaN float is found where it's not allowed.
+	ErrNaN = errors.New("NaN for float not allowed")
+	// ErrUnsupportedType is matched by the errors returned when
+	// the type of the value cannot be decoded.
+	ErrUnsupportedType = errors.New("unsupported type")
+	// ErrUnsortedKeys is matched by the errors returned when the keys
+	// of a set (or map, in strict mode) are not in increasing order.
+	ErrUnsortedKeys = errors.New("keys not in increasing order")
+	// ErrDuplicateKey is matched by the errors returned when the keys
+	// of a set (or map, in strict mode) contain duplicates.
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrInvalidBoolByte is matched by the errors returned, in strict mode,
+	// when a bool byte has a value other than 0 or 1.
+	ErrInvalidBoolByte = errors.New("invalid bool byte")
+	// ErrTrailingBytes is matched by the errors returned, in strict mode,
+	// when data is left after decoding a value.
+	ErrTrailingBytes = errors.New("trailing bytes")
+	// ErrOverflow is matched by the errors returned when the result of an
+	// operation (e.g. Uint128.CheckedAdd) or a conversion doesn't fit its type.
+	ErrOverflow = errors.New("integer overflow")
+	// ErrValueTooLong is matched by the errors returned when a value
+	// doesn't fit in the fixed size of its field (e.g. `bin:"fixed=32"`).
+	ErrValueTooLong = errors.New("value too long")
+	// ErrInvalidVarint is matched by the errors returned when a variable-length
+	// integer is malformed (e.g. a BCS ULEB128 not in its shortest form).
+	ErrInvalidVarint = errors.New("invalid varint")
+	// ErrNonCanonical is matched by the errors returned, in strict mode,
+	// when a value is not in its canonical encoding (e.g. an RLP integer with leading zeros).
+	ErrNonCanonical = errors.New("non-canonical encoding")
+	// ErrUnexpectedKind is matched by the errors returned when the data holds
+	// another kind of value than its Go type (e.g. an RLP list for a string).
+	ErrUnexpectedKind = errors.New("unexpected kind of value")
+	// ErrSizeOfMismatch is matched by the errors returned when encoding
+	// a `sizeof=` field whose value is not the length of its slice.
+	ErrSizeOfMismatch = errors.New("sizeof mismatch")
+)
+
+// A DecodeError describes an error that occurred while decoding a value.
+// Use errors.Is to check the kind of error (e.g. ErrShortBuffer),
+// and errors.As to obtain the location of the error.
+type DecodeError struct {
+	// Offset is the position, in the decoded data, of the value
+	// that could not be decoded.
+	Offset int
+	// Type is the Go type of the value that could not be decoded.
+	Type reflect.Type
+	// Path is the path of the value that could not be decoded,
+	// starting from the root type (e.g. `Metadata.Data.Creators[2].Address`).
+	Path string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Type == nil {
+		// Decoded without a Go type (e.g. from an IDL).
+		return fmt.Sprintf("decode: %s at offset %d: %s", e.Path, e.Offset, e.Err)
+	}
+	if e.Path == "" {
+		return fmt.Sprintf("decode: %s at offset %d: %s", e.Type, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("decode: %s (%s) at offset %d: %s", e.Path, e.Type, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// newDecodeError returns err as a *DecodeError, creating one for the value
+// of the provided type at the provided offset if err isn't one already
+// (the innermost location is the one kept).
+func newDecodeError(err error, offset int, typ reflect.Type) *DecodeError {
+	if decodeErr, ok := err.(*DecodeError); ok {
+		return decodeErr
+	}
+	return &DecodeError{
+		Offset: offset,
+		Type:   typ,
+		Err:    err,
+	}
+}
+
+// withField prepends the provided field (or type) name to the path.
+func (e *DecodeError) withField(name string) *DecodeError {
+	switch {
+	case e.Path == "":
+		e.Path = name
+	case e.Path[0] == '[':
+		e.Path = name + e.Path
+	default:
+		e.Path = name + "." + e.Path
+	}
+	return e
+}
+
+// withIndex prepends the provided slice/array index to the path.
+func (e *DecodeError) withIndex(index int) *DecodeError {
+	return e.withKey(strconv.Itoa(index))
+}
+
+// withKey prepends the provided map key to the path.
+func (e *DecodeError) withKey(key string) *DecodeError {
+	switch {
+	case e.Path == "":
+		e.Path = "[" + key + "]"
+	case e.Path[0] == '[':
+		e.Path = "[" + key + "]" + e.Path
+	default:
+		e.Path = "[" + key + "]." + e.Path
+	}
+	return e
+}
+
+// decodedType returns the type of the value being decoded.
+func decodedType(rv reflect.Value, unmarshaler BinaryUnmarshaler) reflect.Type {
+	if rv.IsValid() {
+		return rv.Type()
+	}
+	typ := reflect.TypeOf(unmarshaler)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+	return typ
+}
+
+func withFieldPath(err error, name string) error {
+	if decodeErr, ok := err.(*DecodeError); ok {
+		return decodeErr.withField(name)
+	}
+	return err
+}
+
+func withIndexPath(err error, index int) error {
+	if decodeErr, ok := err.(*DecodeError); ok {
+		return decodeErr.withIndex(index)
+	}
+	return err
+}
+
+func withKeyPath(err error, key reflect.Value) error {
+	if decodeErr, ok := err.(*DecodeError); ok {
+		return decodeErr.withKey(fmt.Sprint(key.Interface()))
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package bin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type errTestMetadata struct {
+	Key  uint8
+	Data errTestData
+}
+
+type errTestData struct {
+	Name     string
+	Creators []errTestCreator
+}
+
+type errTestCreator struct {
+	Address  [32]byte
+	Verified bool
+	Share    uint8
+}
+
+type errTestEnum struct {
+	Enum BorshEnum `borsh_enum:"true"`
+	One  EmptyVariant
+	Two  uint32
+}
+
+type errTestCOption struct {
+	Value *uint64 `bin:"coption"`
+}
+
+func TestDecodeError_Path(t *testing.T) {
+	meta := errTestMetadata{
+		Key: 4,
+		Data: errTestData{
+			Name: "hello",
+			Creators: []errTestCreator{
+				{Share: 1},
+				{Share: 2},
+				{Share: 3},
+			},
+		},
+	}
+	data, err := MarshalBorsh(meta)
+	require.NoError(t, err)
+
+	// Truncate in the middle of the address of the third creator:
+	creatorsOffset := 1 + 4 + len("hello") + 4
+	thirdCreatorOffset := creatorsOffset + 2*(32+1+1)
+	truncated := data[:thirdCreatorOffset+10]
+
+	var got errTestMetadata
+	err = UnmarshalBorsh(&got, truncated)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrShortBuffer))
+
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	require.Equal(t, "errTestMetadata.Data.Creators[2].Address", decodeErr.Path)
+	require.Equal(t, reflect.TypeOf([32]byte{}), decodeErr.Type)
+	require.Equal(t, thirdCreatorOffset, decodeErr.Offset)
+	require.Contains(t, err.Error(), "errTestMetadata.Data.Creators[2].Address ([32]uint8) at offset 82")
+}
+
+func TestDecodeError_Kinds(t *testing.T) {
+	{
+		var got errTestEnum
+		err := UnmarshalBorsh(&got, []byte{5})
+		require.True(t, errors.Is(err, ErrInvalidEnumVariant))
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		require.Equal(t, "errTestEnum", decodeErr.Path)
+	}
+	{
+		var got errTestCOption
+		err := UnmarshalBorsh(&got, []byte{2, 0, 0, 0})
+		require.True(t, errors.Is(err, ErrInvalidOptionByte))
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		require.Equal(t, "errTestCOption.Value", decodeErr.Path)
+	}
+	{
+		var got []byte
+		err := UnmarshalBin(&got, []byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+		require.True(t, errors.Is(err, ErrLengthTooLarge))
+	}
+	{
+		var got map[string]errTestCreator
+		data, err := MarshalBorsh(map[string]errTestCreator{"abc": {Share: 1}})
+		require.NoError(t, err)
+		err = UnmarshalBorsh(&got, data[:len(data)-1])
+		require.True(t, errors.Is(err, ErrShortBuffer))
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		require.Equal(t, "[abc].Share", decodeErr.Path)
+	}
+	{
+		var got struct {
+			F chan int
+		}
+		err := UnmarshalBorsh(&got, []byte{0})
+		require.True(t, errors.Is(err, ErrUnsupportedType))
+	}
+	{
+		var got Tree
+		err := UnmarshalBin(&got, concatByteSlices(
+			[]byte{0, 0, 0, 0, 0},
+			uint32ToBytes(1, LE),
+			uint64ToBytes(0, LE),
+			uint32ToBytes(9, LE), // unknown variant
+		))
+		require.True(t, errors.Is(err, ErrInvalidEnumVariant))
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		require.Equal(t, "Tree.Nodes[0]", decodeErr.Path)
+	}
+}
@@ -0,0 +1,664 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// FixedLayout describes a fixed-point number: an integer of
+// IntBits+FracBits bits (in two's complement if Signed), scaled by
+// 2**-FracBits, like the types of the Rust `fixed` crate.
+// When Decimals is set, the integer is scaled by 10**-Decimals instead
+// (e.g. the WAD-scaled decimals, where Decimals is 18), and FracBits must be zero.
+//
+// The width of the integer must be a multiple of 8 bits, up to 256.
+//
+// The layout of a Fixed struct field can be set with a tag naming it like
+// the Rust `fixed` crate, with D<n> for n decimal digits
+// (e.g. `bin:"layout=I80F48"` or `bin:"layout=U192D18"`).
+type FixedLayout struct {
+	// IntBits is the width of the integer part, including the sign bit.
+	IntBits uint
+	// FracBits is the width of the (binary) fractional part.
+	FracBits uint
+	// Decimals is the count of decimal digits of the fractional part.
+	Decimals uint
+	Signed   bool
+}
+
+var (
+	// LayoutI80F48 is the layout of I80F48 (e.g. Mango).
+	LayoutI80F48 = FixedLayout{IntBits: 80, FracBits: 48, Signed: true}
+	// LayoutU64F64 is the layout of U64F64 (e.g. the Q64.64 prices of Orca Whirlpool).
+	LayoutU64F64 = FixedLayout{IntBits: 64, FracBits: 64}
+	// LayoutWad is the layout of WadDecimal (e.g. spl-token-lending's Decimal):
+	// a u192 scaled by 10**18.
+	LayoutWad = FixedLayout{IntBits: 192, Decimals: 18}
+)
+
+// Bits returns the width of the integer holding the fixed-point number.
+func (l FixedLayout) Bits() uint {
+	return l.IntBits + l.FracBits
+}
+
+// Size returns the encoded size of the fixed-point number, in bytes.
+func (l FixedLayout) Size() int {
+	return int(l.Bits() / 8)
+}
+
+// String returns the name of l (e.g. I80F48, or U192D18 for LayoutWad).
+func (l FixedLayout) String() string {
+	sign := "U"
+	if l.Signed {
+		sign = "I"
+	}
+	if l.Decimals != 0 {
+		return fmt.Sprintf("%s%dD%d", sign, l.IntBits, l.Decimals)
+	}
+	return fmt.Sprintf("%s%dF%d", sign, l.IntBits, l.FracBits)
+}
+
+// parseFixedLayout parses the name of a layout (e.g. I80F48 or U192D18).
+func parseFixedLayout(s string) (FixedLayout, error) {
+	var l FixedLayout
+	if len(s) == 0 || (s[0] != 'I' && s[0] != 'U') {
+		return l, fmt.Errorf("invalid fixed-point layout %q", s)
+	}
+	l.Signed = s[0] == 'I'
+	sep := strings.IndexAny(s, "FD")
+	if sep < 0 {
+		return l, fmt.Errorf("invalid fixed-point layout %q", s)
+	}
+	intBits, err := strconv.ParseUint(s[1:sep], 10, 16)
+	if err != nil {
+		return l, fmt.Errorf("invalid fixed-point layout %q", s)
+	}
+	frac, err := strconv.ParseUint(s[sep+1:], 10, 16)
+	if err != nil {
+		return l, fmt.Errorf("invalid fixed-point layout %q", s)
+	}
+	l.IntBits = uint(intBits)
+	if s[sep] == 'F' {
+		l.FracBits = uint(frac)
+	} else {
+		l.Decimals = uint(frac)
+	}
+	return l, nil
+}
+
+func (l FixedLayout) validate() error {
+	switch {
+	case l == FixedLayout{}:
+		return fmt.Errorf("fixed-point layout not set (see the `bin:\"layout=...\"` tag)")
+	case l.Bits() == 0 || l.Bits() > 256 || l.Bits()%8 != 0:
+		return fmt.Errorf("invalid fixed-point layout %s: the width must be a multiple of 8 bits, up to 256", l.String())
+	case l.FracBits != 0 && l.Decimals != 0:
+		return fmt.Errorf("invalid fixed-point layout %s: FracBits and Decimals are mutually exclusive", l.String())
+	}
+	return nil
+}
+
+// denom returns the denominator of the fixed-point numbers of l.
+func (l FixedLayout) denom() *big.Int {
+	if l.Decimals != 0 {
+		return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(l.Decimals)), nil)
+	}
+	return new(big.Int).Lsh(big.NewInt(1), l.FracBits)
+}
+
+// magnitude reports whether a value whose binary exponent is exp
+// (i.e. 2**(exp-1) <= |value| < 2**exp) overflows l, or rounds to zero.
+func (l FixedLayout) magnitude(exp int) (overflows, zero bool) {
+	// 10**Decimals < 2**(4*Decimals).
+	return exp-1 >= int(l.Bits()), exp+int(l.FracBits)+4*int(l.Decimals) <= -1
+}
+
+// Fixed is a fixed-point number of any FixedLayout.
+// The layout must be set before decoding (or unmarshaling) into a Fixed,
+// or, for the struct fields, with a `bin:"layout=..."` tag:
+//
+//	price := bin.Fixed{Layout: bin.FixedLayout{IntBits: 32, FracBits: 32}}
+//
+//	type Reserve struct {
+//		Price bin.Fixed `bin:"layout=U32F32"`
+//	}
+//
+// Its String and Decimal methods are exact, and the conversions
+// from other numbers round to the nearest fixed-point number (ties to even).
+type Fixed struct {
+	// Raw holds the underlying integer,
+	// sign-extended to 256 bits for the signed layouts.
+	Raw    Uint256
+	Layout FixedLayout
+}
+
+// rawBigInt returns the underlying integer of f.
+func (f Fixed) rawBigInt() *big.Int {
+	if f.Layout.Signed {
+		return Int256(f.Raw).BigInt()
+	}
+	return f.Raw.BigInt()
+}
+
+// setRawBigInt sets the underlying integer of f to b, or returns
+// ErrOverflow (leaving f unchanged) if b doesn't fit in the layout.
+func (f *Fixed) setRawBigInt(b *big.Int) error {
+	bits := f.Layout.Bits()
+	fits := b.Sign() >= 0 && b.BitLen() <= int(bits)
+	if f.Layout.Signed {
+		// -2**(bits-1) <= b < 2**(bits-1)
+		abs := new(big.Int).Abs(b)
+		if b.Sign() < 0 {
+			abs.Sub(abs, big.NewInt(1))
+		}
+		fits = abs.BitLen() < int(bits)
+	}
+	if !fits {
+		return fmt.Errorf("%w: %s doesn't fit in a %s", ErrOverflow, new(big.Rat).SetFrac(b, f.Layout.denom()).RatString(), f.Layout.String())
+	}
+
+	var words [4]uint64
+	bigIntWords(b, words[:])
+	if b.Sign() < 0 {
+		words = Int256{Words: words}.Neg().Words
+	}
+	f.Raw.Words = words
+	return nil
+}
+
+// fits reports whether the Raw integer of f fits in its layout.
+func (f Fixed) fits() bool {
+	return extendWords256(f.Raw.Words, f.Layout.Bits(), f.Layout.Signed) == f.Raw.Words
+}
+
+// extendWords256 returns the low n bits of x, sign-extended if signed.
+func extendWords256(x words256, n uint, signed bool) words256 {
+	if n == 0 || n >= 256 {
+		return x
+	}
+	var fill uint64
+	if signed && x[(n-1)/64]>>((n-1)%64)&1 == 1 {
+		fill = math.MaxUint64
+	}
+	k := n / 64
+	if r := n % 64; r != 0 {
+		mask := uint64(1)<<r - 1
+		x[k] = x[k]&mask | fill&^mask
+		k++
+	}
+	for ; k < 4; k++ {
+		x[k] = fill
+	}
+	return x
+}
+
+// Rat returns f as a big.Rat.
+func (f Fixed) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(f.rawBigInt(), f.Layout.denom())
+}
+
+// SetRat sets f to r, rounded to the nearest fixed-point number of its
+// layout (ties to even), or returns ErrOverflow (leaving f unchanged)
+// if r doesn't fit in the layout.
+func (f *Fixed) SetRat(r *big.Rat) error {
+	if err := f.Layout.validate(); err != nil {
+		return err
+	}
+	num := new(big.Int).Mul(r.Num(), f.Layout.denom())
+	raw, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if c := rem.Abs(rem).Lsh(rem, 1).Cmp(r.Denom()); c > 0 || (c == 0 && raw.Bit(0) == 1) {
+		raw.Add(raw, big.NewInt(int64(num.Sign())))
+	}
+	return f.setRawBigInt(raw)
+}
+
+// String returns the exact decimal value of f.
+func (f Fixed) String() string {
+	digits := f.Layout.FracBits
+	if f.Layout.Decimals != 0 {
+		digits = f.Layout.Decimals
+	}
+	s := f.Rat().FloatString(int(digits))
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	return s
+}
+
+// SetString sets f to the decimal number s (e.g. "-1.25" or "3e-4"),
+// rounded to the nearest fixed-point number of its layout (ties to even).
+func (f *Fixed) SetString(s string) error {
+	if err := f.Layout.validate(); err != nil {
+		return err
+	}
+	// Find out the magnitude of s first, so that the huge exponents
+	// (e.g. 1e1000000000) don't make huge rationals.
+	parsed, _, err := new(big.Float).Parse(s, 10)
+	if err != nil {
+		return fmt.Errorf("could not parse %q as a fixed-point number", s)
+	}
+	if handled, err := f.setMagnitude(parsed); handled {
+		return err
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("could not parse %q as a fixed-point number", s)
+	}
+	return f.SetRat(r)
+}
+
+// setMagnitude sets f to zero, or returns ErrOverflow, if the magnitude
+// of x is out of the layout; it reports whether x was handled.
+func (f *Fixed) setMagnitude(x *big.Float) (handled bool, err error) {
+	if x.IsInf() {
+		return true, fmt.Errorf("%w: %s doesn't fit in a %s", ErrOverflow, x, f.Layout.String())
+	}
+	if x.Sign() == 0 {
+		f.Raw.Words = words256{}
+		return true, nil
+	}
+	overflows, zero := f.Layout.magnitude(x.MantExp(nil))
+	switch {
+	case overflows:
+		return true, fmt.Errorf("%w: %s doesn't fit in a %s", ErrOverflow, x.Text('g', 10), f.Layout.String())
+	case zero:
+		f.Raw.Words = words256{}
+		return true, nil
+	}
+	return false, nil
+}
+
+// BigFloat returns f as a big.Float, whose precision is the width of
+// the layout (at least 64 bits). It is exact for the binary layouts,
+// and rounded to the nearest for the decimal ones.
+func (f Fixed) BigFloat() *big.Float {
+	prec := f.Layout.Bits()
+	if prec < 64 {
+		prec = 64
+	}
+	out := new(big.Float).SetPrec(prec)
+	if f.Layout.Decimals != 0 {
+		return out.SetRat(f.Rat())
+	}
+	out.SetInt(f.rawBigInt())
+	return out.SetMantExp(out, -int(f.Layout.FracBits))
+}
+
+// SetBigFloat sets f to x, rounded to the nearest fixed-point number
+// of its layout (ties to even).
+func (f *Fixed) SetBigFloat(x *big.Float) error {
+	if err := f.Layout.validate(); err != nil {
+		return err
+	}
+	if handled, err := f.setMagnitude(x); handled {
+		return err
+	}
+	r, _ := x.Rat(nil)
+	return f.SetRat(r)
+}
+
+// Decimal returns the exact value of f as a decimal.Decimal.
+func (f Fixed) Decimal() decimal.Decimal {
+	raw := f.rawBigInt()
+	if f.Layout.Decimals != 0 {
+		return decimal.NewFromBigInt(raw, -int32(f.Layout.Decimals))
+	}
+	// raw / 2**n == raw * 5**n / 10**n
+	n := int64(f.Layout.FracBits)
+	raw.Mul(raw, new(big.Int).Exp(big.NewInt(5), big.NewInt(n), nil))
+	return decimal.NewFromBigInt(raw, -int32(n))
+}
+
+// SetDecimal sets f to d, rounded to the nearest fixed-point number
+// of its layout (ties to even).
+func (f *Fixed) SetDecimal(d decimal.Decimal) error {
+	if err := f.Layout.validate(); err != nil {
+		return err
+	}
+	if d.Sign() == 0 {
+		f.Raw.Words = words256{}
+		return nil
+	}
+	// Find out the magnitude of d first (10**(n-1) <= |d| < 10**n),
+	// so that the huge exponents don't make huge rationals.
+	coef := d.Coefficient()
+	n := len(coef.Abs(coef).String()) + int(d.Exponent())
+	if n-1 >= int(f.Layout.Bits()) {
+		return fmt.Errorf("%w: %se%d doesn't fit in a %s", ErrOverflow, d.Coefficient(), d.Exponent(), f.Layout.String())
+	}
+	if n+int(f.Layout.FracBits)+int(f.Layout.Decimals) <= -1 {
+		f.Raw.Words = words256{}
+		return nil
+	}
+	return f.SetRat(d.Rat())
+}
+
+// MarshalJSON encodes f as a string holding its exact decimal value.
+func (f Fixed) MarshalJSON() (data []byte, err error) {
+	return []byte(`"` + f.String() + `"`), nil
+}
+
+// UnmarshalJSON decodes f from a string holding a decimal number, or from
+// a JSON number, rounded to the nearest fixed-point number of its layout.
+func (f *Fixed) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	return f.SetString(s)
+}
+
+// fieldLayout returns the layout set by the tag of the field of f, if any.
+func (f Fixed) fieldLayout(opt *option) (FixedLayout, error) {
+	if opt == nil || opt.FixedLayout == nil {
+		return f.Layout, nil
+	}
+	if f.Layout != (FixedLayout{}) && f.Layout != *opt.FixedLayout {
+		return f.Layout, fmt.Errorf("fixed-point layout %s doesn't match the layout of the field, %s", f.Layout, opt.FixedLayout)
+	}
+	return *opt.FixedLayout, nil
+}
+
+func (f *Fixed) UnmarshalWithDecoder(dec *Decoder) error {
+	if _, ok := dec.encoding.intCodec(); ok {
+		// The integers of the encoding aren't fixed-size values.
+		return fmt.Errorf("%w: fixed-point number in %s", ErrUnsupportedType, dec.encoding)
+	}
+	var order binary.ByteOrder = defaultByteOrder
+	var opt *option
+	if dec != nil && dec.currentFieldOpt != nil {
+		opt = dec.currentFieldOpt
+		order = opt.Order
+	}
+	layout, err := f.fieldLayout(opt)
+	if err != nil {
+		return err
+	}
+	if err := layout.validate(); err != nil {
+		return err
+	}
+	f.Layout = layout
+	size := f.Layout.Size()
+	if !dec.fill(size) {
+		return fmt.Errorf("%w: %s required [%d] bytes, remaining [%d]", ErrShortBuffer, f.Layout.String(), size, dec.Remaining())
+	}
+
+	data := dec.data[dec.pos : dec.pos+size]
+	var words words256
+	for k := 0; k < size; k++ {
+		var b byte
+		switch order {
+		case binary.LittleEndian:
+			b = data[k]
+		case binary.BigEndian:
+			b = data[size-1-k]
+		default:
+			return fmt.Errorf("invalid byte order: %v", order)
+		}
+		words[k/8] |= uint64(b) << (8 * uint(k%8))
+	}
+	dec.pos += size
+
+	f.Raw.Words = extendWords256(words, f.Layout.Bits(), f.Layout.Signed)
+	return nil
+}
+
+func (f Fixed) MarshalWithEncoder(enc *Encoder) error {
+	if _, ok := enc.encoding.intCodec(); ok {
+		// The integers of the encoding aren't fixed-size values.
+		return fmt.Errorf("%w: fixed-point number in %s", ErrUnsupportedType, enc.encoding)
+	}
+	var order binary.ByteOrder = defaultByteOrder
+	var opt *option
+	if enc != nil && enc.currentFieldOpt != nil {
+		opt = enc.currentFieldOpt
+		order = opt.Order
+	}
+	layout, err := f.fieldLayout(opt)
+	if err != nil {
+		return err
+	}
+	if err := layout.validate(); err != nil {
+		return err
+	}
+	f.Layout = layout
+	if !f.fits() {
+		return fmt.Errorf("%w: raw value %s doesn't fit in a %s", ErrOverflow, f.Raw.HexString(), f.Layout)
+	}
+
+	size := f.Layout.Size()
+	buf := enc.scratchBuf(size)
+	for k := 0; k < size; k++ {
+		b := byte(f.Raw.Words[k/8] >> (8 * uint(k%8)))
+		switch order {
+		case binary.LittleEndian:
+			buf[k] = b
+		case binary.BigEndian:
+			buf[size-1-k] = b
+		default:
+			return fmt.Errorf("invalid byte order: %v", order)
+		}
+	}
+	return enc.toWriter(buf)
+}
+
+// I80F48 is a signed fixed-point number with 80 integer bits and
+// 48 fractional bits (e.g. the I80F48 of Mango), held in an Int128.
+type I80F48 Int128
+
+// Fixed returns x as a Fixed.
+func (x I80F48) Fixed() Fixed {
+	return Fixed{Raw: Uint256(NewInt256FromInt128(Int128(x))), Layout: LayoutI80F48}
+}
+
+func (x *I80F48) set(set func(f *Fixed) error) error {
+	f := x.Fixed()
+	if err := set(&f); err != nil {
+		return err
+	}
+	x.Lo, x.Hi = f.Raw.Words[0], f.Raw.Words[1]
+	return nil
+}
+
+// String returns the exact decimal value of x.
+func (x I80F48) String() string {
+	return x.Fixed().String()
+}
+
+// SetString sets x to the decimal number s, rounded to the nearest I80F48.
+func (x *I80F48) SetString(s string) error {
+	return x.set(func(f *Fixed) error { return f.SetString(s) })
+}
+
+// BigFloat returns the exact value of x as a big.Float.
+func (x I80F48) BigFloat() *big.Float {
+	return x.Fixed().BigFloat()
+}
+
+// SetBigFloat sets x to v, rounded to the nearest I80F48.
+func (x *I80F48) SetBigFloat(v *big.Float) error {
+	return x.set(func(f *Fixed) error { return f.SetBigFloat(v) })
+}
+
+// Decimal returns the exact value of x as a decimal.Decimal.
+func (x I80F48) Decimal() decimal.Decimal {
+	return x.Fixed().Decimal()
+}
+
+// SetDecimal sets x to d, rounded to the nearest I80F48.
+func (x *I80F48) SetDecimal(d decimal.Decimal) error {
+	return x.set(func(f *Fixed) error { return f.SetDecimal(d) })
+}
+
+func (x I80F48) MarshalJSON() (data []byte, err error) {
+	return x.Fixed().MarshalJSON()
+}
+
+func (x *I80F48) UnmarshalJSON(data []byte) error {
+	return x.set(func(f *Fixed) error { return f.UnmarshalJSON(data) })
+}
+
+func (x *I80F48) UnmarshalWithDecoder(dec *Decoder) error {
+	return (*Int128)(x).UnmarshalWithDecoder(dec)
+}
+
+func (x I80F48) MarshalWithEncoder(enc *Encoder) error {
+	return Int128(x).MarshalWithEncoder(enc)
+}
+
+// U64F64 is an unsigned fixed-point number with 64 integer bits and
+// 64 fractional bits (e.g. a Q64.64 price), held in a Uint128.
+type U64F64 Uint128
+
+// Fixed returns x as a Fixed.
+func (x U64F64) Fixed() Fixed {
+	return Fixed{Raw: NewUint256FromUint128(Uint128(x)), Layout: LayoutU64F64}
+}
+
+func (x *U64F64) set(set func(f *Fixed) error) error {
+	f := x.Fixed()
+	if err := set(&f); err != nil {
+		return err
+	}
+	x.Lo, x.Hi = f.Raw.Words[0], f.Raw.Words[1]
+	return nil
+}
+
+// String returns the exact decimal value of x.
+func (x U64F64) String() string {
+	return x.Fixed().String()
+}
+
+// SetString sets x to the decimal number s, rounded to the nearest U64F64.
+func (x *U64F64) SetString(s string) error {
+	return x.set(func(f *Fixed) error { return f.SetString(s) })
+}
+
+// BigFloat returns the exact value of x as a big.Float.
+func (x U64F64) BigFloat() *big.Float {
+	return x.Fixed().BigFloat()
+}
+
+// SetBigFloat sets x to v, rounded to the nearest U64F64.
+func (x *U64F64) SetBigFloat(v *big.Float) error {
+	return x.set(func(f *Fixed) error { return f.SetBigFloat(v) })
+}
+
+// Decimal returns the exact value of x as a decimal.Decimal.
+func (x U64F64) Decimal() decimal.Decimal {
+	return x.Fixed().Decimal()
+}
+
+// SetDecimal sets x to d, rounded to the nearest U64F64.
+func (x *U64F64) SetDecimal(d decimal.Decimal) error {
+	return x.set(func(f *Fixed) error { return f.SetDecimal(d) })
+}
+
+func (x U64F64) MarshalJSON() (data []byte, err error) {
+	return x.Fixed().MarshalJSON()
+}
+
+func (x *U64F64) UnmarshalJSON(data []byte) error {
+	return x.set(func(f *Fixed) error { return f.UnmarshalJSON(data) })
+}
+
+func (x *U64F64) UnmarshalWithDecoder(dec *Decoder) error {
+	return (*Uint128)(x).UnmarshalWithDecoder(dec)
+}
+
+func (x U64F64) MarshalWithEncoder(enc *Encoder) error {
+	return Uint128(x).MarshalWithEncoder(enc)
+}
+
+// WadDecimal is an unsigned 192-bit integer scaled by 10**18
+// (e.g. the Decimal of spl-token-lending), held in its three 64-bit
+// words, the least significant first.
+type WadDecimal [3]uint64
+
+// Fixed returns x as a Fixed.
+func (x WadDecimal) Fixed() Fixed {
+	return Fixed{Raw: Uint256{Words: words256{x[0], x[1], x[2]}}, Layout: LayoutWad}
+}
+
+func (x *WadDecimal) set(set func(f *Fixed) error) error {
+	f := x.Fixed()
+	if err := set(&f); err != nil {
+		return err
+	}
+	copy(x[:], f.Raw.Words[:3])
+	return nil
+}
+
+// String returns the exact decimal value of x.
+func (x WadDecimal) String() string {
+	return x.Fixed().String()
+}
+
+// SetString sets x to the decimal number s, rounded to the nearest WadDecimal.
+func (x *WadDecimal) SetString(s string) error {
+	return x.set(func(f *Fixed) error { return f.SetString(s) })
+}
+
+// BigFloat returns x as a big.Float (with a precision of 192 bits).
+func (x WadDecimal) BigFloat() *big.Float {
+	return x.Fixed().BigFloat()
+}
+
+// SetBigFloat sets x to v, rounded to the nearest WadDecimal.
+func (x *WadDecimal) SetBigFloat(v *big.Float) error {
+	return x.set(func(f *Fixed) error { return f.SetBigFloat(v) })
+}
+
+// Decimal returns the exact value of x as a decimal.Decimal.
+func (x WadDecimal) Decimal() decimal.Decimal {
+	return x.Fixed().Decimal()
+}
+
+// SetDecimal sets x to d, rounded to the nearest WadDecimal.
+func (x *WadDecimal) SetDecimal(d decimal.Decimal) error {
+	return x.set(func(f *Fixed) error { return f.SetDecimal(d) })
+}
+
+func (x WadDecimal) MarshalJSON() (data []byte, err error) {
+	return x.Fixed().MarshalJSON()
+}
+
+func (x *WadDecimal) UnmarshalJSON(data []byte) error {
+	return x.set(func(f *Fixed) error { return f.UnmarshalJSON(data) })
+}
+
+func (x *WadDecimal) UnmarshalWithDecoder(dec *Decoder) error {
+	return x.set(func(f *Fixed) error { return f.UnmarshalWithDecoder(dec) })
+}
+
+func (x WadDecimal) MarshalWithEncoder(enc *Encoder) error {
+	return x.Fixed().MarshalWithEncoder(enc)
+}
@@ -0,0 +1,255 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestI80F48_String(t *testing.T) {
+	tests := []struct {
+		raw  Int128
+		want string
+	}{
+		{Int128{}, "0"},
+		{Int128{Lo: 1 << 48}, "1"},
+		{NewInt128FromInt64(-1 << 47), "-0.5"},
+		{Int128{Lo: 1}, "0.000000000000003552713678800500929355621337890625"},
+		{NewInt128FromInt64(-1), "-0.000000000000003552713678800500929355621337890625"},
+		{Int128{Lo: 3<<48 | 1<<46}, "3.25"},
+		{Int128{Lo: math.MaxUint64, Hi: math.MaxInt64}, "604462909807314587353087.999999999999996447286321199499070644378662109375"},
+		{Int128{Hi: 1 << 63}, "-604462909807314587353088"},
+	}
+	for _, test := range tests {
+		x := I80F48(test.raw)
+		require.Equal(t, test.want, x.String())
+
+		d, err := decimal.NewFromString(test.want)
+		require.NoError(t, err)
+		require.True(t, d.Equal(x.Decimal()), x.Decimal().String())
+		r, _ := x.BigFloat().Rat(nil)
+		require.Equal(t, d.Rat().String(), r.String())
+
+		var y I80F48
+		require.NoError(t, y.SetString(test.want))
+		require.Equal(t, x, y)
+		y = I80F48{}
+		require.NoError(t, y.SetDecimal(d))
+		require.Equal(t, x, y)
+		y = I80F48{}
+		require.NoError(t, y.SetBigFloat(x.BigFloat()))
+		require.Equal(t, x, y)
+	}
+}
+
+func TestFixed_rounding(t *testing.T) {
+	layout := FixedLayout{IntBits: 14, FracBits: 2, Signed: true}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0.125", "0"},     // tie, to even
+		{"0.375", "0.5"},   // tie, to even
+		{"0.3", "0.25"},    // nearest
+		{"-0.375", "-0.5"}, // tie, to even
+		{"-0.4", "-0.5"},
+		{"1e-1000000000", "0"},
+		{"8191.75", "8191.75"},
+		{"-8192", "-8192"},
+		{"81917.5e-1", "8191.75"},
+	}
+	for _, test := range tests {
+		f := Fixed{Layout: layout}
+		require.NoError(t, f.SetString(test.in), test.in)
+		require.Equal(t, test.want, f.String(), test.in)
+	}
+
+	for _, in := range []string{"8191.875", "8192", "-8192.25", "1e1000000000", "Inf"} {
+		f := Fixed{Layout: layout}
+		require.True(t, errors.Is(f.SetString(in), ErrOverflow), in)
+	}
+	f := Fixed{Layout: layout}
+	require.Error(t, f.SetString("abc"))
+	require.Error(t, (&Fixed{}).SetString("1"))
+	require.True(t, errors.Is(f.SetDecimal(decimal.New(1, 1000000000)), ErrOverflow))
+	require.NoError(t, f.SetDecimal(decimal.New(1, -1000000000)))
+	require.Equal(t, "0", f.String())
+
+	wad := WadDecimal{}
+	require.NoError(t, wad.SetString("1.5"))
+	require.Equal(t, WadDecimal{1500000000000000000}, wad)
+	require.Equal(t, "1.5", wad.String())
+	require.NoError(t, wad.SetString("0.0000000000000000005"))
+	require.Equal(t, WadDecimal{0}, wad)
+	require.NoError(t, wad.SetString("0.0000000000000000015"))
+	require.Equal(t, WadDecimal{2}, wad)
+	require.True(t, errors.Is(wad.SetString("-1"), ErrOverflow))
+	require.Equal(t, WadDecimal{2}, wad)
+
+	var u U64F64
+	require.NoError(t, u.SetBigFloat(big.NewFloat(1.5)))
+	require.Equal(t, U64F64{Hi: 1, Lo: 1 << 63}, u)
+	require.Equal(t, "1.5", u.String())
+}
+
+func TestFixed_encoding(t *testing.T) {
+	type S struct {
+		A I80F48
+		B I80F48 `bin:"big"`
+		C U64F64
+		D WadDecimal
+		E Fixed `bin:"layout=I14F2"`
+		F Fixed `bin:"layout=I14F2 big"`
+	}
+	q := FixedLayout{IntBits: 14, FracBits: 2, Signed: true}
+	x := S{
+		A: I80F48(NewInt128FromInt64(-1 << 48)),
+		B: I80F48(NewInt128FromInt64(-1 << 48)),
+		C: U64F64{Hi: 1},
+		D: WadDecimal{1, 2, 3},
+		E: Fixed{Layout: q},
+		F: Fixed{Layout: q},
+	}
+	require.NoError(t, x.E.SetString("-1.5"))
+	require.NoError(t, x.F.SetString("-1.5"))
+	want := concatByteSlices(
+		[]byte{0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0xfa, 0xff},
+		[]byte{0xff, 0xfa},
+	)
+
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		data, err := marshalWithEncoding(&x, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, want, data, encoding)
+
+		var y S
+		require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+		require.Equal(t, x, y, encoding)
+		require.Equal(t, "-1", y.A.String())
+		require.Equal(t, "-1.5", y.F.String())
+
+		// Outside of the tagged fields, the layout of a Fixed must be set:
+		var untagged struct{ E Fixed }
+		err = NewDecoderWithEncoding(data, encoding).Decode(&untagged)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "layout not set")
+		top := Fixed{Layout: q}
+		require.NoError(t, NewDecoderWithEncoding(data[len(data)-4:], encoding).Decode(&top))
+		require.Equal(t, x.E, top)
+	}
+
+	// The layout of a value must match the layout of its field:
+	x.E.Layout = LayoutI80F48
+	_, err := MarshalBorsh(&x)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't match")
+
+	// A Raw integer out of the layout can't be encoded:
+	f := Fixed{Raw: NewUint256FromUint64(1 << 15), Layout: q}
+	_, err = MarshalBorsh(&f)
+	require.True(t, errors.Is(err, ErrOverflow), err)
+	f.Raw = Uint256(NewInt256FromInt64(-1 << 15))
+	_, err = MarshalBorsh(&f)
+	require.NoError(t, err)
+
+	_, err = MarshalBorsh(&Fixed{Layout: FixedLayout{IntBits: 4, FracBits: 2}})
+	require.Error(t, err)
+	_, err = MarshalBorsh(&Fixed{Layout: FixedLayout{IntBits: 8, FracBits: 8, Decimals: 2}})
+	require.Error(t, err)
+}
+
+func TestFixed_JSON(t *testing.T) {
+	type S struct {
+		A I80F48     `json:"a"`
+		C U64F64     `json:"c"`
+		D WadDecimal `json:"d"`
+		E Fixed      `json:"e"`
+	}
+	x := S{
+		A: I80F48(NewInt128FromInt64(-3 << 46)),
+		C: U64F64{Lo: 1},
+		D: WadDecimal{1, 1},
+		E: Fixed{Raw: NewUint256FromUint64(0x1234), Layout: FixedLayout{IntBits: 8, FracBits: 8}},
+	}
+	data, err := json.Marshal(x)
+	require.NoError(t, err)
+	require.Equal(t, `{"a":"-0.75","c":"0.0000000000000000000542101086242752217003726400434970855712890625","d":"18.446744073709551617","e":"18.203125"}`, string(data))
+
+	y := S{E: Fixed{Layout: x.E.Layout}}
+	require.NoError(t, json.Unmarshal(data, &y))
+	require.Equal(t, x, y)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"a":1.25,"c":null,"e":"0.5"}`), &y))
+	require.Equal(t, "1.25", y.A.String())
+	require.Equal(t, x.C, y.C)
+	require.Equal(t, "0.5", y.E.String())
+
+	require.Error(t, json.Unmarshal([]byte(`{"e":"256"}`), &y))
+	require.Error(t, json.Unmarshal([]byte(`{"e":true}`), &y))
+	require.Equal(t, "0.5", y.E.String())
+	require.Error(t, json.Unmarshal([]byte(`{"e":"1"}`), &S{}))
+}
+
+func TestFixed_BigFloat(t *testing.T) {
+	wad := WadDecimal{1}
+	f, _ := wad.BigFloat().Float64()
+	require.Equal(t, 1e-18, f)
+	require.Equal(t, uint(192), wad.BigFloat().Prec())
+
+	var x I80F48
+	require.NoError(t, x.SetBigFloat(big.NewFloat(-0.1)))
+	// -0.1 is rounded twice: to a float64, then to an I80F48.
+	require.Equal(t, "-0.10000000000000142108547152020037174224853515625", x.String())
+	require.True(t, errors.Is(x.SetBigFloat(new(big.Float).SetInf(false)), ErrOverflow))
+	require.True(t, errors.Is(x.SetBigFloat(new(big.Float).SetMantExp(big.NewFloat(1), 79)), ErrOverflow))
+	require.NoError(t, x.SetBigFloat(new(big.Float).SetMantExp(big.NewFloat(-1), 79)))
+	require.Equal(t, Int128{Hi: 1 << 63}, Int128(x))
+}
+
+func TestFixedLayout(t *testing.T) {
+	require.Equal(t, 16, LayoutI80F48.Size())
+	require.Equal(t, 24, LayoutWad.Size())
+	require.Equal(t, uint(128), LayoutU64F64.Bits())
+
+	for _, name := range []string{"I80F48", "U64F64", "U192D18", "I14F2", "U8F0"} {
+		layout, err := parseFixedLayout(name)
+		require.NoError(t, err, name)
+		require.Equal(t, name, layout.String())
+	}
+	wad, err := parseFixedLayout("U192D18")
+	require.NoError(t, err)
+	require.Equal(t, LayoutWad, wad)
+	for _, name := range []string{"", "X8F8", "I80", "IF48", "I80F", "I80F48x", "U-8F8"} {
+		_, err := parseFixedLayout(name)
+		require.Error(t, err, name)
+	}
+
+	var be I80F48
+	be.Endianness = binary.BigEndian
+	require.NoError(t, be.SetString("2"))
+	require.Equal(t, binary.BigEndian, be.Endianness)
+}
@@ -0,0 +1,326 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Float128 is an IEEE 754 binary128 (quadruple precision) floating-point
+// number, e.g. a Rust `f128` or a C `__float128`: Hi holds the sign,
+// the 15-bit exponent and the top 48 bits of the significand,
+// Lo the low 64 bits of the significand.
+type Float128 Uint128
+
+const (
+	float128MantBits = 112
+	float128ExpBias  = 16383
+	float128MaxExp   = 16383  // exponent of the largest normal numbers
+	float128MinExp   = -16382 // exponent of the smallest normal numbers
+	// float128Prec is the precision of the significand, with the implicit bit.
+	float128Prec = float128MantBits + 1
+
+	float128SignMask = 1 << 63
+	float128ExpMask  = 0x7fff << 48
+	float128HiMant   = 1<<48 - 1
+)
+
+func (i Float128) getByteOrder() binary.ByteOrder {
+	return Uint128(i).getByteOrder()
+}
+
+// Float128Inf returns positive infinity if sign >= 0, negative infinity if sign < 0.
+func Float128Inf(sign int) Float128 {
+	f := Float128{Hi: float128ExpMask}
+	if sign < 0 {
+		f.Hi |= float128SignMask
+	}
+	return f
+}
+
+// Float128NaN returns a (quiet) NaN.
+func Float128NaN() Float128 {
+	return Float128{Hi: float128ExpMask | 1<<47}
+}
+
+// NewFloat128FromFloat64 returns the Float128 equal to f (binary128 represents
+// every float64 exactly). A NaN is converted to a quiet NaN, with the same sign.
+func NewFloat128FromFloat64(f float64) Float128 {
+	if math.IsNaN(f) {
+		nan := Float128NaN()
+		if math.Signbit(f) {
+			nan.Hi |= float128SignMask
+		}
+		return nan
+	}
+	return NewFloat128FromBigFloat(big.NewFloat(f))
+}
+
+// NewFloat128FromBigFloat returns f rounded to the nearest Float128 (ties to even):
+// the values too large are rounded to an infinity, the values too small
+// to a subnormal number or to a (signed) zero.
+func NewFloat128FromBigFloat(f *big.Float) Float128 {
+	switch {
+	case f.IsInf():
+		return Float128Inf(f.Sign())
+	case f.Sign() == 0:
+		return newFloat128(f.Signbit(), 0, new(big.Int))
+	}
+	// Handle the values out of range before computing their exact value:
+	// |f| < 2**exp.
+	exp := f.MantExp(nil)
+	if exp > float128MaxExp+1 {
+		return Float128Inf(f.Sign())
+	}
+	if exp < float128MinExp-float128MantBits-1 {
+		// Less than half the smallest subnormal number.
+		return newFloat128(f.Signbit(), 0, new(big.Int))
+	}
+	r, _ := f.Rat(nil)
+	return newFloat128FromRat(r)
+}
+
+// newFloat128FromRat returns r rounded to the nearest Float128 (ties to even).
+func newFloat128FromRat(r *big.Rat) Float128 {
+	neg := r.Sign() < 0
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+	if num.Sign() == 0 {
+		return newFloat128(neg, 0, num)
+	}
+
+	// 2**exp <= |r| < 2**(exp+1), with the exponent of the subnormal numbers
+	// when |r| is below the smallest normal number.
+	exp := num.BitLen() - den.BitLen()
+	if exp >= 0 {
+		if num.Cmp(new(big.Int).Lsh(den, uint(exp))) < 0 {
+			exp--
+		}
+	} else if new(big.Int).Lsh(num, uint(-exp)).Cmp(den) < 0 {
+		exp--
+	}
+	if exp > float128MaxExp {
+		return Float128Inf(r.Sign())
+	}
+	if exp < float128MinExp {
+		exp = float128MinExp
+	}
+
+	// The significand: |r| * 2**(112-exp), rounded to an integer.
+	shift := float128MantBits - exp
+	if shift > 0 {
+		num.Lsh(num, uint(shift))
+	} else {
+		den = new(big.Int).Lsh(den, uint(-shift))
+	}
+	mant, rem := num.QuoRem(num, den, new(big.Int))
+	if c := rem.Lsh(rem, 1).Cmp(den); c > 0 || (c == 0 && mant.Bit(0) == 1) {
+		mant.Add(mant, big.NewInt(1))
+	}
+	if mant.BitLen() > float128Prec {
+		// Rounded up to the next power of two.
+		mant.Rsh(mant, 1)
+		exp++
+		if exp > float128MaxExp {
+			return Float128Inf(r.Sign())
+		}
+	}
+	if mant.BitLen() < float128Prec {
+		// A subnormal number (or zero).
+		return newFloat128(neg, 0, mant)
+	}
+	return newFloat128(neg, exp+float128ExpBias, mant)
+}
+
+// newFloat128 returns the Float128 with the provided sign, biased exponent
+// and significand (whose implicit bit, if any, is ignored).
+func newFloat128(neg bool, biasedExp int, mant *big.Int) Float128 {
+	var lo, hi uint64
+	words := new(big.Int).Set(mant)
+	lo = words.Uint64()
+	hi = words.Rsh(words, 64).Uint64() & float128HiMant
+	hi |= uint64(biasedExp) << 48
+	if neg {
+		hi |= float128SignMask
+	}
+	return Float128{Lo: lo, Hi: hi}
+}
+
+// IsNaN reports whether f is a NaN.
+func (i Float128) IsNaN() bool {
+	return i.Hi&float128ExpMask == float128ExpMask && (i.Hi&float128HiMant != 0 || i.Lo != 0)
+}
+
+// IsInf reports whether f is an infinity, according to sign
+// (like math.IsInf): positive infinity if sign > 0, negative infinity
+// if sign < 0, either if sign == 0.
+func (i Float128) IsInf(sign int) bool {
+	if i.Hi&^float128SignMask != float128ExpMask || i.Lo != 0 {
+		return false
+	}
+	return sign == 0 || (sign > 0) == !i.Signbit()
+}
+
+// Signbit reports whether f is negative or negative zero.
+func (i Float128) Signbit() bool {
+	return i.Hi&float128SignMask != 0
+}
+
+// BigFloat returns the value of f, exactly (with a 113-bit precision);
+// it returns ErrNaN if f is a NaN, which a big.Float can't hold.
+func (i Float128) BigFloat() (*big.Float, error) {
+	if i.IsNaN() {
+		return nil, ErrNaN
+	}
+	out := new(big.Float).SetPrec(float128Prec)
+	if i.IsInf(0) {
+		return out.SetInf(i.Signbit()), nil
+	}
+	biasedExp := int((i.Hi & float128ExpMask) >> 48)
+	mant := new(big.Int).SetUint64(i.Hi & float128HiMant)
+	mant.Lsh(mant, 64).Or(mant, new(big.Int).SetUint64(i.Lo))
+	exp := float128MinExp - float128MantBits // subnormal numbers
+	if biasedExp != 0 {
+		mant.SetBit(mant, float128MantBits, 1)
+		exp = biasedExp - float128ExpBias - float128MantBits
+	}
+	out.SetInt(mant)
+	out.SetMantExp(out, exp)
+	if i.Signbit() {
+		out.Neg(out)
+	}
+	return out, nil
+}
+
+// Float64 returns f rounded to the nearest float64 (ties to even),
+// and whether the result is exact, below or above f
+// (like big.Float.Float64). A NaN is returned as a NaN, exactly.
+func (i Float128) Float64() (float64, big.Accuracy) {
+	f, err := i.BigFloat()
+	if err != nil {
+		return math.NaN(), big.Exact
+	}
+	return f.Float64()
+}
+
+// String returns the shortest decimal representation of f that
+// converts back to f, or "NaN", "+Inf", "-Inf".
+func (i Float128) String() string {
+	f, err := i.BigFloat()
+	if err != nil {
+		return "NaN"
+	}
+	return f.Text('g', -1)
+}
+
+// MarshalJSON encodes f as a JSON number; NaN and the infinities,
+// which JSON numbers can't represent, are encoded as
+// the strings "NaN", "+Inf" and "-Inf".
+func (i Float128) MarshalJSON() (data []byte, err error) {
+	s := i.String()
+	if i.IsNaN() || i.IsInf(0) {
+		return []byte(`"` + s + `"`), nil
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalJSON decodes f from a JSON number (rounded to the nearest Float128),
+// or from one of the strings "NaN", "+Inf", "-Inf" or "Inf".
+//
+// The other strings are rejected: before Float128 had the semantics of a
+// binary128 float, its JSON form was the decimal string of its raw bits
+// (e.g. "123"), which must not be read as a number.
+func (i *Float128) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		switch s {
+		case "NaN", "Inf", "+Inf", "-Inf":
+		default:
+			return fmt.Errorf("float128: unexpected JSON string %s: the values are JSON numbers, or \"NaN\", \"+Inf\" or \"-Inf\" (the former form, the decimal string of the raw bits, is no longer supported)", data)
+		}
+	}
+	out, err := parseFloat128(s)
+	if err != nil {
+		return err
+	}
+	out.Endianness = i.Endianness
+	*i = out
+	return nil
+}
+
+// parseFloat128 returns the decimal number s rounded to the nearest Float128.
+func parseFloat128(s string) (Float128, error) {
+	switch s {
+	case "NaN":
+		return Float128NaN(), nil
+	case "Inf", "+Inf":
+		return Float128Inf(1), nil
+	case "-Inf":
+		return Float128Inf(-1), nil
+	}
+	// Find out the magnitude of s first, so that the huge exponents
+	// (e.g. 1e1000000000) don't make huge rationals; f is rounded,
+	// hence the margin of one around the limits.
+	f, _, err := new(big.Float).Parse(s, 10)
+	if err != nil {
+		return Float128{}, fmt.Errorf("could not parse %q as a float128", s)
+	}
+	if exp := f.MantExp(nil); f.IsInf() || f.Sign() == 0 || exp > float128MaxExp+2 || exp < float128MinExp-float128MantBits-2 {
+		return NewFloat128FromBigFloat(f), nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Float128{}, fmt.Errorf("could not parse %q as a float128", s)
+	}
+	return newFloat128FromRat(r), nil
+}
+
+func (i *Float128) UnmarshalWithDecoder(dec *Decoder) error {
+	var order binary.ByteOrder
+	if dec != nil && dec.currentFieldOpt != nil {
+		order = dec.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	value, err := dec.ReadFloat128(order)
+	if err != nil {
+		return err
+	}
+
+	*i = Float128(value)
+	return nil
+}
+
+func (i Float128) MarshalWithEncoder(enc *Encoder) error {
+	var order binary.ByteOrder
+	if enc != nil && enc.currentFieldOpt != nil {
+		order = enc.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	return enc.WriteFloat128(i, order)
+}
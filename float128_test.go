@@ -0,0 +1,302 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pow2 returns 2**exp, times mul.
+func pow2(mul int64, exp int) *big.Float {
+	f := new(big.Float).SetInt64(mul)
+	return f.SetMantExp(f, exp)
+}
+
+// exactSub returns x-y, without rounding.
+func exactSub(x, y *big.Float) *big.Float {
+	return new(big.Float).SetPrec(20000).Sub(x, y)
+}
+
+func TestFloat128_bigFloat(t *testing.T) {
+	maxFloat128 := exactSub(pow2(2, 0), pow2(1, -112))
+	maxFloat128.SetMantExp(maxFloat128, 16383)
+
+	tests := []struct {
+		name  string
+		value *big.Float
+		bits  Float128
+	}{
+		{"zero", big.NewFloat(0), Float128{}},
+		{"negative zero", big.NewFloat(math.Copysign(0, -1)), Float128{Hi: 0x8000000000000000}},
+		{"one", big.NewFloat(1), Float128{Hi: 0x3fff000000000000}},
+		{"minus two", big.NewFloat(-2), Float128{Hi: 0xc000000000000000}},
+		{"one third", new(big.Float).SetPrec(200).Quo(big.NewFloat(1), big.NewFloat(3)), Float128{Hi: 0x3ffd555555555555, Lo: 0x5555555555555555}},
+		{"max", maxFloat128, Float128{Hi: 0x7ffeffffffffffff, Lo: 0xffffffffffffffff}},
+		{"min normal", pow2(1, -16382), Float128{Hi: 0x0001000000000000}},
+		{"max subnormal", exactSub(pow2(1, -16382), pow2(1, -16494)), Float128{Hi: 0x0000ffffffffffff, Lo: 0xffffffffffffffff}},
+		{"min subnormal", pow2(-1, -16494), Float128{Hi: 0x8000000000000000, Lo: 1}},
+		{"inf", new(big.Float).SetInf(false), Float128{Hi: 0x7fff000000000000}},
+		{"minus inf", new(big.Float).SetInf(true), Float128{Hi: 0xffff000000000000}},
+	}
+	for _, test := range tests {
+		got := NewFloat128FromBigFloat(test.value)
+		assert.Equal(t, test.bits, got, test.name)
+
+		back, err := got.BigFloat()
+		require.NoError(t, err)
+		assert.Equal(t, got, NewFloat128FromBigFloat(back), test.name)
+		if test.value.MinPrec() <= 113 {
+			assert.Equal(t, 0, back.Cmp(test.value), "%s: %s", test.name, back)
+		}
+		assert.Equal(t, test.value.Signbit(), back.Signbit(), test.name)
+	}
+
+	_, err := Float128NaN().BigFloat()
+	require.True(t, errors.Is(err, ErrNaN))
+}
+
+func TestFloat128_rounding(t *testing.T) {
+	one := big.NewFloat(1)
+	plus := func(f *big.Float, mul int64, exp int) *big.Float {
+		return exactSub(f, pow2(-mul, exp))
+	}
+	maxFloat128 := Float128{Hi: 0x7ffeffffffffffff, Lo: 0xffffffffffffffff}
+	max, err := maxFloat128.BigFloat()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value *big.Float
+		bits  Float128
+	}{
+		// Ties to even:
+		{"1 + half ulp", plus(one, 1, -113), Float128{Hi: 0x3fff000000000000}},
+		{"1 + 3/2 ulp", plus(one, 3, -113), Float128{Hi: 0x3fff000000000000, Lo: 2}},
+		{"1 + just over half ulp", plus(plus(one, 1, -113), 1, -300), Float128{Hi: 0x3fff000000000000, Lo: 1}},
+		{"1 - half ulp", plus(one, -1, -114), Float128{Hi: 0x3fff000000000000}},
+		// Carry into the exponent:
+		{"2 - quarter ulp", plus(big.NewFloat(2), -1, -114), Float128{Hi: 0x4000000000000000}},
+		// Overflow:
+		{"max + half ulp", plus(max, 1, 16383-113), Float128Inf(1)},
+		{"max + less than half ulp", plus(max, 1, 16383-114), maxFloat128},
+		{"2**16384", pow2(1, 16384), Float128Inf(1)},
+		{"-2**100000", pow2(-1, 100000), Float128Inf(-1)},
+		// Subnormal numbers and underflow:
+		{"half min subnormal", pow2(1, -16495), Float128{}},
+		{"-half min subnormal", pow2(-1, -16495), Float128{Hi: 0x8000000000000000}},
+		{"3/2 min subnormal", pow2(3, -16495), Float128{Lo: 2}},
+		{"just over half min subnormal", plus(pow2(1, -16495), 1, -17000), Float128{Lo: 1}},
+		{"quarter min subnormal", pow2(1, -16496), Float128{}},
+		{"2**-100000", pow2(1, -100000), Float128{}},
+		{"max subnormal + half", plus(exactSub(pow2(1, -16382), pow2(1, -16494)), 1, -16495), Float128{Hi: 0x0001000000000000}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.bits, NewFloat128FromBigFloat(test.value), test.name)
+	}
+}
+
+func TestFloat128_float64(t *testing.T) {
+	for _, f := range []float64{
+		0, 1, -1, 0.1, 1.0 / 3, math.Pi, math.MaxFloat64, -math.SmallestNonzeroFloat64,
+		math.Float64frombits(0x000fffffffffffff), // max subnormal float64
+		math.Inf(1), math.Inf(-1), math.Copysign(0, -1),
+	} {
+		f128 := NewFloat128FromFloat64(f)
+		got, acc := f128.Float64()
+		assert.Equal(t, math.Float64bits(f), math.Float64bits(got), "%v", f)
+		assert.Equal(t, big.Exact, acc, "%v", f)
+	}
+	assert.Equal(t, Float128{Hi: 0x3fff000000000000}, NewFloat128FromFloat64(1))
+	assert.Equal(t, Float128{Hi: 0x3ff0000000000000}, NewFloat128FromFloat64(math.Ldexp(1, -15)))
+
+	nan := NewFloat128FromFloat64(math.NaN())
+	assert.True(t, nan.IsNaN())
+	got, _ := nan.Float64()
+	assert.True(t, math.IsNaN(got))
+
+	// Rounded:
+	third, err := parseFloat128("0.33333333333333333333333333333333333333")
+	require.NoError(t, err)
+	got, acc := third.Float64()
+	assert.Equal(t, 1.0/3, got)
+	assert.Equal(t, big.Below, acc)
+
+	got, acc = Float128{Hi: 0x7ffeffffffffffff, Lo: 0xffffffffffffffff}.Float64()
+	assert.Equal(t, math.Inf(1), got)
+	assert.Equal(t, big.Above, acc)
+
+	got, acc = Float128{Lo: 1}.Float64()
+	assert.Equal(t, float64(0), got)
+	assert.Equal(t, big.Below, acc)
+}
+
+func TestFloat128_classify(t *testing.T) {
+	assert.True(t, Float128NaN().IsNaN())
+	assert.True(t, Float128{Hi: 0xffff000000000000, Lo: 1}.IsNaN())
+	assert.False(t, Float128Inf(1).IsNaN())
+	assert.False(t, Float128{Hi: 0x7ffe000000000000}.IsNaN())
+
+	assert.True(t, Float128Inf(1).IsInf(1))
+	assert.True(t, Float128Inf(1).IsInf(0))
+	assert.False(t, Float128Inf(1).IsInf(-1))
+	assert.True(t, Float128Inf(-1).IsInf(-1))
+	assert.False(t, Float128NaN().IsInf(0))
+
+	assert.True(t, Float128{Hi: 0x8000000000000000}.Signbit())
+	assert.False(t, Float128{}.Signbit())
+}
+
+func TestFloat128_String(t *testing.T) {
+	assert.Equal(t, "1", NewFloat128FromFloat64(1).String())
+	assert.Equal(t, "-0", NewFloat128FromFloat64(math.Copysign(0, -1)).String())
+	assert.Equal(t, "0.1", mustParseFloat128(t, "0.1").String())
+	assert.Equal(t, "NaN", Float128NaN().String())
+	assert.Equal(t, "+Inf", Float128Inf(1).String())
+	assert.Equal(t, "-Inf", Float128Inf(-1).String())
+
+	// The strings convert back to the same value:
+	for _, f := range []Float128{
+		{Hi: 0x4000921fb54442d1, Lo: 0x8469898cc51701b8}, // pi
+		{Hi: 0x3ffd555555555555, Lo: 0x5555555555555555}, // 1/3
+		{Hi: 0x7ffeffffffffffff, Lo: 0xffffffffffffffff},
+		{Hi: 0x0001000000000000},
+		{Hi: 0x0000ffffffffffff, Lo: 0xffffffffffffffff},
+		{Lo: 1},
+		{Hi: 0x8000000000000000, Lo: 12345},
+	} {
+		assert.Equal(t, f, mustParseFloat128(t, f.String()), f.String())
+	}
+	assert.Equal(t, "3.1415926535897932384626433832795028", Float128{Hi: 0x4000921fb54442d1, Lo: 0x8469898cc51701b8}.String())
+}
+
+func mustParseFloat128(t *testing.T, s string) Float128 {
+	t.Helper()
+	f, err := parseFloat128(s)
+	require.NoError(t, err)
+	return f
+}
+
+func TestFloat128_parse(t *testing.T) {
+	assert.Equal(t, Float128{Hi: 0x3ffb999999999999, Lo: 0x999999999999999a}, mustParseFloat128(t, "0.1"))
+	assert.Equal(t, Float128{Hi: 0x3fff000000000000}, mustParseFloat128(t, "1e0"))
+	assert.Equal(t, Float128{Hi: 0xc000000000000000}, mustParseFloat128(t, "-2"))
+	assert.Equal(t, Float128Inf(1), mustParseFloat128(t, "1e1000000000"))
+	assert.Equal(t, Float128{Hi: 0x8000000000000000}, mustParseFloat128(t, "-1e-1000000000"))
+	assert.Equal(t, Float128{Hi: 0x8000000000000000}, mustParseFloat128(t, "-0"))
+	// Halfway between the max and the next (even) power of two, exactly:
+	halfway := new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 114), big.NewInt(1)), 16383-113)
+	assert.Equal(t, Float128Inf(1), mustParseFloat128(t, halfway.String()))
+	assert.Equal(t, Float128Inf(1), mustParseFloat128(t, "1.189731495357231765085759326628007073479956870e4932"))
+	assert.Equal(t, Float128{Hi: 0x7ffeffffffffffff, Lo: 0xffffffffffffffff}, mustParseFloat128(t, "1.189731495357231765085759326628007073479956869e4932"))
+	assert.Equal(t, Float128{Hi: 0x7ffeffffffffffff, Lo: 0xffffffffffffffff}, mustParseFloat128(t, "1.18973149535723176508575932662800702e4932"))
+	assert.Equal(t, Float128{Lo: 1}, mustParseFloat128(t, "6.475175119438025110924438958227646552e-4966"))
+	assert.True(t, mustParseFloat128(t, "NaN").IsNaN())
+	assert.Equal(t, Float128Inf(-1), mustParseFloat128(t, "-Inf"))
+
+	for _, s := range []string{"", "abc", "1/3", "0x10", "Inf1", "1e"} {
+		_, err := parseFloat128(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestFloat128_JSON(t *testing.T) {
+	type S struct {
+		F Float128 `json:"f"`
+	}
+	tests := []struct {
+		json string
+		f    Float128
+	}{
+		{`{"f":1.5}`, Float128{Hi: 0x3fff800000000000}},
+		{`{"f":-0}`, Float128{Hi: 0x8000000000000000}},
+		{`{"f":3.1415926535897932384626433832795028}`, Float128{Hi: 0x4000921fb54442d1, Lo: 0x8469898cc51701b8}},
+		{`{"f":6.475175119438025110924438958227647e-4966}`, Float128{Lo: 1}},
+		{`{"f":"NaN"}`, Float128NaN()},
+		{`{"f":"+Inf"}`, Float128Inf(1)},
+		{`{"f":"-Inf"}`, Float128Inf(-1)},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(S{F: test.f})
+		require.NoError(t, err)
+		assert.Equal(t, test.json, string(data))
+
+		var got S
+		require.NoError(t, json.Unmarshal([]byte(test.json), &got))
+		assert.Equal(t, test.f, got.F, test.json)
+	}
+
+	var got S
+	require.NoError(t, json.Unmarshal([]byte(`{"f":1e2}`), &got))
+	assert.Equal(t, NewFloat128FromFloat64(100), got.F)
+	require.NoError(t, json.Unmarshal([]byte(`{"f":null}`), &got))
+	assert.Equal(t, NewFloat128FromFloat64(100), got.F)
+	require.Error(t, json.Unmarshal([]byte(`{"f":"x"}`), &got))
+	// The former JSON form, the decimal string of the raw bits:
+	err := json.Unmarshal([]byte(`{"f":"123"}`), &got)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "raw bits")
+	require.Error(t, json.Unmarshal([]byte(`{"f":"1e2"}`), &got))
+	assert.Equal(t, NewFloat128FromFloat64(100), got.F)
+	require.Error(t, json.Unmarshal([]byte(`{"f":true}`), &got))
+}
+
+func TestFloat128_encoding(t *testing.T) {
+	type S struct {
+		F Float128
+		G Float128 `bin:"big"`
+	}
+	x := S{
+		F: NewFloat128FromFloat64(1),
+		G: NewFloat128FromFloat64(-2),
+	}
+	data, err := MarshalBorsh(&x)
+	require.NoError(t, err)
+	assert.Equal(t, concatByteSlices(
+		[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0x3f},
+		[]byte{0xc0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	), data)
+
+	var y S
+	require.NoError(t, UnmarshalBorsh(&y, data))
+	assert.Equal(t, x, y)
+
+	// NaN is rejected by borsh, like for float32 and float64:
+	nan := S{F: Float128NaN()}
+	_, err = MarshalBorsh(&nan)
+	require.EqualError(t, err, `error while encoding "F" field: NaN float value`)
+
+	nanData := make([]byte, 32)
+	binary.LittleEndian.PutUint64(nanData[8:], Float128NaN().Hi)
+	err = UnmarshalBorsh(&y, nanData)
+	require.True(t, errors.Is(err, ErrNaN), err)
+
+	_, err = NewBorshDecoder(nanData).ReadFloat128(binary.LittleEndian)
+	require.True(t, errors.Is(err, ErrNaN), err)
+
+	// But not by the other encodings:
+	got, err := NewBinDecoder(nanData).ReadFloat128(binary.LittleEndian)
+	require.NoError(t, err)
+	assert.True(t, got.IsNaN())
+	_, err = MarshalBin(&nan)
+	require.NoError(t, err)
+}
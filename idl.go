@@ -0,0 +1,401 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// IDL is an Anchor IDL, used to decode instructions, accounts and types
+// of programs without having Go types for them (see DecodeInstruction).
+// Both the legacy IDL format and the one of Anchor 0.30 (with explicit
+// discriminators) are supported.
+type IDL struct {
+	Version      string           `json:"version"`
+	Name         string           `json:"name"`
+	Instructions []IDLInstruction `json:"instructions"`
+	Accounts     []IDLTypeDef     `json:"accounts"`
+	Types        []IDLTypeDef     `json:"types"`
+	Events       []IDLTypeDef     `json:"events,omitempty"`
+
+	instructionsByDiscriminator map[TypeID]*IDLInstruction
+	accountsByDiscriminator     map[TypeID]*IDLTypeDef
+	typesByName                 map[string]*IDLTypeDef
+}
+
+type IDLInstruction struct {
+	Name string `json:"name"`
+	// Discriminator is only set in Anchor 0.30 IDLs;
+	// otherwise the discriminator is SighashInstruction(Name).
+	Discriminator []int            `json:"discriminator,omitempty"`
+	Accounts      []IDLAccountItem `json:"accounts"`
+	Args          []IDLField       `json:"args"`
+}
+
+// IDLAccountItem is an account of an instruction, or a group of accounts.
+type IDLAccountItem struct {
+	Name     string           `json:"name"`
+	IsMut    bool             `json:"isMut,omitempty"`
+	IsSigner bool             `json:"isSigner,omitempty"`
+	Writable bool             `json:"writable,omitempty"`
+	Signer   bool             `json:"signer,omitempty"`
+	Optional bool             `json:"optional,omitempty"`
+	Accounts []IDLAccountItem `json:"accounts,omitempty"`
+}
+
+type IDLField struct {
+	Name string  `json:"name"`
+	Type IDLType `json:"type"`
+}
+
+// IDLTypeDef is the definition of a type (or account, or event).
+type IDLTypeDef struct {
+	Name string `json:"name"`
+	// Discriminator is only set for the accounts of Anchor 0.30 IDLs;
+	// otherwise the discriminator of an account is SighashAccount(Name).
+	Discriminator []int `json:"discriminator,omitempty"`
+	// Type is nil for the accounts of Anchor 0.30 IDLs,
+	// which are defined in the types.
+	Type *IDLTypeDefTy `json:"type,omitempty"`
+}
+
+type IDLTypeDefTy struct {
+	// Kind is "struct", "enum" or "alias".
+	Kind     string           `json:"kind"`
+	Fields   IDLFields        `json:"fields,omitempty"`
+	Variants []IDLEnumVariant `json:"variants,omitempty"`
+	// Value is the aliased type of an "alias".
+	Value *IDLType `json:"value,omitempty"`
+}
+
+type IDLEnumVariant struct {
+	Name   string    `json:"name"`
+	Fields IDLFields `json:"fields,omitempty"`
+}
+
+// IDLFields are the fields of a struct or enum variant:
+// either named, or (for tuples) only types.
+type IDLFields struct {
+	Named []IDLField
+	Tuple []IDLType
+}
+
+func (f IDLFields) IsTuple() bool {
+	return f.Tuple != nil
+}
+
+func (f *IDLFields) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, &f.Named); err == nil && f.Named[0].Name != "" {
+		return nil
+	}
+	f.Named = nil
+	return json.Unmarshal(data, &f.Tuple)
+}
+
+func (f IDLFields) MarshalJSON() ([]byte, error) {
+	if f.IsTuple() {
+		return json.Marshal(f.Tuple)
+	}
+	return json.Marshal(f.Named)
+}
+
+// IDLType is the type of a field: either a primitive (e.g. "u64", "publicKey")
+// or one of vec, option, coption, array and defined.
+type IDLType struct {
+	Primitive string
+	Vec       *IDLType
+	Option    *IDLType
+	COption   *IDLType
+	Array     *IDLType
+	ArrayLen  int
+	Defined   string
+}
+
+func (t *IDLType) UnmarshalJSON(data []byte) error {
+	var primitive string
+	if err := json.Unmarshal(data, &primitive); err == nil {
+		t.Primitive = primitive
+		return nil
+	}
+	var obj struct {
+		Vec     *IDLType          `json:"vec"`
+		Option  *IDLType          `json:"option"`
+		COption *IDLType          `json:"coption"`
+		Array   []json.RawMessage `json:"array"`
+		Defined json.RawMessage   `json:"defined"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("idl: invalid type %s: %w", data, err)
+	}
+	switch {
+	case obj.Vec != nil:
+		t.Vec = obj.Vec
+	case obj.Option != nil:
+		t.Option = obj.Option
+	case obj.COption != nil:
+		t.COption = obj.COption
+	case obj.Array != nil:
+		if len(obj.Array) != 2 {
+			return fmt.Errorf("idl: invalid array type %s", data)
+		}
+		t.Array = new(IDLType)
+		if err := json.Unmarshal(obj.Array[0], t.Array); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(obj.Array[1], &t.ArrayLen); err != nil {
+			return fmt.Errorf("idl: invalid array length %s: %w", obj.Array[1], err)
+		}
+	case obj.Defined != nil:
+		// Either "Name" or (Anchor 0.30) {"name": "Name"}.
+		if err := json.Unmarshal(obj.Defined, &t.Defined); err != nil {
+			var defined struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(obj.Defined, &defined); err != nil {
+				return fmt.Errorf("idl: invalid defined type %s: %w", obj.Defined, err)
+			}
+			t.Defined = defined.Name
+		}
+	default:
+		return fmt.Errorf("idl: unknown type %s", data)
+	}
+	return nil
+}
+
+func (t IDLType) MarshalJSON() ([]byte, error) {
+	switch {
+	case t.Vec != nil:
+		return json.Marshal(map[string]interface{}{"vec": t.Vec})
+	case t.Option != nil:
+		return json.Marshal(map[string]interface{}{"option": t.Option})
+	case t.COption != nil:
+		return json.Marshal(map[string]interface{}{"coption": t.COption})
+	case t.Array != nil:
+		return json.Marshal(map[string]interface{}{"array": []interface{}{t.Array, t.ArrayLen}})
+	case t.Defined != "":
+		return json.Marshal(map[string]interface{}{"defined": t.Defined})
+	default:
+		return json.Marshal(t.Primitive)
+	}
+}
+
+func (t IDLType) String() string {
+	switch {
+	case t.Vec != nil:
+		return "Vec<" + t.Vec.String() + ">"
+	case t.Option != nil:
+		return "Option<" + t.Option.String() + ">"
+	case t.COption != nil:
+		return "COption<" + t.COption.String() + ">"
+	case t.Array != nil:
+		return "[" + t.Array.String() + "; " + strconv.Itoa(t.ArrayLen) + "]"
+	case t.Defined != "":
+		return t.Defined
+	default:
+		return t.Primitive
+	}
+}
+
+// ErrUnknownDiscriminator is matched by the errors returned when
+// a discriminator does not match any instruction or account of an IDL.
+var ErrUnknownDiscriminator = errors.New("unknown discriminator")
+
+// ParseIDL parses an Anchor IDL from its JSON.
+func ParseIDL(data []byte) (*IDL, error) {
+	idl := new(IDL)
+	if err := json.Unmarshal(data, idl); err != nil {
+		return nil, fmt.Errorf("idl: %w", err)
+	}
+	if err := idl.index(); err != nil {
+		return nil, err
+	}
+	return idl, nil
+}
+
+// index builds the lookup tables of the IDL, and checks
+// that the types it refers to are defined.
+func (idl *IDL) index() error {
+	idl.typesByName = make(map[string]*IDLTypeDef)
+	for i := range idl.Types {
+		def := &idl.Types[i]
+		if def.Type == nil {
+			return fmt.Errorf("idl: type %q has no definition", def.Name)
+		}
+		idl.typesByName[def.Name] = def
+	}
+
+	idl.accountsByDiscriminator = make(map[TypeID]*IDLTypeDef)
+	for i := range idl.Accounts {
+		def := &idl.Accounts[i]
+		if def.Type == nil {
+			// Anchor 0.30: the account is defined in the types.
+			typeDef, ok := idl.typesByName[def.Name]
+			if !ok {
+				return fmt.Errorf("idl: account %q has no definition", def.Name)
+			}
+			def.Type = typeDef.Type
+		} else if _, ok := idl.typesByName[def.Name]; !ok {
+			// Legacy IDLs define the accounts only here,
+			// but other types can refer to them.
+			idl.typesByName[def.Name] = def
+		}
+		discriminator, err := idlDiscriminator(def.Discriminator, SighashAccount(def.Name))
+		if err != nil {
+			return fmt.Errorf("idl: account %q: %w", def.Name, err)
+		}
+		idl.accountsByDiscriminator[discriminator] = def
+	}
+
+	idl.instructionsByDiscriminator = make(map[TypeID]*IDLInstruction)
+	for i := range idl.Instructions {
+		ins := &idl.Instructions[i]
+		discriminator, err := idlDiscriminator(ins.Discriminator, SighashInstruction(ins.Name))
+		if err != nil {
+			return fmt.Errorf("idl: instruction %q: %w", ins.Name, err)
+		}
+		idl.instructionsByDiscriminator[discriminator] = ins
+		for _, arg := range ins.Args {
+			if err := idl.checkType(arg.Type); err != nil {
+				return fmt.Errorf("idl: instruction %q: arg %q: %w", ins.Name, arg.Name, err)
+			}
+		}
+	}
+
+	for name, def := range idl.typesByName {
+		if err := idl.checkTypeDef(def.Type); err != nil {
+			return fmt.Errorf("idl: type %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func idlDiscriminator(explicit []int, sighash []byte) (TypeID, error) {
+	if explicit == nil {
+		return TypeIDFromBytes(sighash), nil
+	}
+	if len(explicit) != ACCOUNT_DISCRIMINATOR_SIZE {
+		return TypeID{}, fmt.Errorf("discriminator must have %d bytes, got %d", ACCOUNT_DISCRIMINATOR_SIZE, len(explicit))
+	}
+	var discriminator TypeID
+	for i, b := range explicit {
+		if b < 0 || b > 255 {
+			return TypeID{}, fmt.Errorf("invalid discriminator byte %d", b)
+		}
+		discriminator[i] = byte(b)
+	}
+	return discriminator, nil
+}
+
+func (idl *IDL) checkTypeDef(def *IDLTypeDefTy) error {
+	switch def.Kind {
+	case "struct":
+		return idl.checkFields(def.Fields)
+	case "enum":
+		if len(def.Variants) > 256 {
+			return fmt.Errorf("enum has %d variants", len(def.Variants))
+		}
+		for _, variant := range def.Variants {
+			if err := idl.checkFields(variant.Fields); err != nil {
+				return fmt.Errorf("variant %q: %w", variant.Name, err)
+			}
+		}
+		return nil
+	case "alias":
+		if def.Value == nil {
+			return errors.New("alias has no value")
+		}
+		return idl.checkType(*def.Value)
+	default:
+		return fmt.Errorf("unsupported kind %q", def.Kind)
+	}
+}
+
+func (idl *IDL) checkFields(fields IDLFields) error {
+	for _, field := range fields.Named {
+		if err := idl.checkType(field.Type); err != nil {
+			return fmt.Errorf("field %q: %w", field.Name, err)
+		}
+	}
+	for _, typ := range fields.Tuple {
+		if err := idl.checkType(typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (idl *IDL) checkType(typ IDLType) error {
+	switch {
+	case typ.Vec != nil:
+		return idl.checkType(*typ.Vec)
+	case typ.Option != nil:
+		return idl.checkType(*typ.Option)
+	case typ.COption != nil:
+		return idl.checkType(*typ.COption)
+	case typ.Array != nil:
+		if typ.ArrayLen < 0 {
+			return fmt.Errorf("invalid array length %d", typ.ArrayLen)
+		}
+		return idl.checkType(*typ.Array)
+	case typ.Defined != "":
+		if _, ok := idl.typesByName[typ.Defined]; !ok {
+			return fmt.Errorf("undefined type %q", typ.Defined)
+		}
+		return nil
+	default:
+		if !isIDLPrimitive(typ.Primitive) {
+			return fmt.Errorf("unsupported type %q", typ.Primitive)
+		}
+		return nil
+	}
+}
+
+func isIDLPrimitive(name string) bool {
+	return isIn(name,
+		"bool",
+		"u8", "i8", "u16", "i16", "u32", "i32", "u64", "i64", "u128", "i128", "u256", "i256",
+		"f32", "f64",
+		"bytes", "string",
+		"publicKey", "pubkey",
+	)
+}
+
+// Instruction returns the instruction with the provided discriminator.
+func (idl *IDL) Instruction(discriminator TypeID) (*IDLInstruction, bool) {
+	ins, ok := idl.instructionsByDiscriminator[discriminator]
+	return ins, ok
+}
+
+// Account returns the account with the provided discriminator.
+func (idl *IDL) Account(discriminator TypeID) (*IDLTypeDef, bool) {
+	def, ok := idl.accountsByDiscriminator[discriminator]
+	return def, ok
+}
+
+// Type returns the type definition with the provided name.
+func (idl *IDL) Type(name string) (*IDLTypeDef, bool) {
+	def, ok := idl.typesByName[name]
+	return def, ok
+}
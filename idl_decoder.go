@@ -0,0 +1,368 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"go.uber.org/zap"
+)
+
+// The values decoded from an IDL have the following Go types:
+//
+//   - bool, u8...u64, i8...i64, f32, f64: bool, uint8...uint64, int8...int64, float32, float64;
+//   - u128, i128: Uint128, Int128;
+//   - u256, i256: Uint256, Int256;
+//   - string: string;
+//   - bytes and Vec<u8>: []byte;
+//   - publicKey: [32]byte;
+//   - vec and array: []interface{};
+//   - option and coption: nil when absent, the value otherwise;
+//   - defined structs: *OrderedMap (or []interface{} for tuple structs);
+//   - defined enums: *OrderedMap with a single key, the name of the variant,
+//     whose value holds the fields of the variant (an empty *OrderedMap if none).
+
+// DecodeInstruction decodes the borsh-encoded data of an instruction,
+// picked by its 8-byte discriminator, into its arguments.
+func (idl *IDL) DecodeInstruction(data []byte) (name string, args *OrderedMap, err error) {
+	return idl.DecodeInstructionWithDecoder(NewBorshDecoder(data))
+}
+
+// DecodeInstructionWithDecoder acts like DecodeInstruction,
+// reading from the provided borsh decoder.
+func (idl *IDL) DecodeInstructionWithDecoder(dec *Decoder) (name string, args *OrderedMap, err error) {
+	if !dec.IsBorsh() {
+		return "", nil, errors.New("idl: decoder must use the borsh encoding")
+	}
+	discriminator, err := dec.ReadDiscriminator()
+	if err != nil {
+		return "", nil, fmt.Errorf("idl: read instruction discriminator: %w", err)
+	}
+	ins, ok := idl.Instruction(discriminator)
+	if !ok {
+		return "", nil, fmt.Errorf("idl: instruction: %w %s", ErrUnknownDiscriminator, discriminator)
+	}
+	if traceEnabled {
+		zlog.Debug("idl: decode instruction", zap.String("name", ins.Name))
+	}
+	args, err = idl.decodeFields(dec, ins.Args)
+	if err != nil {
+		return ins.Name, nil, withFieldPath(err, ins.Name)
+	}
+	return ins.Name, args, nil
+}
+
+// DecodeAccount decodes the borsh-encoded data of an account,
+// picked by its 8-byte discriminator.
+// Accounts are structs, so value is usually an *OrderedMap.
+func (idl *IDL) DecodeAccount(data []byte) (name string, value interface{}, err error) {
+	return idl.DecodeAccountWithDecoder(NewBorshDecoder(data))
+}
+
+// DecodeAccountWithDecoder acts like DecodeAccount,
+// reading from the provided borsh decoder.
+func (idl *IDL) DecodeAccountWithDecoder(dec *Decoder) (name string, value interface{}, err error) {
+	if !dec.IsBorsh() {
+		return "", nil, errors.New("idl: decoder must use the borsh encoding")
+	}
+	discriminator, err := dec.ReadDiscriminator()
+	if err != nil {
+		return "", nil, fmt.Errorf("idl: read account discriminator: %w", err)
+	}
+	def, ok := idl.Account(discriminator)
+	if !ok {
+		return "", nil, fmt.Errorf("idl: account: %w %s", ErrUnknownDiscriminator, discriminator)
+	}
+	if traceEnabled {
+		zlog.Debug("idl: decode account", zap.String("name", def.Name))
+	}
+	value, err = idl.decodeTypeDef(dec, def.Type)
+	if err != nil {
+		return def.Name, nil, withFieldPath(err, def.Name)
+	}
+	return def.Name, value, nil
+}
+
+// DecodeType decodes a value of the named type (e.g. an event)
+// from the provided borsh decoder.
+func (idl *IDL) DecodeType(name string, dec *Decoder) (interface{}, error) {
+	if !dec.IsBorsh() {
+		return nil, errors.New("idl: decoder must use the borsh encoding")
+	}
+	def, ok := idl.Type(name)
+	if !ok {
+		return nil, fmt.Errorf("idl: undefined type %q", name)
+	}
+	value, err := idl.decodeTypeDef(dec, def.Type)
+	if err != nil {
+		return nil, withFieldPath(err, name)
+	}
+	return value, nil
+}
+
+func (idl *IDL) decodeTypeDef(dec *Decoder, def *IDLTypeDefTy) (interface{}, error) {
+	switch def.Kind {
+	case "struct":
+		if def.Fields.IsTuple() {
+			return idl.decodeTuple(dec, def.Fields.Tuple)
+		}
+		return idl.decodeFields(dec, def.Fields.Named)
+	case "enum":
+		return idl.decodeEnum(dec, def.Variants)
+	case "alias":
+		return idl.decodeType(dec, *def.Value)
+	default:
+		return nil, fmt.Errorf("idl: unsupported kind %q", def.Kind)
+	}
+}
+
+func (idl *IDL) decodeFields(dec *Decoder, fields []IDLField) (*OrderedMap, error) {
+	out := NewOrderedMap()
+	for _, field := range fields {
+		value, err := idl.decodeType(dec, field.Type)
+		if err != nil {
+			return nil, withFieldPath(err, field.Name)
+		}
+		out.Set(field.Name, value)
+	}
+	return out, nil
+}
+
+func (idl *IDL) decodeTuple(dec *Decoder, types []IDLType) ([]interface{}, error) {
+	out := make([]interface{}, len(types))
+	for i, typ := range types {
+		value, err := idl.decodeType(dec, typ)
+		if err != nil {
+			return nil, withIndexPath(err, i)
+		}
+		out[i] = value
+	}
+	return out, nil
+}
+
+func (idl *IDL) decodeEnum(dec *Decoder, variants []IDLEnumVariant) (interface{}, error) {
+	offset := int(dec.Position())
+	index, err := dec.ReadUint8()
+	if err != nil {
+		return nil, newDecodeError(err, offset, nil)
+	}
+	if int(index) >= len(variants) {
+		return nil, newDecodeError(fmt.Errorf("%w: %d out of %d", ErrInvalidEnumVariant, index, len(variants)), offset, nil)
+	}
+	variant := variants[index]
+
+	var value interface{}
+	if variant.Fields.IsTuple() {
+		value, err = idl.decodeTuple(dec, variant.Fields.Tuple)
+	} else {
+		value, err = idl.decodeFields(dec, variant.Fields.Named)
+	}
+	if err != nil {
+		return nil, withFieldPath(err, variant.Name)
+	}
+	out := NewOrderedMap()
+	out.Set(variant.Name, value)
+	return out, nil
+}
+
+func (idl *IDL) decodeType(dec *Decoder, typ IDLType) (value interface{}, err error) {
+	offset := int(dec.Position())
+	defer func() {
+		if err != nil {
+			err = newDecodeError(err, offset, nil)
+		}
+	}()
+	if traceEnabled {
+		zlog.Debug("idl: decode type", zap.Stringer("type", typ))
+	}
+
+	switch {
+	case typ.Vec != nil:
+		if typ.Vec.Primitive == "u8" {
+			return dec.ReadByteSlice()
+		}
+		l, err := dec.ReadLength()
+		if err != nil {
+			return nil, err
+		}
+		return idl.decodeSequence(dec, *typ.Vec, l, true)
+	case typ.Array != nil:
+		return idl.decodeSequence(dec, *typ.Array, typ.ArrayLen, false)
+	case typ.Option != nil:
+		isPresent, err := dec.ReadOption()
+		if err != nil || !isPresent {
+			return nil, err
+		}
+		return idl.decodeType(dec, *typ.Option)
+	case typ.COption != nil:
+		isPresent, err := dec.ReadCOption()
+		if err != nil || !isPresent {
+			return nil, err
+		}
+		return idl.decodeType(dec, *typ.COption)
+	case typ.Defined != "":
+		def, ok := idl.Type(typ.Defined)
+		if !ok {
+			return nil, fmt.Errorf("idl: undefined type %q", typ.Defined)
+		}
+		if err := dec.enterNested(); err != nil {
+			return nil, err
+		}
+		defer dec.leaveNested()
+		return idl.decodeTypeDef(dec, def.Type)
+	}
+
+	switch typ.Primitive {
+	case "bool":
+		return dec.ReadBool()
+	case "u8":
+		return dec.ReadUint8()
+	case "i8":
+		return dec.ReadInt8()
+	case "u16":
+		return dec.ReadUint16(LE)
+	case "i16":
+		return dec.ReadInt16(LE)
+	case "u32":
+		return dec.ReadUint32(LE)
+	case "i32":
+		return dec.ReadInt32(LE)
+	case "u64":
+		return dec.ReadUint64(LE)
+	case "i64":
+		return dec.ReadInt64(LE)
+	case "u128":
+		return dec.ReadUint128(LE)
+	case "i128":
+		return dec.ReadInt128(LE)
+	case "u256":
+		return dec.ReadUint256(LE)
+	case "i256":
+		return dec.ReadInt256(LE)
+	case "f32":
+		return dec.ReadFloat32(LE)
+	case "f64":
+		return dec.ReadFloat64(LE)
+	case "string":
+		return dec.ReadString()
+	case "bytes":
+		return dec.ReadByteSlice()
+	case "publicKey", "pubkey":
+		b, err := dec.ReadNBytes(32)
+		if err != nil {
+			return nil, err
+		}
+		var key [32]byte
+		copy(key[:], b)
+		return key, nil
+	default:
+		return nil, fmt.Errorf("idl: %w %q", ErrUnsupportedType, typ.Primitive)
+	}
+}
+
+// decodeSequence decodes l elements of type elem; prefixed is true if l was
+// read from the data (the length of a Vec), not from the IDL.
+func (idl *IDL) decodeSequence(dec *Decoder, elem IDLType, l int, prefixed bool) ([]interface{}, error) {
+	if err := dec.enterNested(); err != nil {
+		return nil, err
+	}
+	defer dec.leaveNested()
+	if err := dec.checkCollectionLength(l); err != nil {
+		return nil, err
+	}
+	if err := dec.trackAllocation(l, 16); err != nil {
+		return nil, err
+	}
+	size := idl.minSize(elem, 0)
+	if size == 0 && prefixed {
+		// Like the reflective decoder, count at least a byte per element
+		// (e.g. an empty struct), so that the prefix can't exceed the data:
+		size = 1
+	}
+	if size > 0 && (l > math.MaxInt32/size || !dec.hasAtLeast(l*size)) {
+		return nil, fmt.Errorf("%w: %d elements of %s", ErrShortBuffer, l, elem)
+	}
+	out := make([]interface{}, l)
+	for i := 0; i < l; i++ {
+		value, err := idl.decodeType(dec, elem)
+		if err != nil {
+			return nil, withIndexPath(err, i)
+		}
+		out[i] = value
+	}
+	return out, nil
+}
+
+// minSize returns the minimum number of bytes taken by the encoding of typ,
+// used to reject (before allocating) sequences longer than the data.
+func (idl *IDL) minSize(typ IDLType, depth int) int {
+	if depth > 32 {
+		// Recursive type: stop counting.
+		return 0
+	}
+	switch {
+	case typ.Vec != nil, typ.COption != nil:
+		return 4
+	case typ.Option != nil:
+		return 1
+	case typ.Array != nil:
+		size := idl.minSize(*typ.Array, depth+1)
+		if size > 0 && typ.ArrayLen > math.MaxInt32/size {
+			return math.MaxInt32
+		}
+		return typ.ArrayLen * size
+	case typ.Defined != "":
+		def, ok := idl.Type(typ.Defined)
+		if !ok {
+			return 0
+		}
+		switch def.Type.Kind {
+		case "struct":
+			size := 0
+			for _, field := range def.Type.Fields.Named {
+				size += idl.minSize(field.Type, depth+1)
+			}
+			for _, fieldType := range def.Type.Fields.Tuple {
+				size += idl.minSize(fieldType, depth+1)
+			}
+			if size > math.MaxInt32 {
+				return math.MaxInt32
+			}
+			return size
+		case "enum":
+			return 1
+		case "alias":
+			return idl.minSize(*def.Type.Value, depth+1)
+		}
+		return 0
+	}
+	switch typ.Primitive {
+	case "bool", "u8", "i8":
+		return 1
+	case "u16", "i16":
+		return 2
+	case "u32", "i32", "f32", "string", "bytes":
+		return 4
+	case "u64", "i64", "f64":
+		return 8
+	case "u128", "i128":
+		return 16
+	case "u256", "i256", "publicKey", "pubkey":
+		return 32
+	}
+	return 0
+}
@@ -0,0 +1,354 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const idlTestJSON = `{
+  "version": "0.1.0",
+  "name": "vault",
+  "instructions": [
+    {
+      "name": "initialize",
+      "accounts": [
+        {"name": "vault", "isMut": true, "isSigner": false},
+        {"name": "authority", "isMut": false, "isSigner": true}
+      ],
+      "args": []
+    },
+    {
+      "name": "deposit",
+      "accounts": [
+        {"name": "vault", "isMut": true, "isSigner": false},
+        {"name": "common", "accounts": [{"name": "systemProgram", "isMut": false, "isSigner": false}]}
+      ],
+      "args": [
+        {"name": "amount", "type": "u64"},
+        {"name": "memo", "type": {"option": "string"}},
+        {"name": "kind", "type": {"defined": "DepositKind"}},
+        {"name": "tags", "type": {"vec": {"defined": "Tag"}}},
+        {"name": "seed", "type": {"array": ["u8", 4]}},
+        {"name": "payload", "type": "bytes"}
+      ]
+    }
+  ],
+  "accounts": [
+    {
+      "name": "Vault",
+      "type": {
+        "kind": "struct",
+        "fields": [
+          {"name": "authority", "type": "publicKey"},
+          {"name": "balance", "type": "u128"},
+          {"name": "supply", "type": "u256"},
+          {"name": "delta", "type": "i64"},
+          {"name": "enabled", "type": "bool"},
+          {"name": "owner", "type": {"coption": "publicKey"}},
+          {"name": "history", "type": {"vec": "u16"}}
+        ]
+      }
+    }
+  ],
+  "types": [
+    {
+      "name": "Tag",
+      "type": {
+        "kind": "struct",
+        "fields": [
+          {"name": "label", "type": "string"},
+          {"name": "weight", "type": "f32"}
+        ]
+      }
+    },
+    {
+      "name": "DepositKind",
+      "type": {
+        "kind": "enum",
+        "variants": [
+          {"name": "Plain"},
+          {"name": "Locked", "fields": [{"name": "until", "type": "i64"}]},
+          {"name": "Split", "fields": ["u8", "u8"]}
+        ]
+      }
+    }
+  ]
+}`
+
+type idlTestTag struct {
+	Label  string
+	Weight float32
+}
+
+type idlTestLocked struct {
+	Until int64
+}
+
+type idlTestSplit struct {
+	A uint8
+	B uint8
+}
+
+type idlTestDepositKind struct {
+	Enum   BorshEnum `borsh_enum:"true"`
+	Plain  EmptyVariant
+	Locked idlTestLocked
+	Split  idlTestSplit
+}
+
+type idlTestDeposit struct {
+	Amount  uint64
+	Memo    *string `bin:"optional"`
+	Kind    idlTestDepositKind
+	Tags    []idlTestTag
+	Seed    [4]uint8
+	Payload []byte
+}
+
+type idlTestVault struct {
+	Authority [32]byte
+	Balance   Uint128
+	Supply    Uint256
+	Delta     int64
+	Enabled   bool
+	Owner     *[32]byte `bin:"coption"`
+	History   []uint16
+}
+
+func withDiscriminator(t *testing.T, discriminator []byte, v interface{}) []byte {
+	data, err := MarshalBorsh(v)
+	require.NoError(t, err)
+	return append(append([]byte{}, discriminator...), data...)
+}
+
+func TestIDL_DecodeInstruction(t *testing.T) {
+	idl, err := ParseIDL([]byte(idlTestJSON))
+	require.NoError(t, err)
+
+	memo := "hello"
+	data := withDiscriminator(t, SighashInstruction("deposit"), idlTestDeposit{
+		Amount:  42,
+		Memo:    &memo,
+		Kind:    idlTestDepositKind{Enum: 1, Locked: idlTestLocked{Until: -7}},
+		Tags:    []idlTestTag{{Label: "a", Weight: 0.5}, {Label: "b", Weight: 2}},
+		Seed:    [4]uint8{1, 2, 3, 4},
+		Payload: []byte{0xca, 0xfe},
+	})
+
+	name, args, err := idl.DecodeInstruction(data)
+	require.NoError(t, err)
+	require.Equal(t, "deposit", name)
+	require.Equal(t, []string{"amount", "memo", "kind", "tags", "seed", "payload"}, args.Keys())
+
+	assert.Equal(t,
+		map[string]interface{}{
+			"amount": uint64(42),
+			"memo":   "hello",
+			"kind": map[string]interface{}{
+				"Locked": map[string]interface{}{"until": int64(-7)},
+			},
+			"tags": []interface{}{
+				map[string]interface{}{"label": "a", "weight": float32(0.5)},
+				map[string]interface{}{"label": "b", "weight": float32(2)},
+			},
+			"seed":    []interface{}{uint8(1), uint8(2), uint8(3), uint8(4)},
+			"payload": []byte{0xca, 0xfe},
+		},
+		args.ToMap(),
+	)
+
+	js, err := json.Marshal(args)
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"amount":42,"memo":"hello","kind":{"Locked":{"until":-7}},"tags":[{"label":"a","weight":0.5},{"label":"b","weight":2}],"seed":[1,2,3,4],"payload":"yv4="}`,
+		string(js),
+	)
+	// The keys keep the order of the arguments:
+	assert.Equal(t, `{"amount":42,"memo":"hello","kind":{"Locked":{"until":-7}},`, string(js[:59]))
+
+	{
+		data := withDiscriminator(t, SighashInstruction("deposit"), idlTestDeposit{
+			Kind: idlTestDepositKind{Enum: 2, Split: idlTestSplit{A: 3, B: 4}},
+		})
+		_, args, err := idl.DecodeInstruction(data)
+		require.NoError(t, err)
+		memo, _ := args.Get("memo")
+		assert.Nil(t, memo)
+		kind, _ := args.Get("kind")
+		assert.Equal(t, map[string]interface{}{"Split": []interface{}{uint8(3), uint8(4)}}, kind.(*OrderedMap).ToMap())
+	}
+	{
+		name, args, err := idl.DecodeInstruction(SighashInstruction("initialize"))
+		require.NoError(t, err)
+		assert.Equal(t, "initialize", name)
+		assert.Equal(t, 0, args.Len())
+	}
+}
+
+func TestIDL_DecodeAccount(t *testing.T) {
+	idl, err := ParseIDL([]byte(idlTestJSON))
+	require.NoError(t, err)
+
+	owner := [32]byte{9, 9, 9}
+	data := withDiscriminator(t, SighashAccount("Vault"), idlTestVault{
+		Authority: [32]byte{1, 2, 3},
+		Balance:   Uint128{Lo: 5, Hi: 1},
+		Supply:    Uint256{Words: [4]uint64{1, 2, 3, 4}},
+		Delta:     -3,
+		Enabled:   true,
+		Owner:     &owner,
+		History:   []uint16{300, 400},
+	})
+	// Accounts are often padded:
+	data = append(data, 0, 0, 0)
+
+	name, value, err := idl.DecodeAccount(data)
+	require.NoError(t, err)
+	require.Equal(t, "Vault", name)
+	assert.Equal(t,
+		map[string]interface{}{
+			"authority": [32]byte{1, 2, 3},
+			"balance":   Uint128{Lo: 5, Hi: 1},
+			"supply":    Uint256{Words: [4]uint64{1, 2, 3, 4}},
+			"delta":     int64(-3),
+			"enabled":   true,
+			"owner":     owner,
+			"history":   []interface{}{uint16(300), uint16(400)},
+		},
+		value.(*OrderedMap).ToMap(),
+	)
+}
+
+func TestIDL_errors(t *testing.T) {
+	idl, err := ParseIDL([]byte(idlTestJSON))
+	require.NoError(t, err)
+
+	_, _, err = idl.DecodeInstruction(SighashAccount("Vault"))
+	require.True(t, errors.Is(err, ErrUnknownDiscriminator))
+	_, _, err = idl.DecodeAccount(SighashInstruction("deposit"))
+	require.True(t, errors.Is(err, ErrUnknownDiscriminator))
+
+	{
+		// Truncated within the second tag:
+		data := withDiscriminator(t, SighashInstruction("deposit"), idlTestDeposit{
+			Tags: []idlTestTag{{Label: "a"}, {Label: "b"}},
+		})
+		_, _, err = idl.DecodeInstruction(data[:len(data)-9])
+		require.True(t, errors.Is(err, ErrShortBuffer))
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, "deposit.tags[1].weight", decodeErr.Path)
+	}
+	{
+		data := append(SighashInstruction("deposit"), make([]byte, 10)...)
+		data[17] = 3
+		_, _, err = idl.DecodeInstruction(data)
+		require.True(t, errors.Is(err, ErrInvalidEnumVariant))
+		require.EqualError(t, err, "decode: deposit.kind at offset 17: invalid enum variant: 3 out of 3")
+	}
+	{
+		// A hostile vec length:
+		data := withDiscriminator(t, SighashInstruction("deposit"), idlTestDeposit{})
+		copy(data[18:], []byte{0xff, 0xff, 0xff, 0x7f})
+		_, _, err = idl.DecodeInstruction(data)
+		require.True(t, errors.Is(err, ErrShortBuffer))
+	}
+
+	{
+		// A hostile vec length, with elements that take no bytes:
+		empty, err := ParseIDL([]byte(`{
+  "instructions": [{"name": "a", "args": [{"name": "x", "type": {"vec": {"defined": "Empty"}}}]}],
+  "types": [{"name": "Empty", "type": {"kind": "struct", "fields": []}}]
+}`))
+		require.NoError(t, err)
+		data := append(SighashInstruction("a"), 0xff, 0xff, 0xff, 0x7f)
+		_, _, err = empty.DecodeInstruction(data)
+		require.True(t, errors.Is(err, ErrShortBuffer), err)
+
+		data = append(SighashInstruction("a"), 3, 0, 0, 0, 0, 0, 0)
+		_, _, err = empty.DecodeInstructionWithDecoder(NewBorshDecoder(data).SetOptions(DecoderOptions{MaxCollectionLength: 2}))
+		require.True(t, errors.Is(err, ErrLimitExceeded), err)
+		_, args, err := empty.DecodeInstruction(data)
+		require.NoError(t, err)
+		x, _ := args.Get("x")
+		require.Len(t, x, 3)
+	}
+
+	_, err = ParseIDL([]byte(`{"instructions": [{"name": "a", "args": [{"name": "x", "type": {"defined": "Missing"}}]}]}`))
+	require.EqualError(t, err, `idl: instruction "a": arg "x": undefined type "Missing"`)
+	_, err = ParseIDL([]byte(`{"instructions": [{"name": "a", "args": [{"name": "x", "type": "u512"}]}]}`))
+	require.EqualError(t, err, `idl: instruction "a": arg "x": unsupported type "u512"`)
+}
+
+func TestIDL_anchor030(t *testing.T) {
+	idl, err := ParseIDL([]byte(`{
+  "address": "11111111111111111111111111111111",
+  "metadata": {"name": "counter", "version": "0.1.0", "spec": "0.1.0"},
+  "instructions": [
+    {
+      "name": "increment",
+      "discriminator": [1, 2, 3, 4, 5, 6, 7, 8],
+      "accounts": [{"name": "counter", "writable": true}],
+      "args": [{"name": "by", "type": {"defined": {"name": "Step"}}}]
+    }
+  ],
+  "accounts": [
+    {"name": "Counter", "discriminator": [8, 7, 6, 5, 4, 3, 2, 1]}
+  ],
+  "types": [
+    {"name": "Step", "type": {"kind": "struct", "fields": ["u8", "pubkey"]}},
+    {"name": "Counter", "type": {"kind": "struct", "fields": [{"name": "count", "type": "u32"}]}}
+  ]
+}`))
+	require.NoError(t, err)
+
+	data := append([]byte{1, 2, 3, 4, 5, 6, 7, 8, 7}, make([]byte, 32)...)
+	name, args, err := idl.DecodeInstruction(data)
+	require.NoError(t, err)
+	assert.Equal(t, "increment", name)
+	by, _ := args.Get("by")
+	assert.Equal(t, []interface{}{uint8(7), [32]byte{}}, by)
+
+	name, value, err := idl.DecodeAccount([]byte{8, 7, 6, 5, 4, 3, 2, 1, 0xff, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, "Counter", name)
+	assert.Equal(t, map[string]interface{}{"count": uint32(255)}, value.(*OrderedMap).ToMap())
+}
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("b", 1)
+	m.Set("a", []interface{}{NewOrderedMap()})
+	m.Set("b", 2)
+
+	assert.Equal(t, []string{"b", "a"}, m.Keys())
+	assert.Equal(t, 2, m.Len())
+	v, ok := m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	_, ok = m.Get("c")
+	assert.False(t, ok)
+
+	js, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.Equal(t, `{"b":2,"a":[{}]}`, string(js))
+	assert.Equal(t, map[string]interface{}{"b": 2, "a": []interface{}{map[string]interface{}{}}}, m.ToMap())
+}
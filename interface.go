@@ -18,8 +18,8 @@
 package bin
 
 import (
-	"bytes"
 	"fmt"
+	"sync"
 )
 
 type BinaryMarshaler interface {
@@ -36,24 +36,51 @@ type EncoderDecoder interface {
 }
 
 func MarshalBin(v interface{}) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	encoder := NewBinEncoder(buf)
-	err := encoder.Encode(v)
-	return buf.Bytes(), err
+	return AppendBin(nil, v)
 }
 
 func MarshalBorsh(v interface{}) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	encoder := NewBorshEncoder(buf)
-	err := encoder.Encode(v)
-	return buf.Bytes(), err
+	return AppendBorsh(nil, v)
 }
 
 func MarshalCompactU16(v interface{}) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	encoder := NewCompactU16Encoder(buf)
+	return AppendCompactU16(nil, v)
+}
+
+// AppendBin appends the bin encoding of v to dst and returns the extended buffer.
+// When dst has enough capacity, encoding does not allocate.
+func AppendBin(dst []byte, v interface{}) ([]byte, error) {
+	return appendWithEncoding(dst, v, EncodingBin)
+}
+
+// AppendBorsh appends the borsh encoding of v to dst and returns the extended buffer.
+// When dst has enough capacity, encoding does not allocate.
+func AppendBorsh(dst []byte, v interface{}) ([]byte, error) {
+	return appendWithEncoding(dst, v, EncodingBorsh)
+}
+
+// AppendCompactU16 appends the compact-u16 encoding of v to dst and returns the extended buffer.
+// When dst has enough capacity, encoding does not allocate.
+func AppendCompactU16(dst []byte, v interface{}) ([]byte, error) {
+	return appendWithEncoding(dst, v, EncodingCompactU16)
+}
+
+var appendEncoderPool = sync.Pool{
+	New: func() interface{} {
+		return new(Encoder)
+	},
+}
+
+func appendWithEncoding(dst []byte, v interface{}, enc Encoding) ([]byte, error) {
+	encoder := appendEncoderPool.Get().(*Encoder)
+	encoder.encoding = enc
+	encoder.setBuffer(dst)
 	err := encoder.Encode(v)
-	return buf.Bytes(), err
+	out := encoder.buf
+	// Don't keep the caller's buffer alive from the pool:
+	*encoder = Encoder{}
+	appendEncoderPool.Put(encoder)
+	return out, err
 }
 
 func UnmarshalBin(v interface{}, b []byte) error {
@@ -66,6 +93,13 @@ func UnmarshalBorsh(v interface{}, b []byte) error {
 	return decoder.Decode(v)
 }
 
+// UnmarshalBorshStrict acts like UnmarshalBorsh, but rejects
+// the inputs that are not canonical borsh (see Decoder.SetStrict).
+func UnmarshalBorshStrict(v interface{}, b []byte) error {
+	decoder := NewBorshDecoder(b).SetStrict(true)
+	return decoder.Decode(v)
+}
+
 func UnmarshalCompactU16(v interface{}, b []byte) error {
 	decoder := NewCompactU16Decoder(b)
 	return decoder.Decode(v)
@@ -124,3 +124,71 @@ func makeStringList(itemCount int) (out []string) {
 	}
 	return
 }
+
+type benchFixed struct {
+	F1  bool
+	F2  int16
+	F3  uint16
+	F4  int32
+	F5  uint32
+	F6  int64
+	F7  uint64
+	F8  float32
+	F9  float64
+	F10 [32]byte
+	F11 Uint128
+}
+
+func BenchmarkAppend(b *testing.B) {
+	v := &benchFixed{F2: -2, F3: 3, F4: -4, F5: 5, F6: -6, F7: 7, F8: 8.5, F9: 9.5, F10: [32]byte{1, 2, 3}, F11: Uint128{Lo: 11, Hi: 12}}
+
+	benchmarks := []struct {
+		name   string
+		append func(dst []byte, v interface{}) ([]byte, error)
+	}{
+		{"bin", AppendBin},
+		{"borsh", AppendBorsh},
+		{"compact-u16", AppendCompactU16},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			buf := make([]byte, 0, 256)
+			for i := 0; i < b.N; i++ {
+				var err error
+				if buf, err = bm.append(buf[:0], v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBufferedEncoder(b *testing.B) {
+	v := &benchFixed{F2: -2, F3: 3, F4: -4, F5: 5, F6: -6, F7: 7, F8: 8.5, F9: 9.5, F10: [32]byte{1, 2, 3}, F11: Uint128{Lo: 11, Hi: 12}}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		b.Run(enc.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			encoder := NewBufferedEncoderWithEncoding(enc)
+			for i := 0; i < b.N; i++ {
+				encoder.Reset()
+				if err := encoder.Encode(v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := &benchFixed{F2: -2, F3: 3, F4: -4, F5: 5, F6: -6, F7: 7, F8: 8.5, F9: 9.5, F10: [32]byte{1, 2, 3}, F11: Uint128{Lo: 11, Hi: 12}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalBorsh(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -16,9 +16,11 @@ package bin
 
 import (
 	"bytes"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type Example struct {
@@ -149,3 +151,66 @@ func TestUnmarshalWithDecoder(t *testing.T) {
 		}
 	}
 }
+
+func TestAppend(t *testing.T) {
+	values := []interface{}{
+		&benchFixed{F1: true, F2: -2, F3: 3, F4: -4, F5: 5, F6: -6, F7: 7, F8: 8.5, F9: 9.5, F10: [32]byte{1, 2, 3}, F11: Uint128{Lo: 11, Hi: 12}},
+		&benchNested{
+			N1: &benchSubset1{F1: 1, F2: "two", F3: []string{"a", "bc"}, F4: []int64{4, 5}},
+			F1: "nested",
+			N2: &benchSubset2{F7: 7},
+		},
+		&Example{Prefix: 0xaa, Value: 72},
+		map[string]uint16{"a": 1, "b": 300, "c": 3},
+		uint64(1 << 40),
+	}
+	appendFuncs := map[Encoding]func(dst []byte, v interface{}) ([]byte, error){
+		EncodingBin:        AppendBin,
+		EncodingBorsh:      AppendBorsh,
+		EncodingCompactU16: AppendCompactU16,
+	}
+
+	for enc, appendFunc := range appendFuncs {
+		for _, v := range values {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(v))
+
+			prefix := []byte{0xde, 0xad}
+			got, err := appendFunc(prefix, v)
+			require.NoError(t, err)
+			if m, ok := v.(map[string]uint16); ok && enc.codec().MapOrder() == MapOrderNone {
+				// The entries are in map iteration order: compare the decoded maps.
+				require.Equal(t, prefix, got[:len(prefix)], enc)
+				var decoded map[string]uint16
+				require.NoError(t, NewDecoderWithEncoding(got[len(prefix):], enc).Decode(&decoded), enc)
+				assert.Equal(t, m, decoded, enc)
+				continue
+			}
+			assert.Equal(t, append([]byte{0xde, 0xad}, buf.Bytes()...), got, "%s %T", enc, v)
+		}
+	}
+}
+
+func TestBufferedEncoder(t *testing.T) {
+	encoder := NewBorshBufferedEncoder()
+
+	require.NoError(t, encoder.Encode(&Example{Prefix: 1, Value: 2}))
+	assert.Equal(t, []byte{0x1, 0x0, 0x0, 0x0, 0x2}, encoder.Bytes())
+	assert.Equal(t, 5, encoder.Written())
+
+	encoder.Reset()
+	assert.Equal(t, 0, encoder.Written())
+	require.NoError(t, encoder.WriteCompactU16(300))
+	require.NoError(t, encoder.WriteUVarInt(math.MaxInt64))
+	assert.Equal(t, []byte{0xac, 0x02, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, encoder.Bytes())
+}
+
+func TestAppend_noAllocs(t *testing.T) {
+	v := &benchFixed{F2: -2, F3: 3, F4: -4, F5: 5, F6: -6, F7: 7, F8: 8.5, F9: 9.5, F10: [32]byte{1, 2, 3}, F11: Uint128{Lo: 11, Hi: 12}}
+	buf := make([]byte, 0, 256)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = AppendBorsh(buf[:0], v)
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math"
+
+	"go.uber.org/zap"
+)
+
+// LengthPrefix is the format of the length prefix of a string, slice or map.
+// It can be set per struct field with a `bin:"len=..."` tag
+// (e.g. `bin:"len=u8"` or `bin:"len=compact_u16"`), in all the encodings.
+type LengthPrefix int
+
+const (
+	// LengthPrefixDefault is the prefix of the encoding
+	// (see Decoder.ReadLength and Encoder.WriteLength).
+	LengthPrefixDefault LengthPrefix = iota
+	LengthPrefixU8
+	LengthPrefixU16
+	LengthPrefixU32
+	LengthPrefixU64
+	LengthPrefixUvarint
+	LengthPrefixCompactU16
+	// LengthPrefixCompact is a SCALE compact integer (see Decoder.ReadCompact).
+	LengthPrefixCompact
+)
+
+var lengthPrefixNames = map[LengthPrefix]string{
+	LengthPrefixU8:         "u8",
+	LengthPrefixU16:        "u16",
+	LengthPrefixU32:        "u32",
+	LengthPrefixU64:        "u64",
+	LengthPrefixUvarint:    "uvarint",
+	LengthPrefixCompactU16: "compact_u16",
+	LengthPrefixCompact:    "compact",
+}
+
+func (p LengthPrefix) String() string {
+	if name, ok := lengthPrefixNames[p]; ok {
+		return name
+	}
+	return "default"
+}
+
+// parseLengthPrefix parses the value of a `len=` tag.
+func parseLengthPrefix(s string) (LengthPrefix, bool) {
+	for p, name := range lengthPrefixNames {
+		if name == s {
+			return p, true
+		}
+	}
+	return LengthPrefixDefault, false
+}
+
+// maxLength returns the largest length that p can hold.
+func (p LengthPrefix) maxLength() uint64 {
+	switch p {
+	case LengthPrefixU8:
+		return math.MaxUint8
+	case LengthPrefixU16, LengthPrefixCompactU16:
+		return math.MaxUint16
+	case LengthPrefixU32:
+		return math.MaxUint32
+	default:
+		return math.MaxUint64
+	}
+}
+
+// ReadLengthPrefix reads a length prefixed in the format p (little endian).
+func (dec *Decoder) ReadLengthPrefix(p LengthPrefix) (length int, err error) {
+	var val uint64
+	switch p {
+	case LengthPrefixDefault:
+		return dec.ReadLength()
+	case LengthPrefixU8:
+		var v uint8
+		v, err = dec.ReadUint8()
+		val = uint64(v)
+	case LengthPrefixU16:
+		var v uint16
+		v, err = dec.ReadUint16(LE)
+		val = uint64(v)
+	case LengthPrefixU32:
+		var v uint32
+		v, err = dec.ReadUint32(LE)
+		val = uint64(v)
+	case LengthPrefixU64:
+		val, err = dec.ReadUint64(LE)
+	case LengthPrefixUvarint:
+		val, err = dec.ReadUvarint64()
+	case LengthPrefixCompactU16:
+		var v int
+		v, err = dec.ReadCompactU16()
+		val = uint64(v)
+	case LengthPrefixCompact:
+		var v Uint128
+		v, err = dec.ReadCompact()
+		if err == nil && v.Hi != 0 {
+			return 0, fmt.Errorf("%w: %s", ErrLengthTooLarge, v)
+		}
+		val = v.Lo
+	default:
+		return 0, fmt.Errorf("invalid length prefix: %d", p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if val > 0x7FFF_FFFF {
+		return 0, fmt.Errorf("%w: %d", ErrLengthTooLarge, val)
+	}
+	if traceEnabled {
+		zlog.Debug("decode: read length prefix", zap.Stringer("prefix", p), zap.Uint64("len", val))
+	}
+	return int(val), nil
+}
+
+// WriteLengthPrefix writes length prefixed in the format p (little endian);
+// it returns ErrLengthTooLarge if p can't hold length.
+func (e *Encoder) WriteLengthPrefix(p LengthPrefix, length int) error {
+	if length < 0 || uint64(length) > p.maxLength() {
+		return fmt.Errorf("%w: %d in a %s length prefix", ErrLengthTooLarge, length, p)
+	}
+	switch p {
+	case LengthPrefixDefault:
+		return e.WriteLength(length)
+	case LengthPrefixU8:
+		return e.WriteUint8(uint8(length))
+	case LengthPrefixU16:
+		return e.WriteUint16(uint16(length), LE)
+	case LengthPrefixU32:
+		return e.WriteUint32(uint32(length), LE)
+	case LengthPrefixU64:
+		return e.WriteUint64(uint64(length), LE)
+	case LengthPrefixUvarint:
+		return e.WriteUVarInt(length)
+	case LengthPrefixCompactU16:
+		return e.WriteCompactU16(length)
+	case LengthPrefixCompact:
+		return e.WriteCompact(Uint128{Lo: uint64(length)})
+	default:
+		return fmt.Errorf("invalid length prefix: %d", p)
+	}
+}
+
+// readPrefixedString reads a string whose length is prefixed in the format p.
+func (dec *Decoder) readPrefixedString(p LengthPrefix) (string, error) {
+	length, err := dec.ReadLengthPrefix(p)
+	if err != nil {
+		return "", err
+	}
+	if err = dec.checkStringLength(length); err != nil {
+		return "", err
+	}
+	if err = dec.trackAllocation(length, 1); err != nil {
+		return "", err
+	}
+	data, err := dec.ReadNBytes(length)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// writePrefixedString writes s, with its length prefixed in the format p.
+func (e *Encoder) writePrefixedString(s string, p LengthPrefix) error {
+	if err := e.WriteLengthPrefix(p, len(s)); err != nil {
+		return err
+	}
+	return e.WriteBytes([]byte(s), false)
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type lengthPrefixed struct {
+	Name   string            `bin:"len=u8"`
+	Items  []uint16          `bin:"len=u16"`
+	Data   []byte            `bin:"len=u64"`
+	Keys   map[uint8]uint8   `bin:"len=compact_u16"`
+	Values []uint32          `bin:"len=uvarint"`
+	Memo   *string           `bin:"len=u32"`
+	Named  []lengthPrefixTag `bin:"len=u8"`
+}
+
+type lengthPrefixTag struct {
+	Tag string `bin:"len=u16"`
+}
+
+func TestLengthPrefixTag(t *testing.T) {
+	memo := "m"
+	x := lengthPrefixed{
+		Name:   "abc",
+		Items:  []uint16{1, 2},
+		Data:   []byte{9},
+		Keys:   map[uint8]uint8{4: 5},
+		Values: make([]uint32, 130),
+		Memo:   &memo,
+		Named:  []lengthPrefixTag{{Tag: "t"}},
+	}
+	x.Values[0] = 7
+	values := make([]byte, 4*130)
+	values[0] = 7
+	want := concatByteSlices(
+		[]byte{3, 'a', 'b', 'c'},
+		[]byte{2, 0, 1, 0, 2, 0},
+		[]byte{1, 0, 0, 0, 0, 0, 0, 0, 9},
+		[]byte{1, 4, 5},
+		[]byte{0x82, 0x01}, values,
+		[]byte{1, 0, 0, 0, 'm'},
+		[]byte{1, 1, 0, 't'},
+	)
+
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		data, err := marshalWithEncoding(&x, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, want, data, encoding)
+
+		var y lengthPrefixed
+		require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+		require.Equal(t, x, y, encoding)
+
+		// The lengths that don't fit in their prefix are rejected:
+		_, err = marshalWithEncoding(&lengthPrefixed{Name: strings.Repeat("a", 256)}, encoding)
+		require.True(t, errors.Is(err, ErrLengthTooLarge), err)
+		_, err = marshalWithEncoding(&lengthPrefixed{Named: make([]lengthPrefixTag, 300)}, encoding)
+		require.True(t, errors.Is(err, ErrLengthTooLarge), err)
+
+		err = NewDecoderWithEncoding([]byte{0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}, encoding).Decode(&y)
+		require.True(t, errors.Is(err, ErrLengthTooLarge), err)
+	}
+}
+
+func TestLengthPrefix(t *testing.T) {
+	tests := []struct {
+		prefix LengthPrefix
+		length int
+		want   []byte
+	}{
+		{LengthPrefixU8, 200, []byte{200}},
+		{LengthPrefixU16, 0x1234, []byte{0x34, 0x12}},
+		{LengthPrefixU32, 0x1234, []byte{0x34, 0x12, 0, 0}},
+		{LengthPrefixU64, 1, []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		{LengthPrefixUvarint, 300, []byte{0xac, 0x02}},
+		{LengthPrefixCompactU16, 300, []byte{0xac, 0x02}},
+	}
+	for _, test := range tests {
+		parsed, ok := parseLengthPrefix(test.prefix.String())
+		require.True(t, ok)
+		require.Equal(t, test.prefix, parsed)
+
+		buf := NewBorshBufferedEncoder()
+		require.NoError(t, buf.WriteLengthPrefix(test.prefix, test.length), test.prefix)
+		require.Equal(t, test.want, buf.Bytes(), test.prefix)
+
+		length, err := NewBorshDecoder(test.want).ReadLengthPrefix(test.prefix)
+		require.NoError(t, err, test.prefix)
+		require.Equal(t, test.length, length, test.prefix)
+	}
+
+	// The default prefix is the one of the encoding:
+	for encoding, want := range map[Encoding][]byte{
+		EncodingBorsh:      {0x2c, 0x01, 0, 0},
+		EncodingBin:        {0xac, 0x02},
+		EncodingCompactU16: {0xac, 0x02},
+	} {
+		length, err := NewDecoderWithEncoding(want, encoding).ReadLengthPrefix(LengthPrefixDefault)
+		require.NoError(t, err)
+		require.Equal(t, 300, length)
+	}
+
+	_, ok := parseLengthPrefix("u128")
+	require.False(t, ok)
+	require.Error(t, NewBorshBufferedEncoder().WriteLengthPrefix(LengthPrefixU16, 1<<16))
+	require.Error(t, NewBorshBufferedEncoder().WriteLengthPrefix(LengthPrefixU8, -1))
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type optionPoint struct {
+	X int16
+	Y int16
+}
+
+type optionFields struct {
+	Amount  *uint64      `bin:"option"`
+	Owner   *[4]byte     `bin:"coption"`
+	Point   *optionPoint `bin:"optional"`
+	Flag    uint8        `bin:"coption"`
+	Balance Uint128      `bin:"option"`
+	Last    uint32
+}
+
+// The `option` (1-byte tag, or u32 in EncodingBin) and `coption` (4-byte tag)
+// fields are encoded the same way in all the encodings.
+func TestOptionTags(t *testing.T) {
+	amount := uint64(0x0102)
+	owner := [4]byte{9, 8, 7, 6}
+	tests := []struct {
+		name string
+		v    optionFields
+		// want returns the encoding of v, with the option tags of tag.
+		want func(tag func(isPresent bool) []byte) []byte
+	}{
+		{
+			name: "none",
+			v:    optionFields{Last: 7},
+			want: func(tag func(bool) []byte) []byte {
+				return concatByteSlices(
+					tag(false),         // Amount
+					[]byte{0, 0, 0, 0}, // Owner
+					tag(false),         // Point
+					[]byte{0, 0, 0, 0}, // Flag
+					tag(false),         // Balance
+					[]byte{7, 0, 0, 0}, // Last
+				)
+			},
+		},
+		{
+			name: "some",
+			v: optionFields{
+				Amount:  &amount,
+				Owner:   &owner,
+				Point:   &optionPoint{X: -1, Y: 2},
+				Flag:    5,
+				Balance: Uint128{Lo: 3},
+				Last:    7,
+			},
+			want: func(tag func(bool) []byte) []byte {
+				return concatByteSlices(
+					tag(true), []byte{2, 1, 0, 0, 0, 0, 0, 0},
+					[]byte{1, 0, 0, 0, 9, 8, 7, 6},
+					tag(true), []byte{0xff, 0xff, 2, 0},
+					[]byte{1, 0, 0, 0, 5},
+					tag(true), []byte{3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+					[]byte{7, 0, 0, 0},
+				)
+			},
+		},
+		{
+			name: "zero pointees",
+			v: optionFields{
+				Amount: new(uint64),
+				Owner:  new([4]byte),
+				Point:  new(optionPoint),
+			},
+			want: func(tag func(bool) []byte) []byte {
+				return concatByteSlices(
+					tag(true), []byte{0, 0, 0, 0, 0, 0, 0, 0},
+					[]byte{1, 0, 0, 0, 0, 0, 0, 0},
+					tag(true), []byte{0, 0, 0, 0},
+					[]byte{0, 0, 0, 0},
+					tag(false),
+					[]byte{0, 0, 0, 0},
+				)
+			},
+		},
+	}
+
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		tag := func(isPresent bool) []byte {
+			b := byte(0)
+			if isPresent {
+				b = 1
+			}
+			if encoding == EncodingBin {
+				return []byte{b, 0, 0, 0}
+			}
+			return []byte{b}
+		}
+		for _, test := range tests {
+			want := test.want(tag)
+			data, err := marshalWithEncoding(&test.v, encoding)
+			require.NoError(t, err, "%s: %s", encoding, test.name)
+			require.Equal(t, want, data, "%s: %s", encoding, test.name)
+
+			var got optionFields
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&got), "%s: %s", encoding, test.name)
+			require.Equal(t, test.v, got, "%s: %s", encoding, test.name)
+
+			// The helpers of the generated code take the same path:
+			wantOwner := []byte{0, 0, 0, 0}
+			if test.v.Owner != nil {
+				wantOwner = append([]byte{1, 0, 0, 0}, test.v.Owner[:]...)
+			}
+			enc := NewBufferedEncoderWithEncoding(encoding)
+			require.NoError(t, enc.EncodeField(&test.v.Owner, `bin:"coption"`))
+			require.Equal(t, wantOwner, enc.Bytes(), "%s: %s", encoding, test.name)
+			var owner *[4]byte
+			require.NoError(t, NewDecoderWithEncoding(wantOwner, encoding).DecodeField(&owner, `bin:"coption"`))
+			require.Equal(t, test.v.Owner, owner, "%s: %s", encoding, test.name)
+		}
+
+		// Invalid tags:
+		var got optionFields
+		err := NewDecoderWithEncoding(append(tag(false), 2, 0, 0, 0), encoding).Decode(&got)
+		require.True(t, errors.Is(err, ErrInvalidOptionByte), "%s: %v", encoding, err)
+		err = NewDecoderWithEncoding([]byte{2, 0, 0, 0, 0, 0, 0, 0, 0}, encoding).SetStrict(true).Decode(&got)
+		require.True(t, errors.Is(err, ErrInvalidOptionByte), "%s: %v", encoding, err)
+
+		// Truncated tags:
+		for _, data := range [][]byte{{}, append(tag(false), 0, 0, 0)} {
+			err = NewDecoderWithEncoding(data, encoding).Decode(&got)
+			require.True(t, errors.Is(err, ErrShortBuffer), "%s: %v", encoding, err)
+		}
+	}
+}
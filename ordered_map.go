@@ -0,0 +1,106 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// An OrderedMap is a string-keyed map that remembers the insertion order
+// of its keys; it holds the structs decoded without a Go type (e.g. from an IDL),
+// so that their fields keep the order of the encoding.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{
+		values: make(map[string]interface{}),
+	}
+}
+
+// Set sets the value of key; a new key is added after the existing ones.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of key, and whether key is in the map.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Keys returns the keys of the map, in insertion order.
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// ToMap converts the map, and the OrderedMap values nested in it
+// (also within slices), to a map[string]interface{}.
+func (m *OrderedMap) ToMap() map[string]interface{} {
+	out := make(map[string]interface{}, len(m.keys))
+	for _, key := range m.keys {
+		out[key] = toPlainValue(m.values[key])
+	}
+	return out
+}
+
+func toPlainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *OrderedMap:
+		return v.ToMap()
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = toPlainValue(v[i])
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// MarshalJSON encodes the map as a JSON object, with its keys in insertion order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		encodedValue, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -76,3 +76,29 @@ func Test_parseFieldTag(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseFieldTag_invalid(t *testing.T) {
+	// The malformed tags would silently change the encoding of their field:
+	for _, tag := range []string{
+		`bin:"layout="`,
+		`bin:"layout=I80"`,
+		`bin:"layout=X80F48"`,
+		`bin:"layout=I81F48"`,
+		`bin:"fixed=0"`,
+		`bin:"fixed=-1"`,
+		`bin:"fixed=abc"`,
+		`bin:"fixed="`,
+	} {
+		assert.Panics(t, func() { parseFieldTag(reflect.StructTag(tag)) }, tag)
+	}
+
+	type badLayout struct {
+		F Fixed `bin:"layout=I80"`
+	}
+	assert.Panics(t, func() { MarshalBorsh(badLayout{}) })
+
+	type badFixed struct {
+		S string `bin:"fixed=abc"`
+	}
+	assert.Panics(t, func() { UnmarshalBorsh(new(badFixed), []byte{0, 0, 0, 0}) })
+}
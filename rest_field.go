@@ -0,0 +1,123 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The `rest` tag applies to the last field of a struct, of type string,
+// []byte or []T: the field is encoded without a length prefix, and
+// decoding consumes everything that remains in the decoder.
+
+func checkRestType(rt reflect.Type) error {
+	if rt.Kind() == reflect.String || rt.Kind() == reflect.Slice {
+		return nil
+	}
+	return fmt.Errorf("%w: the `rest` tag applies to strings and slices, got %s", ErrUnsupportedType, rt)
+}
+
+// decodeRest decodes into rv, until the end of the data,
+// a string or slice tagged with `rest`.
+func (dec *Decoder) decodeRest(rv reflect.Value, opt *option) (err error) {
+	rt := rv.Type()
+	if err = checkRestType(rt); err != nil {
+		return err
+	}
+
+	if rt.Kind() == reflect.String || rt.Elem().Kind() == reflect.Uint8 {
+		var data []byte
+		// On stream decoders, Remaining only covers the buffered window.
+		for dec.HasRemaining() {
+			n := dec.Remaining()
+			if rt.Kind() == reflect.String {
+				err = dec.checkStringLength(len(data) + n)
+			} else {
+				err = dec.checkCollectionLength(len(data) + n)
+			}
+			if err != nil {
+				return err
+			}
+			if err = dec.trackAllocation(n, 1); err != nil {
+				return err
+			}
+			var chunk []byte
+			chunk, err = dec.ReadNBytes(n)
+			if err != nil {
+				return err
+			}
+			data = append(data, chunk...)
+		}
+		if rt.Kind() == reflect.String {
+			rv.SetString(string(data))
+		} else if len(data) == 0 {
+			// Empty slices are left nil
+			rv.Set(reflect.Zero(rt))
+		} else {
+			rv.SetBytes(data)
+		}
+		return nil
+	}
+
+	rv.Set(reflect.Zero(rt))
+	for i := 0; dec.HasRemaining(); i++ {
+		if err = dec.checkCollectionLength(i + 1); err != nil {
+			return err
+		}
+		if err = dec.trackAllocation(1, int(rt.Elem().Size())); err != nil {
+			return err
+		}
+		start := dec.Position()
+		element := reflect.New(rt.Elem())
+		if err = dec.decodeValue(element); err != nil {
+			return withIndexPath(err, i)
+		}
+		if dec.Position() == start {
+			// The remaining bytes would never be consumed.
+			return fmt.Errorf("element %d of %s consumed no bytes", i, rt)
+		}
+		rv.Set(reflect.Append(rv, element.Elem()))
+	}
+	return nil
+}
+
+// encodeRest encodes rv, a string or slice tagged with `rest`, without length prefix.
+func (e *Encoder) encodeRest(rv reflect.Value, opt *option) (err error) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv = reflect.Zero(rv.Type().Elem())
+		} else {
+			rv = rv.Elem()
+		}
+	}
+	rt := rv.Type()
+	if err = checkRestType(rt); err != nil {
+		return err
+	}
+
+	if rt.Kind() == reflect.String {
+		return e.WriteBytes([]byte(rv.String()), false)
+	}
+	if rt.Elem().Kind() == reflect.Uint8 {
+		return e.WriteBytes(rv.Bytes(), false)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err = e.encodeValue(rv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
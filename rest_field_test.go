@@ -0,0 +1,140 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+type restPayload struct {
+	Kind uint8
+	Data []byte `bin:"rest"`
+}
+
+type restMemo struct {
+	Kind uint8
+	Memo string `bin:"rest"`
+	Hash []byte `bin:"-"`
+}
+
+type restEntry struct {
+	A uint8
+	B uint16
+}
+
+type restEntries struct {
+	Kind    uint8
+	Entries []restEntry `bin:"rest"`
+}
+
+type restNotLast struct {
+	Data []byte `bin:"rest"`
+	Kind uint8
+}
+
+type restUnsupported struct {
+	Kind  uint8
+	Value uint32 `bin:"rest"`
+}
+
+func TestRestField(t *testing.T) {
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		{
+			x := restPayload{Kind: 1, Data: []byte{0xca, 0xfe, 0xba, 0xbe}}
+			data, err := marshalWithEncoding(&x, encoding)
+			require.NoError(t, err, encoding)
+			require.Equal(t, []byte{1, 0xca, 0xfe, 0xba, 0xbe}, data, encoding)
+
+			var y restPayload
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+			require.Equal(t, x, y, encoding)
+
+			// Nothing left: the slice is left nil.
+			y = restPayload{}
+			require.NoError(t, NewDecoderWithEncoding([]byte{2}, encoding).Decode(&y), encoding)
+			require.Equal(t, restPayload{Kind: 2}, y, encoding)
+		}
+		{
+			x := restMemo{Kind: 3, Memo: "hello"}
+			data, err := marshalWithEncoding(&x, encoding)
+			require.NoError(t, err, encoding)
+			require.Equal(t, []byte("\x03hello"), data, encoding)
+
+			var y restMemo
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+			require.Equal(t, x, y, encoding)
+		}
+		{
+			x := restEntries{Kind: 4, Entries: []restEntry{{A: 1, B: 0x0302}, {A: 4, B: 0x0605}}}
+			data, err := marshalWithEncoding(&x, encoding)
+			require.NoError(t, err, encoding)
+			require.Equal(t, []byte{4, 1, 2, 3, 4, 5, 6}, data, encoding)
+
+			var y restEntries
+			require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+			require.Equal(t, x, y, encoding)
+
+			// A truncated element:
+			err = NewDecoderWithEncoding(data[:6], encoding).Decode(&y)
+			require.True(t, errors.Is(err, ErrShortBuffer), err)
+		}
+
+		require.PanicsWithValue(t,
+			"the `bin:\"rest\"` tag must be on the last field of the struct, problematic field \"Kind\"",
+			func() {
+				var y restNotLast
+				NewDecoderWithEncoding([]byte{1, 2}, encoding).Decode(&y)
+			},
+			encoding,
+		)
+
+		var y restUnsupported
+		err := NewDecoderWithEncoding([]byte{1, 2, 3, 4, 5}, encoding).Decode(&y)
+		require.True(t, errors.Is(err, ErrUnsupportedType), err)
+		_, err = marshalWithEncoding(&restUnsupported{Value: 1}, encoding)
+		require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	}
+}
+
+func TestRestField_stream(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xab}, 3*DefaultStreamWindowSize+5)
+	data := append([]byte{7}, payload...)
+
+	var y restPayload
+	dec := NewBorshStreamDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+	require.NoError(t, dec.Decode(&y))
+	require.Equal(t, restPayload{Kind: 7, Data: payload}, y)
+	require.False(t, dec.HasRemaining())
+}
+
+func TestRestField_limits(t *testing.T) {
+	data := []byte("\x01too long")
+
+	var y restMemo
+	err := NewBorshDecoder(data).SetOptions(DecoderOptions{MaxStringLength: 4}).Decode(&y)
+	var limitErr *LimitExceededError
+	require.True(t, errors.As(err, &limitErr), err)
+	require.Equal(t, "MaxStringLength", limitErr.Limit)
+
+	var z restEntries
+	err = NewBorshDecoder([]byte{1, 1, 2, 3, 4, 5, 6}).SetOptions(DecoderOptions{MaxCollectionLength: 1}).Decode(&z)
+	require.True(t, errors.As(err, &limitErr), err)
+	require.Equal(t, "MaxCollectionLength", limitErr.Limit)
+}
@@ -0,0 +1,379 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+)
+
+// EncodingRLP is the Recursive Length Prefix encoding of Ethereum
+// (https://ethereum.org/en/developers/docs/data-structures-and-encoding/rlp/):
+// structs are lists of their fields, slices and arrays are lists of their elements,
+// and strings, byte slices and byte arrays are byte strings. Unsigned integers,
+// Uint128, Uint256 and big.Int values are big-endian byte strings without leading zeros;
+// bools are 0 (the empty string) or 1.
+//
+// Nil pointers are encoded as the empty string, or the empty list for the
+// types encoded as lists; pointers are decoded from these empty values as nil
+// if they have the `optional` tag, and as pointers to zero values otherwise. The trailing `binary_extension` fields may be missing
+// from the list of a struct. The other tags (e.g. `len=`, `sizeof=`) don't apply.
+//
+// Strict decoders (see Decoder.SetStrict) reject the non-canonical inputs
+// (ErrNonCanonical): integers with leading zeros, sizes that aren't in their
+// shortest form, and single bytes below 0x80 encoded as strings.
+var EncodingRLP = RegisterEncoding("RLP", rlpCodec{})
+
+func NewRLPDecoder(data []byte) *Decoder {
+	return NewDecoderWithEncoding(data, EncodingRLP)
+}
+
+func NewRLPEncoder(writer io.Writer) *Encoder {
+	return NewEncoderWithEncoding(writer, EncodingRLP)
+}
+
+func NewRLPBufferedEncoder() *Encoder {
+	return NewBufferedEncoderWithEncoding(EncodingRLP)
+}
+
+func MarshalRLP(v interface{}) ([]byte, error) {
+	return AppendRLP(nil, v)
+}
+
+// AppendRLP appends the RLP encoding of v to dst and returns the extended buffer.
+func AppendRLP(dst []byte, v interface{}) ([]byte, error) {
+	return appendWithEncoding(dst, v, EncodingRLP)
+}
+
+func UnmarshalRLP(v interface{}, b []byte) error {
+	decoder := NewRLPDecoder(b)
+	return decoder.Decode(v)
+}
+
+// rlpCodec is the wire format of EncodingRLP, which has its own walker:
+// the values have no length prefixes, option tags or enum tags.
+type rlpCodec struct{}
+
+func (rlpCodec) ReadLength(dec *Decoder) (int, error) {
+	return 0, fmt.Errorf("%w: RLP has no length prefixes", ErrUnsupportedType)
+}
+
+func (rlpCodec) WriteLength(enc *Encoder, length int) error {
+	return fmt.Errorf("%w: RLP has no length prefixes", ErrUnsupportedType)
+}
+
+func (rlpCodec) ReadOptionTag(dec *Decoder) (bool, error) {
+	return false, fmt.Errorf("%w: RLP has no option tags", ErrUnsupportedType)
+}
+
+func (rlpCodec) WriteOptionTag(enc *Encoder, isPresent bool) error {
+	return fmt.Errorf("%w: RLP has no option tags", ErrUnsupportedType)
+}
+
+func (rlpCodec) ReadEnumTag(dec *Decoder) (int, error) {
+	return 0, fmt.Errorf("%w: RLP has no enums", ErrUnsupportedType)
+}
+
+func (rlpCodec) WriteEnumTag(enc *Encoder, variant int) error {
+	return fmt.Errorf("%w: RLP has no enums", ErrUnsupportedType)
+}
+
+func (rlpCodec) MapOrder() MapOrder { return MapOrderNone }
+
+// DecodeStruct decodes the struct rv from a list.
+func (rlpCodec) DecodeStruct(dec *Decoder, rv reflect.Value, decodeFields func(*Decoder, reflect.Value) error) error {
+	sub, err := dec.readRLPList()
+	if err != nil {
+		return err
+	}
+	if err = decodeFields(sub, rv); err != nil {
+		return err
+	}
+	return dec.CloseSubDecoder(sub)
+}
+
+// EncodeStruct encodes the struct rv as a list.
+func (rlpCodec) EncodeStruct(enc *Encoder, rv reflect.Value, encodeFields func(*Encoder, reflect.Value) error) error {
+	return enc.writeRLPList(func(fields *Encoder) error {
+		return encodeFields(fields, rv)
+	})
+}
+
+func (rlpCodec) ReadUint(dec *Decoder, bits int) (Uint128, error) {
+	return dec.readRLPUint(bits)
+}
+
+func (rlpCodec) WriteUint(enc *Encoder, v Uint128, bits int) error {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], v.Hi)
+	binary.BigEndian.PutUint64(buf[8:], v.Lo)
+	i := 0
+	for i < len(buf) && buf[i] == 0 {
+		i++
+	}
+	return enc.writeRLPString(buf[i:])
+}
+
+func (rlpCodec) ReadInt(dec *Decoder, bits int) (Int128, error) {
+	return Int128{}, fmt.Errorf("%w: RLP has no signed integers", ErrUnsupportedType)
+}
+
+func (rlpCodec) WriteInt(enc *Encoder, v Int128, bits int) error {
+	return fmt.Errorf("%w: RLP has no signed integers", ErrUnsupportedType)
+}
+
+func (rlpCodec) DecodeValue(dec *Decoder, rv reflect.Value, tag reflect.StructTag) error {
+	return dec.decodeRLP(rv, fieldOption(tag))
+}
+
+func (rlpCodec) EncodeValue(enc *Encoder, rv reflect.Value, tag reflect.StructTag) error {
+	return enc.encodeRLP(rv, fieldOption(tag))
+}
+
+// The first bytes of the RLP headers: a string or list of up to 55 bytes
+// has a 1-byte header (rlpString or rlpList plus its size); for longer ones,
+// the size of the big-endian size follows rlpString+55 or rlpList+55.
+const (
+	rlpString       = 0x80
+	rlpList         = 0xc0
+	rlpMaxShortSize = 55
+)
+
+// rlpHeader is the header of an RLP item.
+type rlpHeader struct {
+	isList bool
+	// size is the size of the payload of the item.
+	size int
+	// single is set for a single byte below 0x80, which has no header:
+	// the byte, in b, is the payload.
+	single bool
+	b      byte
+}
+
+// readRLPHeader reads the header of an RLP item.
+func (dec *Decoder) readRLPHeader() (h rlpHeader, err error) {
+	b, err := dec.ReadByte()
+	if err != nil {
+		return h, err
+	}
+	switch {
+	case b < rlpString:
+		return rlpHeader{single: true, size: 1, b: b}, nil
+	case b < rlpList:
+		h.size, err = dec.readRLPSize(b - rlpString)
+		if err == nil && dec.strict && h.size == 1 {
+			next, e := dec.Peek(1)
+			if e == nil && next[0] < rlpString {
+				err = fmt.Errorf("%w: RLP byte 0x%02x encoded as a string", ErrNonCanonical, next[0])
+			}
+		}
+	default:
+		h.isList = true
+		h.size, err = dec.readRLPSize(b - rlpList)
+	}
+	return h, err
+}
+
+// readRLPSize reads the size of a string or list whose header starts with
+// the offset n: the size itself, or 55 plus the size of the big-endian size.
+func (dec *Decoder) readRLPSize(n byte) (int, error) {
+	if n <= rlpMaxShortSize {
+		return int(n), nil
+	}
+	sizeLen := int(n - rlpMaxShortSize)
+	data, err := dec.ReadNBytes(sizeLen)
+	if err != nil {
+		return 0, err
+	}
+	if dec.strict && data[0] == 0 {
+		return 0, fmt.Errorf("%w: RLP size with leading zeros", ErrNonCanonical)
+	}
+	var size uint64
+	for _, b := range data {
+		if size > 0x7FFF_FFFF>>8 {
+			return 0, fmt.Errorf("%w: RLP size of %d bytes", ErrLengthTooLarge, sizeLen)
+		}
+		size = size<<8 | uint64(b)
+	}
+	if dec.strict && size <= rlpMaxShortSize {
+		return 0, fmt.Errorf("%w: RLP size %d in the long form", ErrNonCanonical, size)
+	}
+	return int(size), nil
+}
+
+// readRLPString reads a string, whose bytes are returned.
+func (dec *Decoder) readRLPString() ([]byte, error) {
+	h, err := dec.readRLPHeader()
+	if err != nil {
+		return nil, err
+	}
+	if h.isList {
+		return nil, fmt.Errorf("%w: RLP list, expected a string", ErrUnexpectedKind)
+	}
+	if h.single {
+		return []byte{h.b}, nil
+	}
+	if err = dec.checkStringLength(h.size); err != nil {
+		return nil, err
+	}
+	return dec.ReadNBytes(h.size)
+}
+
+// readRLPList reads the header of a list, and returns a sub-decoder of its items;
+// dec is moved past the list. See CloseSubDecoder.
+func (dec *Decoder) readRLPList() (*Decoder, error) {
+	h, err := dec.readRLPHeader()
+	if err != nil {
+		return nil, err
+	}
+	if !h.isList {
+		return nil, fmt.Errorf("%w: RLP string, expected a list", ErrUnexpectedKind)
+	}
+	return dec.SubDecoder(h.size)
+}
+
+// readRLPBigEndian reads a string holding a big-endian unsigned integer,
+// whose bytes are returned.
+func (dec *Decoder) readRLPBigEndian() ([]byte, error) {
+	data, err := dec.readRLPString()
+	if err != nil {
+		return nil, err
+	}
+	if dec.strict && len(data) > 0 && data[0] == 0 {
+		return nil, fmt.Errorf("%w: RLP integer with leading zeros", ErrNonCanonical)
+	}
+	return data, nil
+}
+
+// readRLPUint reads an unsigned integer, which must fit in bits bits.
+func (dec *Decoder) readRLPUint(bits int) (Uint128, error) {
+	data, err := dec.readRLPBigEndian()
+	if err != nil {
+		return Uint128{}, err
+	}
+	for len(data) > 0 && data[0] == 0 {
+		data = data[1:]
+	}
+	if len(data) > 16 {
+		return Uint128{}, fmt.Errorf("%w: RLP integer of %d bytes", ErrOverflow, len(data))
+	}
+	var v Uint128
+	for _, b := range data {
+		v = v.Lsh(8)
+		v.Lo |= uint64(b)
+	}
+	if (bits < 64 && (v.Hi != 0 || v.Lo>>uint(bits) != 0)) || (bits == 64 && v.Hi != 0) {
+		return Uint128{}, fmt.Errorf("%w: RLP integer %s in %d bits", ErrOverflow, v, bits)
+	}
+	return v, nil
+}
+
+// readRLPUint256 reads a Uint256, a big-endian integer of up to 32 bytes.
+func (dec *Decoder) readRLPUint256() (Uint256, error) {
+	data, err := dec.readRLPBigEndian()
+	if err != nil {
+		return Uint256{}, err
+	}
+	for len(data) > 0 && data[0] == 0 {
+		data = data[1:]
+	}
+	if len(data) > 32 {
+		return Uint256{}, fmt.Errorf("%w: RLP integer of %d bytes in 256 bits", ErrOverflow, len(data))
+	}
+	var v Uint256
+	for k, b := range data {
+		shift := len(data) - 1 - k
+		v.Words[shift/8] |= uint64(b) << (8 * uint(shift%8))
+	}
+	return v, nil
+}
+
+// writeRLPUint256 writes v as a big-endian integer without leading zeros.
+func (e *Encoder) writeRLPUint256(v Uint256) error {
+	var buf [32]byte
+	for k := range v.Words {
+		binary.BigEndian.PutUint64(buf[8*(3-k):], v.Words[k])
+	}
+	i := 0
+	for i < len(buf) && buf[i] == 0 {
+		i++
+	}
+	return e.writeRLPString(buf[i:])
+}
+
+// writeRLPHeader writes the header of a string (offset rlpString)
+// or list (offset rlpList) whose payload has size bytes.
+func (e *Encoder) writeRLPHeader(offset byte, size int) error {
+	if size <= rlpMaxShortSize {
+		return e.WriteByte(offset + byte(size))
+	}
+	var buf [9]byte
+	n := 0
+	for s := size; s > 0; s >>= 8 {
+		n++
+	}
+	buf[0] = offset + rlpMaxShortSize + byte(n)
+	for i, s := n, size; i > 0; i, s = i-1, s>>8 {
+		buf[i] = byte(s)
+	}
+	return e.WriteBytes(buf[:n+1], false)
+}
+
+// writeRLPString writes data as a string.
+func (e *Encoder) writeRLPString(data []byte) error {
+	if len(data) == 1 && data[0] < rlpString {
+		return e.WriteByte(data[0])
+	}
+	if err := e.writeRLPHeader(rlpString, len(data)); err != nil {
+		return err
+	}
+	return e.WriteBytes(data, false)
+}
+
+// writeRLPList writes the list of the items written by writeItems.
+func (e *Encoder) writeRLPList(writeItems func(*Encoder) error) error {
+	items := NewBufferedEncoderWithEncoding(e.encoding)
+	if err := writeItems(items); err != nil {
+		return err
+	}
+	if err := e.writeRLPHeader(rlpList, len(items.Bytes())); err != nil {
+		return err
+	}
+	return e.WriteBytes(items.Bytes(), false)
+}
+
+var (
+	bigIntType  = reflect.TypeOf(big.Int{})
+	uint256Type = reflect.TypeOf(Uint256{})
+)
+
+// isRLPList tells whether the values of type t are encoded as lists
+// (structs, and slices and arrays of other elements than bytes),
+// through the pointers.
+func isRLPList(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != bigIntType && t != uint128Type && t != uint256Type
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
@@ -0,0 +1,339 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rlpLegacyTx is the signing data of an EIP-155 legacy transaction.
+type rlpLegacyTx struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	Gas      uint64
+	To       *[20]byte `bin:"optional"`
+	Value    *big.Int
+	Data     []byte
+	ChainID  uint64
+	R        uint64
+	S        uint64
+}
+
+type rlpEmptyList struct{}
+
+// The set theoretical representation of three: [ [], [[]], [ [], [[]] ] ].
+type rlpThree struct {
+	Zero rlpEmptyList
+	One  struct{ Zero rlpEmptyList }
+	Two  struct {
+		Zero rlpEmptyList
+		One  struct{ Zero rlpEmptyList }
+	}
+}
+
+type rlpFields struct {
+	Name    string
+	Amount  Uint128
+	Big     big.Int
+	Flag    bool
+	Small   uint8
+	List    []uint16
+	Next    *rlpFields `bin:"optional"`
+	skipped uint8
+	Ignored uint8  `bin:"-"`
+	Ext     string `bin:"binary_extension"`
+}
+
+type rlpPointers struct {
+	Inner  *struct{ A, B uint8 }
+	Addr   *[4]byte
+	Nested **struct{ A uint8 }
+	N      *uint64
+	Opt    *struct{ A uint8 } `bin:"optional"`
+}
+
+// The examples of the RLP specification
+// (https://ethereum.org/en/developers/docs/data-structures-and-encoding/rlp/).
+func TestRLP_vectors(t *testing.T) {
+	lorem := "Lorem ipsum dolor sit amet, consectetur adipisicing elit"
+	for _, test := range []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{"string", "dog", []byte{0x83, 'd', 'o', 'g'}},
+		{"list", []string{"cat", "dog"}, []byte{0xc8, 0x83, 'c', 'a', 't', 0x83, 'd', 'o', 'g'}},
+		{"empty string", "", []byte{0x80}},
+		{"empty list", []string{}, []byte{0xc0}},
+		{"zero", uint64(0), []byte{0x80}},
+		{"zero byte", []byte{0}, []byte{0x00}},
+		{"byte", []byte{0x0f}, []byte{0x0f}},
+		{"15", uint8(15), []byte{0x0f}},
+		{"1024", uint16(1024), []byte{0x82, 0x04, 0x00}},
+		{"200", uint8(200), []byte{0x81, 0xc8}},
+		{"three", rlpThree{}, []byte{0xc7, 0xc0, 0xc1, 0xc0, 0xc3, 0xc0, 0xc1, 0xc0}},
+		{"long string", lorem, append([]byte{0xb8, 0x38}, lorem...)},
+		{"true", true, []byte{0x01}},
+		{"false", false, []byte{0x80}},
+		{"u128", Uint128{Lo: 0x0102, Hi: 0x01}, []byte{0x89, 0x01, 0, 0, 0, 0, 0, 0, 0x01, 0x02}},
+		{"big", big.NewInt(0x0400), []byte{0x82, 0x04, 0x00}},
+		{"address", [4]byte{0, 0, 0, 1}, []byte{0x84, 0, 0, 0, 1}},
+	} {
+		data, err := MarshalRLP(test.v)
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.want, data, test.name)
+	}
+
+	// A long list:
+	long := make([]string, 20)
+	for i := range long {
+		long[i] = "abc"
+	}
+	data, err := MarshalRLP(long)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xf8, 80, 0x83, 'a', 'b', 'c'}, data[:6])
+	var gotLong []string
+	require.NoError(t, NewRLPDecoder(data).SetStrict(true).Decode(&gotLong))
+	require.Equal(t, long, gotLong)
+
+	var three rlpThree
+	require.NoError(t, NewRLPDecoder([]byte{0xc7, 0xc0, 0xc1, 0xc0, 0xc3, 0xc0, 0xc1, 0xc0}).SetStrict(true).Decode(&three))
+}
+
+func TestRLP_legacyTx(t *testing.T) {
+	// The signing data of the example of EIP-155.
+	want, err := hex.DecodeString("ec098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080018080")
+	require.NoError(t, err)
+	to := [20]byte{}
+	for i := range to {
+		to[i] = 0x35
+	}
+	value, _ := new(big.Int).SetString("1000000000000000000", 10)
+	tx := rlpLegacyTx{
+		Nonce:    9,
+		GasPrice: big.NewInt(20000000000),
+		Gas:      21000,
+		To:       &to,
+		Value:    value,
+		ChainID:  1,
+	}
+	data, err := MarshalRLP(&tx)
+	require.NoError(t, err)
+	require.Equal(t, want, data)
+
+	var got rlpLegacyTx
+	require.NoError(t, NewRLPDecoder(data).SetStrict(true).Decode(&got))
+	require.Equal(t, tx, got)
+
+	// A contract creation has no recipient:
+	tx.To = nil
+	data, err = MarshalRLP(&tx)
+	require.NoError(t, err)
+	require.NoError(t, UnmarshalRLP(&got, data))
+	require.Nil(t, got.To)
+}
+
+func TestRLP(t *testing.T) {
+	v := rlpFields{
+		Name:    "a",
+		Amount:  Uint128{Lo: 1000},
+		Big:     *big.NewInt(0),
+		Flag:    true,
+		Small:   0x80,
+		List:    []uint16{1, 256},
+		Next:    &rlpFields{Name: "b", Big: *big.NewInt(7), Ext: "x"},
+		skipped: 1,
+		Ignored: 2,
+		Ext:     "e",
+	}
+	data, err := MarshalRLP(&v)
+	require.NoError(t, err)
+	next := []byte{0xc8, 'b', 0x80, 0x07, 0x80, 0x80, 0xc0, 0xc0, 'x'}
+	want := concatByteSlices(
+		[]byte{0xc0 + 23},
+		[]byte{'a'},
+		[]byte{0x82, 0x03, 0xe8},
+		[]byte{0x80},
+		[]byte{0x01},
+		[]byte{0x81, 0x80},
+		[]byte{0xc4, 0x01, 0x82, 0x01, 0x00},
+		next,
+		[]byte{'e'},
+	)
+	require.Equal(t, want, data)
+
+	var got rlpFields
+	require.NoError(t, NewRLPDecoder(data).SetStrict(true).Decode(&got))
+	v.skipped, v.Ignored = 0, 0
+	require.Equal(t, v.Name, got.Name)
+	require.Equal(t, v.Amount, got.Amount)
+	require.Equal(t, 0, v.Big.Cmp(&got.Big))
+	require.Equal(t, v.List, got.List)
+	require.Equal(t, "b", got.Next.Name)
+	require.Equal(t, 0, got.Next.Big.Cmp(big.NewInt(7)))
+	require.Nil(t, got.Next.Next)
+	require.Equal(t, "e", got.Ext)
+
+	// The trailing extensions may be missing:
+	got = rlpFields{}
+	require.NoError(t, UnmarshalRLP(&got, []byte{0xc7, 'b', 0x80, 0x07, 0x80, 0x80, 0xc0, 0xc0}))
+	require.Equal(t, "", got.Ext)
+
+	_, err = MarshalRLP(int64(1))
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	_, err = MarshalRLP(big.NewInt(-1))
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	var i Int128
+	err = UnmarshalRLP(&i, []byte{0x01})
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+}
+
+func TestRLP_nilPointers(t *testing.T) {
+	// A nil pointer is encoded as the empty value of its type:
+	data, err := MarshalRLP(&rlpPointers{})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xc5, 0xc0, 0x80, 0xc0, 0x80, 0xc0}, data)
+
+	// which is decoded as a pointer to the zero value,
+	// or as nil for the optional fields:
+	var got rlpPointers
+	require.NoError(t, NewRLPDecoder(data).SetStrict(true).Decode(&got))
+	require.Equal(t, struct{ A, B uint8 }{}, *got.Inner)
+	require.Equal(t, [4]byte{}, *got.Addr)
+	require.NotNil(t, got.Nested)
+	require.Equal(t, uint64(0), *got.N)
+	require.Nil(t, got.Opt)
+
+	// The zero values are encoded as such, and decoded back:
+	data, err = MarshalRLP(&got)
+	require.NoError(t, err)
+	var again rlpPointers
+	require.NoError(t, NewRLPDecoder(data).SetStrict(true).Decode(&again))
+	require.Equal(t, got, again)
+}
+
+type rlpUint256Field struct {
+	A uint8
+	B Uint256
+}
+
+func TestRLP_uint256(t *testing.T) {
+	max := Uint256{Words: [4]uint64{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}}
+	for _, test := range []struct {
+		v    Uint256
+		want []byte
+	}{
+		{Uint256{}, []byte{0x80}},
+		{Uint256{Words: [4]uint64{15}}, []byte{0x0f}},
+		{Uint256{Words: [4]uint64{1024}}, []byte{0x82, 0x04, 0x00}},
+		{Uint256{Words: [4]uint64{0, 0, 0, 1}}, append([]byte{0x99, 0x01}, make([]byte, 24)...)},
+		{Uint256{Words: [4]uint64{0, 0, 0, 1 << 56}}, append([]byte{0xa0, 0x01}, make([]byte, 31)...)},
+		{max, append([]byte{0xa0}, bytes.Repeat([]byte{0xff}, 32)...)},
+	} {
+		data, err := MarshalRLP(&test.v)
+		require.NoError(t, err)
+		require.Equal(t, test.want, data, test.v.HexString())
+
+		var got Uint256
+		require.NoError(t, NewRLPDecoder(data).SetStrict(true).Decode(&got))
+		require.Equal(t, test.v, got)
+	}
+
+	// A struct field is a string of the list of the struct:
+	v := rlpUint256Field{A: 5, B: Uint256{Words: [4]uint64{7}}}
+	data, err := MarshalRLP(&v)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xc2, 0x05, 0x07}, data)
+	var got rlpUint256Field
+	require.NoError(t, UnmarshalRLP(&got, data))
+	require.Equal(t, v, got)
+
+	var u Uint256
+	err = UnmarshalRLP(&u, append([]byte{0xa1, 0x01}, make([]byte, 32)...))
+	require.True(t, errors.Is(err, ErrOverflow), err)
+
+	// The fixed-size types without a representation in RLP:
+	for _, v := range []interface{}{
+		Int256{Words: [4]uint64{1}},
+		Float128{Lo: 1},
+		Fixed{Raw: Uint256{Words: [4]uint64{1}}, Layout: LayoutU64F64},
+		WadDecimal{1},
+	} {
+		_, err = MarshalRLP(v)
+		require.True(t, errors.Is(err, ErrUnsupportedType), "%T: %v", v, err)
+	}
+	var i Int256
+	err = UnmarshalRLP(&i, []byte{0x01})
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	var f Float128
+	err = UnmarshalRLP(&f, []byte{0x01})
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	var fixed Fixed
+	err = UnmarshalRLP(&fixed, []byte{0x01})
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+}
+
+func TestRLP_errors(t *testing.T) {
+	var n uint16
+	var s string
+	var list []uint16
+
+	// Non-canonical inputs are only rejected by strict decoders:
+	for _, test := range []struct {
+		name string
+		data []byte
+		v    interface{}
+	}{
+		{"leading zeros", []byte{0x82, 0x00, 0x01}, &n},
+		{"zero byte", []byte{0x00}, &n},
+		{"single byte string", []byte{0x81, 0x05}, &s},
+		{"long form", []byte{0xb8, 0x02, 'a', 'b'}, &s},
+		{"size leading zeros", append([]byte{0xb9, 0x00, 0x38}, make([]byte, 56)...), &s},
+		{"long list", []byte{0xf8, 0x01, 0x01}, &list},
+	} {
+		require.NoError(t, UnmarshalRLP(test.v, test.data), test.name)
+		err := NewRLPDecoder(test.data).SetStrict(true).Decode(test.v)
+		require.True(t, errors.Is(err, ErrNonCanonical), "%s: %v", test.name, err)
+	}
+
+	err := UnmarshalRLP(&s, []byte{0xc0})
+	require.True(t, errors.Is(err, ErrUnexpectedKind), err)
+	err = UnmarshalRLP(&list, []byte{0x80})
+	require.True(t, errors.Is(err, ErrUnexpectedKind), err)
+	var small uint8
+	err = UnmarshalRLP(&small, []byte{0x82, 0x01, 0x00})
+	require.True(t, errors.Is(err, ErrOverflow), err)
+	var addr [4]byte
+	err = UnmarshalRLP(&addr, []byte{0x83, 1, 2, 3})
+	require.True(t, errors.Is(err, ErrUnexpectedKind), err)
+	err = UnmarshalRLP(&s, []byte{0x83, 'a'})
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+	var b bool
+	err = UnmarshalRLP(&b, []byte{0x02})
+	require.True(t, errors.Is(err, ErrInvalidBoolByte), err)
+	err = NewRLPDecoder([]byte{0x83, 'a', 'b', 'c'}).SetOptions(DecoderOptions{MaxTotalAllocation: 2}).Decode(&s)
+	require.True(t, errors.Is(err, ErrLimitExceeded), err)
+
+	// Items left in the list of a struct:
+	var three rlpThree
+	err = NewRLPDecoder([]byte{0xc8, 0xc0, 0xc1, 0xc0, 0xc3, 0xc0, 0xc1, 0xc0, 0x80}).SetStrict(true).Decode(&three)
+	require.True(t, errors.Is(err, ErrTrailingBytes), err)
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// EncodingSCALE is the SCALE codec of Substrate and Polkadot
+// (https://docs.substrate.io/reference/scale-codec/): like borsh, but with
+// compact lengths, and an optional bool (a *bool with the `optional` tag)
+// in a single byte: 0 (None), 1 (true) or 2 (false).
+// The unsigned integers with the `compact` tag are Compact<T> values.
+var EncodingSCALE = RegisterEncoding("SCALE", scaleCodec{})
+
+func NewSCALEDecoder(data []byte) *Decoder {
+	return NewDecoderWithEncoding(data, EncodingSCALE)
+}
+
+func NewSCALEEncoder(writer io.Writer) *Encoder {
+	return NewEncoderWithEncoding(writer, EncodingSCALE)
+}
+
+func NewSCALEBufferedEncoder() *Encoder {
+	return NewBufferedEncoderWithEncoding(EncodingSCALE)
+}
+
+func MarshalSCALE(v interface{}) ([]byte, error) {
+	return AppendSCALE(nil, v)
+}
+
+// AppendSCALE appends the SCALE encoding of v to dst and returns the extended buffer.
+// When dst has enough capacity, encoding does not allocate.
+func AppendSCALE(dst []byte, v interface{}) ([]byte, error) {
+	return appendWithEncoding(dst, v, EncodingSCALE)
+}
+
+func UnmarshalSCALE(v interface{}, b []byte) error {
+	decoder := NewSCALEDecoder(b)
+	return decoder.Decode(v)
+}
+
+// scaleCodec is the wire format of EncodingSCALE.
+type scaleCodec struct {
+	fieldsCodec
+	byteTagsCodec
+}
+
+func (scaleCodec) ReadLength(dec *Decoder) (int, error) {
+	val, err := dec.ReadCompact()
+	if err != nil {
+		return 0, err
+	}
+	if val.Hi != 0 || val.Lo > 0x7FFF_FFFF {
+		return 0, fmt.Errorf("%w: %s", ErrLengthTooLarge, val)
+	}
+	return int(val.Lo), nil
+}
+
+func (scaleCodec) WriteLength(enc *Encoder, length int) error {
+	return enc.WriteCompact(Uint128{Lo: uint64(length)})
+}
+
+// MapOrder returns MapOrderKeys: the maps of Substrate are BTreeMaps.
+func (scaleCodec) MapOrder() MapOrder { return MapOrderKeys }
+
+func (scaleCodec) ReadOptionBool(dec *Decoder) (isPresent bool, value bool, err error) {
+	b, err := dec.ReadByte()
+	if err != nil {
+		return false, false, err
+	}
+	switch b {
+	case 0:
+		return false, false, nil
+	case 1:
+		return true, true, nil
+	case 2:
+		return true, false, nil
+	default:
+		return false, false, fmt.Errorf("%w: %d for an optional bool", ErrInvalidOptionByte, b)
+	}
+}
+
+func (scaleCodec) WriteOptionBool(enc *Encoder, isPresent bool, value bool) error {
+	switch {
+	case !isPresent:
+		return enc.WriteUint8(0)
+	case value:
+		return enc.WriteUint8(1)
+	default:
+		return enc.WriteUint8(2)
+	}
+}
+
+// ReadCompact reads a SCALE compact integer, whose mode is set by the 2 low bits
+// of its first byte: the value is in the 6 high bits of 1, 2 or 4 little-endian bytes
+// (modes 0, 1 and 2), or in the next 4 to 16 bytes (mode 3, the 6 high bits being
+// the number of bytes minus 4). Values not in their shortest form are rejected.
+func (dec *Decoder) ReadCompact() (Uint128, error) {
+	b, err := dec.ReadByte()
+	if err != nil {
+		return Uint128{}, err
+	}
+	switch b & 0x03 {
+	case 0:
+		return Uint128{Lo: uint64(b >> 2)}, nil
+	case 1:
+		next, err := dec.ReadByte()
+		if err != nil {
+			return Uint128{}, err
+		}
+		v := (uint64(b) | uint64(next)<<8) >> 2
+		if v < 1<<6 {
+			return Uint128{}, fmt.Errorf("%w: compact integer %d not in its shortest form", ErrInvalidVarint, v)
+		}
+		return Uint128{Lo: v}, nil
+	case 2:
+		next, err := dec.ReadNBytes(3)
+		if err != nil {
+			return Uint128{}, err
+		}
+		v := (uint64(b) | uint64(next[0])<<8 | uint64(next[1])<<16 | uint64(next[2])<<24) >> 2
+		if v < 1<<14 {
+			return Uint128{}, fmt.Errorf("%w: compact integer %d not in its shortest form", ErrInvalidVarint, v)
+		}
+		return Uint128{Lo: v}, nil
+	default:
+		n := int(b>>2) + 4
+		if n > 16 {
+			return Uint128{}, fmt.Errorf("%w: compact integer of %d bytes", ErrOverflow, n)
+		}
+		data, err := dec.ReadNBytes(n)
+		if err != nil {
+			return Uint128{}, err
+		}
+		var buf [16]byte
+		copy(buf[:], data)
+		v := Uint128{
+			Lo: binary.LittleEndian.Uint64(buf[:8]),
+			Hi: binary.LittleEndian.Uint64(buf[8:]),
+		}
+		if (n == 4 && v.Lo < 1<<30) || (n > 4 && data[n-1] == 0) {
+			return Uint128{}, fmt.Errorf("%w: compact integer %s not in its shortest form", ErrInvalidVarint, v)
+		}
+		return v, nil
+	}
+}
+
+// WriteCompact writes v as a SCALE compact integer, in its shortest form.
+func (e *Encoder) WriteCompact(v Uint128) error {
+	switch {
+	case v.Hi == 0 && v.Lo < 1<<6:
+		return e.WriteUint8(uint8(v.Lo << 2))
+	case v.Hi == 0 && v.Lo < 1<<14:
+		return e.WriteUint16(uint16(v.Lo<<2|1), LE)
+	case v.Hi == 0 && v.Lo < 1<<30:
+		return e.WriteUint32(uint32(v.Lo<<2|2), LE)
+	default:
+		// The mode byte, then the bytes of v up to the last non-zero one:
+		var buf [17]byte
+		binary.LittleEndian.PutUint64(buf[1:9], v.Lo)
+		binary.LittleEndian.PutUint64(buf[9:], v.Hi)
+		n := 16
+		for buf[n] == 0 {
+			n--
+		}
+		buf[0] = byte(n-4)<<2 | 3
+		return e.WriteBytes(buf[:n+1], false)
+	}
+}
+
+var uint128Type = reflect.TypeOf(Uint128{})
+
+// decodeCompact decodes rv, an unsigned integer or a Uint128
+// with the `compact` tag, from a compact integer.
+func (dec *Decoder) decodeCompact(rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if rv.Type() != uint128Type && !isUintKind(rv.Kind()) {
+		return fmt.Errorf("decode: %w: compact %q", ErrUnsupportedType, rv.Type())
+	}
+	v, err := dec.ReadCompact()
+	if err != nil {
+		return err
+	}
+	if rv.Type() == uint128Type {
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+	if bits := uint(rv.Type().Bits()); v.Hi != 0 || (bits < 64 && v.Lo>>bits != 0) {
+		return fmt.Errorf("%w: compact integer %s in a %s", ErrOverflow, v, rv.Type())
+	}
+	rv.SetUint(v.Lo)
+	return nil
+}
+
+// encodeCompact encodes rv, an unsigned integer or a Uint128
+// with the `compact` tag, as a compact integer.
+// A nil pointer is encoded as zero, which decodeCompact reads back.
+func (e *Encoder) encodeCompact(rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv = reflect.Zero(rv.Type().Elem())
+			continue
+		}
+		rv = rv.Elem()
+	}
+	switch {
+	case rv.Type() == uint128Type:
+		return e.WriteCompact(rv.Interface().(Uint128))
+	case isUintKind(rv.Kind()):
+		return e.WriteCompact(Uint128{Lo: rv.Uint()})
+	default:
+		return fmt.Errorf("encode: %w: compact %q", ErrUnsupportedType, rv.Type())
+	}
+}
+
+func isUintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// decodeOptionBool decodes rv, a *bool with the `optional` tag, with c.
+func (dec *Decoder) decodeOptionBool(c OptionBoolCodec, rv reflect.Value) error {
+	isPresent, value, err := c.ReadOptionBool(dec)
+	if err != nil {
+		return err
+	}
+	if !isPresent {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	p := reflect.New(rv.Type().Elem())
+	p.Elem().SetBool(value)
+	rv.Set(p)
+	return nil
+}
+
+// isOptionBool tells whether rv is a *bool with the `optional` tag.
+func isOptionBool(rv reflect.Value, opt *option) bool {
+	return opt.is_Optional() && rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Bool
+}
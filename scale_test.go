@@ -0,0 +1,209 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type scaleIntOrBool struct {
+	Enum BorshEnum `borsh_enum:"true"`
+	Int  uint8
+	Bool bool
+}
+
+type scaleFields struct {
+	Nonce   uint32 `bin:"compact"`
+	Balance Uint128
+	Tip     Uint128 `bin:"compact"`
+	Era     *uint64 `bin:"optional compact"`
+	Flag    *bool   `bin:"optional"`
+	Calls   []uint16
+	Label   string
+	Kind    scaleIntOrBool
+}
+
+// The examples of the SCALE codec documentation
+// (https://docs.substrate.io/reference/scale-codec/).
+func TestSCALE_compact(t *testing.T) {
+	for _, test := range []struct {
+		v    Uint128
+		want []byte
+	}{
+		{Uint128{Lo: 0}, []byte{0x00}},
+		{Uint128{Lo: 1}, []byte{0x04}},
+		{Uint128{Lo: 42}, []byte{0xa8}},
+		{Uint128{Lo: 63}, []byte{0xfc}},
+		{Uint128{Lo: 64}, []byte{0x01, 0x01}},
+		{Uint128{Lo: 69}, []byte{0x15, 0x01}},
+		{Uint128{Lo: 16383}, []byte{0xfd, 0xff}},
+		{Uint128{Lo: 16384}, []byte{0x02, 0x00, 0x01, 0x00}},
+		{Uint128{Lo: 65535}, []byte{0xfe, 0xff, 0x03, 0x00}},
+		{Uint128{Lo: 1<<30 - 1}, []byte{0xfe, 0xff, 0xff, 0xff}},
+		{Uint128{Lo: 1 << 30}, []byte{0x03, 0x00, 0x00, 0x00, 0x40}},
+		{Uint128{Lo: 100000000000000}, []byte{0x0b, 0x00, 0x40, 0x7a, 0x10, 0xf3, 0x5a}},
+		{Uint128{Lo: math.MaxUint64}, []byte{0x13, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{Uint128{Lo: math.MaxUint64, Hi: math.MaxUint64}, concatByteSlices(
+			[]byte{0x33},
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		)},
+	} {
+		enc := NewSCALEBufferedEncoder()
+		require.NoError(t, enc.WriteCompact(test.v))
+		require.Equal(t, test.want, enc.Bytes(), test.v.String())
+		got, err := NewSCALEDecoder(test.want).ReadCompact()
+		require.NoError(t, err)
+		require.Equal(t, test.v, got)
+	}
+
+	// Values not in their shortest form:
+	for _, data := range [][]byte{
+		{0x01, 0x00},
+		{0xfd, 0x00},
+		{0x02, 0x00, 0x00, 0x00},
+		{0x03, 0xff, 0xff, 0xff, 0x3f},
+		{0x07, 0x00, 0x00, 0x00, 0x40, 0x00},
+	} {
+		_, err := NewSCALEDecoder(data).ReadCompact()
+		require.True(t, errors.Is(err, ErrInvalidVarint), "%x: %v", data, err)
+	}
+	_, err := NewSCALEDecoder([]byte{0xff}).ReadCompact()
+	require.True(t, errors.Is(err, ErrOverflow), err)
+	_, err = NewSCALEDecoder([]byte{0x0b, 0x00, 0x40}).ReadCompact()
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+}
+
+func TestSCALE(t *testing.T) {
+	yes, no := true, false
+	for _, test := range []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{"u32", uint32(42), []byte{0x2a, 0, 0, 0}},
+		{"vec", []uint16{4, 8, 15, 16, 23, 42}, []byte{0x18, 4, 0, 8, 0, 15, 0, 16, 0, 23, 0, 42, 0}},
+		{"string", "ab", []byte{0x08, 'a', 'b'}},
+		{"enum int", scaleIntOrBool{Enum: 0, Int: 42}, []byte{0x00, 0x2a}},
+		{"enum bool", scaleIntOrBool{Enum: 1, Bool: true}, []byte{0x01, 0x01}},
+		{"option bool none", &struct {
+			V *bool `bin:"optional"`
+		}{}, []byte{0}},
+		{"option bool true", &struct {
+			V *bool `bin:"optional"`
+		}{&yes}, []byte{1}},
+		{"option bool false", &struct {
+			V *bool `bin:"optional"`
+		}{&no}, []byte{2}},
+		{"tuple", &struct {
+			A uint32 `bin:"compact"`
+			B bool
+		}{3, false}, []byte{0x0c, 0x00}},
+		{"map", map[uint8]uint16{2: 1, 1: 2}, []byte{0x08, 1, 2, 0, 2, 1, 0}},
+	} {
+		data, err := MarshalSCALE(test.v)
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.want, data, test.name)
+	}
+
+	era := uint64(64)
+	v := scaleFields{
+		Nonce:   69,
+		Balance: Uint128{Lo: 1},
+		Tip:     Uint128{Lo: 1 << 30},
+		Era:     &era,
+		Flag:    &no,
+		Calls:   []uint16{7},
+		Label:   "x",
+		Kind:    scaleIntOrBool{Enum: 1, Bool: true},
+	}
+	want := concatByteSlices(
+		[]byte{0x15, 0x01}, // Nonce
+		[]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, // Balance
+		[]byte{0x03, 0x00, 0x00, 0x00, 0x40},                   // Tip
+		[]byte{1, 0x01, 0x01},                                  // Era
+		[]byte{2},                                              // Flag
+		[]byte{0x04, 7, 0},                                     // Calls
+		[]byte{0x04, 'x'},                                      // Label
+		[]byte{1, 1},                                           // Kind
+	)
+	data, err := MarshalSCALE(&v)
+	require.NoError(t, err)
+	require.Equal(t, want, data)
+	var got scaleFields
+	require.NoError(t, UnmarshalSCALE(&got, data))
+	require.Equal(t, v, got)
+
+	// The helpers of the generated code take the same path:
+	enc := NewSCALEBufferedEncoder()
+	require.NoError(t, enc.EncodeField(&v.Tip, `bin:"compact"`))
+	require.NoError(t, enc.EncodeField(&v.Flag, `bin:"optional"`))
+	require.Equal(t, []byte{0x03, 0x00, 0x00, 0x00, 0x40, 2}, enc.Bytes())
+	var tip Uint128
+	var flag *bool
+	dec := NewSCALEDecoder([]byte{0x03, 0x00, 0x00, 0x00, 0x40, 1})
+	require.NoError(t, dec.DecodeField(&tip, `bin:"compact"`))
+	require.NoError(t, dec.DecodeField(&flag, `bin:"optional"`))
+	require.Equal(t, v.Tip, tip)
+	require.Equal(t, &yes, flag)
+
+	err = UnmarshalSCALE(&got, concatByteSlices(want[:26], []byte{3}))
+	require.True(t, errors.Is(err, ErrInvalidOptionByte), err)
+	var small struct {
+		V uint8 `bin:"compact"`
+	}
+	err = UnmarshalSCALE(&small, []byte{0x01, 0x04})
+	require.True(t, errors.Is(err, ErrOverflow), err)
+	var signed struct {
+		V int32 `bin:"compact"`
+	}
+	err = UnmarshalSCALE(&signed, []byte{0x04})
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+}
+
+// The `compact` and `len=compact` tags are honored in all the encodings.
+func TestCompactTags(t *testing.T) {
+	type compactFields struct {
+		N    uint64   `bin:"compact"`
+		List []uint16 `bin:"len=compact"`
+	}
+	v := compactFields{N: 69, List: []uint16{1}}
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16, EncodingBincode} {
+		data, err := marshalWithEncoding(&v, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, []byte{0x15, 0x01, 0x04, 1, 0}, data, encoding)
+		var got compactFields
+		require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&got), encoding)
+		require.Equal(t, v, got, encoding)
+	}
+	{
+		// A nil pointer is encoded as zero:
+		type compactPointer struct {
+			A *uint64 `bin:"compact"`
+			B uint8
+		}
+		data, err := MarshalBorsh(&compactPointer{B: 7})
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x00, 7}, data)
+		var got compactPointer
+		require.NoError(t, UnmarshalBorsh(&got, data))
+		require.Equal(t, uint8(7), got.B)
+		require.NotNil(t, got.A)
+		require.Equal(t, uint64(0), *got.A)
+	}
+}
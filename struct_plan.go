@@ -0,0 +1,190 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// structPlan is the compiled, reusable description of how to walk
+// a struct type. It is computed once per reflect.Type and shared
+// by all the Encoder/Decoder instances (for all the encodings).
+type structPlan struct {
+	fields []fieldPlan
+	// isComplexEnum is true if the first field is a BorshEnum
+	// flagged with the `borsh_enum` tag.
+	isComplexEnum bool
+	// hasSizeOf is true if at least one field has a `sizeof=` tag.
+	hasSizeOf bool
+	// hasByteSizeOf is true if at least one field has a `byte_sizeof=` tag.
+	hasByteSizeOf bool
+}
+
+type fieldPlan struct {
+	index int
+	name  string
+	typ   reflect.Type
+	tag   *fieldTag
+
+	// sizeOfTarget is the index (in structPlan.fields) of the field
+	// whose length is defined by the value of this field; -1 if none.
+	sizeOfTarget int
+	// byteSizeOfTarget is the index of the field whose size in bytes
+	// is defined by the value of this field; -1 if none.
+	byteSizeOfTarget int
+
+	// ptrImplementsUnmarshaler is true if *T implements BinaryUnmarshaler.
+	ptrImplementsUnmarshaler bool
+	// implementsUnmarshaler is true if T implements BinaryUnmarshaler.
+	implementsUnmarshaler bool
+}
+
+var structPlanCache sync.Map // map[reflect.Type]*structPlan
+
+// getStructPlan returns the cached plan for the provided struct type,
+// compiling it on first use.
+func getStructPlan(rt reflect.Type) *structPlan {
+	if plan, ok := structPlanCache.Load(rt); ok {
+		return plan.(*structPlan)
+	}
+	plan, _ := structPlanCache.LoadOrStore(rt, compileStructPlan(rt))
+	return plan.(*structPlan)
+}
+
+func compileStructPlan(rt reflect.Type) *structPlan {
+	numField := rt.NumField()
+	plan := &structPlan{
+		fields: make([]fieldPlan, numField),
+	}
+	indexByName := make(map[string]int, numField)
+	for i := 0; i < numField; i++ {
+		structField := rt.Field(i)
+		plan.fields[i] = fieldPlan{
+			index:                    i,
+			name:                     structField.Name,
+			typ:                      structField.Type,
+			tag:                      parseFieldTag(structField.Tag),
+			sizeOfTarget:             -1,
+			byteSizeOfTarget:         -1,
+			ptrImplementsUnmarshaler: reflect.PtrTo(structField.Type).Implements(unmarshalableType),
+			implementsUnmarshaler:    structField.Type.Implements(unmarshalableType),
+		}
+		indexByName[structField.Name] = i
+	}
+	for i := range plan.fields {
+		field := &plan.fields[i]
+		if field.tag.ByteSizeOf != "" {
+			plan.hasByteSizeOf = true
+			if target, ok := indexByName[field.tag.ByteSizeOf]; ok {
+				field.byteSizeOfTarget = target
+			}
+		}
+		if field.tag.SizeOf == "" {
+			continue
+		}
+		plan.hasSizeOf = true
+		if target, ok := indexByName[field.tag.SizeOf]; ok {
+			field.sizeOfTarget = target
+		}
+	}
+	if numField > 0 {
+		// If the first field has type BorshEnum and is flagged with "borsh_enum"
+		// we have a complex enum:
+		plan.isComplexEnum = isTypeBorshEnum(plan.fields[0].typ) && plan.fields[0].tag.IsBorshEnum
+	}
+	return plan
+}
+
+// sizeOfTracker holds the lengths defined by `sizeof=` fields
+// while walking a single struct value.
+type sizeOfTracker map[int]int
+
+func (plan *structPlan) newSizeOfTracker() sizeOfTracker {
+	if !plan.hasSizeOf {
+		return nil
+	}
+	return make(sizeOfTracker)
+}
+
+func (t sizeOfTracker) set(field *fieldPlan, size int) {
+	if t == nil || field.sizeOfTarget < 0 {
+		return
+	}
+	t[field.sizeOfTarget] = size
+}
+
+// newByteSizeOfTracker returns the tracker of the sizes in bytes
+// defined by `byte_sizeof=` fields (see setByteSize).
+func (plan *structPlan) newByteSizeOfTracker() sizeOfTracker {
+	if !plan.hasByteSizeOf {
+		return nil
+	}
+	return make(sizeOfTracker)
+}
+
+func (t sizeOfTracker) setByteSize(field *fieldPlan, size int) {
+	if t == nil || field.byteSizeOfTarget < 0 {
+		return
+	}
+	t[field.byteSizeOfTarget] = size
+}
+
+func (t sizeOfTracker) get(field *fieldPlan) (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+	size, ok := t[field.index]
+	return size, ok
+}
+
+// sizeOfValue returns the value to encode for field, a `sizeof=` field
+// of the struct sv, whose value is fv: when its target is a slice encoded
+// after it, a zero value is filled with the length of the slice, and the
+// other values must be equal to it (see ErrSizeOfMismatch).
+func (plan *structPlan) sizeOfValue(field *fieldPlan, fv reflect.Value, sv reflect.Value) (reflect.Value, error) {
+	if field.sizeOfTarget <= field.index {
+		return fv, nil
+	}
+	target := &plan.fields[field.sizeOfTarget]
+	tv := sv.Field(target.index)
+	if target.tag.Skip || !tv.CanInterface() || tv.Kind() != reflect.Slice {
+		return fv, nil
+	}
+	if fv.IsZero() && tv.Len() > 0 {
+		filled := reflect.New(field.typ).Elem()
+		switch filled.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			filled.SetInt(int64(tv.Len()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			filled.SetUint(uint64(tv.Len()))
+		default:
+			return fv, nil
+		}
+		fv = filled
+	}
+	return fv, checkSizeOf(sizeof(field.typ, fv), target.name, tv.Len())
+}
+
+// checkSizeOf checks that size, the value of a `sizeof=` field,
+// is the length of its target slice (which doesn't hold if the length
+// was truncated to fit the type of the field).
+func checkSizeOf(size int, target string, length int) error {
+	if size != length {
+		return fmt.Errorf("%w: %d, but %s has %d elements", ErrSizeOfMismatch, size, target, length)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package bin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type structPlanTestEnum struct {
+	Enum BorshEnum `borsh_enum:"true"`
+	One  EmptyVariant
+	Two  uint32
+}
+
+func TestStructPlan(t *testing.T) {
+	{
+		rt := reflect.TypeOf(benchPlanStruct{})
+		plan := getStructPlan(rt)
+		require.Same(t, plan, getStructPlan(rt))
+		require.Len(t, plan.fields, rt.NumField())
+		require.False(t, plan.isComplexEnum)
+		require.True(t, plan.hasSizeOf)
+
+		require.Equal(t, "F11", plan.fields[10].name)
+		require.Equal(t, 11, plan.fields[10].sizeOfTarget)
+		require.Equal(t, -1, plan.fields[11].sizeOfTarget)
+		require.Equal(t, BE, plan.fields[1].tag.Order)
+		require.True(t, plan.fields[12].tag.Option)
+		require.True(t, plan.fields[14].tag.Skip)
+
+		require.True(t, plan.fields[13].ptrImplementsUnmarshaler)
+		require.False(t, plan.fields[13].implementsUnmarshaler)
+		require.False(t, plan.fields[0].ptrImplementsUnmarshaler)
+	}
+	{
+		plan := getStructPlan(reflect.TypeOf(structPlanTestEnum{}))
+		require.True(t, plan.isComplexEnum)
+		require.False(t, plan.hasSizeOf)
+		require.Nil(t, plan.newSizeOfTracker())
+	}
+}
@@ -22,8 +22,24 @@ import "encoding/binary"
 type option struct {
 	is_OptionalField  bool
 	is_COptionalField bool
+	is_SetField       bool
 	SizeOfSlice       *int
 	Order             binary.ByteOrder
+	FixedLayout       *FixedLayout
+	// FixedSize is the size of the NUL-padded buffer holding
+	// a string or []byte (with the `fixed=N` tag); 0 if none.
+	FixedSize int
+	// CString is set for the NUL-terminated strings (with the `cstring` tag).
+	CString bool
+	// LenPrefix is the format of the length prefix
+	// of a string, slice or map (with the `len=` tag).
+	LenPrefix LengthPrefix
+	// Rest is set for the trailing fields without length prefix
+	// (with the `rest` tag), which extend to the end of the data.
+	Rest bool
+	// Compact is set for the unsigned integers encoded as
+	// SCALE compact integers (with the `compact` tag).
+	Compact bool
 }
 
 var (
@@ -44,8 +60,15 @@ func (o *option) clone() *option {
 	out := &option{
 		is_OptionalField:  o.is_OptionalField,
 		is_COptionalField: o.is_COptionalField,
+		is_SetField:       o.is_SetField,
 		SizeOfSlice:       o.SizeOfSlice,
 		Order:             o.Order,
+		FixedLayout:       o.FixedLayout,
+		FixedSize:         o.FixedSize,
+		CString:           o.CString,
+		LenPrefix:         o.LenPrefix,
+		Rest:              o.Rest,
+		Compact:           o.Compact,
 	}
 	return out
 }
@@ -58,6 +81,15 @@ func (o *option) is_COptional() bool {
 	return o.is_COptionalField
 }
 
+func (o *option) is_Set() bool {
+	return o.is_SetField
+}
+
+// isText tells whether the value has a `fixed=N` or `cstring` tag.
+func (o *option) isText() bool {
+	return o.FixedSize > 0 || o.CString
+}
+
 func (o *option) hasSizeOfSlice() bool {
 	return o.SizeOfSlice != nil
 }
@@ -81,6 +113,8 @@ func (o *option) set_COptional(isCOptional bool) *option {
 	return o
 }
 
+// Encoding is a wire format: one of the built-in encodings,
+// or one added with RegisterEncoding.
 type Encoding int
 
 const (
@@ -90,16 +124,8 @@ const (
 )
 
 func (enc Encoding) String() string {
-	switch enc {
-	case EncodingBin:
-		return "Bin"
-	case EncodingCompactU16:
-		return "CompactU16"
-	case EncodingBorsh:
-		return "Borsh"
-	default:
-		return ""
-	}
+	c, _ := lookupCodec(enc)
+	return c.name
 }
 
 func (en Encoding) IsBorsh() bool {
@@ -115,10 +141,6 @@ func (en Encoding) IsCompactU16() bool {
 }
 
 func isValidEncoding(enc Encoding) bool {
-	switch enc {
-	case EncodingBin, EncodingCompactU16, EncodingBorsh:
-		return true
-	default:
-		return false
-	}
+	_, ok := lookupCodec(enc)
+	return ok
 }
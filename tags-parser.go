@@ -19,17 +19,27 @@ package bin
 
 import (
 	"encoding/binary"
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
 type fieldTag struct {
 	SizeOf          string
+	ByteSizeOf      string
 	Skip            bool
 	Order           binary.ByteOrder
 	Option          bool
 	COption         bool
 	BinaryExtension bool
+	Set             bool
+	FixedLayout     *FixedLayout
+	FixedSize       int
+	CString         bool
+	LenPrefix       LengthPrefix
+	Rest            bool
+	Compact         bool
 
 	IsBorshEnum bool
 }
@@ -52,6 +62,8 @@ func parseFieldTag(tag reflect.StructTag) *fieldTag {
 		if strings.HasPrefix(s, "sizeof=") {
 			tmp := strings.SplitN(s, "=", 2)
 			t.SizeOf = tmp[1]
+		} else if strings.HasPrefix(s, "byte_sizeof=") {
+			t.ByteSizeOf = strings.TrimPrefix(s, "byte_sizeof=")
 		} else if s == "big" {
 			t.Order = binary.BigEndian
 		} else if s == "little" {
@@ -62,8 +74,37 @@ func parseFieldTag(tag reflect.StructTag) *fieldTag {
 			t.COption = true
 		} else if s == "binary_extension" {
 			t.BinaryExtension = true
+		} else if s == "set" {
+			t.Set = true
 		} else if isIn(s, "-", "skip") {
 			t.Skip = true
+		} else if strings.HasPrefix(s, "layout=") {
+			layout, err := parseFixedLayout(strings.TrimPrefix(s, "layout="))
+			if err == nil {
+				err = layout.validate()
+			}
+			if err != nil {
+				panic(fmt.Sprintf("invalid `bin:\"%s\"` tag: %s", s, err))
+			}
+			t.FixedLayout = &layout
+		} else if strings.HasPrefix(s, "fixed=") {
+			size, err := strconv.Atoi(strings.TrimPrefix(s, "fixed="))
+			if err != nil || size <= 0 {
+				panic(fmt.Sprintf("invalid `bin:\"%s\"` tag: the size must be a positive number of bytes", s))
+			}
+			t.FixedSize = size
+		} else if strings.HasPrefix(s, "len=") {
+			prefix, ok := parseLengthPrefix(strings.TrimPrefix(s, "len="))
+			if !ok {
+				panic(fmt.Sprintf("invalid `bin:\"%s\"` tag: unknown length prefix (see LengthPrefix)", s))
+			}
+			t.LenPrefix = prefix
+		} else if s == "cstring" {
+			t.CString = true
+		} else if s == "rest" {
+			t.Rest = true
+		} else if s == "compact" {
+			t.Compact = true
 		} else if isIn(s, "enum") {
 			t.IsBorshEnum = true
 		}
@@ -0,0 +1,105 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The `fixed=N` and `cstring` tags apply to the string and []byte fields,
+// the same way in all the encodings:
+//   - `fixed=N`: the value is stored in N bytes, padded with NUL bytes
+//     (stripped when decoding);
+//   - `cstring`: the value is followed by a NUL byte;
+//   - both: the value, and its NUL terminator, are stored in N bytes
+//     (when decoding, the value ends at the first NUL byte).
+
+func checkTextType(rt reflect.Type) error {
+	if rt.Kind() == reflect.String || (rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8) {
+		return nil
+	}
+	return fmt.Errorf("%w: the `fixed` and `cstring` tags apply to strings and byte slices, got %s", ErrUnsupportedType, rt)
+}
+
+// decodeText decodes into rv a string or []byte tagged with `fixed=N` or `cstring`.
+func (dec *Decoder) decodeText(rv reflect.Value, opt *option) (err error) {
+	if err = checkTextType(rv.Type()); err != nil {
+		return err
+	}
+
+	var s string
+	switch {
+	case opt.FixedSize == 0:
+		s, err = dec.ReadCString()
+	case opt.CString:
+		var data []byte
+		data, err = dec.ReadNBytes(opt.FixedSize)
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			data = data[:i]
+		}
+		s = string(data)
+	default:
+		s, err = dec.ReadFixedString(opt.FixedSize)
+	}
+	if err != nil {
+		return err
+	}
+
+	if rv.Kind() == reflect.String {
+		rv.SetString(s)
+	} else if len(s) == 0 {
+		// Empty slices are left nil
+		rv.Set(reflect.Zero(rv.Type()))
+	} else {
+		rv.SetBytes([]byte(s))
+	}
+	return nil
+}
+
+// encodeText encodes rv, a string or []byte tagged with `fixed=N` or `cstring`.
+func (e *Encoder) encodeText(rv reflect.Value, opt *option) error {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv = reflect.Zero(rv.Type().Elem())
+		} else {
+			rv = rv.Elem()
+		}
+	}
+	if err := checkTextType(rv.Type()); err != nil {
+		return err
+	}
+
+	var s string
+	if rv.Kind() == reflect.String {
+		s = rv.String()
+	} else {
+		s = string(rv.Bytes())
+	}
+	switch {
+	case opt.FixedSize == 0:
+		return e.WriteCString(s)
+	case opt.CString:
+		if strings.IndexByte(s, 0) >= 0 {
+			return fmt.Errorf("cstring %q contains a NUL byte", s)
+		}
+		if len(s) >= opt.FixedSize {
+			return fmt.Errorf("%w: cstring of %d bytes (and its NUL terminator) in %d bytes", ErrValueTooLong, len(s), opt.FixedSize)
+		}
+	}
+	return e.WriteFixedString(s, opt.FixedSize)
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+type textFields struct {
+	Name   string  `bin:"fixed=8"`
+	Symbol string  `bin:"cstring"`
+	Label  string  `bin:"fixed=6 cstring"`
+	Data   []byte  `bin:"fixed=4"`
+	Memo   *string `bin:"cstring"`
+	Count  uint8
+}
+
+func TestTextFields(t *testing.T) {
+	memo := "hi"
+	x := textFields{
+		Name:   "Token",
+		Symbol: "TKN",
+		Label:  "label",
+		Data:   []byte{1, 2},
+		Memo:   &memo,
+		Count:  7,
+	}
+	want := concatByteSlices(
+		[]byte{'T', 'o', 'k', 'e', 'n', 0, 0, 0},
+		[]byte{'T', 'K', 'N', 0},
+		[]byte{'l', 'a', 'b', 'e', 'l', 0},
+		[]byte{1, 2, 0, 0},
+		[]byte{'h', 'i', 0},
+		[]byte{7},
+	)
+
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		data, err := marshalWithEncoding(&x, encoding)
+		require.NoError(t, err, encoding)
+		require.Equal(t, want, data, encoding)
+
+		var y textFields
+		require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+		require.Equal(t, x, y, encoding)
+
+		// Empty values, and garbage after the terminator of a fixed cstring:
+		data = concatByteSlices(
+			make([]byte, 8),
+			[]byte{0},
+			[]byte{'a', 0, 'x', 'y', 0, 0},
+			make([]byte, 4),
+			[]byte{0},
+			[]byte{1},
+		)
+		y = textFields{}
+		require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+		empty := ""
+		require.Equal(t, textFields{Label: "a", Memo: &empty, Count: 1}, y, encoding)
+
+		// Missing NUL terminator:
+		err = NewDecoderWithEncoding(want[:10], encoding).Decode(&y)
+		require.True(t, errors.Is(err, ErrShortBuffer), err)
+	}
+}
+
+func TestTextFields_encodingErrors(t *testing.T) {
+	for _, x := range []textFields{
+		{Name: "123456789"},
+		{Label: "123456"},
+		{Data: []byte{1, 2, 3, 4, 5}},
+	} {
+		for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+			_, err := marshalWithEncoding(&x, encoding)
+			require.True(t, errors.Is(err, ErrValueTooLong), err)
+		}
+	}
+
+	_, err := MarshalBorsh(&textFields{Symbol: "a\x00b"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "NUL byte")
+
+	// The longest values fit:
+	_, err = MarshalBorsh(&textFields{Name: "12345678", Label: "12345", Data: []byte{1, 2, 3, 4}})
+	require.NoError(t, err)
+
+	var unsupported struct {
+		A uint32 `bin:"fixed=4"`
+	}
+	_, err = MarshalBorsh(&unsupported)
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	err = NewBorshDecoder(make([]byte, 4)).Decode(&unsupported)
+	require.True(t, errors.Is(err, ErrUnsupportedType), err)
+}
+
+func TestTextFields_optional(t *testing.T) {
+	type S struct {
+		Name *string `bin:"optional fixed=4"`
+	}
+	name := "ab"
+	data, err := MarshalBorsh(&S{Name: &name})
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 'a', 'b', 0, 0}, data)
+
+	var s S
+	require.NoError(t, UnmarshalBorsh(&s, data))
+	require.Equal(t, name, *s.Name)
+
+	data, err = MarshalBorsh(&S{})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0}, data)
+}
+
+func TestDecoder_ReadCString(t *testing.T) {
+	dec := NewBinDecoder([]byte("abc\x00\x00de"))
+	s, err := dec.ReadCString()
+	require.NoError(t, err)
+	require.Equal(t, "abc", s)
+	s, err = dec.ReadCString()
+	require.NoError(t, err)
+	require.Equal(t, "", s)
+	_, err = dec.ReadCString()
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+
+	dec = NewBinStreamDecoder(iotest.OneByteReader(strings.NewReader("abc\x00de")))
+	s, err = dec.ReadCString()
+	require.NoError(t, err)
+	require.Equal(t, "abc", s)
+	_, err = dec.ReadCString()
+	require.True(t, errors.Is(err, ErrShortBuffer), err)
+
+	dec = NewBinDecoder([]byte("abcdef\x00")).SetOptions(DecoderOptions{MaxStringLength: 4})
+	_, err = dec.ReadCString()
+	require.True(t, errors.Is(err, ErrLimitExceeded), err)
+}
@@ -55,9 +55,6 @@ func (i Uint128) getByteOrder() binary.ByteOrder {
 func (i Int128) getByteOrder() binary.ByteOrder {
 	return Uint128(i).getByteOrder()
 }
-func (i Float128) getByteOrder() binary.ByteOrder {
-	return Uint128(i).getByteOrder()
-}
 
 func (i Uint128) Bytes() []byte {
 	buf := make([]byte, 16)
@@ -80,7 +77,7 @@ func (i Uint128) BigInt() *big.Int {
 }
 
 func (i Uint128) String() string {
-	//Same for Int128, Float128
+	//Same for Int128
 	return i.DecimalString()
 }
 
@@ -164,6 +161,14 @@ func (i *Uint128) unmarshalJSON_hex(s string) error {
 }
 
 func (i *Uint128) UnmarshalWithDecoder(dec *Decoder) error {
+	if c, ok := dec.encoding.intCodec(); ok {
+		value, err := c.ReadUint(dec, 128)
+		if err != nil {
+			return err
+		}
+		*i = value
+		return nil
+	}
 	var order binary.ByteOrder
 	if dec != nil && dec.currentFieldOpt != nil {
 		order = dec.currentFieldOpt.Order
@@ -180,6 +185,9 @@ func (i *Uint128) UnmarshalWithDecoder(dec *Decoder) error {
 }
 
 func (i Uint128) MarshalWithEncoder(enc *Encoder) error {
+	if c, ok := enc.encoding.intCodec(); ok {
+		return c.WriteUint(enc, i, 128)
+	}
 	var order binary.ByteOrder
 	if enc != nil && enc.currentFieldOpt != nil {
 		order = enc.currentFieldOpt.Order
@@ -232,6 +240,14 @@ func (i *Int128) UnmarshalJSON(data []byte) error {
 }
 
 func (i *Int128) UnmarshalWithDecoder(dec *Decoder) error {
+	if c, ok := dec.encoding.intCodec(); ok {
+		value, err := c.ReadInt(dec, 128)
+		if err != nil {
+			return err
+		}
+		*i = value
+		return nil
+	}
 	var order binary.ByteOrder
 	if dec != nil && dec.currentFieldOpt != nil {
 		order = dec.currentFieldOpt.Order
@@ -248,55 +264,14 @@ func (i *Int128) UnmarshalWithDecoder(dec *Decoder) error {
 }
 
 func (i Int128) MarshalWithEncoder(enc *Encoder) error {
-	var order binary.ByteOrder
-	if enc != nil && enc.currentFieldOpt != nil {
-		order = enc.currentFieldOpt.Order
-	} else {
-		order = i.getByteOrder()
+	if c, ok := enc.encoding.intCodec(); ok {
+		return c.WriteInt(enc, i, 128)
 	}
-	return enc.WriteInt128(i, order)
-}
-
-type Float128 Uint128
-
-func (i Float128) MarshalJSON() (data []byte, err error) {
-	return []byte(`"` + Uint128(i).String() + `"`), nil
-}
-
-func (i *Float128) UnmarshalJSON(data []byte) error {
-	var el Uint128
-	if err := json.Unmarshal(data, &el); err != nil {
-		return err
-	}
-
-	out := Float128(el)
-	*i = out
-
-	return nil
-}
-
-func (i *Float128) UnmarshalWithDecoder(dec *Decoder) error {
-	var order binary.ByteOrder
-	if dec != nil && dec.currentFieldOpt != nil {
-		order = dec.currentFieldOpt.Order
-	} else {
-		order = i.getByteOrder()
-	}
-	value, err := dec.ReadFloat128(order)
-	if err != nil {
-		return err
-	}
-
-	*i = Float128(value)
-	return nil
-}
-
-func (i Float128) MarshalWithEncoder(enc *Encoder) error {
 	var order binary.ByteOrder
 	if enc != nil && enc.currentFieldOpt != nil {
 		order = enc.currentFieldOpt.Order
 	} else {
 		order = i.getByteOrder()
 	}
-	return enc.WriteUint128(Uint128(i), order)
+	return enc.WriteInt128(i, order)
 }
@@ -0,0 +1,436 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// The arithmetic of Uint128 and Int128 works on the Hi:Lo value
+// (Endianness only affects their encoding), without allocating.
+// The results keep the Endianness of the receiver.
+// Like the Go integer types, Add, Sub, Mul and the shifts wrap around;
+// the Checked variants return ErrOverflow instead.
+
+// NewUint128FromUint64 returns v as a Uint128.
+func NewUint128FromUint64(v uint64) Uint128 {
+	return Uint128{Lo: v}
+}
+
+// NewUint128FromBigInt returns b as a Uint128,
+// or ErrOverflow if b is negative or doesn't fit in 128 bits.
+func NewUint128FromBigInt(b *big.Int) (Uint128, error) {
+	var out Uint128
+	err := out.SetBigInt(b)
+	return out, err
+}
+
+// SetUint64 sets i to v, keeping its Endianness, and returns i.
+func (i *Uint128) SetUint64(v uint64) *Uint128 {
+	i.Lo, i.Hi = v, 0
+	return i
+}
+
+// SetBigInt sets i to b, keeping its Endianness; it returns ErrOverflow
+// (leaving i unchanged) if b is negative or doesn't fit in 128 bits.
+func (i *Uint128) SetBigInt(b *big.Int) error {
+	if b.Sign() < 0 || b.BitLen() > 128 {
+		return fmt.Errorf("%w: %s doesn't fit in a uint128", ErrOverflow, b)
+	}
+	var words [2]uint64
+	bigIntWords(b, words[:])
+	i.Lo, i.Hi = words[0], words[1]
+	return nil
+}
+
+// bigIntWords sets out to the low 64-bit words of |b|, the least significant first.
+func bigIntWords(b *big.Int, out []uint64) {
+	for k := range out {
+		out[k] = 0
+	}
+	for k, w := range b.Bits() {
+		if bits.UintSize == 64 {
+			if k >= len(out) {
+				return
+			}
+			out[k] = uint64(w)
+		} else {
+			if k/2 >= len(out) {
+				return
+			}
+			out[k/2] |= uint64(w) << (32 * uint(k%2))
+		}
+	}
+}
+
+func (i Uint128) with(lo, hi uint64) Uint128 {
+	return Uint128{Lo: lo, Hi: hi, Endianness: i.Endianness}
+}
+
+// IsZero reports whether i is zero.
+func (i Uint128) IsZero() bool {
+	return i.Lo == 0 && i.Hi == 0
+}
+
+// IsUint64 reports whether i fits in a uint64.
+func (i Uint128) IsUint64() bool {
+	return i.Hi == 0
+}
+
+// Uint64 returns the low 64 bits of i (i.e. i, if IsUint64).
+func (i Uint128) Uint64() uint64 {
+	return i.Lo
+}
+
+// Cmp returns -1 if i < j, 0 if i == j, and +1 if i > j.
+func (i Uint128) Cmp(j Uint128) int {
+	switch {
+	case i.Hi < j.Hi, i.Hi == j.Hi && i.Lo < j.Lo:
+		return -1
+	case i.Hi == j.Hi && i.Lo == j.Lo:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Add returns i+j, wrapping around on overflow.
+func (i Uint128) Add(j Uint128) Uint128 {
+	out, _ := i.add(j)
+	return out
+}
+
+// CheckedAdd returns i+j, or ErrOverflow if it doesn't fit in 128 bits.
+func (i Uint128) CheckedAdd(j Uint128) (Uint128, error) {
+	out, carry := i.add(j)
+	if carry != 0 {
+		return Uint128{}, ErrOverflow
+	}
+	return out, nil
+}
+
+func (i Uint128) add(j Uint128) (Uint128, uint64) {
+	lo, carry := bits.Add64(i.Lo, j.Lo, 0)
+	hi, carry := bits.Add64(i.Hi, j.Hi, carry)
+	return i.with(lo, hi), carry
+}
+
+// Sub returns i-j, wrapping around on underflow.
+func (i Uint128) Sub(j Uint128) Uint128 {
+	out, _ := i.sub(j)
+	return out
+}
+
+// CheckedSub returns i-j, or ErrOverflow if j > i.
+func (i Uint128) CheckedSub(j Uint128) (Uint128, error) {
+	out, borrow := i.sub(j)
+	if borrow != 0 {
+		return Uint128{}, ErrOverflow
+	}
+	return out, nil
+}
+
+func (i Uint128) sub(j Uint128) (Uint128, uint64) {
+	lo, borrow := bits.Sub64(i.Lo, j.Lo, 0)
+	hi, borrow := bits.Sub64(i.Hi, j.Hi, borrow)
+	return i.with(lo, hi), borrow
+}
+
+// Mul returns i*j, wrapping around on overflow.
+func (i Uint128) Mul(j Uint128) Uint128 {
+	hi, lo := bits.Mul64(i.Lo, j.Lo)
+	hi += i.Hi*j.Lo + i.Lo*j.Hi
+	return i.with(lo, hi)
+}
+
+// CheckedMul returns i*j, or ErrOverflow if it doesn't fit in 128 bits.
+func (i Uint128) CheckedMul(j Uint128) (Uint128, error) {
+	if i.Hi != 0 && j.Hi != 0 {
+		return Uint128{}, ErrOverflow
+	}
+	hi, lo := bits.Mul64(i.Lo, j.Lo)
+	h1, l1 := bits.Mul64(i.Hi, j.Lo)
+	h2, l2 := bits.Mul64(i.Lo, j.Hi)
+	hi, c1 := bits.Add64(hi, l1, 0)
+	hi, c2 := bits.Add64(hi, l2, 0)
+	if h1|h2|c1|c2 != 0 {
+		return Uint128{}, ErrOverflow
+	}
+	return i.with(lo, hi), nil
+}
+
+// QuoRem returns the quotient i/j and the remainder i%j;
+// it panics if j is zero, like the integer division.
+func (i Uint128) QuoRem(j Uint128) (q, r Uint128) {
+	if j.Hi == 0 {
+		q, r64 := i.quoRem64(j.Lo)
+		return q, i.with(r64, 0)
+	}
+	// A trial quotient, from the top 64 bits of the normalized divisor,
+	// is at most one less than the quotient.
+	n := uint(bits.LeadingZeros64(j.Hi))
+	j1 := j.Lsh(n)
+	i1 := i.Rsh(1)
+	tq, _ := bits.Div64(i1.Hi, i1.Lo, j1.Hi)
+	tq >>= 63 - n
+	if tq != 0 {
+		tq--
+	}
+	q = i.with(tq, 0)
+	r = i.Sub(j.Mul(q))
+	if r.Cmp(j) >= 0 {
+		q = q.Add(i.with(1, 0))
+		r = r.Sub(j)
+	}
+	return q, r
+}
+
+func (i Uint128) quoRem64(j uint64) (q Uint128, r uint64) {
+	q = i.with(0, 0)
+	if i.Hi < j {
+		q.Lo, r = bits.Div64(i.Hi, i.Lo, j)
+	} else {
+		q.Hi, r = bits.Div64(0, i.Hi, j)
+		q.Lo, r = bits.Div64(r, i.Lo, j)
+	}
+	return q, r
+}
+
+// Quo returns i/j; it panics if j is zero.
+func (i Uint128) Quo(j Uint128) Uint128 {
+	q, _ := i.QuoRem(j)
+	return q
+}
+
+// Rem returns i%j; it panics if j is zero.
+func (i Uint128) Rem(j Uint128) Uint128 {
+	_, r := i.QuoRem(j)
+	return r
+}
+
+// Lsh returns i<<n.
+func (i Uint128) Lsh(n uint) Uint128 {
+	switch {
+	case n >= 128:
+		return i.with(0, 0)
+	case n >= 64:
+		return i.with(0, i.Lo<<(n-64))
+	default:
+		return i.with(i.Lo<<n, i.Hi<<n|i.Lo>>(64-n))
+	}
+}
+
+// Rsh returns i>>n.
+func (i Uint128) Rsh(n uint) Uint128 {
+	switch {
+	case n >= 128:
+		return i.with(0, 0)
+	case n >= 64:
+		return i.with(i.Hi>>(n-64), 0)
+	default:
+		return i.with(i.Lo>>n|i.Hi<<(64-n), i.Hi>>n)
+	}
+}
+
+// NewInt128FromInt64 returns v as an Int128.
+func NewInt128FromInt64(v int64) Int128 {
+	var out Int128
+	return *out.SetInt64(v)
+}
+
+// NewInt128FromBigInt returns b as an Int128,
+// or ErrOverflow if b doesn't fit in 128 bits (two's complement).
+func NewInt128FromBigInt(b *big.Int) (Int128, error) {
+	var out Int128
+	err := out.SetBigInt(b)
+	return out, err
+}
+
+// SetInt64 sets i to v, keeping its Endianness, and returns i.
+func (i *Int128) SetInt64(v int64) *Int128 {
+	i.Lo, i.Hi = uint64(v), uint64(v>>63)
+	return i
+}
+
+// SetBigInt sets i to b, keeping its Endianness; it returns ErrOverflow
+// (leaving i unchanged) if b doesn't fit in 128 bits (two's complement).
+func (i *Int128) SetBigInt(b *big.Int) error {
+	neg := b.Sign() < 0
+	// -2**127 is the only negative value whose magnitude takes 128 bits.
+	if b.BitLen() > 128 || (b.BitLen() == 128 && !(neg && b.TrailingZeroBits() == 127)) {
+		return fmt.Errorf("%w: %s doesn't fit in an int128", ErrOverflow, b)
+	}
+	var words [2]uint64
+	bigIntWords(b, words[:])
+	v := Int128{Lo: words[0], Hi: words[1]}
+	if neg {
+		v = v.Neg()
+	}
+	i.Lo, i.Hi = v.Lo, v.Hi
+	return nil
+}
+
+func (i Int128) with(lo, hi uint64) Int128 {
+	return Int128{Lo: lo, Hi: hi, Endianness: i.Endianness}
+}
+
+// Sign returns -1 if i < 0, 0 if i == 0, and +1 if i > 0.
+func (i Int128) Sign() int {
+	switch {
+	case int64(i.Hi) < 0:
+		return -1
+	case i.Hi == 0 && i.Lo == 0:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// IsInt64 reports whether i fits in an int64.
+func (i Int128) IsInt64() bool {
+	return i.Hi == uint64(int64(i.Lo)>>63)
+}
+
+// Int64 returns the low 64 bits of i, as an int64 (i.e. i, if IsInt64).
+func (i Int128) Int64() int64 {
+	return int64(i.Lo)
+}
+
+// Cmp returns -1 if i < j, 0 if i == j, and +1 if i > j.
+func (i Int128) Cmp(j Int128) int {
+	switch {
+	case int64(i.Hi) < int64(j.Hi), i.Hi == j.Hi && i.Lo < j.Lo:
+		return -1
+	case i.Hi == j.Hi && i.Lo == j.Lo:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Neg returns -i (the minimum value is its own negation).
+func (i Int128) Neg() Int128 {
+	lo, borrow := bits.Sub64(0, i.Lo, 0)
+	hi, _ := bits.Sub64(0, i.Hi, borrow)
+	return i.with(lo, hi)
+}
+
+// abs returns |i|, as an unsigned value (which holds |min|).
+func (i Int128) abs() Uint128 {
+	if int64(i.Hi) < 0 {
+		i = i.Neg()
+	}
+	return Uint128(i)
+}
+
+// Add returns i+j, wrapping around on overflow.
+func (i Int128) Add(j Int128) Int128 {
+	return Int128(Uint128(i).Add(Uint128(j)))
+}
+
+// CheckedAdd returns i+j, or ErrOverflow if it doesn't fit in 128 bits.
+func (i Int128) CheckedAdd(j Int128) (Int128, error) {
+	out := i.Add(j)
+	// Overflow iff the operands have the same sign, and the result the other one.
+	if int64((out.Hi^i.Hi)&(out.Hi^j.Hi)) < 0 {
+		return Int128{}, ErrOverflow
+	}
+	return out, nil
+}
+
+// Sub returns i-j, wrapping around on overflow.
+func (i Int128) Sub(j Int128) Int128 {
+	return Int128(Uint128(i).Sub(Uint128(j)))
+}
+
+// CheckedSub returns i-j, or ErrOverflow if it doesn't fit in 128 bits.
+func (i Int128) CheckedSub(j Int128) (Int128, error) {
+	out := i.Sub(j)
+	// Overflow iff the operands have different signs, and the result the sign of j.
+	if int64((i.Hi^j.Hi)&(out.Hi^i.Hi)) < 0 {
+		return Int128{}, ErrOverflow
+	}
+	return out, nil
+}
+
+// Mul returns i*j, wrapping around on overflow.
+func (i Int128) Mul(j Int128) Int128 {
+	return Int128(Uint128(i).Mul(Uint128(j)))
+}
+
+// CheckedMul returns i*j, or ErrOverflow if it doesn't fit in 128 bits.
+func (i Int128) CheckedMul(j Int128) (Int128, error) {
+	abs, err := i.abs().CheckedMul(j.abs())
+	if err != nil {
+		return Int128{}, ErrOverflow
+	}
+	neg := (i.Sign() < 0) != (j.Sign() < 0)
+	if abs.Hi > math.MaxInt64 && !(neg && abs.Hi == 1<<63 && abs.Lo == 0) {
+		return Int128{}, ErrOverflow
+	}
+	out := i.with(abs.Lo, abs.Hi)
+	if neg {
+		out = out.Neg()
+	}
+	return out, nil
+}
+
+// QuoRem returns the quotient i/j and the remainder i%j, truncated
+// towards zero like the Go integer division (the minimum value divided
+// by -1 wraps around); it panics if j is zero.
+func (i Int128) QuoRem(j Int128) (q, r Int128) {
+	uq, ur := i.abs().QuoRem(j.abs())
+	q, r = i.with(uq.Lo, uq.Hi), i.with(ur.Lo, ur.Hi)
+	if (i.Sign() < 0) != (j.Sign() < 0) {
+		q = q.Neg()
+	}
+	if i.Sign() < 0 {
+		r = r.Neg()
+	}
+	return q, r
+}
+
+// Quo returns i/j, truncated towards zero; it panics if j is zero.
+func (i Int128) Quo(j Int128) Int128 {
+	q, _ := i.QuoRem(j)
+	return q
+}
+
+// Rem returns i%j, with the sign of i; it panics if j is zero.
+func (i Int128) Rem(j Int128) Int128 {
+	_, r := i.QuoRem(j)
+	return r
+}
+
+// Lsh returns i<<n.
+func (i Int128) Lsh(n uint) Int128 {
+	return Int128(Uint128(i).Lsh(n))
+}
+
+// Rsh returns i>>n, extending the sign (like the Go signed integers).
+func (i Int128) Rsh(n uint) Int128 {
+	switch {
+	case n >= 128:
+		fill := uint64(int64(i.Hi) >> 63)
+		return i.with(fill, fill)
+	case n >= 64:
+		return i.with(uint64(int64(i.Hi)>>(n-64)), uint64(int64(i.Hi)>>63))
+	default:
+		return i.with(i.Lo>>n|i.Hi<<(64-n), uint64(int64(i.Hi)>>n))
+	}
+}
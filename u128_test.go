@@ -1,7 +1,12 @@
 package bin
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
 	"testing"
 
 	"github.com/shopspring/decimal"
@@ -62,3 +67,213 @@ func TestUint128(t *testing.T) {
 		}
 	}
 }
+
+var (
+	two128 = new(big.Int).Lsh(big.NewInt(1), 128)
+	two127 = new(big.Int).Lsh(big.NewInt(1), 127)
+)
+
+// u128Samples returns edge values and random values, of random sizes.
+func u128Samples() []Uint128 {
+	samples := []Uint128{
+		{}, {Lo: 1}, {Lo: 2}, {Lo: math.MaxUint64}, {Hi: 1}, {Lo: 1, Hi: 1},
+		{Lo: math.MaxUint64, Hi: math.MaxUint64}, {Hi: 1 << 63}, {Lo: math.MaxUint64, Hi: math.MaxInt64},
+		{Lo: 1, Hi: 1 << 63}, {Lo: math.MaxUint64 - 1, Hi: math.MaxUint64},
+	}
+	rnd := rand.New(rand.NewSource(42))
+	for i := 0; i < 300; i++ {
+		v := Uint128{Lo: rnd.Uint64(), Hi: rnd.Uint64()}
+		samples = append(samples, v.Rsh(uint(rnd.Intn(128))))
+	}
+	return samples
+}
+
+// wrap returns b modulo 2**128, as a Uint128.
+func wrap(b *big.Int) Uint128 {
+	b = new(big.Int).Mod(b, two128)
+	out, err := NewUint128FromBigInt(b)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func TestUint128_arithmetic(t *testing.T) {
+	samples := u128Samples()
+	for _, x := range samples {
+		bx := x.BigInt()
+		for _, n := range []uint{0, 1, 63, 64, 65, 127, 128, 200} {
+			require.Equal(t, wrap(new(big.Int).Lsh(bx, n)), x.Lsh(n), "%s << %d", x, n)
+			require.Equal(t, wrap(new(big.Int).Rsh(bx, n)), x.Rsh(n), "%s >> %d", x, n)
+		}
+		for _, y := range samples {
+			by := y.BigInt()
+			require.Equal(t, bx.Cmp(by), x.Cmp(y), "%s cmp %s", x, y)
+
+			sum := new(big.Int).Add(bx, by)
+			require.Equal(t, wrap(sum), x.Add(y), "%s + %s", x, y)
+			checked, err := x.CheckedAdd(y)
+			if sum.Cmp(two128) >= 0 {
+				require.Equal(t, ErrOverflow, err, "%s + %s", x, y)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, wrap(sum), checked)
+			}
+
+			diff := new(big.Int).Sub(bx, by)
+			require.Equal(t, wrap(diff), x.Sub(y), "%s - %s", x, y)
+			checked, err = x.CheckedSub(y)
+			if diff.Sign() < 0 {
+				require.Equal(t, ErrOverflow, err, "%s - %s", x, y)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, wrap(diff), checked)
+			}
+
+			prod := new(big.Int).Mul(bx, by)
+			require.Equal(t, wrap(prod), x.Mul(y), "%s * %s", x, y)
+			checked, err = x.CheckedMul(y)
+			if prod.Cmp(two128) >= 0 {
+				require.Equal(t, ErrOverflow, err, "%s * %s", x, y)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, wrap(prod), checked)
+			}
+
+			if !y.IsZero() {
+				q, r := x.QuoRem(y)
+				bq, br := new(big.Int).QuoRem(bx, by, new(big.Int))
+				require.Equal(t, wrap(bq), q, "%s / %s", x, y)
+				require.Equal(t, wrap(br), r, "%s %% %s", x, y)
+				require.Equal(t, q, x.Quo(y))
+				require.Equal(t, r, x.Rem(y))
+			}
+		}
+	}
+	require.Panics(t, func() { Uint128{Lo: 1}.QuoRem(Uint128{}) })
+}
+
+func TestInt128_arithmetic(t *testing.T) {
+	var samples []Int128
+	for _, v := range u128Samples() {
+		samples = append(samples, Int128(v))
+	}
+	// wrap returns b modulo 2**128, as an Int128.
+	wrap := func(b *big.Int) Int128 {
+		b = new(big.Int).Mod(b, two128)
+		if b.Cmp(two127) >= 0 {
+			b.Sub(b, two128)
+		}
+		out, err := NewInt128FromBigInt(b)
+		if err != nil {
+			panic(err)
+		}
+		return out
+	}
+	fits := func(b *big.Int) bool {
+		return b.Cmp(two127) < 0 && b.Cmp(new(big.Int).Neg(two127)) >= 0
+	}
+	for _, x := range samples {
+		bx := x.BigInt()
+		require.Equal(t, bx.Sign(), x.Sign())
+		require.Equal(t, wrap(new(big.Int).Neg(bx)), x.Neg())
+		require.Equal(t, bx.IsInt64(), x.IsInt64(), "%s", bx)
+		if x.IsInt64() {
+			require.Equal(t, bx.Int64(), x.Int64())
+		}
+		for _, n := range []uint{0, 1, 63, 64, 65, 127, 128, 200} {
+			require.Equal(t, wrap(new(big.Int).Lsh(bx, n)), x.Lsh(n), "%s << %d", bx, n)
+			require.Equal(t, wrap(new(big.Int).Rsh(bx, n)), x.Rsh(n), "%s >> %d", bx, n)
+		}
+		for _, y := range samples {
+			by := y.BigInt()
+			require.Equal(t, bx.Cmp(by), x.Cmp(y), "%s cmp %s", bx, by)
+
+			for _, op := range []struct {
+				name    string
+				want    *big.Int
+				got     Int128
+				checked func(Int128) (Int128, error)
+			}{
+				{"+", new(big.Int).Add(bx, by), x.Add(y), x.CheckedAdd},
+				{"-", new(big.Int).Sub(bx, by), x.Sub(y), x.CheckedSub},
+				{"*", new(big.Int).Mul(bx, by), x.Mul(y), x.CheckedMul},
+			} {
+				require.Equal(t, wrap(op.want), op.got, "%s %s %s", bx, op.name, by)
+				checked, err := op.checked(y)
+				if fits(op.want) {
+					require.NoError(t, err, "%s %s %s", bx, op.name, by)
+					require.Equal(t, op.got, checked)
+				} else {
+					require.Equal(t, ErrOverflow, err, "%s %s %s", bx, op.name, by)
+				}
+			}
+
+			if y.Sign() != 0 {
+				q, r := x.QuoRem(y)
+				// big.Int.QuoRem truncates towards zero, like Go.
+				bq, br := new(big.Int).QuoRem(bx, by, new(big.Int))
+				require.Equal(t, wrap(bq), q, "%s / %s", bx, by)
+				require.Equal(t, wrap(br), r, "%s %% %s", bx, by)
+			}
+		}
+	}
+
+	min := Int128{Hi: 1 << 63}
+	q, r := min.QuoRem(NewInt128FromInt64(-1))
+	require.Equal(t, min, q)
+	require.Equal(t, Int128{}, r)
+}
+
+func TestUint128_constructors(t *testing.T) {
+	require.Equal(t, Uint128{Lo: 7}, NewUint128FromUint64(7))
+	require.Equal(t, "7", NewUint128FromUint64(7).String())
+
+	// The setters keep the byte order:
+	u := NewUint128BigEndian().SetUint64(0x0102)
+	require.Equal(t, Uint128{Lo: 0x0102, Endianness: binary.BigEndian}, *u)
+	require.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}, u.Bytes())
+	sum := u.Add(Uint128{Lo: 1})
+	require.Equal(t, binary.BigEndian, sum.Endianness)
+
+	max := new(big.Int).Sub(two128, big.NewInt(1))
+	require.NoError(t, u.SetBigInt(max))
+	require.Equal(t, Uint128{Lo: math.MaxUint64, Hi: math.MaxUint64, Endianness: binary.BigEndian}, *u)
+	require.Equal(t, max, u.BigInt())
+
+	for _, b := range []*big.Int{two128, big.NewInt(-1)} {
+		_, err := NewUint128FromBigInt(b)
+		require.True(t, errors.Is(err, ErrOverflow), b)
+	}
+	require.True(t, NewUint128FromUint64(math.MaxUint64).IsUint64())
+	require.False(t, Uint128{Hi: 1}.IsUint64())
+
+	require.Equal(t, Int128{Lo: math.MaxUint64 - 1, Hi: math.MaxUint64}, NewInt128FromInt64(-2))
+	require.Equal(t, big.NewInt(-2), NewInt128FromInt64(-2).BigInt())
+	i := Int128{Endianness: binary.BigEndian}
+	i.SetInt64(math.MinInt64)
+	require.Equal(t, Int128{Lo: 1 << 63, Hi: math.MaxUint64, Endianness: binary.BigEndian}, i)
+
+	for _, b := range []*big.Int{new(big.Int).Neg(two127), new(big.Int).Sub(two127, big.NewInt(1)), big.NewInt(-1), big.NewInt(0)} {
+		v, err := NewInt128FromBigInt(b)
+		require.NoError(t, err, b)
+		require.Equal(t, 0, b.Cmp(v.BigInt()), b)
+	}
+	for _, b := range []*big.Int{two127, new(big.Int).Sub(new(big.Int).Neg(two127), big.NewInt(1)), two128} {
+		_, err := NewInt128FromBigInt(b)
+		require.True(t, errors.Is(err, ErrOverflow), b)
+	}
+}
+
+func TestUint128_arithmeticAllocs(t *testing.T) {
+	x := Uint128{Lo: 12345, Hi: 678, Endianness: binary.BigEndian}
+	y := Uint128{Lo: 99, Hi: 1}
+	allocs := testing.AllocsPerRun(100, func() {
+		z := x.Add(y).Sub(y).Mul(y).Lsh(3).Rsh(2)
+		q, r := z.QuoRem(y)
+		_, _ = q.CheckedAdd(r)
+		_, _ = Int128(q).CheckedMul(Int128(r))
+		_, _ = Int128(z).QuoRem(Int128(y).Neg())
+	})
+	require.Equal(t, float64(0), allocs)
+}
@@ -0,0 +1,254 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Uint256 is an unsigned 256-bit integer (e.g. a Rust `u256`).
+type Uint256 struct {
+	// Words holds the 64-bit words of the value, the least significant first.
+	Words      [4]uint64
+	Endianness binary.ByteOrder
+}
+
+func NewUint256BigEndian() *Uint256 {
+	return &Uint256{
+		Endianness: binary.BigEndian,
+	}
+}
+
+func NewUint256LittleEndian() *Uint256 {
+	return &Uint256{
+		Endianness: binary.LittleEndian,
+	}
+}
+
+func (i Uint256) getByteOrder() binary.ByteOrder {
+	if i.Endianness == nil {
+		return defaultByteOrder
+	}
+	return i.Endianness
+}
+
+func (i Int256) getByteOrder() binary.ByteOrder {
+	return Uint256(i).getByteOrder()
+}
+
+// Bytes returns the 32 bytes of i, the most significant first.
+func (i Uint256) Bytes() []byte {
+	buf := make([]byte, 32)
+	for k, w := range i.Words {
+		binary.BigEndian.PutUint64(buf[8*(3-k):], w)
+	}
+	return buf
+}
+
+func (i Uint256) BigInt() *big.Int {
+	return new(big.Int).SetBytes(i.Bytes())
+}
+
+func (i Uint256) String() string {
+	return i.DecimalString()
+}
+
+func (i Uint256) DecimalString() string {
+	return i.BigInt().String()
+}
+
+func (i Uint256) HexString() string {
+	return fmt.Sprintf("0x%s", hex.EncodeToString(i.Bytes()))
+}
+
+func (i Uint256) MarshalJSON() (data []byte, err error) {
+	return []byte(`"` + i.String() + `"`), nil
+}
+
+// UnmarshalJSON decodes i from a string holding its decimal value,
+// or its hexadecimal value prefixed by 0x (at most 64 digits).
+func (i *Uint256) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		words, err := parseHexWords256(s[2:])
+		if err != nil {
+			return err
+		}
+		i.Words = words
+		return nil
+	}
+
+	parsed, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("could not parse %q", s)
+	}
+	return i.SetBigInt(parsed)
+}
+
+// parseHexWords256 parses the hexadecimal digits of a 256-bit value.
+func parseHexWords256(s string) (words [4]uint64, err error) {
+	if len(s) == 0 || len(s) > 64 {
+		return words, fmt.Errorf("uint256 expects 1 to 64 characters after 0x, had %v", len(s))
+	}
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return words, err
+	}
+	var buf [32]byte
+	copy(buf[32-len(data):], data)
+	for k := range words {
+		words[k] = binary.BigEndian.Uint64(buf[8*(3-k):])
+	}
+	return words, nil
+}
+
+func (i *Uint256) UnmarshalWithDecoder(dec *Decoder) error {
+	if _, ok := dec.encoding.intCodec(); ok {
+		return fmt.Errorf("%w: Uint256 in %s", ErrUnsupportedType, dec.encoding)
+	}
+	var order binary.ByteOrder
+	if dec != nil && dec.currentFieldOpt != nil {
+		order = dec.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	value, err := dec.ReadUint256(order)
+	if err != nil {
+		return err
+	}
+
+	value.Endianness = i.Endianness
+	*i = value
+	return nil
+}
+
+func (i Uint256) MarshalWithEncoder(enc *Encoder) error {
+	if _, ok := enc.encoding.intCodec(); ok {
+		return fmt.Errorf("%w: Uint256 in %s", ErrUnsupportedType, enc.encoding)
+	}
+	var order binary.ByteOrder
+	if enc != nil && enc.currentFieldOpt != nil {
+		order = enc.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	return enc.WriteUint256(i, order)
+}
+
+// Int256 is a signed 256-bit integer (e.g. a Rust `i256`),
+// in two's complement.
+type Int256 Uint256
+
+func (i Int256) BigInt() *big.Int {
+	if i.Sign() < 0 {
+		value := Uint256(i.Neg()).BigInt()
+		return value.Neg(value)
+	}
+	return Uint256(i).BigInt()
+}
+
+func (i Int256) String() string {
+	return i.DecimalString()
+}
+
+func (i Int256) DecimalString() string {
+	return i.BigInt().String()
+}
+
+// HexString returns the 256 bits of i (in two's complement), in hexadecimal.
+func (i Int256) HexString() string {
+	return Uint256(i).HexString()
+}
+
+func (i Int256) MarshalJSON() (data []byte, err error) {
+	return []byte(`"` + i.String() + `"`), nil
+}
+
+// UnmarshalJSON decodes i from a string holding its decimal value,
+// or its 256 bits (in two's complement) in hexadecimal, prefixed by 0x.
+func (i *Int256) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		words, err := parseHexWords256(s[2:])
+		if err != nil {
+			return err
+		}
+		i.Words = words
+		return nil
+	}
+
+	parsed, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("could not parse %q", s)
+	}
+	return i.SetBigInt(parsed)
+}
+
+func (i *Int256) UnmarshalWithDecoder(dec *Decoder) error {
+	if _, ok := dec.encoding.intCodec(); ok {
+		return fmt.Errorf("%w: Int256 in %s", ErrUnsupportedType, dec.encoding)
+	}
+	var order binary.ByteOrder
+	if dec != nil && dec.currentFieldOpt != nil {
+		order = dec.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	value, err := dec.ReadInt256(order)
+	if err != nil {
+		return err
+	}
+
+	value.Endianness = i.Endianness
+	*i = value
+	return nil
+}
+
+func (i Int256) MarshalWithEncoder(enc *Encoder) error {
+	if _, ok := enc.encoding.intCodec(); ok {
+		return fmt.Errorf("%w: Int256 in %s", ErrUnsupportedType, enc.encoding)
+	}
+	var order binary.ByteOrder
+	if enc != nil && enc.currentFieldOpt != nil {
+		order = enc.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	return enc.WriteInt256(i, order)
+}
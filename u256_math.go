@@ -0,0 +1,468 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// The arithmetic of Uint256 and Int256 follows the one of Uint128 and Int128
+// (see u128_math.go): it doesn't allocate, the results keep the Endianness
+// of the receiver, and the Checked variants return ErrOverflow
+// where the others wrap around.
+
+type words256 = [4]uint64
+
+// NewUint256FromUint64 returns v as a Uint256.
+func NewUint256FromUint64(v uint64) Uint256 {
+	return Uint256{Words: words256{v}}
+}
+
+// NewUint256FromUint128 returns v as a Uint256, with the Endianness of v.
+func NewUint256FromUint128(v Uint128) Uint256 {
+	return Uint256{Words: words256{v.Lo, v.Hi}, Endianness: v.Endianness}
+}
+
+// NewUint256FromBigInt returns b as a Uint256,
+// or ErrOverflow if b is negative or doesn't fit in 256 bits.
+func NewUint256FromBigInt(b *big.Int) (Uint256, error) {
+	var out Uint256
+	err := out.SetBigInt(b)
+	return out, err
+}
+
+// SetUint64 sets i to v, keeping its Endianness, and returns i.
+func (i *Uint256) SetUint64(v uint64) *Uint256 {
+	i.Words = words256{v}
+	return i
+}
+
+// SetBigInt sets i to b, keeping its Endianness; it returns ErrOverflow
+// (leaving i unchanged) if b is negative or doesn't fit in 256 bits.
+func (i *Uint256) SetBigInt(b *big.Int) error {
+	if b.Sign() < 0 || b.BitLen() > 256 {
+		return fmt.Errorf("%w: %s doesn't fit in a uint256", ErrOverflow, b)
+	}
+	bigIntWords(b, i.Words[:])
+	return nil
+}
+
+func (i Uint256) with(words words256) Uint256 {
+	return Uint256{Words: words, Endianness: i.Endianness}
+}
+
+// IsZero reports whether i is zero.
+func (i Uint256) IsZero() bool {
+	return i.Words == words256{}
+}
+
+// IsUint64 reports whether i fits in a uint64.
+func (i Uint256) IsUint64() bool {
+	return i.Words[1]|i.Words[2]|i.Words[3] == 0
+}
+
+// Uint64 returns the low 64 bits of i (i.e. i, if IsUint64).
+func (i Uint256) Uint64() uint64 {
+	return i.Words[0]
+}
+
+// BitLen returns the length of i in bits (0 for 0).
+func (i Uint256) BitLen() int {
+	for k := 3; k >= 0; k-- {
+		if i.Words[k] != 0 {
+			return 64*k + bits.Len64(i.Words[k])
+		}
+	}
+	return 0
+}
+
+// Cmp returns -1 if i < j, 0 if i == j, and +1 if i > j.
+func (i Uint256) Cmp(j Uint256) int {
+	return cmpWords256(i.Words, j.Words)
+}
+
+func cmpWords256(x, y words256) int {
+	for k := 3; k >= 0; k-- {
+		switch {
+		case x[k] < y[k]:
+			return -1
+		case x[k] > y[k]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Add returns i+j, wrapping around on overflow.
+func (i Uint256) Add(j Uint256) Uint256 {
+	out, _ := addWords256(i.Words, j.Words)
+	return i.with(out)
+}
+
+// CheckedAdd returns i+j, or ErrOverflow if it doesn't fit in 256 bits.
+func (i Uint256) CheckedAdd(j Uint256) (Uint256, error) {
+	out, carry := addWords256(i.Words, j.Words)
+	if carry != 0 {
+		return Uint256{}, ErrOverflow
+	}
+	return i.with(out), nil
+}
+
+func addWords256(x, y words256) (out words256, carry uint64) {
+	for k := range out {
+		out[k], carry = bits.Add64(x[k], y[k], carry)
+	}
+	return out, carry
+}
+
+// Sub returns i-j, wrapping around on underflow.
+func (i Uint256) Sub(j Uint256) Uint256 {
+	out, _ := subWords256(i.Words, j.Words)
+	return i.with(out)
+}
+
+// CheckedSub returns i-j, or ErrOverflow if j > i.
+func (i Uint256) CheckedSub(j Uint256) (Uint256, error) {
+	out, borrow := subWords256(i.Words, j.Words)
+	if borrow != 0 {
+		return Uint256{}, ErrOverflow
+	}
+	return i.with(out), nil
+}
+
+func subWords256(x, y words256) (out words256, borrow uint64) {
+	for k := range out {
+		out[k], borrow = bits.Sub64(x[k], y[k], borrow)
+	}
+	return out, borrow
+}
+
+// Mul returns i*j, wrapping around on overflow.
+func (i Uint256) Mul(j Uint256) Uint256 {
+	lo, _ := mulWords256(i.Words, j.Words)
+	return i.with(lo)
+}
+
+// CheckedMul returns i*j, or ErrOverflow if it doesn't fit in 256 bits.
+func (i Uint256) CheckedMul(j Uint256) (Uint256, error) {
+	lo, hi := mulWords256(i.Words, j.Words)
+	if hi != (words256{}) {
+		return Uint256{}, ErrOverflow
+	}
+	return i.with(lo), nil
+}
+
+// mulWords256 returns the 512-bit product of x and y.
+func mulWords256(x, y words256) (lo, hi words256) {
+	var r [8]uint64
+	for a := 0; a < 4; a++ {
+		var carry uint64
+		for b := 0; b < 4; b++ {
+			h, l := bits.Mul64(x[a], y[b])
+			var c uint64
+			l, c = bits.Add64(l, r[a+b], 0)
+			h += c
+			l, c = bits.Add64(l, carry, 0)
+			h += c
+			r[a+b], carry = l, h
+		}
+		r[a+4] = carry
+	}
+	copy(lo[:], r[:4])
+	copy(hi[:], r[4:])
+	return lo, hi
+}
+
+// QuoRem returns the quotient i/j and the remainder i%j;
+// it panics if j is zero, like the integer division.
+func (i Uint256) QuoRem(j Uint256) (q, r Uint256) {
+	q, r = i.with(words256{}), i.with(words256{})
+	if j.IsUint64() {
+		// Word by word, which panics if j is zero.
+		var rem uint64
+		for k := 3; k >= 0; k-- {
+			q.Words[k], rem = bits.Div64(rem, i.Words[k], j.Words[0])
+		}
+		r.Words[0] = rem
+		return q, r
+	}
+	// Long division, one bit at a time (the quotient takes at most 192 bits).
+	n := i.BitLen() - j.BitLen()
+	if n < 0 {
+		return q, i
+	}
+	rem := i.Words
+	d := lshWords256(j.Words, uint(n))
+	for s := n; s >= 0; s-- {
+		if cmpWords256(rem, d) >= 0 {
+			rem, _ = subWords256(rem, d)
+			q.Words[s/64] |= 1 << uint(s%64)
+		}
+		d = rshWords256(d, 1, 0)
+	}
+	r.Words = rem
+	return q, r
+}
+
+// Quo returns i/j; it panics if j is zero.
+func (i Uint256) Quo(j Uint256) Uint256 {
+	q, _ := i.QuoRem(j)
+	return q
+}
+
+// Rem returns i%j; it panics if j is zero.
+func (i Uint256) Rem(j Uint256) Uint256 {
+	_, r := i.QuoRem(j)
+	return r
+}
+
+// Lsh returns i<<n.
+func (i Uint256) Lsh(n uint) Uint256 {
+	return i.with(lshWords256(i.Words, n))
+}
+
+// Rsh returns i>>n.
+func (i Uint256) Rsh(n uint) Uint256 {
+	return i.with(rshWords256(i.Words, n, 0))
+}
+
+// lshWords256 returns x<<n.
+func lshWords256(x words256, n uint) (out words256) {
+	if n >= 256 {
+		return out
+	}
+	shift, rem := int(n/64), n%64
+	for k := 3; k >= shift; k-- {
+		out[k] = x[k-shift] << rem
+		if rem != 0 && k-shift-1 >= 0 {
+			out[k] |= x[k-shift-1] >> (64 - rem)
+		}
+	}
+	return out
+}
+
+// rshWords256 returns x>>n, filling the vacated words with fill
+// (0, or all ones to extend the sign).
+func rshWords256(x words256, n uint, fill uint64) (out words256) {
+	if n >= 256 {
+		return words256{fill, fill, fill, fill}
+	}
+	shift, rem := int(n/64), n%64
+	word := func(k int) uint64 {
+		if k < 4 {
+			return x[k]
+		}
+		return fill
+	}
+	for k := range out {
+		out[k] = word(k + shift)
+		if rem != 0 {
+			out[k] = out[k]>>rem | word(k+shift+1)<<(64-rem)
+		}
+	}
+	return out
+}
+
+// NewInt256FromInt64 returns v as an Int256.
+func NewInt256FromInt64(v int64) Int256 {
+	var out Int256
+	return *out.SetInt64(v)
+}
+
+// NewInt256FromInt128 returns v as an Int256, with the Endianness of v.
+func NewInt256FromInt128(v Int128) Int256 {
+	fill := uint64(int64(v.Hi) >> 63)
+	return Int256{Words: words256{v.Lo, v.Hi, fill, fill}, Endianness: v.Endianness}
+}
+
+// NewInt256FromBigInt returns b as an Int256,
+// or ErrOverflow if b doesn't fit in 256 bits (two's complement).
+func NewInt256FromBigInt(b *big.Int) (Int256, error) {
+	var out Int256
+	err := out.SetBigInt(b)
+	return out, err
+}
+
+// SetInt64 sets i to v, keeping its Endianness, and returns i.
+func (i *Int256) SetInt64(v int64) *Int256 {
+	fill := uint64(v >> 63)
+	i.Words = words256{uint64(v), fill, fill, fill}
+	return i
+}
+
+// SetBigInt sets i to b, keeping its Endianness; it returns ErrOverflow
+// (leaving i unchanged) if b doesn't fit in 256 bits (two's complement).
+func (i *Int256) SetBigInt(b *big.Int) error {
+	neg := b.Sign() < 0
+	// -2**255 is the only negative value whose magnitude takes 256 bits.
+	if b.BitLen() > 256 || (b.BitLen() == 256 && !(neg && b.TrailingZeroBits() == 255)) {
+		return fmt.Errorf("%w: %s doesn't fit in an int256", ErrOverflow, b)
+	}
+	var v Int256
+	bigIntWords(b, v.Words[:])
+	if neg {
+		v = v.Neg()
+	}
+	i.Words = v.Words
+	return nil
+}
+
+func (i Int256) with(words words256) Int256 {
+	return Int256{Words: words, Endianness: i.Endianness}
+}
+
+// fill returns the sign extension word of i.
+func (i Int256) fill() uint64 {
+	return uint64(int64(i.Words[3]) >> 63)
+}
+
+// Sign returns -1 if i < 0, 0 if i == 0, and +1 if i > 0.
+func (i Int256) Sign() int {
+	switch {
+	case int64(i.Words[3]) < 0:
+		return -1
+	case i.Words == words256{}:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// IsInt64 reports whether i fits in an int64.
+func (i Int256) IsInt64() bool {
+	fill := uint64(int64(i.Words[0]) >> 63)
+	return i.Words[1] == fill && i.Words[2] == fill && i.Words[3] == fill
+}
+
+// Int64 returns the low 64 bits of i, as an int64 (i.e. i, if IsInt64).
+func (i Int256) Int64() int64 {
+	return int64(i.Words[0])
+}
+
+// Cmp returns -1 if i < j, 0 if i == j, and +1 if i > j.
+func (i Int256) Cmp(j Int256) int {
+	switch {
+	case int64(i.Words[3]) < int64(j.Words[3]):
+		return -1
+	case int64(i.Words[3]) > int64(j.Words[3]):
+		return 1
+	}
+	return cmpWords256(i.Words, j.Words)
+}
+
+// Neg returns -i (the minimum value is its own negation).
+func (i Int256) Neg() Int256 {
+	out, _ := subWords256(words256{}, i.Words)
+	return i.with(out)
+}
+
+// abs returns |i|, as an unsigned value (which holds |min|).
+func (i Int256) abs() Uint256 {
+	if i.Sign() < 0 {
+		i = i.Neg()
+	}
+	return Uint256(i)
+}
+
+// Add returns i+j, wrapping around on overflow.
+func (i Int256) Add(j Int256) Int256 {
+	return Int256(Uint256(i).Add(Uint256(j)))
+}
+
+// CheckedAdd returns i+j, or ErrOverflow if it doesn't fit in 256 bits.
+func (i Int256) CheckedAdd(j Int256) (Int256, error) {
+	out := i.Add(j)
+	// Overflow iff the operands have the same sign, and the result the other one.
+	if int64((out.Words[3]^i.Words[3])&(out.Words[3]^j.Words[3])) < 0 {
+		return Int256{}, ErrOverflow
+	}
+	return out, nil
+}
+
+// Sub returns i-j, wrapping around on overflow.
+func (i Int256) Sub(j Int256) Int256 {
+	return Int256(Uint256(i).Sub(Uint256(j)))
+}
+
+// CheckedSub returns i-j, or ErrOverflow if it doesn't fit in 256 bits.
+func (i Int256) CheckedSub(j Int256) (Int256, error) {
+	out := i.Sub(j)
+	// Overflow iff the operands have different signs, and the result the sign of j.
+	if int64((i.Words[3]^j.Words[3])&(out.Words[3]^i.Words[3])) < 0 {
+		return Int256{}, ErrOverflow
+	}
+	return out, nil
+}
+
+// Mul returns i*j, wrapping around on overflow.
+func (i Int256) Mul(j Int256) Int256 {
+	return Int256(Uint256(i).Mul(Uint256(j)))
+}
+
+// CheckedMul returns i*j, or ErrOverflow if it doesn't fit in 256 bits.
+func (i Int256) CheckedMul(j Int256) (Int256, error) {
+	abs, err := i.abs().CheckedMul(j.abs())
+	if err != nil {
+		return Int256{}, ErrOverflow
+	}
+	neg := (i.Sign() < 0) != (j.Sign() < 0)
+	if int64(abs.Words[3]) < 0 && !(neg && abs.Words == words256{0, 0, 0, 1 << 63}) {
+		return Int256{}, ErrOverflow
+	}
+	out := i.with(abs.Words)
+	if neg {
+		out = out.Neg()
+	}
+	return out, nil
+}
+
+// QuoRem returns the quotient i/j and the remainder i%j, truncated
+// towards zero like the Go integer division (the minimum value divided
+// by -1 wraps around); it panics if j is zero.
+func (i Int256) QuoRem(j Int256) (q, r Int256) {
+	uq, ur := i.abs().QuoRem(j.abs())
+	q, r = i.with(uq.Words), i.with(ur.Words)
+	if (i.Sign() < 0) != (j.Sign() < 0) {
+		q = q.Neg()
+	}
+	if i.Sign() < 0 {
+		r = r.Neg()
+	}
+	return q, r
+}
+
+// Quo returns i/j, truncated towards zero; it panics if j is zero.
+func (i Int256) Quo(j Int256) Int256 {
+	q, _ := i.QuoRem(j)
+	return q
+}
+
+// Rem returns i%j, with the sign of i; it panics if j is zero.
+func (i Int256) Rem(j Int256) Int256 {
+	_, r := i.QuoRem(j)
+	return r
+}
+
+// Lsh returns i<<n.
+func (i Int256) Lsh(n uint) Int256 {
+	return i.with(lshWords256(i.Words, n))
+}
+
+// Rsh returns i>>n, extending the sign (like the Go signed integers).
+func (i Int256) Rsh(n uint) Int256 {
+	return i.with(rshWords256(i.Words, n, i.fill()))
+}
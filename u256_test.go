@@ -0,0 +1,299 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	two256 = new(big.Int).Lsh(big.NewInt(1), 256)
+	two255 = new(big.Int).Lsh(big.NewInt(1), 255)
+)
+
+// u256Samples returns edge values and random values, of random sizes.
+func u256Samples() []Uint256 {
+	const max = math.MaxUint64
+	samples := []Uint256{
+		{}, {Words: [4]uint64{1}}, {Words: [4]uint64{2}}, {Words: [4]uint64{max}},
+		{Words: [4]uint64{0, 1}}, {Words: [4]uint64{0, 0, 1}}, {Words: [4]uint64{0, 0, 0, 1}},
+		{Words: [4]uint64{max, max, max, max}}, {Words: [4]uint64{0, 0, 0, 1 << 63}},
+		{Words: [4]uint64{max, max, max, math.MaxInt64}}, {Words: [4]uint64{1, 0, 0, 1 << 63}},
+		{Words: [4]uint64{max, max}}, {Words: [4]uint64{3, 0, max}},
+	}
+	rnd := rand.New(rand.NewSource(256))
+	for i := 0; i < 150; i++ {
+		v := Uint256{Words: [4]uint64{rnd.Uint64(), rnd.Uint64(), rnd.Uint64(), rnd.Uint64()}}
+		samples = append(samples, v.Rsh(uint(rnd.Intn(256))))
+	}
+	return samples
+}
+
+// wrap256 returns b modulo 2**256, as a Uint256.
+func wrap256(b *big.Int) Uint256 {
+	out, err := NewUint256FromBigInt(new(big.Int).Mod(b, two256))
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// wrapInt256 returns b modulo 2**256, as an Int256.
+func wrapInt256(b *big.Int) Int256 {
+	b = new(big.Int).Mod(b, two256)
+	if b.Cmp(two255) >= 0 {
+		b.Sub(b, two256)
+	}
+	out, err := NewInt256FromBigInt(b)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func TestUint256_arithmetic(t *testing.T) {
+	samples := u256Samples()
+	for _, x := range samples {
+		bx := x.BigInt()
+		require.Equal(t, bx.BitLen(), x.BitLen())
+		for _, n := range []uint{0, 1, 63, 64, 65, 127, 128, 191, 255, 256, 300} {
+			require.Equal(t, wrap256(new(big.Int).Lsh(bx, n)), x.Lsh(n), "%s << %d", x, n)
+			require.Equal(t, wrap256(new(big.Int).Rsh(bx, n)), x.Rsh(n), "%s >> %d", x, n)
+		}
+		for _, y := range samples {
+			by := y.BigInt()
+			require.Equal(t, bx.Cmp(by), x.Cmp(y), "%s cmp %s", x, y)
+
+			for _, op := range []struct {
+				name    string
+				want    *big.Int
+				got     Uint256
+				checked func(Uint256) (Uint256, error)
+			}{
+				{"+", new(big.Int).Add(bx, by), x.Add(y), x.CheckedAdd},
+				{"-", new(big.Int).Sub(bx, by), x.Sub(y), x.CheckedSub},
+				{"*", new(big.Int).Mul(bx, by), x.Mul(y), x.CheckedMul},
+			} {
+				require.Equal(t, wrap256(op.want), op.got, "%s %s %s", x, op.name, y)
+				checked, err := op.checked(y)
+				if op.want.Sign() >= 0 && op.want.Cmp(two256) < 0 {
+					require.NoError(t, err, "%s %s %s", x, op.name, y)
+					require.Equal(t, op.got, checked)
+				} else {
+					require.Equal(t, ErrOverflow, err, "%s %s %s", x, op.name, y)
+				}
+			}
+
+			if !y.IsZero() {
+				q, r := x.QuoRem(y)
+				bq, br := new(big.Int).QuoRem(bx, by, new(big.Int))
+				require.Equal(t, wrap256(bq), q, "%s / %s", x, y)
+				require.Equal(t, wrap256(br), r, "%s %% %s", x, y)
+				require.Equal(t, q, x.Quo(y))
+				require.Equal(t, r, x.Rem(y))
+			}
+		}
+	}
+	require.Panics(t, func() { NewUint256FromUint64(1).QuoRem(Uint256{}) })
+}
+
+func TestInt256_arithmetic(t *testing.T) {
+	var samples []Int256
+	for _, v := range u256Samples() {
+		samples = append(samples, Int256(v))
+	}
+	fits := func(b *big.Int) bool {
+		return b.Cmp(two255) < 0 && b.Cmp(new(big.Int).Neg(two255)) >= 0
+	}
+	for _, x := range samples {
+		bx := x.BigInt()
+		require.Equal(t, bx.Sign(), x.Sign())
+		require.Equal(t, wrapInt256(new(big.Int).Neg(bx)), x.Neg())
+		require.Equal(t, bx.IsInt64(), x.IsInt64(), "%s", bx)
+		if x.IsInt64() {
+			require.Equal(t, bx.Int64(), x.Int64())
+		}
+		for _, n := range []uint{0, 1, 63, 64, 65, 127, 128, 191, 255, 256, 300} {
+			require.Equal(t, wrapInt256(new(big.Int).Lsh(bx, n)), x.Lsh(n), "%s << %d", bx, n)
+			require.Equal(t, wrapInt256(new(big.Int).Rsh(bx, n)), x.Rsh(n), "%s >> %d", bx, n)
+		}
+		for _, y := range samples {
+			by := y.BigInt()
+			require.Equal(t, bx.Cmp(by), x.Cmp(y), "%s cmp %s", bx, by)
+
+			for _, op := range []struct {
+				name    string
+				want    *big.Int
+				got     Int256
+				checked func(Int256) (Int256, error)
+			}{
+				{"+", new(big.Int).Add(bx, by), x.Add(y), x.CheckedAdd},
+				{"-", new(big.Int).Sub(bx, by), x.Sub(y), x.CheckedSub},
+				{"*", new(big.Int).Mul(bx, by), x.Mul(y), x.CheckedMul},
+			} {
+				require.Equal(t, wrapInt256(op.want), op.got, "%s %s %s", bx, op.name, by)
+				checked, err := op.checked(y)
+				if fits(op.want) {
+					require.NoError(t, err, "%s %s %s", bx, op.name, by)
+					require.Equal(t, op.got, checked)
+				} else {
+					require.Equal(t, ErrOverflow, err, "%s %s %s", bx, op.name, by)
+				}
+			}
+
+			if y.Sign() != 0 {
+				q, r := x.QuoRem(y)
+				bq, br := new(big.Int).QuoRem(bx, by, new(big.Int))
+				require.Equal(t, wrapInt256(bq), q, "%s / %s", bx, by)
+				require.Equal(t, wrapInt256(br), r, "%s %% %s", bx, by)
+			}
+		}
+	}
+}
+
+func TestUint256_constructors(t *testing.T) {
+	require.Equal(t, Uint256{Words: [4]uint64{7}}, NewUint256FromUint64(7))
+	require.Equal(t,
+		Uint256{Words: [4]uint64{1, 2}, Endianness: binary.BigEndian},
+		NewUint256FromUint128(Uint128{Lo: 1, Hi: 2, Endianness: binary.BigEndian}),
+	)
+	require.Equal(t, NewInt256FromInt64(-5), NewInt256FromInt128(NewInt128FromInt64(-5)))
+
+	u := NewUint256BigEndian().SetUint64(3)
+	require.Equal(t, binary.BigEndian, u.Add(NewUint256FromUint64(1)).Endianness)
+
+	max := new(big.Int).Sub(two256, big.NewInt(1))
+	require.NoError(t, u.SetBigInt(max))
+	require.Equal(t, 0, max.Cmp(u.BigInt()))
+	require.Equal(t, binary.BigEndian, u.Endianness)
+	for _, b := range []*big.Int{two256, big.NewInt(-1)} {
+		_, err := NewUint256FromBigInt(b)
+		require.True(t, errors.Is(err, ErrOverflow), b)
+	}
+
+	for _, b := range []*big.Int{new(big.Int).Neg(two255), new(big.Int).Sub(two255, big.NewInt(1)), big.NewInt(-1), big.NewInt(0)} {
+		v, err := NewInt256FromBigInt(b)
+		require.NoError(t, err, b)
+		require.Equal(t, 0, b.Cmp(v.BigInt()), b)
+	}
+	for _, b := range []*big.Int{two255, new(big.Int).Sub(new(big.Int).Neg(two255), big.NewInt(1))} {
+		_, err := NewInt256FromBigInt(b)
+		require.True(t, errors.Is(err, ErrOverflow), b)
+	}
+}
+
+func TestUint256_encoding(t *testing.T) {
+	type S struct {
+		A Uint256
+		B Uint256 `bin:"big"`
+		C Int256
+		D Int256 `bin:"big"`
+	}
+	x := S{
+		A: Uint256{Words: [4]uint64{1, 2, 3, 4}},
+		B: Uint256{Words: [4]uint64{1, 2, 3, 4}},
+		C: NewInt256FromInt64(-2),
+		D: NewInt256FromInt64(-2),
+	}
+	le := []byte{
+		1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0,
+		3, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0,
+	}
+	be := make([]byte, 32)
+	copy(be, le)
+	ReverseBytes(be)
+	minusTwoLE := append([]byte{0xfe}, bytesOf(0xff, 31)...)
+	minusTwoBE := append(bytesOf(0xff, 31), 0xfe)
+	want := concatByteSlices(le, be, minusTwoLE, minusTwoBE)
+
+	for _, encoding := range []Encoding{EncodingBorsh, EncodingBin, EncodingCompactU16} {
+		data, err := marshalWithEncoding(&x, encoding)
+		require.NoError(t, err)
+		require.Equal(t, want, data, encoding)
+
+		var y S
+		require.NoError(t, NewDecoderWithEncoding(data, encoding).Decode(&y), encoding)
+		require.Equal(t, x, y, encoding)
+
+		_, err = NewDecoderWithEncoding(data[:31], encoding).ReadUint256(LE)
+		require.True(t, errors.Is(err, ErrShortBuffer), err)
+	}
+
+}
+
+func bytesOf(b byte, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = b
+	}
+	return out
+}
+
+func TestUint256_JSON(t *testing.T) {
+	type S struct {
+		U Uint256 `json:"u"`
+		I Int256  `json:"i"`
+	}
+	maxU, err := NewUint256FromBigInt(new(big.Int).Sub(two256, big.NewInt(1)))
+	require.NoError(t, err)
+	x := S{U: maxU, I: NewInt256FromInt64(-42)}
+	data, err := json.Marshal(x)
+	require.NoError(t, err)
+	require.Equal(t, `{"u":"115792089237316195423570985008687907853269984665640564039457584007913129639935","i":"-42"}`, string(data))
+
+	var y S
+	require.NoError(t, json.Unmarshal(data, &y))
+	require.Equal(t, x, y)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"u":"0x0102","i":"0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffd6"}`), &y))
+	require.Equal(t, NewUint256FromUint64(0x0102), y.U)
+	require.Equal(t, NewInt256FromInt64(-42), y.I)
+	require.Equal(t, "0x0000000000000000000000000000000000000000000000000000000000000102", y.U.HexString())
+	require.Equal(t, "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffd6", y.I.HexString())
+
+	for _, bad := range []string{
+		`{"u":"-1"}`,
+		`{"u":"115792089237316195423570985008687907853269984665640564039457584007913129639936"}`,
+		`{"u":"0x"}`,
+		`{"u":"0x` + string(bytesOf('f', 65)) + `"}`,
+		`{"u":"0xzz"}`,
+		`{"u":"abc"}`,
+		`{"u":1}`,
+		`{"i":"57896044618658097711785492504343953926634992332820282019728792003956564819968"}`,
+	} {
+		require.Error(t, json.Unmarshal([]byte(bad), &y), bad)
+	}
+}
+
+func TestUint256_arithmeticAllocs(t *testing.T) {
+	x := Uint256{Words: [4]uint64{1, 2, 3, 4}, Endianness: binary.BigEndian}
+	y := Uint256{Words: [4]uint64{5, 6}}
+	allocs := testing.AllocsPerRun(100, func() {
+		z := x.Add(y).Sub(y).Mul(y).Lsh(3).Rsh(2)
+		q, r := z.QuoRem(y)
+		_, _ = q.CheckedAdd(r)
+		_, _ = Int256(q).CheckedMul(Int256(r))
+		_, _ = Int256(z).QuoRem(Int256(y).Neg())
+	})
+	require.Equal(t, float64(0), allocs)
+}
@@ -41,8 +41,10 @@ type VariantType struct {
 
 type VariantDefinition struct {
 	typeIDToType   map[TypeID]reflect.Type
+	typeIDToElem   map[TypeID]reflect.Type
 	typeIDToName   map[TypeID]string
 	typeNameToID   map[string]TypeID
+	typeGoToID     map[reflect.Type]TypeID
 	typeIDEncoding TypeIDEncoding
 }
 
@@ -160,62 +162,32 @@ func NewVariantDefinition(typeIDEncoding TypeIDEncoding, types []VariantType) (o
 	out = &VariantDefinition{
 		typeIDEncoding: typeIDEncoding,
 		typeIDToType:   make(map[TypeID]reflect.Type, typeCount),
+		typeIDToElem:   make(map[TypeID]reflect.Type, typeCount),
 		typeIDToName:   make(map[TypeID]string, typeCount),
 		typeNameToID:   make(map[string]TypeID, typeCount),
+		typeGoToID:     make(map[reflect.Type]TypeID, typeCount),
 	}
 
 	switch typeIDEncoding {
 	case Uvarint32TypeIDEncoding:
 		for i, typeDef := range types {
 			typeID := TypeIDFromUvarint32(uint32(i))
-
-			// FIXME: Check how the reflect.Type is used and cache all its usage in the definition.
-			//        Right now, on each Unmarshal, we re-compute some expensive stuff that can be
-			//        re-used like the `typeGo.Elem()` which is always the same. It would be preferable
-			//        to have those already pre-defined here so we can actually speed up the
-			//        Unmarshal code.
-			out.typeIDToType[typeID] = reflect.TypeOf(typeDef.Type)
-			out.typeIDToName[typeID] = typeDef.Name
-			out.typeNameToID[typeDef.Name] = typeID
+			out.register(typeID, typeDef)
 		}
 	case Uint32TypeIDEncoding:
 		for i, typeDef := range types {
 			typeID := TypeIDFromUint32(uint32(i), binary.LittleEndian)
-
-			// FIXME: Check how the reflect.Type is used and cache all its usage in the definition.
-			//        Right now, on each Unmarshal, we re-compute some expensive stuff that can be
-			//        re-used like the `typeGo.Elem()` which is always the same. It would be preferable
-			//        to have those already pre-defined here so we can actually speed up the
-			//        Unmarshal code.
-			out.typeIDToType[typeID] = reflect.TypeOf(typeDef.Type)
-			out.typeIDToName[typeID] = typeDef.Name
-			out.typeNameToID[typeDef.Name] = typeID
+			out.register(typeID, typeDef)
 		}
 	case Uint8TypeIDEncoding:
 		for i, typeDef := range types {
 			typeID := TypeIDFromUint8(uint8(i))
-
-			// FIXME: Check how the reflect.Type is used and cache all its usage in the definition.
-			//        Right now, on each Unmarshal, we re-compute some expensive stuff that can be
-			//        re-used like the `typeGo.Elem()` which is always the same. It would be preferable
-			//        to have those already pre-defined here so we can actually speed up the
-			//        Unmarshal code.
-			out.typeIDToType[typeID] = reflect.TypeOf(typeDef.Type)
-			out.typeIDToName[typeID] = typeDef.Name
-			out.typeNameToID[typeDef.Name] = typeID
+			out.register(typeID, typeDef)
 		}
 	case AnchorTypeIDEncoding:
 		for _, typeDef := range types {
 			typeID := TypeIDFromSighash(Sighash(SIGHASH_GLOBAL_NAMESPACE, typeDef.Name))
-
-			// FIXME: Check how the reflect.Type is used and cache all its usage in the definition.
-			//        Right now, on each Unmarshal, we re-compute some expensive stuff that can be
-			//        re-used like the `typeGo.Elem()` which is always the same. It would be preferable
-			//        to have those already pre-defined here so we can actually speed up the
-			//        Unmarshal code.
-			out.typeIDToType[typeID] = reflect.TypeOf(typeDef.Type)
-			out.typeIDToName[typeID] = typeDef.Name
-			out.typeNameToID[typeDef.Name] = typeID
+			out.register(typeID, typeDef)
 		}
 	case NoTypeIDEncoding:
 		if len(types) != 1 {
@@ -224,15 +196,7 @@ func NewVariantDefinition(typeIDEncoding TypeIDEncoding, types []VariantType) (o
 		typeDef := types[0]
 
 		typeID := NoTypeIDDefaultID
-
-		// FIXME: Check how the reflect.Type is used and cache all its usage in the definition.
-		//        Right now, on each Unmarshal, we re-compute some expensive stuff that can be
-		//        re-used like the `typeGo.Elem()` which is always the same. It would be preferable
-		//        to have those already pre-defined here so we can actually speed up the
-		//        Unmarshal code.
-		out.typeIDToType[typeID] = reflect.TypeOf(typeDef.Type)
-		out.typeIDToName[typeID] = typeDef.Name
-		out.typeNameToID[typeDef.Name] = typeID
+		out.register(typeID, typeDef)
 
 	default:
 		panic(fmt.Errorf("unsupported TypeIDEncoding: %v", typeIDEncoding))
@@ -241,6 +205,49 @@ func NewVariantDefinition(typeIDEncoding TypeIDEncoding, types []VariantType) (o
 	return out
 }
 
+// register adds the provided variant type to the definition.
+// The reflect information that is needed on each unmarshal (like the
+// element type of pointer types) is computed here once.
+// If the same Go type is used by more than one variant, the first one
+// is the one used for type ID lookups by Go type.
+func (d *VariantDefinition) register(typeID TypeID, typeDef VariantType) {
+	typeGo := reflect.TypeOf(typeDef.Type)
+	d.typeIDToType[typeID] = typeGo
+	if typeGo != nil && typeGo.Kind() == reflect.Ptr {
+		d.typeIDToElem[typeID] = typeGo.Elem()
+	}
+	d.typeIDToName[typeID] = typeDef.Name
+	d.typeNameToID[typeDef.Name] = typeID
+	if _, found := d.typeGoToID[typeGo]; !found {
+		d.typeGoToID[typeGo] = typeID
+	}
+}
+
+// typeIDOf returns the TypeID of the provided variant implementation.
+// If `current` is a known TypeID for the type of `impl`, it is preferred;
+// this disambiguates variants that share the same Go type.
+// A T implementation matches a variant registered as *T, and vice versa.
+func (d *VariantDefinition) typeIDOf(current TypeID, impl interface{}) (TypeID, bool) {
+	typeGo := reflect.TypeOf(impl)
+	candidates := []reflect.Type{typeGo}
+	if typeGo.Kind() == reflect.Ptr {
+		candidates = append(candidates, typeGo.Elem())
+	} else {
+		candidates = append(candidates, reflect.PtrTo(typeGo))
+	}
+	for _, typ := range candidates {
+		if known, found := d.typeIDToType[current]; found && known == typ {
+			return current, true
+		}
+	}
+	for _, typ := range candidates {
+		if id, found := d.typeGoToID[typ]; found {
+			return id, true
+		}
+	}
+	return TypeID{}, false
+}
+
 func (d *VariantDefinition) TypeID(name string) TypeID {
 	id, found := d.typeNameToID[name]
 	if !found {
@@ -284,25 +291,25 @@ func (a *BaseVariant) UnmarshalBinaryVariant(decoder *Decoder, def *VariantDefin
 	case Uvarint32TypeIDEncoding:
 		val, err := decoder.ReadUvarint32()
 		if err != nil {
-			return fmt.Errorf("uvarint32: unable to read variant type id: %s", err)
+			return fmt.Errorf("uvarint32: unable to read variant type id: %w", err)
 		}
 		typeID = TypeIDFromUvarint32(val)
 	case Uint32TypeIDEncoding:
 		val, err := decoder.ReadUint32(binary.LittleEndian)
 		if err != nil {
-			return fmt.Errorf("uint32: unable to read variant type id: %s", err)
+			return fmt.Errorf("uint32: unable to read variant type id: %w", err)
 		}
 		typeID = TypeIDFromUint32(val, binary.LittleEndian)
 	case Uint8TypeIDEncoding:
 		id, err := decoder.ReadUint8()
 		if err != nil {
-			return fmt.Errorf("uint8: unable to read variant type id: %s", err)
+			return fmt.Errorf("uint8: unable to read variant type id: %w", err)
 		}
 		typeID = TypeIDFromBytes([]byte{id})
 	case AnchorTypeIDEncoding:
 		typeID, err = decoder.ReadTypeID()
 		if err != nil {
-			return fmt.Errorf("anchor: unable to read variant type id: %s", err)
+			return fmt.Errorf("anchor: unable to read variant type id: %w", err)
 		}
 	case NoTypeIDEncoding:
 		typeID = NoTypeIDDefaultID
@@ -312,13 +319,13 @@ func (a *BaseVariant) UnmarshalBinaryVariant(decoder *Decoder, def *VariantDefin
 
 	typeGo := def.typeIDToType[typeID]
 	if typeGo == nil {
-		return fmt.Errorf("no known type for type %d", typeID)
+		return fmt.Errorf("%w: no known type for type %d", ErrInvalidEnumVariant, typeID)
 	}
 
-	if typeGo.Kind() == reflect.Ptr {
-		a.Impl = reflect.New(typeGo.Elem()).Interface()
+	if typeElem, isPtr := def.typeIDToElem[typeID]; isPtr {
+		a.Impl = reflect.New(typeElem).Interface()
 		if err = decoder.Decode(a.Impl); err != nil {
-			return fmt.Errorf("unable to decode variant type %d: %s", typeID, err)
+			return variantDecodeError(typeID, err)
 		}
 	} else {
 		// This is not the most optimal way of doing things for "value"
@@ -344,10 +351,61 @@ func (a *BaseVariant) UnmarshalBinaryVariant(decoder *Decoder, def *VariantDefin
 		// an unsafe pointer and play with it.
 		value := reflect.New(typeGo)
 		if err = decoder.Decode(value.Interface()); err != nil {
-			return fmt.Errorf("unable to decode variant type %d: %s", typeID, err)
+			return variantDecodeError(typeID, err)
 		}
 
 		a.Impl = value.Elem().Interface()
 	}
 	return nil
 }
+
+// variantDecodeError returns the error that occurred while decoding
+// the implementation of a variant; decoding errors that carry
+// their location are returned as is.
+func variantDecodeError(typeID TypeID, err error) error {
+	if _, ok := err.(*DecodeError); ok {
+		return err
+	}
+	return fmt.Errorf("unable to decode variant type %d: %w", typeID, err)
+}
+
+// MarshalBinaryVariant writes the type ID of the variant implementation
+// (as defined by the provided definition's TypeIDEncoding) followed
+// by the encoded implementation.
+func (a *BaseVariant) MarshalBinaryVariant(encoder *Encoder, def *VariantDefinition) (err error) {
+	if a.Impl == nil {
+		return fmt.Errorf("unable to encode variant: no implementation set")
+	}
+	typeID, found := def.typeIDOf(a.TypeID, a.Impl)
+	if !found {
+		return fmt.Errorf("no known type id for type %T", a.Impl)
+	}
+
+	switch def.typeIDEncoding {
+	case Uvarint32TypeIDEncoding:
+		if err := encoder.WriteUVarInt(int(typeID.Uvarint32())); err != nil {
+			return fmt.Errorf("uvarint32: unable to write variant type id: %w", err)
+		}
+	case Uint32TypeIDEncoding:
+		if err := encoder.WriteUint32(typeID.Uint32(), binary.LittleEndian); err != nil {
+			return fmt.Errorf("uint32: unable to write variant type id: %w", err)
+		}
+	case Uint8TypeIDEncoding:
+		if err := encoder.WriteUint8(typeID.Uint8()); err != nil {
+			return fmt.Errorf("uint8: unable to write variant type id: %w", err)
+		}
+	case AnchorTypeIDEncoding:
+		if err := encoder.WriteBytes(typeID.Bytes(), false); err != nil {
+			return fmt.Errorf("anchor: unable to write variant type id: %w", err)
+		}
+	case NoTypeIDEncoding:
+		// Nothing to write.
+	default:
+		return fmt.Errorf("unsupported TypeIDEncoding: %v", def.typeIDEncoding)
+	}
+
+	if err = encoder.Encode(a.Impl); err != nil {
+		return fmt.Errorf("unable to encode variant type %d: %w", typeID, err)
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ package bin
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -313,3 +314,160 @@ func TestEncode_UnexporterStruct(t *testing.T) {
 	enc.Encode(&unexportesStruct{value: 5})
 	assert.Equal(t, expectData, buf.Bytes())
 }
+
+type roundTripVariantA struct {
+	Key         uint32
+	Description string
+}
+
+type roundTripVariantB struct {
+	Owner    uint64
+	Quantity Uint64
+}
+
+func TestBaseVariant_MarshalBinaryVariant(t *testing.T) {
+	for _, enc := range []TypeIDEncoding{
+		Uvarint32TypeIDEncoding,
+		Uint32TypeIDEncoding,
+		Uint8TypeIDEncoding,
+		AnchorTypeIDEncoding,
+	} {
+		def := NewVariantDefinition(
+			enc,
+			[]VariantType{
+				{"variant_a", (*roundTripVariantA)(nil)},
+				{"variant_b", (*roundTripVariantB)(nil)},
+			})
+
+		for _, impl := range []interface{}{
+			&roundTripVariantA{Key: 3, Description: "abc"},
+			&roundTripVariantB{Owner: 82, Quantity: 923},
+		} {
+			// The TypeID is left empty: it is inferred from the type of the impl.
+			variant := BaseVariant{Impl: impl}
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, variant.MarshalBinaryVariant(NewBorshEncoder(buf), def))
+
+			var got BaseVariant
+			decoder := NewBorshDecoder(buf.Bytes())
+			require.NoError(t, got.UnmarshalBinaryVariant(decoder, def))
+			require.Equal(t, 0, decoder.Remaining())
+			require.Equal(t, impl, got.Impl)
+
+			name := "variant_a"
+			if _, ok := impl.(*roundTripVariantB); ok {
+				name = "variant_b"
+			}
+			require.Equal(t, def.TypeID(name), got.TypeID)
+		}
+	}
+	{
+		def := NewVariantDefinition(
+			Uint32TypeIDEncoding,
+			[]VariantType{
+				{"variant_a", (*roundTripVariantA)(nil)},
+				{"variant_b", (*roundTripVariantB)(nil)},
+			})
+		variant := BaseVariant{Impl: &roundTripVariantB{Owner: 1, Quantity: 2}}
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, variant.MarshalBinaryVariant(NewBinEncoder(buf), def))
+		require.Equal(t,
+			concatByteSlices(
+				[]byte{1, 0, 0, 0},
+				uint64ToBytes(1, LE),
+				uint64ToBytes(2, LE),
+			),
+			buf.Bytes(),
+		)
+	}
+	{
+		def := NewVariantDefinition(
+			AnchorTypeIDEncoding,
+			[]VariantType{
+				{"variant_a", (*roundTripVariantA)(nil)},
+			})
+		variant := BaseVariant{Impl: &roundTripVariantA{Key: 1}}
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, variant.MarshalBinaryVariant(NewBorshEncoder(buf), def))
+		require.Equal(t, Sighash(SIGHASH_GLOBAL_NAMESPACE, "variant_a"), buf.Bytes()[:8])
+	}
+	{
+		def := NewVariantDefinition(
+			NoTypeIDEncoding,
+			[]VariantType{
+				{"variant_a", (*roundTripVariantA)(nil)},
+			})
+		impl := &roundTripVariantA{Key: 7, Description: "hello"}
+		variant := BaseVariant{Impl: impl}
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, variant.MarshalBinaryVariant(NewBorshEncoder(buf), def))
+
+		expected, err := MarshalBorsh(impl)
+		require.NoError(t, err)
+		require.Equal(t, expected, buf.Bytes())
+
+		var got BaseVariant
+		require.NoError(t, got.UnmarshalBinaryVariant(NewBorshDecoder(buf.Bytes()), def))
+		require.Equal(t, impl, got.Impl)
+	}
+	{
+		// Variants sharing the same Go type: the provided TypeID wins.
+		def := NewVariantDefinition(
+			Uint8TypeIDEncoding,
+			[]VariantType{
+				{"first", (*roundTripVariantA)(nil)},
+				{"second", (*roundTripVariantA)(nil)},
+			})
+		variant := BaseVariant{TypeID: def.TypeID("second"), Impl: &roundTripVariantA{}}
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, variant.MarshalBinaryVariant(NewBorshEncoder(buf), def))
+		require.Equal(t, byte(1), buf.Bytes()[0])
+	}
+	{
+		def := NewVariantDefinition(
+			Uint8TypeIDEncoding,
+			[]VariantType{
+				{"variant_a", (*roundTripVariantA)(nil)},
+			})
+		variant := BaseVariant{Impl: &roundTripVariantB{}}
+		require.Error(t, variant.MarshalBinaryVariant(NewBorshEncoder(new(bytes.Buffer)), def))
+
+		// A T implementation of a variant registered as *T:
+		variant = BaseVariant{Impl: roundTripVariantA{Key: 7}}
+		buf := new(bytes.Buffer)
+		require.NoError(t, variant.MarshalBinaryVariant(NewBorshEncoder(buf), def))
+		require.Equal(t, concatByteSlices([]byte{0}, uint32ToBytes(7, LE), []byte{0, 0, 0, 0}), buf.Bytes())
+
+		// The errors of the encoder are wrapped:
+		writeErr := errors.New("boom")
+		err := variant.MarshalBinaryVariant(NewBorshEncoder(failingWriter{writeErr}), def)
+		require.True(t, errors.Is(err, writeErr), err)
+	}
+	{
+		// A *T implementation of a variant registered as T:
+		def := NewVariantDefinition(
+			Uint8TypeIDEncoding,
+			[]VariantType{
+				{"variant_a", roundTripVariantA{}},
+				{"variant_b", roundTripVariantB{}},
+			})
+		variant := BaseVariant{Impl: &roundTripVariantB{Owner: 1}}
+		buf := new(bytes.Buffer)
+		require.NoError(t, variant.MarshalBinaryVariant(NewBorshEncoder(buf), def))
+		require.Equal(t, byte(1), buf.Bytes()[0])
+	}
+}
+
+// failingWriter is an io.Writer whose writes fail with err.
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}